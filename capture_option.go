@@ -0,0 +1,47 @@
+package sentry
+
+// A CaptureOption changes the temporary scope of a single capture, see
+// Hub.CaptureExceptionWith.
+type CaptureOption func(scope *Scope)
+
+// WithTag sets a tag on the captured event.
+func WithTag(key, value string) CaptureOption {
+	return func(scope *Scope) {
+		scope.SetTag(key, value)
+	}
+}
+
+// WithExtra sets extra data on the captured event.
+func WithExtra(key string, value interface{}) CaptureOption {
+	return func(scope *Scope) {
+		scope.SetExtra(key, value)
+	}
+}
+
+// WithLevel sets the level of the captured event.
+func WithLevel(level Level) CaptureOption {
+	return func(scope *Scope) {
+		scope.SetLevel(level)
+	}
+}
+
+// WithFingerprint sets the fingerprint of the captured event, which groups
+// events with the same fingerprint into an issue.
+func WithFingerprint(fingerprint ...string) CaptureOption {
+	return func(scope *Scope) {
+		scope.SetFingerprint(fingerprint)
+	}
+}
+
+// WithSpan links the captured event to the trace of span, setting its trace
+// context, such that the issue shows the trace it happened in. A nil span
+// leaves the trace context of the scope, if any, as is.
+func WithSpan(span *Span) CaptureOption {
+	return func(scope *Scope) {
+		if span == nil {
+			return
+		}
+		scope.SetContext("trace", span.traceContext().Map())
+		scope.setSpan(span)
+	}
+}
@@ -0,0 +1,170 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// checkInType is the type of a check-in envelope item.
+const checkInType = "check_in"
+
+// CheckInStatus is the status of a CheckIn.
+type CheckInStatus string
+
+// Statuses of a CheckIn. A job reports CheckInStatusInProgress when it starts,
+// then CheckInStatusOK or CheckInStatusError with the same check-in ID when it
+// completes.
+const (
+	CheckInStatusInProgress CheckInStatus = "in_progress"
+	CheckInStatusOK         CheckInStatus = "ok"
+	CheckInStatusError      CheckInStatus = "error"
+)
+
+// CheckIn reports the status of a run of a scheduled job to Sentry Crons.
+type CheckIn struct {
+	// ID identifies the run of the job. It is generated when left empty,
+	// and must be reused when reporting the completion of a run previously
+	// reported as in progress.
+	ID EventID
+	// MonitorSlug is the slug of the monitor of the job in Sentry.
+	MonitorSlug string
+	// Status is the status of the run.
+	Status CheckInStatus
+	// Duration is the duration of the run, if it is completed. Zero means
+	// unknown.
+	Duration time.Duration
+}
+
+// MonitorScheduleUnit is the unit of an IntervalSchedule.
+type MonitorScheduleUnit string
+
+// Units of an IntervalSchedule.
+const (
+	MonitorScheduleUnitMinute MonitorScheduleUnit = "minute"
+	MonitorScheduleUnitHour   MonitorScheduleUnit = "hour"
+	MonitorScheduleUnitDay    MonitorScheduleUnit = "day"
+	MonitorScheduleUnitWeek   MonitorScheduleUnit = "week"
+	MonitorScheduleUnitMonth  MonitorScheduleUnit = "month"
+	MonitorScheduleUnitYear   MonitorScheduleUnit = "year"
+)
+
+// MonitorSchedule is the schedule of a monitor, created with CrontabSchedule
+// or IntervalSchedule.
+type MonitorSchedule struct {
+	Type  string              `json:"type"`
+	Value interface{}         `json:"value"`
+	Unit  MonitorScheduleUnit `json:"unit,omitempty"`
+}
+
+// CrontabSchedule returns the schedule of a job run according to the given
+// crontab expression, for example "*/5 * * * *".
+func CrontabSchedule(expression string) *MonitorSchedule {
+	return &MonitorSchedule{Type: "crontab", Value: expression}
+}
+
+// IntervalSchedule returns the schedule of a job run every value units of
+// time.
+func IntervalSchedule(value int64, unit MonitorScheduleUnit) *MonitorSchedule {
+	return &MonitorSchedule{Type: "interval", Value: value, Unit: unit}
+}
+
+// MonitorConfig configures the monitor of a CheckIn. When it is sent along
+// with a check-in, Sentry creates or updates the monitor accordingly, such
+// that monitors need not be set up in the Sentry UI.
+type MonitorConfig struct {
+	Schedule *MonitorSchedule `json:"schedule,omitempty"`
+	// CheckInMargin is the number of minutes after the expected start of a
+	// run before it is considered missed.
+	CheckInMargin int64 `json:"checkin_margin,omitempty"`
+	// MaxRuntime is the number of minutes a run may last before it is
+	// considered failed.
+	MaxRuntime int64 `json:"max_runtime,omitempty"`
+	// Timezone is the tz database name of the time zone of the schedule, for
+	// example "Europe/Vienna". The default is UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// checkInPayload is the payload of a check-in envelope item.
+type checkInPayload struct {
+	CheckInID     EventID        `json:"check_in_id"`
+	MonitorSlug   string         `json:"monitor_slug"`
+	Status        CheckInStatus  `json:"status"`
+	Duration      float64        `json:"duration,omitempty"`
+	Release       string         `json:"release,omitempty"`
+	Environment   string         `json:"environment,omitempty"`
+	MonitorConfig *MonitorConfig `json:"monitor_config,omitempty"`
+}
+
+// newCheckInItem returns the envelope item reporting checkIn, along with the
+// configuration of its monitor unless monitorConfig is nil.
+func newCheckInItem(checkIn *CheckIn, monitorConfig *MonitorConfig, release, environment string) *envelopeItem {
+	var duration float64
+	if checkIn.Duration > 0 {
+		duration = checkIn.Duration.Seconds()
+	}
+	return &envelopeItem{
+		eventID:  checkIn.ID,
+		itemType: checkInType,
+		payload: checkInPayload{
+			CheckInID:     checkIn.ID,
+			MonitorSlug:   checkIn.MonitorSlug,
+			Status:        checkIn.Status,
+			Duration:      duration,
+			Release:       release,
+			Environment:   environment,
+			MonitorConfig: monitorConfig,
+		},
+		description: "check-in",
+	}
+}
+
+// WithCheckIn runs f, reporting a CheckIn for the monitor slug to the hub
+// stored in ctx, or to the current hub: in progress before f runs, then ok or
+// error, with the duration of f, when it returns. It returns the error
+// returned by f.
+//
+// If f panics, the check-in is reported with status error and the panic goes
+// on.
+//
+//	err := sentry.WithCheckIn(ctx, "nightly-report", func() error {
+//		return generateReport(ctx)
+//	})
+func WithCheckIn(ctx context.Context, slug string, f func() error) (err error) {
+	hub := hubFromContext(ctx)
+	checkIn := &CheckIn{MonitorSlug: slug, Status: CheckInStatusInProgress}
+	if id := hub.CaptureCheckIn(checkIn, nil); id != nil {
+		checkIn.ID = *id
+	}
+
+	start := time.Now()
+	completed := false
+	defer func() {
+		checkIn.Duration = time.Since(start)
+		checkIn.Status = CheckInStatusOK
+		if !completed || err != nil {
+			checkIn.Status = CheckInStatusError
+		}
+		hub.CaptureCheckIn(checkIn, nil)
+	}()
+
+	err = f()
+	completed = true
+	return err
+}
+
+// validateCheckIn returns an error if checkIn cannot be sent to Sentry.
+func validateCheckIn(checkIn *CheckIn) error {
+	if checkIn == nil {
+		return errors.New("nil check-in")
+	}
+	if checkIn.MonitorSlug == "" {
+		return errors.New("check-in without monitor slug")
+	}
+	switch checkIn.Status {
+	case CheckInStatusInProgress, CheckInStatusOK, CheckInStatusError:
+		return nil
+	}
+	return fmt.Errorf("check-in with invalid status %q", checkIn.Status)
+}
@@ -0,0 +1,121 @@
+package sentry
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCaptureCheckIn(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport:   transport,
+		Release:     "v1.2.3",
+		Environment: "production",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := client.CaptureCheckIn(&CheckIn{
+		MonitorSlug: "job",
+		Status:      CheckInStatusOK,
+		Duration:    1500 * time.Millisecond,
+	}, &MonitorConfig{
+		Schedule:      CrontabSchedule("*/5 * * * *"),
+		CheckInMargin: 1,
+		MaxRuntime:    10,
+		Timezone:      "Europe/Vienna",
+	})
+	if id == nil || *id == "" {
+		t.Fatalf("CaptureCheckIn() = %v, want a check-in ID", id)
+	}
+
+	items := transport.Items()
+	if len(items) != 1 || items[0].itemType != checkInType || items[0].eventID != *id {
+		t.Fatalf("got items %v, want a check-in", items)
+	}
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("got %d events, want check-ins to be sent as envelope items only", len(events))
+	}
+	b, err := json.Marshal(items[0].payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	want := `{"check_in_id":"` + string(*id) + `","monitor_slug":"job","status":"ok","duration":1.5,"release":"v1.2.3","environment":"production","monitor_config":{"schedule":{"type":"crontab","value":"*/5 * * * *"},"checkin_margin":1,"max_runtime":10,"timezone":"Europe/Vienna"}}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestCaptureCheckInIntervalSchedule(t *testing.T) {
+	b, err := json.Marshal(IntervalSchedule(2, MonitorScheduleUnitHour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"type":"interval","value":2,"unit":"hour"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestCaptureCheckInInvalid(t *testing.T) {
+	client, _, transport := setupClientTest()
+
+	for _, checkIn := range []*CheckIn{
+		nil,
+		{Status: CheckInStatusOK},
+		{MonitorSlug: "job", Status: "unknown"},
+	} {
+		if id := client.CaptureCheckIn(checkIn, nil); id != nil {
+			t.Errorf("CaptureCheckIn(%v) = %v, want nil", checkIn, *id)
+		}
+	}
+	if items := transport.Items(); len(items) != 0 {
+		t.Errorf("got %d items, want 0", len(items))
+	}
+}
+
+func TestWithCheckIn(t *testing.T) {
+	ctx := NewTestContext(ClientOptions{Transport: &TransportMock{}})
+	transport := hubFromContext(ctx).Client().Transport.(*TransportMock)
+
+	wantErr := errors.New("failed")
+	if err := WithCheckIn(ctx, "job", func() error { return wantErr }); err != wantErr {
+		t.Errorf("WithCheckIn() = %v, want %v", err, wantErr)
+	}
+	if err := WithCheckIn(ctx, "job", func() error { return nil }); err != nil {
+		t.Errorf("WithCheckIn() = %v, want nil", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithCheckIn did not propagate the panic")
+			}
+		}()
+		_ = WithCheckIn(ctx, "job", func() error { panic("boom") })
+	}()
+
+	items := transport.Items()
+	if len(items) != 6 {
+		t.Fatalf("got %d items, want 6 check-ins", len(items))
+	}
+	wantStatus := []CheckInStatus{
+		CheckInStatusInProgress, CheckInStatusError,
+		CheckInStatusInProgress, CheckInStatusOK,
+		CheckInStatusInProgress, CheckInStatusError,
+	}
+	var checkIns []checkInPayload
+	for _, item := range items {
+		checkIns = append(checkIns, item.payload.(checkInPayload))
+	}
+	for i, checkIn := range checkIns {
+		if checkIn.MonitorSlug != "job" || checkIn.Status != wantStatus[i] {
+			t.Errorf("check-in %d: got %q with status %q, want %q with status %q", i, checkIn.MonitorSlug, checkIn.Status, "job", wantStatus[i])
+		}
+		if i%2 == 1 && checkIn.CheckInID != checkIns[i-1].CheckInID {
+			t.Errorf("check-in %d: got ID %q, want the ID of the in progress check-in %q", i, checkIn.CheckInID, checkIns[i-1].CheckInID)
+		}
+	}
+}
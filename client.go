@@ -2,17 +2,27 @@ package sentry
 
 import (
 	"context"
+	cryptorand "crypto/rand"
 	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/getsentry/sentry-go/internal/debug"
 )
@@ -31,6 +41,34 @@ const maxErrorDepth = 10
 // would be rejected by Sentry.
 const defaultMaxSpans = 1000
 
+// defaultMaxEventSize is the default maximum size of a serialized event, in
+// bytes. It matches the size limit applied during event ingestion.
+const defaultMaxEventSize = 1 << 20
+
+// defaultMaxValueLength is the default maximum length of string values in
+// events. It matches the length of messages accepted during event ingestion.
+const defaultMaxValueLength = 8192
+
+// defaultMaxValueDepth is the default maximum depth of the values of extra
+// data and contexts in events.
+const defaultMaxValueDepth = 10
+
+// truncatedValueSuffix is appended to string values shortened because of
+// ClientOptions.MaxValueLength.
+const truncatedValueSuffix = "..."
+
+// truncatedTag is the tag set on events trimmed because of
+// ClientOptions.MaxEventSize.
+const truncatedTag = "truncated"
+
+// droppedSpansTag is the tag set on transactions with spans dropped because of
+// ClientOptions.MaxSpans.
+const droppedSpansTag = "spans_dropped"
+
+// flushPollInterval is the timeout of each call to the Flush method of
+// transports without a FlushWithContext method, see flushTransport.
+const flushPollInterval = 100 * time.Millisecond
+
 // hostname is the host name reported by the kernel. It is precomputed once to
 // avoid syscalls when capturing events.
 //
@@ -63,7 +101,18 @@ func (r *lockedRand) Float64() float64 {
 // concurrent use, so we need to couple its use with a sync.Mutex.
 var rng = &lockedRand{
 	// #nosec G404 -- We are fine using transparent, non-secure value here.
-	r: rand.New(rand.NewSource(time.Now().UnixNano())),
+	r: rand.New(rand.NewSource(randomSeed())),
+}
+
+// randomSeed returns a seed for rng read from crypto/rand, such that processes
+// started at the same time do not make the same sampling decisions. It falls
+// back to the current time if crypto/rand fails.
+func randomSeed() int64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
 }
 
 // usageError is used to report to Sentry an SDK usage error.
@@ -104,58 +153,250 @@ func AddGlobalEventProcessor(processor EventProcessor) {
 
 // Integration allows for registering a functions that modify or discard captured events.
 type Integration interface {
+	// Name identifies the integration. Only the first integration with a
+	// given name is installed on a client.
 	Name() string
+	// SetupOnce installs the integration on client, typically by adding an
+	// event processor with client.AddEventProcessor. It is called once, when
+	// the client is created.
 	SetupOnce(client *Client)
 }
 
 // ClientOptions that configures a SDK Client.
 type ClientOptions struct {
-	// The DSN to use. If the DSN is not set, the client is effectively
-	// disabled.
+	// The DSN to use. This will default to the SENTRY_DSN environment
+	// variable. If the DSN is not set, the client is effectively disabled.
+	//
+	// Unless a Transport or a BeforeSend or BeforeSendTransaction callback is
+	// set as well, capturing events and adding breadcrumbs then return
+	// immediately, making calls to the SDK nearly free.
 	Dsn string
+	// IngestURL, if set, is the base URL events are sent to instead of the
+	// scheme, host and path of the DSN, for instance a relay or gateway in
+	// front of a self-hosted Sentry:
+	//
+	//	IngestURL: "https://relay.example.com/sentry",
+	//
+	// sends the events of the project of the DSN to
+	// https://relay.example.com/sentry/api/<project>/envelope/, authenticated
+	// with the keys of the DSN. Region-specific ingest hosts, such as
+	// o1.ingest.de.sentry.io, need no IngestURL: the host of the DSN is used as
+	// is.
+	IngestURL string
 	// In debug mode, the debug information is printed to stdout to help you
-	// understand what sentry is doing.
+	// understand what sentry is doing. This will default to the SENTRY_DEBUG
+	// environment variable, parsed with strconv.ParseBool, such that
+	// SENTRY_DEBUG=1 or SENTRY_DEBUG=true enables it.
+	//
+	// Together with Dsn, Environment and Release, it lets programs configure
+	// the SDK entirely from the environment with sentry.Init(ClientOptions{}).
 	Debug bool
+	// StrictMode makes NewClient and Init return an error for configuration
+	// mistakes that are otherwise tolerated, such that they are caught in
+	// development and CI rather than in production: a missing DSN, with
+	// neither Transport nor Spotlight set, TracesSampleRate or
+	// ProfilesSampleRate outside of the range [0.0, 1.0], and invalid
+	// HTTPProxy or HTTPSProxy URLs. Invalid DSNs and sample rates are errors
+	// regardless.
+	//
+	// Mistakes that only show at runtime, like a BeforeSend callback that
+	// drops all events, are reported in the debug output, which strict mode
+	// enables, see Debug.
+	StrictMode bool
 	// Configures whether SDK should generate and attach stacktraces to pure
-	// capture message calls.
+	// capture message calls. The stacktrace of the calling goroutine is sent
+	// as the current thread of the event, without the frames of the SDK, such
+	// that Sentry shows where the message was captured.
 	AttachStacktrace bool
+	// InAppInclude lists package path prefixes of the code considered part of
+	// the application. Stacktrace frames in these packages are marked as in
+	// app, such that Sentry highlights them and uses them for grouping. A
+	// prefix matches a package path and its subpackages.
+	//
+	// By default, frames outside of GOROOT and of vendor and third_party
+	// directories are in app.
+	InAppInclude []string
+	// InAppExclude lists package path prefixes of the code not considered
+	// part of the application, for example vendored dependencies. It takes
+	// precedence over InAppInclude.
+	InAppExclude []string
 	// The sample rate for event submission in the range [0.0, 1.0]. By default,
 	// all events are sent. Thus, as a historical special case, the sample rate
 	// 0.0 is treated as if it was 1.0. To drop all events, set the DSN to the
 	// empty string.
+	//
+	// Error and message events are sampled independently of transactions,
+	// see TracesSampleRate. NewClient and Init return an error if the sample
+	// rate is outside of the range.
 	SampleRate float64
+	// ErrorSampler, if set, returns the sample rate in the range [0.0, 1.0] of
+	// an error or message event, replacing SampleRate. It is called once the
+	// scope and event processors applied to the event, before BeforeSend, so
+	// that the rate can depend on the request of the event, for example to
+	// sample errors of a high-volume endpoint down:
+	//
+	//	ErrorSampler: func(event *sentry.Event, hint *sentry.EventHint) float64 {
+	//		if event.Request != nil && strings.HasSuffix(event.Request.URL, "/poll") {
+	//			return 0.01
+	//		}
+	//		return 1.0
+	//	},
+	//
+	// Events are dropped with rates outside of the range. Sampled out events
+	// are counted in client reports, like those sampled out by SampleRate.
+	ErrorSampler func(event *Event, hint *EventHint) float64
+	// MinLevel is the minimum level of error and message events sent to
+	// Sentry. Events below this level are dropped after BeforeSend, such that
+	// BeforeSend can still change their level. Events with a level that is
+	// not one of the Level constants are always sent. The empty string, the
+	// default, sends events of all levels. Transactions are not affected.
+	MinLevel Level
 	// Enable performance tracing.
 	EnableTracing bool
+	// EnableLogs enables sending structured logs with the loggers returned
+	// by NewLogger. Logs are batched and sent separately from events, they
+	// are not subject to SampleRate, BeforeSend nor event processors.
+	EnableLogs bool
 	// The sample rate for sampling traces in the range [0.0, 1.0].
 	TracesSampleRate float64
 	// Used to customize the sampling of traces, overrides TracesSampleRate.
+	// It is not called for transactions that continue a trace with a sampling
+	// decision, for example from an incoming sentry-trace header, in which
+	// case the decision is inherited.
 	TracesSampler TracesSampler
+	// MaxTransactionsPerSecond caps the rate of transactions sent to Sentry,
+	// to protect the quota of the project from bursts of traffic that sampling
+	// alone lets through. Sampled transactions above the rate, in bursts of at
+	// most one second of transactions, or a single one for rates below 1, are
+	// dropped when they finish and counted as rate limited in client reports.
+	// It is not applied when zero, the default, nor negative.
+	MaxTransactionsPerSecond float64
 	// The sample rate for profiling traces in the range [0.0, 1.0].
 	// This is relative to TracesSampleRate - it is a ratio of profiled traces out of all sampled traces.
+	//
+	// A profiled transaction collects the stack traces of all goroutines at
+	// 101 Hz, from its start until it finishes or for at most 30 seconds. The
+	// profile is sent as an envelope item along with the transaction, linked
+	// to it by its event ID and trace ID. Profiling is off by default, and
+	// unsampled transactions are never profiled.
 	ProfilesSampleRate float64
 	// List of regexp strings that will be used to match against event's message
 	// and if applicable, caught errors type and value.
 	// If the match is found, then a whole event will be dropped.
+	// NewClient and Init return an error if any of the strings is not a valid
+	// regular expression.
 	IgnoreErrors []string
 	// If this flag is enabled, certain personally identifiable information (PII) is added by active integrations.
 	// By default, no such data is sent.
+	//
+	// When disabled, the IP address of the user, request cookies and request
+	// headers identifying users, like Authorization or X-Forwarded-For, are
+	// removed from all events, even if set explicitly. When enabled, the IP
+	// address of the user defaults to the one of the client that made the
+	// request set with Scope.SetRequest, taken from the X-Forwarded-For or
+	// X-Real-Ip request headers or the remote address of the request.
 	SendDefaultPII bool
+	// OmitRequestFields are fields of the HTTP request of events, such as the
+	// one set with Scope.SetRequest, that are never sent. The URL and method
+	// are always kept. For example, to only send the method and the path:
+	//
+	//	OmitRequestFields: sentry.RequestQueryString | sentry.RequestHeaders |
+	//		sentry.RequestCookies | sentry.RequestEnv | sentry.RequestData,
+	//
+	// Fields are removed before BeforeSend is called, after the IP address of
+	// the user was inferred from the request, see SendDefaultPII.
+	OmitRequestFields RequestFields
 	// BeforeSend is called before error events are sent to Sentry.
 	// Use it to mutate the event or return nil to discard the event.
+	//
+	// It is not called for transactions, see BeforeSendTransaction. It is
+	// called last, after events are sampled with SampleRate and processed by
+	// the scope and event processors, so only for events about to be sent.
+	//
+	// Since the scope was already applied, event.Fingerprint holds the
+	// fingerprint set with Scope.SetFingerprint, if the event had none of its
+	// own. Set event.Fingerprint in BeforeSend to group events dynamically,
+	// based on their content; it takes precedence over the scope fingerprint.
 	BeforeSend func(event *Event, hint *EventHint) *Event
 	// BeforeSendTransaction is called before transaction events are sent to Sentry.
 	// Use it to mutate the transaction or return nil to discard the transaction.
+	//
+	// It is only called for transactions, and only for those sampled when
+	// they were started, with TracesSampleRate or TracesSampler. Like
+	// BeforeSend, it is called after the scope and event processors.
 	BeforeSendTransaction func(event *Event, hint *EventHint) *Event
-	// Before breadcrumb add callback.
+	// BeforeBreadcrumb is called before a breadcrumb is added to the scope
+	// with Hub.AddBreadcrumb or AddBreadcrumb.
+	// Use it to mutate the breadcrumb or return nil to discard the breadcrumb.
 	BeforeBreadcrumb func(breadcrumb *Breadcrumb, hint *BreadcrumbHint) *Breadcrumb
+	// OnBreadcrumbEvicted is called with each breadcrumb removed from the
+	// scope by Hub.AddBreadcrumb or AddBreadcrumb to make room for a newer one,
+	// because of MaxBreadcrumbs or MaxBreadcrumbsPerCategory, for example to
+	// count them or to keep a longer trail elsewhere. It is called after the
+	// new breadcrumb was added, in the goroutine adding it, and must not modify
+	// the breadcrumb, which may still be referenced by events being sent.
+	OnBreadcrumbEvicted func(breadcrumb *Breadcrumb)
+	// DropOnCallbackPanic changes what happens when BeforeSend,
+	// BeforeSendTransaction or BeforeBreadcrumb panics. The panic is always
+	// recovered and logged with the debug logger. By default, the event or
+	// breadcrumb is then kept as passed to the callback, including the changes
+	// the callback made before panicking. With DropOnCallbackPanic set, it is
+	// discarded instead.
+	DropOnCallbackPanic bool
+	// BreadcrumbLevel is the minimum level of the breadcrumbs added to the
+	// scope. Breadcrumbs below this level are dropped after BeforeBreadcrumb,
+	// such that BeforeBreadcrumb can still change their level. Breadcrumbs
+	// without a level count as LevelInfo, the level Sentry shows for them,
+	// and those with a level that is not one of the Level constants are
+	// always kept. The empty string, the default, keeps breadcrumbs of all
+	// levels.
+	BreadcrumbLevel Level
 	// Integrations to be installed on the current Client, receives default
 	// integrations.
+	//
+	// The default integrations are InAppFrames, ContextifyFrames, Environment,
+	// Modules and IgnoreErrors, in that order, which is the order their event
+	// processors run in. The returned list replaces them, so the function can
+	// remove, reorder or append integrations, for example to skip the
+	// Modules and ContextifyFrames integrations in a low-overhead service:
+	//
+	//	Integrations: func(integrations []sentry.Integration) []sentry.Integration {
+	//		var kept []sentry.Integration
+	//		for _, integration := range integrations {
+	//			switch integration.Name() {
+	//			case "Modules", "ContextifyFrames":
+	//			default:
+	//				kept = append(kept, integration)
+	//			}
+	//		}
+	//		return kept
+	//	},
 	Integrations func([]Integration) []Integration
+	// DisableDefaultIntegrations leaves out the default integrations that
+	// add data to events, ContextifyFrames, Environment and Modules, for the
+	// smallest overhead per event. InAppFrames, which marks the frames of
+	// stack traces as in app, is kept, as is IgnoreErrors when the
+	// IgnoreErrors option is set. The Integrations function receives the
+	// remaining integrations.
+	DisableDefaultIntegrations bool
 	// io.Writer implementation that should be used with the Debug mode.
 	DebugWriter io.Writer
 	// The transport to use. Defaults to HTTPTransport.
+	// Any implementation of the Transport interface can be used.
 	Transport Transport
+	// EnableSpotlight mirrors all events to Spotlight, a debugging UI run
+	// locally during development, in addition to sending them with the
+	// transport. Events are still sent to Sentry if a DSN is set, and
+	// failures to reach Spotlight never affect their delivery. It is meant
+	// for development only.
+	EnableSpotlight bool
+	// SpotlightURL is the URL of the Spotlight sidecar events are mirrored
+	// to when EnableSpotlight is set. Defaults to
+	// "http://localhost:8969/stream".
+	SpotlightURL string
 	// The server name to be reported.
+	// This will default to the SENTRY_NAME environment variable, or the host
+	// name reported by the kernel.
 	ServerName string
 	// The release to be sent with events.
 	//
@@ -164,8 +405,9 @@ type ClientOptions struct {
 	// https://docs.sentry.io/product/releases/.
 	//
 	// If Release is not set, the SDK will try to derive a default value
-	// from environment variables or the Git repository in the working
-	// directory.
+	// from the VCS revision stamped into the binary by the go command,
+	// environment variables such as SENTRY_RELEASE, or the Git repository in
+	// the working directory, in that order.
 	//
 	// If you distribute a compiled binary, it is recommended to set the
 	// Release value explicitly at build time. As an example, you can use:
@@ -181,18 +423,145 @@ type ClientOptions struct {
 	// See https://golang.org/cmd/go/ and https://golang.org/cmd/link/ for
 	// the official documentation of -ldflags and -X, respectively.
 	Release string
-	// The dist to be sent with events.
+	// The dist to be sent with events, distinguishing builds of the same
+	// release, for instance binaries of the same version built for different
+	// platforms. This will default to the SENTRY_DIST environment variable.
 	Dist string
 	// The environment to be sent with events.
+	// This will default to the SENTRY_ENVIRONMENT environment variable. If
+	// neither is set, events are sent without an environment and Sentry
+	// treats them as "production".
 	Environment string
-	// Maximum number of breadcrumbs
-	// when MaxBreadcrumbs is negative then ignore breadcrumbs.
+	// Maximum number of breadcrumbs kept in the scope, and thus sent with an
+	// event. Defaults to 30 when zero and is capped at 100.
+	// When MaxBreadcrumbs is negative, breadcrumbs are ignored.
 	MaxBreadcrumbs int
-	// Maximum number of spans.
+	// MaxBreadcrumbsPerCategory limits the number of breadcrumbs kept in the
+	// scope per category, for example {"http": 10} to keep at most the 10
+	// most recent "http" breadcrumbs, such that a noisy category does not
+	// push out all other breadcrumbs. When the limit of a category is
+	// reached, its oldest breadcrumb is evicted first. Breadcrumbs of
+	// categories with a zero or negative limit are ignored.
+	//
+	// Categories not in the map are only limited by MaxBreadcrumbs, which
+	// also applies to all breadcrumbs.
+	MaxBreadcrumbsPerCategory map[string]int
+	// Repanic configures whether the package-level Recover and
+	// RecoverWithContext functions panic again after capturing a panic, for
+	// example to still crash the program or let an outer panic handler run.
+	// The captured event is flushed before panicking again.
+	//
+	// It does not affect the Recover methods of Hub and Client, nor the
+	// integrations, which have their own Repanic options.
+	Repanic bool
+	// PanicLevel is the level of the events of panics recovered with the
+	// Recover and RecoverWithContext functions and methods, which the
+	// integrations recovering panics in handlers use. Defaults to LevelFatal,
+	// telling panics apart from handled errors, when empty.
+	PanicLevel Level
+	// SampleRand, if set, returns the random numbers in [0.0, 1.0) used for
+	// all sampling decisions: SampleRate, TracesSampleRate, TracesSampler and
+	// ProfilesSampleRate. It is meant for tests, to make sampling decisions
+	// deterministic, and must be safe for concurrent use. By default, the
+	// numbers come from a pseudo-random source seeded from crypto/rand.
+	SampleRand func() float64
+	// ContextTags, if set, returns tags to set on events captured with a
+	// context, such as request or tenant IDs stored in the context by the
+	// application. It is called with the context of RecoverWithContext and
+	// CaptureRecovered, and by integrations like sentryhttp with the context
+	// of every request. Tags already set on an event are kept.
+	ContextTags func(ctx context.Context) map[string]string
+	// Tags are set on every event sent by the client, regardless of the
+	// scope, for static metadata such as the name of the service. Tags of the
+	// same name set on the event or the scope take precedence.
+	Tags map[string]string
+	// Contexts are set on every event sent by the client, regardless of the
+	// scope. Like for contexts set with Scope.SetContext, the fields set on
+	// the event or the scope for the same context take precedence.
+	Contexts map[string]Context
+	// GroupPanicsByType configures recovered panics to be grouped by the type
+	// of the recovered value and its message, with numbers and addresses left
+	// out, instead of by stack trace. This keeps the same bug in one issue
+	// when the code around it changes. The fingerprint replaces the one of the
+	// scope, but event processors and BeforeSend can still change it.
+	GroupPanicsByType bool
+	// GoroutineDumpSize, if positive, attaches the stacks of all goroutines
+	// at the time of the panic, as formatted by runtime.Stack, to the events
+	// of recovered panics, as a goroutines.txt file of at most
+	// GoroutineDumpSize bytes. Dumps larger than that are cut. It helps
+	// diagnose panics caused by concurrency bugs, at the cost of briefly
+	// stopping the world while the dump is taken.
+	GoroutineDumpSize int
+	// Maximum number of spans recorded in a transaction. Defaults to 1000
+	// when zero. Spans started once the limit is reached are dropped, and the
+	// transaction is sent with a "spans_dropped" tag holding their number.
 	//
 	// See https://develop.sentry.dev/sdk/envelopes/#size-limits for size limits
 	// applied during event ingestion. Events that exceed these limits might get dropped.
 	MaxSpans int
+	// Maximum size of a serialized event, in bytes. Defaults to 1MB when zero.
+	// Larger events are trimmed before being sent, removing breadcrumbs
+	// first, then extra data, then the local variables of stack frames, and
+	// get a "truncated" tag. Events that are still too large are dropped.
+	// Set to a negative value to disable the limit.
+	//
+	// The limit is enforced by the client right before events are passed to
+	// the Transport, after BeforeSend, on a copy of the event.
+	MaxEventSize int
+	// Maximum length, in characters, of the message, tag values and the
+	// string values of extra data and of breadcrumbs of an event. Longer
+	// values are truncated right before the event is sent, after BeforeSend,
+	// and end with "...". Defaults to 8192 when zero. Set to a negative value
+	// to send values in full.
+	MaxValueLength int
+	// Maximum depth of the values of the extra data and contexts of an event.
+	// Maps, slices, arrays and structs nested deeper are replaced with
+	// "[MaxDepth]", and references to a value containing them, as in
+	// self-referential structs, with "[Circular]", which would otherwise make
+	// the event fail to serialize. The values are normalized right before the
+	// event is sent, after BeforeSend, and only copied if they need to be.
+	// Defaults to 10 when zero. Set to a negative value to send values as is.
+	MaxValueDepth int
+	// DataScrubbers are patterns whose matches are replaced with "[Filtered]"
+	// in the message, tags, extra data, contexts, exception values,
+	// breadcrumbs, request data and span descriptions and data of events,
+	// walking nested maps and slices. Scrubbing runs right before the event
+	// is sent, after BeforeSend, and costs nothing without patterns. The user
+	// of the event is kept as is. See CreditCardNumberPattern and
+	// EmailAddressPattern:
+	//
+	//	DataScrubbers: []*regexp.Regexp{
+	//		sentry.CreditCardNumberPattern,
+	//		sentry.EmailAddressPattern,
+	//	},
+	DataScrubbers []*regexp.Regexp
+	// ScrubQueryString replaces the values of query string parameters with
+	// "[Filtered]", keeping their names, in the request query string and URL
+	// of events and in transaction names, for query strings carrying tokens
+	// or personal data. Like DataScrubbers, it applies right before events
+	// are sent.
+	ScrubQueryString bool
+	// EnvelopeHeaders, if set, returns headers added to the envelope of each
+	// error event and transaction sent by the HTTPTransport and
+	// HTTPSyncTransport, for relays routing or filtering envelopes on custom
+	// headers. It is called right before the event is handed over to the
+	// transport. Headers set by the SDK, event_id, sent_at, dsn, sdk and
+	// trace, the dynamic sampling context of the trace of the event, cannot
+	// be overridden.
+	EnvelopeHeaders func(event *Event) map[string]interface{}
+	// OnSendError, if set, is called by the HTTPTransport and
+	// HTTPSyncTransport when an event could not be delivered: with
+	// ErrRateLimited when it was dropped because of rate limits, with
+	// ErrBufferFull when it was dropped because the buffer of the
+	// HTTPTransport is full, with a *SendError when Sentry rejected it, and
+	// with the network error otherwise, once retries, if any, are exhausted.
+	// Use errors.Is(err, sentry.ErrRateLimited) to tell rate limits from other
+	// failures. Events stored by an OfflineTransport to be sent later are not
+	// reported.
+	//
+	// It is called in a new goroutine, such that it never blocks the delivery
+	// of other events. It must not modify the event.
+	OnSendError func(event *Event, err error)
 	// An optional pointer to http.Client that will be used with a default
 	// HTTPTransport. Using your own client will make HTTPTransport, HTTPProxy,
 	// HTTPSProxy and CaCerts options ignored.
@@ -201,6 +570,12 @@ type ClientOptions struct {
 	// HTTPTransport. Using your own transport will make HTTPProxy, HTTPSProxy
 	// and CaCerts options ignored.
 	HTTPTransport http.RoundTripper
+	// Number of events buffered by the default HTTPTransport while they wait
+	// to be sent. Defaults to 30, or 1000 when tracing is enabled. Events sent
+	// while the buffer is full are dropped and counted in client reports.
+	// Ignored when Transport is set; see HTTPTransport.BufferSize and
+	// HTTPTransport.DropOldest to configure your own HTTPTransport.
+	BufferSize int
 	// An optional HTTP proxy to use.
 	// This will default to the HTTP_PROXY environment variable.
 	HTTPProxy string
@@ -208,6 +583,10 @@ type ClientOptions struct {
 	// This will default to the HTTPS_PROXY environment variable.
 	// HTTPS_PROXY takes precedence over HTTP_PROXY for https requests.
 	HTTPSProxy string
+	// DisableCompression disables gzip compression of the payloads sent to
+	// Sentry by HTTPTransport and HTTPSyncTransport. Compression is enabled
+	// by default.
+	DisableCompression bool
 	// An optional set of SSL certificates to use.
 	CaCerts *x509.CertPool
 	// MaxErrorDepth is the maximum number of errors reported in a chain of errors.
@@ -227,6 +606,21 @@ type Client struct {
 	dsn             *Dsn
 	eventProcessors []EventProcessor
 	integrations    []Integration
+	// discarded counts events dropped by the client, reported to Sentry by
+	// the transports that send client reports.
+	discarded discardedEvents
+	// closed is set to 1 by Close, after which events are dropped.
+	closed int32
+	// logs buffers structured logs until they are sent, it is nil unless
+	// ClientOptions.EnableLogs is set.
+	logs *logBuffer
+	// transactionLimiter enforces ClientOptions.MaxTransactionsPerSecond, it
+	// is nil if the rate is not capped.
+	transactionLimiter *tokenBucket
+	// noop is set when the client has no DSN, custom transport, callback nor
+	// event processor, other than those of default integrations, observing
+	// events. See disabled.
+	noop bool
 	// Transport is read-only. Replacing the transport of an existing client is
 	// not supported, create a new client instead.
 	Transport Transport
@@ -258,10 +652,20 @@ func NewClient(options ClientOptions) (*Client, error) {
 	// pattern. That would either require a breaking change if we want to reuse
 	// the obvious NewClient name, or a new function as an alternative
 	// constructor.
+	if options.SampleRate < 0.0 || options.SampleRate > 1.0 || math.IsNaN(options.SampleRate) {
+		return nil, fmt.Errorf("invalid SampleRate %v: must be in the range [0.0, 1.0]", options.SampleRate)
+	}
 	if options.SampleRate == 0.0 {
 		options.SampleRate = 1.0
 	}
 
+	if !options.Debug {
+		options.Debug, _ = strconv.ParseBool(os.Getenv("SENTRY_DEBUG"))
+	}
+	if options.StrictMode {
+		options.Debug = true
+	}
+
 	if options.Debug {
 		debugWriter := options.DebugWriter
 		if debugWriter == nil {
@@ -278,10 +682,18 @@ func NewClient(options ClientOptions) (*Client, error) {
 		options.Release = defaultRelease()
 	}
 
+	if options.Dist == "" {
+		options.Dist = os.Getenv("SENTRY_DIST")
+	}
+
 	if options.Environment == "" {
 		options.Environment = os.Getenv("SENTRY_ENVIRONMENT")
 	}
 
+	if options.ServerName == "" {
+		options.ServerName = os.Getenv("SENTRY_NAME")
+	}
+
 	if options.MaxErrorDepth == 0 {
 		options.MaxErrorDepth = maxErrorDepth
 	}
@@ -290,6 +702,18 @@ func NewClient(options ClientOptions) (*Client, error) {
 		options.MaxSpans = defaultMaxSpans
 	}
 
+	if options.MaxEventSize == 0 {
+		options.MaxEventSize = defaultMaxEventSize
+	}
+
+	if options.MaxValueLength == 0 {
+		options.MaxValueLength = defaultMaxValueLength
+	}
+
+	if options.MaxValueDepth == 0 {
+		options.MaxValueDepth = defaultMaxValueDepth
+	}
+
 	// SENTRYGODEBUG is a comma-separated list of key=value pairs (similar
 	// to GODEBUG). It is not a supported feature: recognized debug options
 	// may change any time.
@@ -313,26 +737,104 @@ func NewClient(options ClientOptions) (*Client, error) {
 		}
 	}
 
+	for _, pattern := range options.IgnoreErrors {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid IgnoreErrors pattern %q: %w", pattern, err)
+		}
+	}
+
+	if options.MinLevel != "" && options.MinLevel.severity() < 0 {
+		return nil, fmt.Errorf("invalid MinLevel %q", options.MinLevel)
+	}
+	if options.PanicLevel == "" {
+		options.PanicLevel = LevelFatal
+	} else if options.PanicLevel.severity() < 0 {
+		return nil, fmt.Errorf("invalid PanicLevel %q", options.PanicLevel)
+	}
+	if options.BreadcrumbLevel != "" && options.BreadcrumbLevel.severity() < 0 {
+		return nil, fmt.Errorf("invalid BreadcrumbLevel %q", options.BreadcrumbLevel)
+	}
+
 	var dsn *Dsn
 	if options.Dsn != "" {
 		var err error
-		dsn, err = NewDsn(options.Dsn)
+		dsn, err = dsnFromOptions(options)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if options.StrictMode {
+		if err := checkStrictOptions(options); err != nil {
+			return nil, err
+		}
+	}
+
 	client := Client{
 		options: options,
 		dsn:     dsn,
 	}
 
+	// Without a DSN, a custom transport nor Spotlight, events are never sent
+	// and can only be observed by the BeforeSend* callbacks, typically in
+	// tests, or by event processors. It is set before the integrations, which
+	// may add event processors.
+	client.noop = options.Dsn == "" && options.Transport == nil && !options.EnableSpotlight &&
+		options.BeforeSend == nil && options.BeforeSendTransaction == nil
+
 	client.setupTransport()
 	client.setupIntegrations()
 
+	if options.EnableLogs {
+		client.logs = &logBuffer{client: &client}
+	}
+
+	if options.MaxTransactionsPerSecond > 0 {
+		client.transactionLimiter = newTokenBucket(options.MaxTransactionsPerSecond)
+	}
+
 	return &client, nil
 }
 
+// checkStrictOptions returns an error for the first configuration mistake
+// reported by ClientOptions.StrictMode.
+func checkStrictOptions(options ClientOptions) error {
+	if options.Dsn == "" && options.Transport == nil && !options.EnableSpotlight {
+		return errors.New("strict mode: no Dsn set: events are not sent")
+	}
+	rates := []struct {
+		name string
+		rate float64
+	}{
+		{"TracesSampleRate", options.TracesSampleRate},
+		{"ProfilesSampleRate", options.ProfilesSampleRate},
+	}
+	for _, r := range rates {
+		if r.rate < 0.0 || r.rate > 1.0 || math.IsNaN(r.rate) {
+			return fmt.Errorf("strict mode: invalid %s %v: must be in the range [0.0, 1.0]", r.name, r.rate)
+		}
+	}
+	proxies := []struct {
+		name, url string
+	}{
+		{"HTTPProxy", options.HTTPProxy},
+		{"HTTPSProxy", options.HTTPSProxy},
+	}
+	for _, p := range proxies {
+		if p.url == "" {
+			continue
+		}
+		u, err := url.Parse(p.url)
+		if err != nil {
+			return fmt.Errorf("strict mode: invalid %s %q: %w", p.name, p.url, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("strict mode: invalid %s %q: must be an absolute URL", p.name, p.url)
+		}
+	}
+	return nil
+}
+
 func (client *Client) setupTransport() {
 	opts := client.options
 	transport := opts.Transport
@@ -349,21 +851,42 @@ func (client *Client) setupTransport() {
 			if opts.EnableTracing {
 				httpTransport.BufferSize = 1000
 			}
+			if opts.BufferSize > 0 {
+				httpTransport.BufferSize = opts.BufferSize
+			}
 			transport = httpTransport
 		}
 	}
 
+	if opts.EnableSpotlight {
+		transport = newSpotlightTransport(transport, client.dsn, opts.SpotlightURL)
+	}
+
+	if source, ok := transport.(clientReportSource); ok {
+		source.setClientDiscardedEvents(&client.discarded)
+	}
 	transport.Configure(opts)
 	client.Transport = transport
 }
 
 func (client *Client) setupIntegrations() {
 	integrations := []Integration{
+		// Frames must be classified before they are contextified, as only
+		// in-app frames get source context.
+		new(inAppFramesIntegration),
 		new(contextifyFramesIntegration),
 		new(environmentIntegration),
 		new(modulesIntegration),
 		new(ignoreErrorsIntegration),
 	}
+	if client.options.DisableDefaultIntegrations {
+		integrations = []Integration{new(inAppFramesIntegration)}
+		if len(client.options.IgnoreErrors) > 0 {
+			integrations = append(integrations, new(ignoreErrorsIntegration))
+		}
+	}
+
+	defaults := integrations
 
 	if client.options.Integrations != nil {
 		integrations = client.options.Integrations(integrations)
@@ -375,7 +898,14 @@ func (client *Client) setupIntegrations() {
 			continue
 		}
 		client.integrations = append(client.integrations, integration)
+		noop := client.noop
 		integration.SetupOnce(client)
+		// The processors of the default integrations only enrich events, they
+		// do not observe them. Other integrations may, for example to record
+		// events in tests.
+		if isDefaultIntegration(defaults, integration) {
+			client.noop = noop
+		}
 		Logger.Printf("Integration installed: %s\n", integration.Name())
 	}
 
@@ -384,6 +914,18 @@ func (client *Client) setupIntegrations() {
 	})
 }
 
+// isDefaultIntegration reports whether integration is one of defaults.
+func isDefaultIntegration(defaults []Integration, integration Integration) bool {
+	for _, d := range defaults {
+		// The default integrations are pointers, so the comparison cannot
+		// panic, even for integrations of incomparable types.
+		if d == integration {
+			return true
+		}
+	}
+	return false
+}
+
 // AddEventProcessor adds an event processor to the client. It must not be
 // called from concurrent goroutines. Most users will prefer to use
 // ClientOptions.BeforeSend or Scope.AddEventProcessor instead.
@@ -393,23 +935,60 @@ func (client *Client) setupIntegrations() {
 // event processor to the client affects all hubs that share the client.
 func (client *Client) AddEventProcessor(processor EventProcessor) {
 	client.eventProcessors = append(client.eventProcessors, processor)
+	client.noop = false
+}
+
+// disabled reports whether events captured by the client can neither be sent
+// nor observed, in which case calls to the API return immediately without
+// assembling events or breadcrumbs.
+func (client *Client) disabled() bool {
+	return client.noop && len(globalEventProcessors) == 0
 }
 
 // Options return ClientOptions for the current Client.
-func (client Client) Options() ClientOptions {
+func (client *Client) Options() ClientOptions {
 	// Note: internally, consider using `client.options` instead of `client.Options()` to avoid copying the object each time.
 	return client.options
 }
 
 // CaptureMessage captures an arbitrary message.
+// It returns the EventID of the event, or nil if the event was not accepted.
 func (client *Client) CaptureMessage(message string, hint *EventHint, scope EventModifier) *EventID {
+	if client.disabled() {
+		return nil
+	}
 	event := client.EventFromMessage(message, LevelInfo)
 	return client.CaptureEvent(event, hint, scope)
 }
 
 // CaptureException captures an error.
+// It returns the EventID of the event, or nil if the event was not accepted.
+//
+// The exception is marked as handled, see Hub.CaptureUnhandledException.
 func (client *Client) CaptureException(exception error, hint *EventHint, scope EventModifier) *EventID {
+	if client.disabled() {
+		return nil
+	}
 	event := client.EventFromException(exception, LevelError)
+	return client.captureExceptionEvent(event, exception, hint, scope, true)
+}
+
+// captureExceptionEvent captures the event of exception, marking the exception
+// as handled or not.
+func (client *Client) captureExceptionEvent(
+	event *Event,
+	exception error,
+	hint *EventHint,
+	scope EventModifier,
+	handled bool,
+) *EventID {
+	if hint == nil {
+		hint = &EventHint{}
+	}
+	if hint.OriginalException == nil {
+		hint.OriginalException = exception
+	}
+	event.setHandled(handled)
 	return client.CaptureEvent(event, hint, scope)
 }
 
@@ -418,12 +997,104 @@ func (client *Client) CaptureException(exception error, hint *EventHint, scope E
 // The event must already be assembled. Typically code would instead use
 // the utility methods like CaptureException. The return value is the
 // event ID. In case Sentry is disabled or event was dropped, the return value will be nil.
+//
+// The Timestamp of the event defaults to the time of the call, but is kept if
+// already set, for instance when reporting errors read back from logs. Note
+// that Sentry drops events older than its retention period.
 func (client *Client) CaptureEvent(event *Event, hint *EventHint, scope EventModifier) *EventID {
+	if client.disabled() {
+		return nil
+	}
 	return client.processEvent(event, hint, scope)
 }
 
+// CaptureCheckIn sends checkIn, reporting the status of a run of a scheduled
+// job to Sentry Crons, along with the configuration of its monitor unless
+// monitorConfig is nil. It returns the ID of the check-in, to be reused when
+// reporting the completion of a run in progress, or nil if it was not sent.
+//
+// Check-ins are not events: they are not subject to SampleRate, BeforeSend nor
+// event processors.
+func (client *Client) CaptureCheckIn(checkIn *CheckIn, monitorConfig *MonitorConfig) *EventID {
+	if client.noop {
+		return nil
+	}
+	if atomic.LoadInt32(&client.closed) == 1 {
+		Logger.Println("Check-in dropped due to the client being closed.")
+		return nil
+	}
+	if err := validateCheckIn(checkIn); err != nil {
+		Logger.Printf("Check-in dropped: %v.", err)
+		return nil
+	}
+
+	sent := *checkIn
+	if sent.ID == "" {
+		sent.ID = EventID(uuid())
+	}
+	sendItem(client.Transport, newCheckInItem(&sent, monitorConfig, client.options.Release, client.options.Environment))
+	return &sent.ID
+}
+
+// CaptureUserFeedback sends feedback from a user about an event previously
+// captured by the client. Feedback without an event ID or comments is dropped.
+func (client *Client) CaptureUserFeedback(feedback *UserFeedback) {
+	if client.noop {
+		return
+	}
+	if atomic.LoadInt32(&client.closed) == 1 {
+		Logger.Println("User feedback dropped due to the client being closed.")
+		return
+	}
+	if err := validateUserFeedback(feedback); err != nil {
+		Logger.Printf("User feedback dropped: %v.", err)
+		return
+	}
+
+	sendItem(client.Transport, newUserReportItem(feedback))
+}
+
+// callBeforeSend calls f, the BeforeSend or BeforeSendTransaction callback
+// called name, recovering from a panic according to
+// ClientOptions.DropOnCallbackPanic.
+func (client *Client) callBeforeSend(
+	name string,
+	f func(event *Event, hint *EventHint) *Event,
+	event *Event,
+	hint *EventHint,
+) (result *Event) {
+	defer func() {
+		if err := recover(); err != nil {
+			logCallbackPanic(name, err)
+			if client.options.DropOnCallbackPanic {
+				result = nil
+			} else {
+				result = event
+			}
+		}
+	}()
+	return f(event, hint)
+}
+
+// callBeforeBreadcrumb calls the BeforeBreadcrumb callback, recovering from a
+// panic according to ClientOptions.DropOnCallbackPanic.
+func (client *Client) callBeforeBreadcrumb(breadcrumb *Breadcrumb, hint *BreadcrumbHint) (result *Breadcrumb) {
+	defer func() {
+		if err := recover(); err != nil {
+			logCallbackPanic("BeforeBreadcrumb", err)
+			if client.options.DropOnCallbackPanic {
+				result = nil
+			} else {
+				result = breadcrumb
+			}
+		}
+	}()
+	return client.options.BeforeBreadcrumb(breadcrumb, hint)
+}
+
 // Recover captures a panic.
-// Returns EventID if successfully, or nil if there's no error to recover from.
+// Returns the EventID of the event, or nil if there's no error to recover from
+// or the event was not accepted.
 func (client *Client) Recover(err interface{}, hint *EventHint, scope EventModifier) *EventID {
 	if err == nil {
 		err = recover()
@@ -438,7 +1109,12 @@ func (client *Client) Recover(err interface{}, hint *EventHint, scope EventModif
 }
 
 // RecoverWithContext captures a panic and passes relevant context object.
-// Returns EventID if successfully, or nil if there's no error to recover from.
+// Returns the EventID of the event, or nil if there's no error to recover from
+// or the event was not accepted.
+//
+// Panics are reported as an unhandled exception, which Sentry counts as a
+// crash. Panics with a value other than an error are also reported with the
+// value as the message of the event, and an exception of type "panic".
 func (client *Client) RecoverWithContext(
 	ctx context.Context,
 	err interface{},
@@ -448,34 +1124,80 @@ func (client *Client) RecoverWithContext(
 	if err == nil {
 		err = recover()
 	}
-	if err == nil {
+	if err == nil || client.disabled() {
 		return nil
 	}
 
-	if ctx != nil {
-		if hint == nil {
-			hint = &EventHint{}
-		}
-		if hint.Context == nil {
-			hint.Context = ctx
-		}
+	if hint == nil {
+		hint = &EventHint{}
+	}
+	if hint.RecoveredException == nil {
+		hint.RecoveredException = err
+	}
+	if ctx != nil && hint.Context == nil {
+		hint.Context = ctx
 	}
 
+	// While deferred functions run, the stack still holds the frames up to the
+	// panic, so capture it here to report where the panic happened.
+	stacktrace := panicStacktrace()
+
 	var event *Event
+	var message string
 	switch err := err.(type) {
 	case error:
-		event = client.EventFromException(err, LevelFatal)
+		event = client.EventFromException(err, client.options.PanicLevel)
+		if ExtractStacktrace(err) == nil {
+			event.Exception[len(event.Exception)-1].Stacktrace = stacktrace
+		}
+		event.setHandled(false)
+		message = err.Error()
 	case string:
-		event = client.EventFromMessage(err, LevelFatal)
+		event = client.EventFromMessage(err, client.options.PanicLevel)
+		message = err
 	default:
-		event = client.EventFromMessage(fmt.Sprintf("%#v", err), LevelFatal)
+		// The message alone does not tell values of different types apart,
+		// such as the integer 42 and the string "42".
+		message = fmt.Sprintf("%v", err)
+		event = client.EventFromMessage(message, client.options.PanicLevel)
+		event.Tags["panic.type"] = fmt.Sprintf("%T", err)
+	}
+	if event.Exception == nil {
+		// The message is kept as is, and the panic is reported as an
+		// exception as well, such that Sentry counts it as a crash.
+		event.Exception = []Exception{{
+			Type:       "panic",
+			Value:      message,
+			Stacktrace: stacktrace,
+		}}
+		event.setHandled(false)
+	}
+	if size := client.options.GoroutineDumpSize; size > 0 {
+		event.Attachments = append(event.Attachments, &Attachment{
+			Filename:    "goroutines.txt",
+			ContentType: "text/plain",
+			Payload:     goroutineDump(size),
+		})
+	}
+	if client.options.GroupPanicsByType {
+		event.Fingerprint = []string{
+			fmt.Sprintf("%T", err),
+			panicMessageNumbers.ReplaceAllString(message, "*"),
+		}
 	}
 	return client.CaptureEvent(event, hint, scope)
 }
 
+// panicMessageNumbers matches the parts of panic messages that differ between
+// occurrences of the same panic, such as indexes and addresses, see
+// ClientOptions.GroupPanicsByType.
+var panicMessageNumbers = regexp.MustCompile(`0x[[:xdigit:]]+|[0-9]+`)
+
 // Flush waits until the underlying Transport sends any buffered events to the
 // Sentry server, blocking for at most the given timeout. It returns false if
 // the timeout was reached. In that case, some events may not have been sent.
+// Structured logs buffered by the client are handed over to the Transport
+// first, see NewLogger.
 //
 // Flush should be called before terminating the program to avoid
 // unintentionally dropping events.
@@ -485,9 +1207,69 @@ func (client *Client) RecoverWithContext(
 // the network synchronously, configure it to use the HTTPSyncTransport in the
 // call to Init.
 func (client *Client) Flush(timeout time.Duration) bool {
+	if client.logs != nil {
+		client.logs.flush()
+	}
 	return client.Transport.Flush(timeout)
 }
 
+// FlushWithContext works like Flush, but waits until ctx is done instead of a
+// fixed timeout. It returns true only if all buffered events were sent before
+// ctx was done.
+//
+// Transports that do not implement a FlushWithContext method of their own are
+// flushed repeatedly with a short timeout, until their Flush method returns
+// true or ctx is done.
+func (client *Client) FlushWithContext(ctx context.Context) bool {
+	if client.logs != nil {
+		client.logs.flush()
+	}
+	return flushTransport(ctx, client.Transport)
+}
+
+// flushTransport flushes transport until ctx is done, with its
+// FlushWithContext method if it has one. See Client.FlushWithContext.
+//
+// Other transports are flushed in rounds of at most flushPollInterval, until
+// Flush returns true or ctx is done, such that no goroutine is left blocked in
+// Flush once flushTransport returns.
+func flushTransport(ctx context.Context, transport Transport) bool {
+	if t, ok := transport.(interface {
+		FlushWithContext(ctx context.Context) bool
+	}); ok {
+		return t.FlushWithContext(ctx)
+	}
+	for ctx.Err() == nil {
+		timeout := flushPollInterval
+		if deadline, ok := ctx.Deadline(); ok {
+			if remaining := time.Until(deadline); remaining < timeout {
+				timeout = remaining
+			}
+		}
+		if transport.Flush(timeout) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close flushes any buffered events, waiting until ctx is done, and then
+// releases the resources held by the underlying Transport, if it has a Close
+// method. It returns false if ctx was done before all events were sent.
+//
+// Close should be called when the client is no longer needed, for example
+// during the graceful shutdown of a server. After Close, the client drops all
+// events instead of sending them, so calls such as CaptureException are no-ops.
+func (client *Client) Close(ctx context.Context) bool {
+	atomic.StoreInt32(&client.closed, 1)
+
+	ok := client.FlushWithContext(ctx)
+	if t, isCloser := client.Transport.(interface{ Close() }); isCloser {
+		t.Close()
+	}
+	return ok
+}
+
 // EventFromMessage creates an event from the given message string.
 func (client *Client) EventFromMessage(message string, level Level) *Event {
 	if message == "" {
@@ -538,36 +1320,100 @@ func (client *Client) processEvent(event *Event, hint *EventHint, scope EventMod
 		return client.CaptureException(err, hint, scope)
 	}
 
+	if atomic.LoadInt32(&client.closed) == 1 {
+		Logger.Println("Event dropped due to the client being closed.")
+		return nil
+	}
+
 	// Transactions are sampled by options.TracesSampleRate or
 	// options.TracesSampler when they are started. All other events
 	// (errors, messages) are sampled here.
-	if event.Type != transactionType && !sample(client.options.SampleRate) {
+	if event.Type != transactionType && client.options.ErrorSampler == nil &&
+		!client.options.sample(client.options.SampleRate) {
 		Logger.Println("Event dropped due to SampleRate hit.")
+		client.discarded.record(discardReasonSampleRate, categoryFor(event.Type))
+		return nil
+	}
+
+	if event.Type == transactionType && client.transactionLimiter != nil && !client.transactionLimiter.allow() {
+		Logger.Println("Transaction dropped due to MaxTransactionsPerSecond.")
+		client.discarded.record(discardReasonRateLimitBackoff, categoryFor(event.Type))
 		return nil
 	}
 
 	if event = client.prepareEvent(event, hint, scope); event == nil {
 		return nil
 	}
+	// category is computed upfront because BeforeSend* may return nil.
+	category := categoryFor(event.Type)
 
-	// Apply beforeSend* processors
 	if hint == nil {
 		hint = &EventHint{}
 	}
+	if event.Type != transactionType && client.options.ErrorSampler != nil {
+		rate := client.options.ErrorSampler(event, hint)
+		if rate < 0.0 || rate > 1.0 {
+			Logger.Printf("Event dropped: ErrorSampler rate out of range [0.0, 1.0]: %f", rate)
+			client.discarded.record(discardReasonSampleRate, category)
+			return nil
+		}
+		if !client.options.sample(rate) {
+			Logger.Println("Event dropped due to ErrorSampler hit.")
+			client.discarded.record(discardReasonSampleRate, category)
+			return nil
+		}
+	}
+
+	// Apply beforeSend* processors
 	if event.Type == transactionType && client.options.BeforeSendTransaction != nil {
 		// Transaction events
-		if event = client.options.BeforeSendTransaction(event, hint); event == nil {
+		if event = client.callBeforeSend("BeforeSendTransaction", client.options.BeforeSendTransaction, event, hint); event == nil {
 			Logger.Println("Transaction dropped due to BeforeSendTransaction callback.")
+			client.discarded.record(discardReasonBeforeSend, category)
 			return nil
 		}
 	} else if event.Type != transactionType && client.options.BeforeSend != nil {
 		// All other events
-		if event = client.options.BeforeSend(event, hint); event == nil {
+		if event = client.callBeforeSend("BeforeSend", client.options.BeforeSend, event, hint); event == nil {
 			Logger.Println("Event dropped due to BeforeSend callback.")
+			client.discarded.record(discardReasonBeforeSend, category)
+			return nil
+		}
+	}
+
+	if event.Type != transactionType && client.options.MinLevel != "" &&
+		event.Level.severity() >= 0 && event.Level.severity() < client.options.MinLevel.severity() {
+		Logger.Printf("Event dropped due to its level %q below MinLevel.", event.Level)
+		client.discarded.record(discardReasonEventProcessor, category)
+		return nil
+	}
+
+	if max := client.options.MaxValueDepth; max > 0 {
+		normalizeValues(event, max)
+	}
+
+	if len(client.options.DataScrubbers) > 0 {
+		scrubber(client.options.DataScrubbers).scrubEvent(event)
+	}
+
+	if client.options.ScrubQueryString {
+		scrubQueryStrings(event)
+	}
+
+	if max := client.options.MaxValueLength; max > 0 {
+		truncateValues(event, max)
+	}
+
+	if max := client.options.MaxEventSize; max > 0 {
+		if event = limitEventSize(event, max); event == nil {
 			return nil
 		}
 	}
 
+	if client.options.EnvelopeHeaders != nil {
+		event.sdkMetaData.envelopeHeaders = client.options.EnvelopeHeaders(event)
+	}
+
 	client.Transport.SendEvent(event)
 
 	return &event.EventID
@@ -618,18 +1464,64 @@ func (client *Client) prepareEvent(event *Event, hint *EventHint, scope EventMod
 		}},
 	}
 
+	// category is computed upfront because event processors may return nil.
+	category := categoryFor(event.Type)
+
+	if client.options.ContextTags != nil && hint != nil && hint.Context != nil {
+		for key, value := range client.options.ContextTags(hint.Context) {
+			if _, ok := event.Tags[key]; ok {
+				continue
+			}
+			if event.Tags == nil {
+				event.Tags = make(map[string]string)
+			}
+			event.Tags[key] = value
+		}
+	}
+
 	if scope != nil {
 		event = scope.ApplyToEvent(event, hint)
 		if event == nil {
+			client.discarded.record(discardReasonEventProcessor, category)
 			return nil
 		}
 	}
 
+	// Tags and contexts of the options are applied after the scope, only
+	// filling in what neither the event nor the scope set.
+	for key, value := range client.options.Tags {
+		if _, ok := event.Tags[key]; ok {
+			continue
+		}
+		if event.Tags == nil {
+			event.Tags = make(map[string]string, len(client.options.Tags))
+		}
+		event.Tags[key] = value
+	}
+
+	for key, context := range client.options.Contexts {
+		if event.Contexts == nil {
+			event.Contexts = make(map[string]Context, len(client.options.Contexts))
+		}
+		// The contexts of the options are shared by all events, so they are
+		// copied into a new context, with the fields of the event and scope
+		// on top.
+		merged := make(Context, len(context)+len(event.Contexts[key]))
+		for k, v := range context {
+			merged[k] = v
+		}
+		for k, v := range event.Contexts[key] {
+			merged[k] = v
+		}
+		event.Contexts[key] = merged
+	}
+
 	for _, processor := range client.eventProcessors {
 		id := event.EventID
 		event = processor(event, hint)
 		if event == nil {
 			Logger.Printf("Event dropped by one of the Client EventProcessors: %s\n", id)
+			client.discarded.record(discardReasonEventProcessor, category)
 			return nil
 		}
 	}
@@ -639,10 +1531,16 @@ func (client *Client) prepareEvent(event *Event, hint *EventHint, scope EventMod
 		event = processor(event, hint)
 		if event == nil {
 			Logger.Printf("Event dropped by one of the Global EventProcessors: %s\n", id)
+			client.discarded.record(discardReasonEventProcessor, category)
 			return nil
 		}
 	}
 
+	client.applyDefaultPII(event)
+	if event.Request != nil && client.options.OmitRequestFields != 0 {
+		event.Request = event.Request.omit(client.options.OmitRequestFields)
+	}
+
 	if event.sdkMetaData.transactionProfile != nil {
 		event.sdkMetaData.transactionProfile.UpdateFromEvent(event)
 	}
@@ -650,7 +1548,321 @@ func (client *Client) prepareEvent(event *Event, hint *EventHint, scope EventMod
 	return event
 }
 
-func (client Client) listIntegrations() []string {
+// applyDefaultPII enforces the SendDefaultPII option on event. Unless it is
+// enabled, the IP address of the user and the request cookies, headers and
+// environment that identify users are removed, even if they were set
+// explicitly. Otherwise, the IP address of the user defaults to the address of
+// the client that made the request, if any.
+func (client *Client) applyDefaultPII(event *Event) {
+	if client.options.SendDefaultPII {
+		if event.User.IPAddress == "" && event.Request != nil {
+			event.User.IPAddress = requestIPAddress(event.Request)
+		}
+		return
+	}
+
+	event.User.IPAddress = ""
+	if event.Request == nil {
+		return
+	}
+	// The request may be shared with the scope, so modify a copy.
+	request := *event.Request
+	request.Cookies = ""
+	sensitiveHeaders := getSensitiveHeaders()
+	request.Headers = make(map[string]string, len(event.Request.Headers))
+	for k, v := range event.Request.Headers {
+		if !sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			request.Headers[k] = v
+		}
+	}
+	if _, ok := request.Env["REMOTE_ADDR"]; ok {
+		request.Env = make(map[string]string, len(event.Request.Env))
+		for k, v := range event.Request.Env {
+			if k != "REMOTE_ADDR" && k != "REMOTE_PORT" {
+				request.Env[k] = v
+			}
+		}
+	}
+	event.Request = &request
+}
+
+// limitEventSize returns event, or a trimmed copy of it if it serializes to
+// more than max bytes, see ClientOptions.MaxEventSize. The serialized event is
+// kept for the transports, to not serialize it twice. Events that cannot be
+// serialized are returned as is, for the transports to report the error.
+//
+// It returns nil if the event is still too large once trimmed.
+func limitEventSize(event *Event, max int) *Event {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return event
+	}
+	if len(body) > max {
+		if event, body = truncateEvent(event, body, max); event == nil {
+			return nil
+		}
+	}
+	event.sdkMetaData.body = body
+	return event
+}
+
+// truncateEvent trims a copy of event until it serializes to at most maxSize
+// bytes, leaving event and the values it shares with the scope untouched. The
+// largest parts of the event are removed in order: breadcrumbs, oldest first,
+// then extra data, largest first, then the local variables of stack frames.
+// Events that got trimmed are tagged with truncatedTag.
+//
+// It returns the trimmed copy and its serialization, or nil if the event is
+// still too large once everything was trimmed.
+func truncateEvent(event *Event, body []byte, maxSize int) (*Event, []byte) {
+	originalSize := len(body)
+	c := *event
+	event = &c
+	fits := func() bool {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return false
+		}
+		body = b
+		return len(body) <= maxSize
+	}
+
+	tags := make(map[string]string, len(event.Tags)+1)
+	for k, v := range event.Tags {
+		tags[k] = v
+	}
+	tags[truncatedTag] = "true"
+	event.Tags = tags
+
+	for len(event.Breadcrumbs) > 0 {
+		event.Breadcrumbs = event.Breadcrumbs[len(event.Breadcrumbs)/2+len(event.Breadcrumbs)%2:]
+		if fits() {
+			return event, body
+		}
+	}
+
+	if len(event.Extra) > 0 {
+		keys := make([]string, 0, len(event.Extra))
+		sizes := make(map[string]int, len(event.Extra))
+		for k, v := range event.Extra {
+			b, _ := json.Marshal(v)
+			keys = append(keys, k)
+			sizes[k] = len(b)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if sizes[keys[i]] != sizes[keys[j]] {
+				return sizes[keys[i]] > sizes[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		extra := make(map[string]interface{}, len(event.Extra))
+		for k, v := range event.Extra {
+			extra[k] = v
+		}
+		event.Extra = extra
+		for _, k := range keys {
+			delete(event.Extra, k)
+			if fits() {
+				return event, body
+			}
+		}
+	}
+
+	trimmed := false
+	if len(event.Exception) > 0 {
+		exceptions := make([]Exception, len(event.Exception))
+		copy(exceptions, event.Exception)
+		for i := range exceptions {
+			if st, ok := withoutVars(exceptions[i].Stacktrace); ok {
+				exceptions[i].Stacktrace = st
+				trimmed = true
+			}
+		}
+		event.Exception = exceptions
+	}
+	if len(event.Threads) > 0 {
+		threads := make([]Thread, len(event.Threads))
+		copy(threads, event.Threads)
+		for i := range threads {
+			if st, ok := withoutVars(threads[i].Stacktrace); ok {
+				threads[i].Stacktrace = st
+				trimmed = true
+			}
+		}
+		event.Threads = threads
+	}
+	if trimmed && fits() {
+		return event, body
+	}
+
+	Logger.Printf("Event %s is too large to be sent (%d bytes, the limit is %d bytes), even with breadcrumbs, "+
+		"extra data and stack frame variables removed. Skipping delivery.", event.EventID, originalSize, maxSize)
+	return nil, nil
+}
+
+// withoutVars returns a copy of st without the local variables of its frames
+// and true, or st itself and false if no frame has variables.
+func withoutVars(st *Stacktrace) (*Stacktrace, bool) {
+	if st == nil {
+		return st, false
+	}
+	var frames []Frame
+	for i, f := range st.Frames {
+		if f.Vars == nil {
+			continue
+		}
+		if frames == nil {
+			frames = make([]Frame, len(st.Frames))
+			copy(frames, st.Frames)
+		}
+		frames[i].Vars = nil
+	}
+	if frames == nil {
+		return st, false
+	}
+	c := *st
+	c.Frames = frames
+	return &c, true
+}
+
+// truncateValues shortens the string values of event longer than max
+// characters: its message, tag values and the string values of its extra data
+// and breadcrumbs. Maps and breadcrumbs may be shared with the scope, so they
+// are copied before being modified. The event may still be referenced by the
+// caller of BeforeSend, so its fields are only assigned if a value was
+// shortened.
+func truncateValues(event *Event, max int) {
+	if message, ok := truncateString(event.Message, max); ok {
+		event.Message = message
+	}
+	if tags, ok := truncateStringMap(event.Tags, max); ok {
+		event.Tags = tags
+	}
+	if extra, ok := truncateInterfaceMap(event.Extra, max); ok {
+		event.Extra = extra
+	}
+
+	var breadcrumbs []*Breadcrumb
+	for i, b := range event.Breadcrumbs {
+		message, messageTruncated := truncateString(b.Message, max)
+		data, dataTruncated := truncateInterfaceMap(b.Data, max)
+		if !messageTruncated && !dataTruncated {
+			continue
+		}
+		if breadcrumbs == nil {
+			breadcrumbs = make([]*Breadcrumb, len(event.Breadcrumbs))
+			copy(breadcrumbs, event.Breadcrumbs)
+		}
+		c := *b
+		c.Message, c.Data = message, data
+		breadcrumbs[i] = &c
+	}
+	if breadcrumbs != nil {
+		event.Breadcrumbs = breadcrumbs
+	}
+}
+
+// truncateString returns s shortened to max characters, ending with
+// truncatedValueSuffix, and whether it was shortened.
+func truncateString(s string, max int) (string, bool) {
+	// Strings of at most max bytes have at most max characters.
+	if len(s) <= max || utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	keep := max - len(truncatedValueSuffix)
+	if keep < 0 {
+		keep = 0
+	}
+	n := 0
+	for i := range s {
+		if n == keep {
+			return s[:i] + truncatedValueSuffix, true
+		}
+		n++
+	}
+	return s, false
+}
+
+// truncateStringMap returns a copy of m with values shortened by
+// truncateString and true, or m itself and false if no value is too long.
+func truncateStringMap(m map[string]string, max int) (map[string]string, bool) {
+	var c map[string]string
+	for k, v := range m {
+		v, truncated := truncateString(v, max)
+		if !truncated {
+			continue
+		}
+		if c == nil {
+			c = make(map[string]string, len(m))
+			for k, v := range m {
+				c[k] = v
+			}
+		}
+		c[k] = v
+	}
+	if c == nil {
+		return m, false
+	}
+	return c, true
+}
+
+// truncateInterfaceMap is like truncateStringMap, for the string values of
+// m. Other values are left untouched.
+func truncateInterfaceMap(m map[string]interface{}, max int) (map[string]interface{}, bool) {
+	var c map[string]interface{}
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		s, truncated := truncateString(s, max)
+		if !truncated {
+			continue
+		}
+		if c == nil {
+			c = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				c[k] = v
+			}
+		}
+		c[k] = s
+	}
+	if c == nil {
+		return m, false
+	}
+	return c, true
+}
+
+// requestIPAddress returns the IP address of the client that made r, as
+// forwarded by proxies or as seen by the server, or the empty string if it is
+// unknown.
+func requestIPAddress(r *Request) string {
+	// The first address is the one of the client, others are the ones of
+	// proxies.
+	if addr := strings.TrimSpace(strings.Split(requestHeader(r, "X-Forwarded-For"), ",")[0]); addr != "" {
+		return addr
+	}
+	if addr := strings.TrimSpace(requestHeader(r, "X-Real-Ip")); addr != "" {
+		return addr
+	}
+	return r.Env["REMOTE_ADDR"]
+}
+
+// requestHeader returns the value of the header of r with the given canonical
+// name, matched case-insensitively, or the empty string.
+func requestHeader(r *Request, name string) string {
+	if v, ok := r.Headers[name]; ok {
+		return v
+	}
+	for k, v := range r.Headers {
+		if http.CanonicalHeaderKey(k) == name {
+			return v
+		}
+	}
+	return ""
+}
+
+func (client *Client) listIntegrations() []string {
 	integrations := make([]string, len(client.integrations))
 	for i, integration := range client.integrations {
 		integrations[i] = integration.Name()
@@ -658,7 +1870,7 @@ func (client Client) listIntegrations() []string {
 	return integrations
 }
 
-func (client Client) integrationAlreadyInstalled(name string) bool {
+func (client *Client) integrationAlreadyInstalled(name string) bool {
 	for _, integration := range client.integrations {
 		if integration.Name() == name {
 			return true
@@ -667,8 +1879,17 @@ func (client Client) integrationAlreadyInstalled(name string) bool {
 	return false
 }
 
+// random returns a random number in [0.0, 1.0) used for sampling decisions,
+// from options.SampleRand if set.
+func (options *ClientOptions) random() float64 {
+	if options.SampleRand != nil {
+		return options.SampleRand()
+	}
+	return rng.Float64()
+}
+
 // sample returns true with the given probability, which must be in the range
 // [0.0, 1.0].
-func sample(probability float64) bool {
-	return rng.Float64() < probability
+func (options *ClientOptions) sample(probability float64) bool {
+	return options.random() < probability
 }
@@ -0,0 +1,68 @@
+package sentry_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/getsentry/sentry-go/sentrytest"
+)
+
+func captureMessageHere(client *sentry.Client) {
+	client.CaptureMessage("here", nil, nil)
+}
+
+func TestCaptureMessageAttachStacktrace(t *testing.T) {
+	client, transport := sentrytest.NewClient(t, sentry.ClientOptions{
+		AttachStacktrace: true,
+	})
+	captureMessageHere(client)
+
+	if len(transport.Events()) != 1 {
+		t.Fatalf("got %d events, want 1", len(transport.Events()))
+	}
+	threads := transport.Events()[0].Threads
+	if len(threads) != 1 || !threads[0].Current || threads[0].Stacktrace == nil {
+		t.Fatalf("got threads %+v, want the current thread with a stacktrace", threads)
+	}
+	frames := threads[0].Stacktrace.Frames
+	if len(frames) == 0 {
+		t.Fatal("got an empty stacktrace")
+	}
+	// Frames are ordered from the outermost to the innermost call, the
+	// frames of the SDK are omitted.
+	last := frames[len(frames)-1]
+	if last.Function != "captureMessageHere" || last.Module != "github.com/getsentry/sentry-go_test" {
+		t.Errorf("innermost frame = %s.%s, want github.com/getsentry/sentry-go_test.captureMessageHere", last.Module, last.Function)
+	}
+	for _, frame := range frames {
+		if frame.Module == "github.com/getsentry/sentry-go" {
+			t.Errorf("unexpected SDK frame %s.%s", frame.Module, frame.Function)
+		}
+	}
+}
+
+// callersError records the program counters of its origin, exposed by its
+// Callers method.
+type callersError struct {
+	pcs []uintptr
+}
+
+func newCallersError() error {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(1, pcs)
+	return &callersError{pcs: pcs[:n]}
+}
+
+func (e *callersError) Error() string      { return "callers" }
+func (e *callersError) Callers() []uintptr { return e.pcs }
+
+func TestExtractStacktraceCallers(t *testing.T) {
+	stacktrace := sentry.ExtractStacktrace(newCallersError())
+	if stacktrace == nil || len(stacktrace.Frames) == 0 {
+		t.Fatalf("got stacktrace %v, want frames", stacktrace)
+	}
+	if got, want := stacktrace.Frames[len(stacktrace.Frames)-1].Function, "newCallersError"; got != want {
+		t.Errorf("most recent frame function = %q, want %q", got, want)
+	}
+}
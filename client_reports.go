@@ -0,0 +1,179 @@
+package sentry
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go/internal/ratelimit"
+)
+
+// A discardReason describes why the SDK dropped an event instead of sending
+// it to Sentry. The values match the reasons accepted in client reports.
+type discardReason string
+
+const (
+	// discardReasonRateLimitBackoff is the reason for events dropped because
+	// their category was rate limited by Sentry.
+	discardReasonRateLimitBackoff discardReason = "ratelimit_backoff"
+	// discardReasonSampleRate is the reason for events dropped because of
+	// ClientOptions.SampleRate.
+	discardReasonSampleRate discardReason = "sample_rate"
+	// discardReasonEventProcessor is the reason for events dropped by an
+	// event processor, including integrations such as IgnoreErrors, and by
+	// ClientOptions.MinLevel.
+	discardReasonEventProcessor discardReason = "event_processor"
+	// discardReasonBeforeSend is the reason for events dropped by
+	// ClientOptions.BeforeSend and ClientOptions.BeforeSendTransaction.
+	discardReasonBeforeSend discardReason = "before_send"
+	// discardReasonQueueOverflow is the reason for events dropped because
+	// the transport buffer was full.
+	discardReasonQueueOverflow discardReason = "queue_overflow"
+)
+
+// A discardedKey identifies a group of discarded events.
+type discardedKey struct {
+	reason   discardReason
+	category ratelimit.Category
+}
+
+// discardedEvents counts the events dropped by the SDK, grouped by reason and
+// category, to be reported to Sentry as client reports.
+//
+// The zero value is ready to use and discardedEvents is safe for concurrent
+// use.
+type discardedEvents struct {
+	mu     sync.Mutex
+	counts map[discardedKey]uint64
+}
+
+// record counts one event of the given category discarded for reason.
+func (d *discardedEvents) record(reason discardReason, category ratelimit.Category) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.counts == nil {
+		d.counts = make(map[discardedKey]uint64)
+	}
+	d.counts[discardedKey{reason: reason, category: category}]++
+}
+
+// take returns the counts recorded since the last call and resets them.
+func (d *discardedEvents) take() map[discardedKey]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	counts := d.counts
+	d.counts = nil
+	return counts
+}
+
+// merge adds counts, typically returned by take for a report that could not
+// be sent, to the recorded counts.
+func (d *discardedEvents) merge(counts map[discardedKey]uint64) {
+	if len(counts) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.counts == nil {
+		d.counts = make(map[discardedKey]uint64)
+	}
+	for k, n := range counts {
+		d.counts[k] += n
+	}
+}
+
+// clientReportSource is implemented by the transports that send client
+// reports. The client calls it when it is created to have the events it
+// discards included in the reports of the transport.
+type clientReportSource interface {
+	setClientDiscardedEvents(d *discardedEvents)
+}
+
+// takeDiscardedEvents returns the counts recorded by all of d since their last
+// call, merged. Nil values of d are ignored.
+func takeDiscardedEvents(d ...*discardedEvents) map[discardedKey]uint64 {
+	var counts map[discardedKey]uint64
+	for _, d := range d {
+		if d == nil {
+			continue
+		}
+		for k, n := range d.take() {
+			if counts == nil {
+				counts = make(map[discardedKey]uint64)
+			}
+			counts[k] += n
+		}
+	}
+	return counts
+}
+
+// A clientReport is the payload of a client_report envelope item.
+type clientReport struct {
+	Timestamp       time.Time          `json:"timestamp"`
+	DiscardedEvents []discardedOutcome `json:"discarded_events"`
+}
+
+type discardedOutcome struct {
+	Reason   discardReason      `json:"reason"`
+	Category ratelimit.Category `json:"category"`
+	Quantity uint64             `json:"quantity"`
+}
+
+// newClientReport returns a report of counts, or nil if counts is empty.
+func newClientReport(counts map[discardedKey]uint64, timestamp time.Time) *clientReport {
+	if len(counts) == 0 {
+		return nil
+	}
+	report := &clientReport{Timestamp: timestamp}
+	for k, n := range counts {
+		report.DiscardedEvents = append(report.DiscardedEvents, discardedOutcome{
+			Reason:   k.reason,
+			Category: k.category,
+			Quantity: n,
+		})
+	}
+	sort.Slice(report.DiscardedEvents, func(i, j int) bool {
+		a, b := report.DiscardedEvents[i], report.DiscardedEvents[j]
+		if a.Reason != b.Reason {
+			return a.Reason < b.Reason
+		}
+		return a.Category < b.Category
+	})
+	return report
+}
+
+// encodeClientReport appends report as an item to an envelope being encoded
+// with enc. It does nothing if report is nil.
+func encodeClientReport(enc *json.Encoder, report *clientReport) error {
+	if report == nil {
+		return nil
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return encodeEnvelopeItem(enc, clientReportType, body)
+}
+
+// envelopeFromClientReport returns an envelope holding only report, sent when
+// there are no events to send it with.
+func envelopeFromClientReport(report *clientReport, dsn *Dsn, sentAt time.Time) (*bytes.Buffer, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	err := enc.Encode(struct {
+		SentAt time.Time `json:"sent_at"`
+		Dsn    string    `json:"dsn"`
+	}{
+		SentAt: sentAt,
+		Dsn:    dsn.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := encodeClientReport(enc, report); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
@@ -0,0 +1,218 @@
+package sentry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go/internal/ratelimit"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiscardedEvents(t *testing.T) {
+	var d discardedEvents
+
+	if got := d.take(); got != nil {
+		t.Fatalf("take() on zero value = %v, want nil", got)
+	}
+
+	d.record(discardReasonRateLimitBackoff, ratelimit.CategoryError)
+	d.record(discardReasonRateLimitBackoff, ratelimit.CategoryError)
+	d.record(discardReasonRateLimitBackoff, ratelimit.CategoryTransaction)
+
+	want := map[discardedKey]uint64{
+		{reason: discardReasonRateLimitBackoff, category: ratelimit.CategoryError}:       2,
+		{reason: discardReasonRateLimitBackoff, category: ratelimit.CategoryTransaction}: 1,
+	}
+	if diff := cmp.Diff(want, d.take(), cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("take() mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := d.take(); got != nil {
+		t.Errorf("take() after reset = %v, want nil", got)
+	}
+}
+
+// clientReportsFromEnvelope returns the counts of the client reports in a
+// serialized envelope.
+func clientReportsFromEnvelope(t *testing.T, envelope []byte) map[discardedKey]uint64 {
+	t.Helper()
+	var counts discardedEvents
+	s := bufio.NewScanner(bytes.NewReader(envelope))
+	s.Buffer(nil, len(envelope)+1)
+	for s.Scan() {
+		var header struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(s.Bytes(), &header) != nil || header.Type != clientReportType || !s.Scan() {
+			continue
+		}
+		var report clientReport
+		if err := json.Unmarshal(s.Bytes(), &report); err != nil {
+			t.Errorf("invalid client report %s: %v", s.Bytes(), err)
+			continue
+		}
+		for _, d := range report.DiscardedEvents {
+			for i := uint64(0); i < d.Quantity; i++ {
+				counts.record(d.Reason, d.Category)
+			}
+		}
+	}
+	return counts.take()
+}
+
+func TestNewClientReport(t *testing.T) {
+	if report := newClientReport(nil, time.Now()); report != nil {
+		t.Errorf("newClientReport(nil) = %v, want nil", report)
+	}
+
+	timestamp := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	report := newClientReport(map[discardedKey]uint64{
+		{reason: discardReasonSampleRate, category: ratelimit.CategoryError}:             3,
+		{reason: discardReasonBeforeSend, category: ratelimit.CategoryTransaction}:       1,
+		{reason: discardReasonBeforeSend, category: ratelimit.CategoryError}:             2,
+		{reason: discardReasonRateLimitBackoff, category: ratelimit.CategoryTransaction}: 4,
+	}, timestamp)
+
+	var b bytes.Buffer
+	if err := encodeClientReport(json.NewEncoder(&b), report); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"timestamp":"2023-05-01T12:00:00Z","discarded_events":[` +
+		`{"reason":"before_send","category":"error","quantity":2},` +
+		`{"reason":"before_send","category":"transaction","quantity":1},` +
+		`{"reason":"ratelimit_backoff","category":"transaction","quantity":4},` +
+		`{"reason":"sample_rate","category":"error","quantity":3}]}`
+	got := b.String()
+	want := fmt.Sprintf("{\"type\":\"client_report\",\"length\":%d}\n%s\n", len(body), body)
+	assertEqual(t, got, want)
+}
+
+func TestClientReportsSentWithEvents(t *testing.T) {
+	var mu sync.Mutex
+	var envelopes [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := requestBody(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		envelopes = append(envelopes, b)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	tests := map[string]Transport{
+		"AsyncTransport": NewHTTPTransport(),
+		"SyncTransport":  NewHTTPSyncTransport(),
+	}
+	for name, tr := range tests {
+		tr := tr
+		t.Run(name, func(t *testing.T) {
+			mu.Lock()
+			envelopes = nil
+			mu.Unlock()
+
+			client, err := NewClient(ClientOptions{
+				Dsn:       strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+				Transport: tr,
+				BeforeSend: func(event *Event, hint *EventHint) *Event {
+					if event.Message == "drop" {
+						return nil
+					}
+					return event
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.CaptureMessage("drop", nil, nil)
+			client.CaptureMessage("drop", nil, nil)
+			client.CaptureMessage("keep", nil, nil)
+			client.CaptureMessage("keep", nil, nil)
+			client.Flush(time.Second)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(envelopes) != 2 {
+				t.Fatalf("got %d envelopes, want 2", len(envelopes))
+			}
+			want := map[discardedKey]uint64{
+				{reason: discardReasonBeforeSend, category: ratelimit.CategoryError}: 2,
+			}
+			if diff := cmp.Diff(want, clientReportsFromEnvelope(t, envelopes[0]), cmp.AllowUnexported(discardedKey{})); diff != "" {
+				t.Errorf("client report mismatch (-want +got):\n%s", diff)
+			}
+			// Counts are only reported once.
+			if got := clientReportsFromEnvelope(t, envelopes[1]); got != nil {
+				t.Errorf("got client report %v in second envelope, want none", got)
+			}
+		})
+	}
+}
+
+func TestHTTPTransportFlushSendsClientReport(t *testing.T) {
+	var mu sync.Mutex
+	var envelopes [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := requestBody(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		envelopes = append(envelopes, b)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport()
+	client, err := NewClient(ClientOptions{
+		Dsn:        strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+		Transport:  tr,
+		SampleRate: 1,
+		BeforeSend: func(event *Event, hint *EventHint) *Event { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.CaptureMessage("drop", nil, nil)
+	if !client.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+	// Nothing left to report.
+	if !client.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(envelopes) != 1 {
+		t.Fatalf("got %d envelopes, want 1", len(envelopes))
+	}
+	want := map[discardedKey]uint64{
+		{reason: discardReasonBeforeSend, category: ratelimit.CategoryError}: 1,
+	}
+	if diff := cmp.Diff(want, clientReportsFromEnvelope(t, envelopes[0]), cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("client report mismatch (-want +got):\n%s", diff)
+	}
+}
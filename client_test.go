@@ -5,11 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/getsentry/sentry-go/internal/ratelimit"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	pkgErrors "github.com/pkg/errors"
@@ -118,6 +122,26 @@ func (e wrappedError) Unwrap() error {
 	return e.original
 }
 
+type joinedError struct{ errs []error }
+
+func (e joinedError) Error() string {
+	return "joined"
+}
+
+func (e joinedError) Unwrap() []error {
+	return e.errs
+}
+
+func cyclicError() error {
+	err := &customErrWithCause{}
+	err.cause = &customErrWithCause{cause: err}
+	return err
+}
+
+func intPtr(i int) *int { return &i }
+
+func boolPtr(b bool) *bool { return &b }
+
 type captureExceptionTestGroup struct {
 	name  string
 	tests []captureExceptionTest
@@ -139,6 +163,7 @@ func TestCaptureException(t *testing.T) {
 					Type:       "sentry.usageError",
 					Value:      "CaptureException called with nil error",
 					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
 				},
 			},
 		},
@@ -150,6 +175,7 @@ func TestCaptureException(t *testing.T) {
 					Type:       "*errors.errorString",
 					Value:      "custom error",
 					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
 				},
 			},
 		},
@@ -171,6 +197,7 @@ func TestCaptureException(t *testing.T) {
 					Type:       "*errors.withStack",
 					Value:      "wat",
 					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
 				},
 			},
 		},
@@ -182,6 +209,7 @@ func TestCaptureException(t *testing.T) {
 					Type:       "*sentry.customErrWithCause",
 					Value:      "err",
 					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
 				},
 			},
 		},
@@ -197,6 +225,7 @@ func TestCaptureException(t *testing.T) {
 					Type:       "*sentry.customErrWithCause",
 					Value:      "err",
 					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
 				},
 			},
 		},
@@ -212,6 +241,71 @@ func TestCaptureException(t *testing.T) {
 					Type:       "sentry.wrappedError",
 					Value:      "wrapped: original",
 					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
+				},
+			},
+		},
+		{
+			name: "Cycle",
+			err:  cyclicError(),
+			want: []Exception{
+				{
+					Type:  "*sentry.customErrWithCause",
+					Value: "err",
+				},
+				{
+					Type:       "*sentry.customErrWithCause",
+					Value:      "err",
+					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
+				},
+			},
+		},
+		{
+			name: "JoinedErrors",
+			err: joinedError{errs: []error{
+				errors.New("first"),
+				wrappedError{original: errors.New("second")},
+			}},
+			want: []Exception{
+				{
+					Type:  "*errors.errorString",
+					Value: "second",
+					Mechanism: &Mechanism{
+						Type:        "chained",
+						ExceptionID: 3,
+						ParentID:    intPtr(2),
+					},
+				},
+				{
+					Type:  "sentry.wrappedError",
+					Value: "wrapped: second",
+					Mechanism: &Mechanism{
+						Type:        "chained",
+						ExceptionID: 2,
+						ParentID:    intPtr(0),
+						Source:      "errors[1]",
+					},
+				},
+				{
+					Type:  "*errors.errorString",
+					Value: "first",
+					Mechanism: &Mechanism{
+						Type:        "chained",
+						ExceptionID: 1,
+						ParentID:    intPtr(0),
+						Source:      "errors[0]",
+					},
+				},
+				{
+					Type:       "sentry.joinedError",
+					Value:      "joined",
+					Stacktrace: &Stacktrace{Frames: []Frame{}},
+					Mechanism: &Mechanism{
+						Type:             "generic",
+						Handled:          boolPtr(true),
+						IsExceptionGroup: true,
+					},
 				},
 			},
 		},
@@ -300,6 +394,15 @@ func TestCaptureEventShouldSendEventWithMessage(t *testing.T) {
 	assertEqual(t, transport.lastEvent.Message, "event message")
 }
 
+func TestCaptureEventKeepsTimestamp(t *testing.T) {
+	client, scope, transport := setupClientTest()
+	timestamp := time.Date(2023, 5, 4, 3, 2, 1, 0, time.UTC)
+	event := NewEvent()
+	event.Timestamp = timestamp
+	client.CaptureEvent(event, nil, scope)
+	assertEqual(t, transport.lastEvent.Timestamp, timestamp)
+}
+
 func TestCaptureEventNil(t *testing.T) {
 	client, scope, transport := setupClientTest()
 	client.CaptureEvent(nil, nil, scope)
@@ -309,6 +412,7 @@ func TestCaptureEventNil(t *testing.T) {
 				Type:       "sentry.usageError",
 				Value:      "CaptureEvent called with nil event",
 				Stacktrace: &Stacktrace{Frames: []Frame{}},
+				Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(true)},
 			},
 		},
 	}
@@ -368,6 +472,56 @@ func TestBeforeSendCanDropEvent(t *testing.T) {
 	}
 }
 
+func TestBeforeSendPanic(t *testing.T) {
+	for _, drop := range []bool{false, true} {
+		client, scope, transport := setupClientTest()
+		client.options.DropOnCallbackPanic = drop
+		client.options.BeforeSend = func(event *Event, hint *EventHint) *Event {
+			event.Tags["before_send"] = "called"
+			var request *Request
+			event.Message = request.URL
+			return event
+		}
+
+		client.CaptureMessage("Foo", nil, scope)
+
+		if drop {
+			if transport.lastEvent != nil {
+				t.Error("expected event to be dropped")
+			}
+			continue
+		}
+		assertEqual(t, transport.lastEvent.Message, "Foo")
+		assertEqual(t, transport.lastEvent.Tags["before_send"], "called")
+	}
+}
+
+func TestBeforeSendTransactionPanic(t *testing.T) {
+	for _, drop := range []bool{false, true} {
+		transport := &TransportMock{}
+		ctx := NewTestContext(ClientOptions{
+			EnableTracing:       true,
+			TracesSampleRate:    1.0,
+			Transport:           transport,
+			DropOnCallbackPanic: drop,
+			BeforeSendTransaction: func(event *Event, hint *EventHint) *Event {
+				panic("BeforeSendTransaction bug")
+			},
+		})
+
+		transaction := StartTransaction(ctx, "Foo")
+		transaction.Finish()
+
+		if drop {
+			if transport.lastEvent != nil {
+				t.Error("expected transaction to be dropped")
+			}
+			continue
+		}
+		assertEqual(t, transport.lastEvent.Transaction, "Foo")
+	}
+}
+
 func TestBeforeSendGetAccessToEventHint(t *testing.T) {
 	client, scope, transport := setupClientTest()
 	client.options.BeforeSend = func(event *Event, hint *EventHint) *Event {
@@ -437,6 +591,33 @@ func TestBeforeSendTransactionIsCalled(t *testing.T) {
 	assertEqual(t, lastEvent.Contexts["trace"]["span_id"], transaction.SpanID)
 }
 
+func TestBeforeSendTransactionOnlySampledTransactions(t *testing.T) {
+	transport := &TransportMock{}
+	var called int
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		TracesSampler: func(ctx SamplingContext) float64 {
+			if ctx.Span.Name == "unsampled" {
+				return 0
+			}
+			return 1
+		},
+		Transport: transport,
+		BeforeSendTransaction: func(event *Event, hint *EventHint) *Event {
+			called++
+			return event
+		},
+	})
+
+	StartTransaction(ctx, "unsampled").Finish()
+	hubFromContext(ctx).CaptureMessage("not a transaction")
+	assertEqual(t, called, 0)
+
+	StartTransaction(ctx, "sampled").Finish()
+	assertEqual(t, called, 1)
+}
+
 func TestSampleRate(t *testing.T) {
 	tests := []struct {
 		SampleRate float64
@@ -465,7 +646,7 @@ func TestSampleRate(t *testing.T) {
 					defer wg.Done()
 					for j := 0; j < 10000; j++ {
 						atomic.AddUint64(&total, 1)
-						s := sample(tt.SampleRate)
+						s := (&ClientOptions{}).sample(tt.SampleRate)
 						switch tt.SampleRate {
 						case 0:
 							if s {
@@ -504,6 +685,93 @@ func BenchmarkProcessEvent(b *testing.B) {
 	}
 }
 
+func newDisabledHub(tb testing.TB) *Hub {
+	tb.Setenv("SENTRY_DSN", "")
+	client, err := NewClient(ClientOptions{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return NewHub(client, NewScope())
+}
+
+func TestDisabledClient(t *testing.T) {
+	hub := newDisabledHub(t)
+
+	if id := hub.CaptureMessage("message"); id != nil {
+		t.Errorf("CaptureMessage() = %v, want nil", *id)
+	}
+	if id := hub.CaptureException(errors.New("error")); id != nil {
+		t.Errorf("CaptureException() = %v, want nil", *id)
+	}
+	hub.AddBreadcrumb(&Breadcrumb{Message: "breadcrumb"}, nil)
+	if n := len(hub.Scope().breadcrumbs); n != 0 {
+		t.Errorf("got %d breadcrumbs, want none", n)
+	}
+	span := StartSpan(SetHubOnContext(context.Background(), hub), "op")
+	span.Finish()
+	if _, ok := hub.Scope().contexts["trace"]; ok {
+		t.Error("got a trace context on the scope, want none")
+	}
+
+	// Clients with callbacks observing events are not disabled.
+	var sent int
+	client, err := NewClient(ClientOptions{
+		BeforeSend: func(event *Event, hint *EventHint) *Event {
+			sent++
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := client.CaptureMessage("message", nil, nil); id == nil || sent != 1 {
+		t.Errorf("CaptureMessage() = %v with %d calls to BeforeSend, want an ID and 1 call", id, sent)
+	}
+}
+
+func BenchmarkDisabledClient(b *testing.B) {
+	hub := newDisabledHub(b)
+	ctx := SetHubOnContext(context.Background(), hub)
+	err := errors.New("error")
+	breadcrumb := &Breadcrumb{Message: "breadcrumb"}
+
+	b.Run("CaptureMessage", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hub.CaptureMessage("message")
+		}
+	})
+	b.Run("CaptureException", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hub.CaptureException(err)
+		}
+	})
+	b.Run("AddBreadcrumb", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hub.AddBreadcrumb(breadcrumb, nil)
+		}
+	})
+	b.Run("StartSpan", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			StartSpan(ctx, "op").Finish()
+		}
+	})
+}
+
+// panicException returns the unhandled exception reported along with the
+// message of a panic with a value other than an error.
+func panicException(message string) []Exception {
+	return []Exception{{
+		Type:       "panic",
+		Value:      message,
+		Stacktrace: &Stacktrace{Frames: []Frame{}},
+		Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(false)},
+	}}
+}
+
 func TestRecover(t *testing.T) {
 	tests := []struct {
 		v    interface{} // for panic(v)
@@ -517,15 +785,17 @@ func TestRecover(t *testing.T) {
 						Type:       "*errors.errorString",
 						Value:      "panic error",
 						Stacktrace: &Stacktrace{Frames: []Frame{}},
+						Mechanism:  &Mechanism{Type: "generic", Handled: boolPtr(false)},
 					},
 				},
 			},
 		},
-		{"panic string", &Event{Message: "panic string"}},
-		// Arbitrary types should be converted to string:
-		{101010, &Event{Message: "101010"}},
-		{[]string{"", "", "hello"}, &Event{Message: `[]string{"", "", "hello"}`}},
-		{&struct{ Field string }{"test"}, &Event{Message: `&struct { Field string }{Field:"test"}`}},
+		{"panic string", &Event{Message: "panic string", Exception: panicException("panic string")}},
+		// Arbitrary types should be converted to string and tagged with
+		// their type:
+		{101010, &Event{Message: "101010", Exception: panicException("101010"), Tags: map[string]string{"panic.type": "int"}}},
+		{[]string{"", "", "hello"}, &Event{Message: "[  hello]", Exception: panicException("[  hello]"), Tags: map[string]string{"panic.type": "[]string"}}},
+		{&struct{ Field string }{"test"}, &Event{Message: "&{test}", Exception: panicException("&{test}"), Tags: map[string]string{"panic.type": "*struct { Field string }"}}},
 	}
 	checkEvent := func(t *testing.T, events []*Event, want *Event) {
 		t.Helper()
@@ -544,8 +814,10 @@ func TestRecover(t *testing.T) {
 					Message:   e.Message,
 					Exception: e.Exception,
 					Level:     e.Level,
+					Tags:      e.Tags,
 				}
 			}),
+			cmpopts.EquateEmpty(),
 		}
 
 		if diff := cmp.Diff(want, got, opts); diff != "" {
@@ -586,16 +858,1242 @@ func TestRecover(t *testing.T) {
 	}
 }
 
-func TestCustomMaxSpansProperty(t *testing.T) {
-	client, _, _ := setupClientTest()
-	assertEqual(t, client.Options().MaxSpans, defaultMaxSpans)
+func TestRecoverPanicLevel(t *testing.T) {
+	for _, level := range []Level{"", LevelError} {
+		client, transport := newClientWithTransportMock(t, ClientOptions{PanicLevel: level})
+		for _, err := range []interface{}{errors.New("error"), "message", 42} {
+			func() {
+				defer client.Recover(nil, nil, NewScope())
+				panic(err)
+			}()
+			want := level
+			if want == "" {
+				want = LevelFatal
+			}
+			assertEqual(t, transport.lastEvent.Level, want)
+		}
+	}
 
-	client.options.MaxSpans = 2000
-	assertEqual(t, client.Options().MaxSpans, 2000)
+	if _, err := NewClient(ClientOptions{PanicLevel: "critical"}); err == nil {
+		t.Error("NewClient() with an invalid PanicLevel succeeded")
+	}
+}
 
-	properClient, _ := NewClient(ClientOptions{
-		MaxSpans: 3000,
+func TestRecoverGoroutineDump(t *testing.T) {
+	for _, size := range []int{0, 64, 1 << 20} {
+		client, transport := newClientWithTransportMock(t, ClientOptions{GoroutineDumpSize: size})
+		blocked := make(chan struct{})
+		defer close(blocked)
+		go func() { <-blocked }()
+
+		func() {
+			defer client.Recover(nil, nil, NewScope())
+			panic("deadlock")
+		}()
+
+		attachments := transport.lastEvent.Attachments
+		if size == 0 {
+			assertEqual(t, len(attachments), 0)
+			continue
+		}
+		if len(attachments) != 1 {
+			t.Fatalf("got %d attachments, want 1", len(attachments))
+		}
+		dump := attachments[0]
+		assertEqual(t, dump.Filename, "goroutines.txt")
+		if len(dump.Payload) == 0 || len(dump.Payload) > size {
+			t.Errorf("got a dump of %d bytes, want at most %d", len(dump.Payload), size)
+		}
+		if size > 64 && !strings.Contains(string(dump.Payload), "TestRecoverGoroutineDump.func1") {
+			t.Errorf("dump does not contain the other goroutine:\n%s", dump.Payload)
+		}
+	}
+}
+
+func TestRecoverGroupPanicsByType(t *testing.T) {
+	tests := []struct {
+		options ClientOptions
+		panic   func()
+		want    []string
+	}{
+		{
+			options: ClientOptions{GroupPanicsByType: true},
+			panic: func() {
+				var s []int
+				i := 5
+				_ = s[i]
+			},
+			want: []string{"runtime.boundsError", "runtime error: index out of range [*] with length *"},
+		},
+		{
+			options: ClientOptions{GroupPanicsByType: true},
+			panic:   func() { panic("order 1234 not found") },
+			want:    []string{"string", "order * not found"},
+		},
+		{
+			options: ClientOptions{},
+			panic:   func() { panic("order 1234 not found") },
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		transport := &TransportMock{}
+		tt.options.Transport = transport
+		client, err := NewClient(tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		func() {
+			defer client.Recover(nil, nil, NewScope())
+			tt.panic()
+		}()
+		assertEqual(t, transport.lastEvent.Fingerprint, tt.want)
+	}
+}
+
+func TestBeforeSendHint(t *testing.T) {
+	var hints []*EventHint
+	client, err := NewClient(ClientOptions{
+		Transport: &TransportMock{},
+		BeforeSend: func(event *Event, hint *EventHint) *Event {
+			hints = append(hints, hint)
+			return event
+		},
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exception := errors.New("failed")
 
-	assertEqual(t, properClient.Options().MaxSpans, 3000)
+	client.CaptureException(exception, nil, nil)
+	func() {
+		defer client.Recover(nil, nil, nil)
+		panic("boom")
+	}()
+
+	if len(hints) != 2 {
+		t.Fatalf("got %d calls to BeforeSend, want 2", len(hints))
+	}
+	if hints[0].OriginalException != exception {
+		t.Errorf("OriginalException = %v, want %v", hints[0].OriginalException, exception)
+	}
+	if hints[1].RecoveredException != "boom" {
+		t.Errorf("RecoveredException = %v, want %q", hints[1].RecoveredException, "boom")
+	}
+}
+
+func TestContextTags(t *testing.T) {
+	type requestIDKey struct{}
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport: transport,
+		ContextTags: func(ctx context.Context) map[string]string {
+			id, _ := ctx.Value(requestIDKey{}).(string)
+			return map[string]string{"request_id": id, "tenant": "acme"}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r-42")
+
+	event := NewEvent()
+	event.Message = "tagged"
+	event.Tags["tenant"] = "other"
+	client.CaptureEvent(event, &EventHint{Context: ctx}, nil)
+	assertEqual(t, transport.lastEvent.Tags, map[string]string{"request_id": "r-42", "tenant": "other"})
+
+	client.CaptureMessage("no context", nil, nil)
+	assertEqual(t, transport.lastEvent.Tags, map[string]string{})
+}
+
+func TestRecoverRepanic(t *testing.T) {
+	for _, repanic := range []bool{false, true} {
+		repanic := repanic
+		t.Run(fmt.Sprintf("Repanic=%v", repanic), func(t *testing.T) {
+			client, _, transport := setupClientTest()
+			client.options.Repanic = repanic
+			currentHub.BindClient(client)
+			defer currentHub.stackTop().SetClient(nil)
+			ctx := SetHubOnContext(context.Background(), NewHub(client, NewScope()))
+
+			// Recover and RecoverWithContext must be deferred directly.
+			panickers := map[string]func(){
+				"Recover": func() {
+					defer Recover()
+					panic("Recover")
+				},
+				"RecoverWithContext": func() {
+					defer RecoverWithContext(ctx)
+					panic("RecoverWithContext")
+				},
+			}
+			for name, panicker := range panickers {
+				var recovered interface{}
+				func() {
+					defer func() { recovered = recover() }()
+					panicker()
+				}()
+				if repanic && recovered != name {
+					t.Errorf("%s: recovered %v, want a new panic with %q", name, recovered, name)
+				}
+				if !repanic && recovered != nil {
+					t.Errorf("%s: unexpected panic %v", name, recovered)
+				}
+			}
+			assertEqual(t, len(transport.Events()), 2)
+		})
+	}
+}
+
+func TestRequestIPAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+		want    string
+	}{
+		{
+			name: "ForwardedForAndRealIP",
+			request: &Request{
+				Headers: map[string]string{
+					"X-Forwarded-For": "203.0.113.1, 198.51.100.2",
+					"X-Real-Ip":       "198.51.100.2",
+				},
+				Env: map[string]string{"REMOTE_ADDR": "192.0.2.1"},
+			},
+			want: "203.0.113.1",
+		},
+		{
+			name: "RealIP",
+			request: &Request{
+				Headers: map[string]string{"x-real-ip": "198.51.100.2"},
+				Env:     map[string]string{"REMOTE_ADDR": "192.0.2.1"},
+			},
+			want: "198.51.100.2",
+		},
+		{
+			name:    "RemoteAddr",
+			request: &Request{Env: map[string]string{"REMOTE_ADDR": "192.0.2.1"}},
+			want:    "192.0.2.1",
+		},
+		{
+			name:    "Unknown",
+			request: &Request{},
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order is random, the result must not depend on it.
+			for i := 0; i < 10; i++ {
+				assertEqual(t, requestIPAddress(tt.request), tt.want)
+			}
+		})
+	}
+}
+
+func TestSendDefaultPII(t *testing.T) {
+	newRequest := func() *Request {
+		return &Request{
+			URL:     "https://example.com/",
+			Cookies: "session=secret",
+			Headers: map[string]string{
+				"Authorization":   "Bearer secret",
+				"X-Forwarded-For": "203.0.113.1, 198.51.100.2",
+				"Accept":          "*/*",
+			},
+			Env: map[string]string{"REMOTE_ADDR": "192.0.2.1", "REMOTE_PORT": "1234"},
+		}
+	}
+	tests := []struct {
+		name           string
+		sendDefaultPII bool
+		user           User
+		request        *Request
+		wantUser       User
+		wantRequest    *Request
+	}{
+		{
+			name:     "Disabled",
+			user:     User{ID: "1", IPAddress: "192.0.2.1"},
+			request:  newRequest(),
+			wantUser: User{ID: "1"},
+			wantRequest: &Request{
+				URL:     "https://example.com/",
+				Headers: map[string]string{"Accept": "*/*"},
+				Env:     map[string]string{},
+			},
+		},
+		{
+			name:           "EnabledForwardedFor",
+			sendDefaultPII: true,
+			user:           User{ID: "1"},
+			request:        newRequest(),
+			wantUser:       User{ID: "1", IPAddress: "203.0.113.1"},
+			wantRequest:    newRequest(),
+		},
+		{
+			name:           "EnabledRemoteAddr",
+			sendDefaultPII: true,
+			request:        &Request{Env: map[string]string{"REMOTE_ADDR": "192.0.2.1"}},
+			wantUser:       User{IPAddress: "192.0.2.1"},
+			wantRequest:    &Request{Env: map[string]string{"REMOTE_ADDR": "192.0.2.1"}},
+		},
+		{
+			name:           "EnabledExplicitIPAddress",
+			sendDefaultPII: true,
+			user:           User{IPAddress: "198.51.100.7"},
+			request:        newRequest(),
+			wantUser:       User{IPAddress: "198.51.100.7"},
+			wantRequest:    newRequest(),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client, scope, transport := setupClientTest()
+			client.options.SendDefaultPII = tt.sendDefaultPII
+			client.CaptureEvent(&Event{Message: "message", User: tt.user, Request: tt.request}, nil, scope)
+
+			event := transport.lastEvent
+			if diff := cmp.Diff(tt.wantUser, event.User); diff != "" {
+				t.Errorf("User mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantRequest, event.Request); diff != "" {
+				t.Errorf("Request mismatch (-want +got):\n%s", diff)
+			}
+			// The request may be shared with the scope and must be left
+			// untouched.
+			if diff := cmp.Diff(newRequest(), tt.request); !tt.sendDefaultPII && diff != "" {
+				t.Errorf("original Request was modified (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOmitRequestFields(t *testing.T) {
+	newRequest := func() *Request {
+		return &Request{
+			URL:         "https://example.com/orders",
+			Method:      "POST",
+			Data:        `{"id":1}`,
+			QueryString: "page=2",
+			Cookies:     "session=secret",
+			Headers:     map[string]string{"Accept": "*/*"},
+			Env:         map[string]string{"REMOTE_ADDR": "192.0.2.1", "REMOTE_PORT": "1234"},
+		}
+	}
+	tests := []struct {
+		name   string
+		fields RequestFields
+		want   *Request
+	}{
+		{name: "None", want: newRequest()},
+		{
+			name:   "QueryStringAndData",
+			fields: RequestQueryString | RequestData,
+			want: &Request{
+				URL:     "https://example.com/orders",
+				Method:  "POST",
+				Cookies: "session=secret",
+				Headers: map[string]string{"Accept": "*/*"},
+				Env:     map[string]string{"REMOTE_ADDR": "192.0.2.1", "REMOTE_PORT": "1234"},
+			},
+		},
+		{
+			name:   "All",
+			fields: RequestQueryString | RequestHeaders | RequestCookies | RequestEnv | RequestData,
+			want:   &Request{URL: "https://example.com/orders", Method: "POST"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client, scope, transport := setupClientTest()
+			client.options.SendDefaultPII = true
+			client.options.OmitRequestFields = tt.fields
+			request := newRequest()
+			client.CaptureEvent(&Event{Message: "message", Request: request}, nil, scope)
+
+			event := transport.lastEvent
+			if diff := cmp.Diff(tt.want, event.Request); diff != "" {
+				t.Errorf("Request mismatch (-want +got):\n%s", diff)
+			}
+			// The IP address is inferred before the environment is removed.
+			assertEqual(t, event.User.IPAddress, "192.0.2.1")
+			if diff := cmp.Diff(newRequest(), request); diff != "" {
+				t.Errorf("original Request was modified (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"", 5, ""},
+		{"hello", 5, "hello"},
+		{"hello world", 8, "hello..."},
+		// Lengths are counted in characters, not bytes.
+		{"héllo", 5, "héllo"},
+		{"héllo wörld", 8, "héllo..."},
+		{"hello", 2, "..."},
+	}
+	for _, tt := range tests {
+		if got, _ := truncateString(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestMaxValueLength(t *testing.T) {
+	client, _, transport := setupClientTest()
+	client.options.MaxValueLength = 10
+	long := strings.Repeat("x", 20)
+	truncated := strings.Repeat("x", 7) + "..."
+
+	scope := NewScope()
+	scope.SetTag("tag", long)
+	scope.SetExtra("extra", long)
+	scope.SetExtra("number", 42)
+	scope.AddBreadcrumb(&Breadcrumb{Message: long, Data: map[string]interface{}{"query": long}}, 10)
+	scope.AddBreadcrumb(&Breadcrumb{Message: "short"}, 10)
+	client.CaptureMessage(long, nil, scope)
+
+	event := transport.lastEvent
+	assertEqual(t, event.Message, truncated)
+	assertEqual(t, event.Tags["tag"], truncated)
+	assertEqual(t, event.Extra, map[string]interface{}{"extra": truncated, "number": 42})
+	assertEqual(t, event.Breadcrumbs[0].Message, truncated)
+	assertEqual(t, event.Breadcrumbs[0].Data, map[string]interface{}{"query": truncated})
+	assertEqual(t, event.Breadcrumbs[1].Message, "short")
+
+	// The scope is left untouched.
+	assertEqual(t, scope.breadcrumbs[0].Message, long)
+	assertEqual(t, scope.breadcrumbs[0].Data["query"], long)
+	assertEqual(t, scope.extra["extra"], long)
+
+	client.options.MaxValueLength = -1
+	client.CaptureMessage(long, nil, scope)
+	assertEqual(t, transport.lastEvent.Message, long)
+}
+
+func TestBeforeSendFingerprint(t *testing.T) {
+	tests := []struct {
+		name       string
+		beforeSend func(event *Event, hint *EventHint) *Event
+		want       []string
+	}{
+		{
+			name: "Replace",
+			beforeSend: func(event *Event, hint *EventHint) *Event {
+				event.Fingerprint = []string{"code", event.Exception[0].Value}
+				return event
+			},
+			want: []string{"code", "error"},
+		},
+		{
+			name: "Extend",
+			beforeSend: func(event *Event, hint *EventHint) *Event {
+				event.Fingerprint = append(event.Fingerprint, "extended")
+				return event
+			},
+			want: []string{"{{ default }}", "scope", "extended"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &TransportMock{}
+			client, err := NewClient(ClientOptions{
+				Transport: transport,
+				BeforeSend: func(event *Event, hint *EventHint) *Event {
+					// The scope fingerprint is applied before BeforeSend.
+					assertEqual(t, event.Fingerprint, []string{"{{ default }}", "scope"})
+					return tt.beforeSend(event, hint)
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			scope := NewScope()
+			scope.SetFingerprint([]string{"{{ default }}", "scope"})
+
+			client.CaptureException(errors.New("error"), nil, scope)
+
+			assertEqual(t, transport.lastEvent.Fingerprint, tt.want)
+			assertEqual(t, scope.fingerprint, []string{"{{ default }}", "scope"})
+		})
+	}
+}
+
+func TestCustomMaxSpansProperty(t *testing.T) {
+	client, _, _ := setupClientTest()
+	assertEqual(t, client.Options().MaxSpans, defaultMaxSpans)
+
+	client.options.MaxSpans = 2000
+	assertEqual(t, client.Options().MaxSpans, 2000)
+
+	properClient, _ := NewClient(ClientOptions{
+		MaxSpans: 3000,
+	})
+
+	assertEqual(t, properClient.Options().MaxSpans, 3000)
+}
+
+// slowFlushTransport is a Transport without a FlushWithContext method, whose
+// Flush blocks for the given timeout or until unblocked.
+type slowFlushTransport struct {
+	TransportMock
+	unblock chan struct{}
+	// flushing is the number of calls to Flush in progress.
+	flushing int32
+}
+
+func (t *slowFlushTransport) Flush(timeout time.Duration) bool {
+	atomic.AddInt32(&t.flushing, 1)
+	defer atomic.AddInt32(&t.flushing, -1)
+	select {
+	case <-t.unblock:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestFlushWithContext(t *testing.T) {
+	t.Run("Deadline", func(t *testing.T) {
+		transport := &slowFlushTransport{unblock: make(chan struct{})}
+		client, _ := NewClient(ClientOptions{Transport: transport})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assertEqual(t, client.FlushWithContext(ctx), false)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		transport := &slowFlushTransport{unblock: make(chan struct{})}
+		defer close(transport.unblock)
+		client, _ := NewClient(ClientOptions{Transport: transport})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assertEqual(t, client.FlushWithContext(ctx), false)
+	})
+
+	t.Run("CanceledWithoutDeadline", func(t *testing.T) {
+		transport := &slowFlushTransport{unblock: make(chan struct{})}
+		defer close(transport.unblock)
+		client, _ := NewClient(ClientOptions{Transport: transport})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		assertEqual(t, client.FlushWithContext(ctx), false)
+		if n := atomic.LoadInt32(&transport.flushing); n != 0 {
+			t.Errorf("got %d calls to Flush in progress after FlushWithContext returned, want 0", n)
+		}
+	})
+
+	t.Run("Done", func(t *testing.T) {
+		transport := &slowFlushTransport{unblock: make(chan struct{})}
+		close(transport.unblock)
+		client, _ := NewClient(ClientOptions{Transport: transport})
+
+		assertEqual(t, client.FlushWithContext(context.Background()), true)
+	})
+}
+
+func TestIgnoreErrorsInvalidPattern(t *testing.T) {
+	_, err := NewClient(ClientOptions{
+		IgnoreErrors: []string{"valid", "(invalid"},
+	})
+	if err == nil {
+		t.Fatal("expected an error for an invalid IgnoreErrors pattern")
+	}
+	if got, want := err.Error(), `invalid IgnoreErrors pattern "(invalid"`; !strings.HasPrefix(got, want) {
+		t.Errorf("got error %q, want prefix %q", got, want)
+	}
+}
+
+func TestIgnoreErrorsCountsDiscardedEvents(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport:    transport,
+		IgnoreErrors: []string{"context canceled"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.CaptureException(context.Canceled, nil, nil)
+	client.CaptureMessage("not ignored", nil, nil)
+
+	if got := len(transport.Events()); got != 1 {
+		t.Errorf("got %d events sent, want 1", got)
+	}
+	want := map[discardedKey]uint64{
+		{reason: discardReasonEventProcessor, category: ratelimit.CategoryError}: 1,
+	}
+	if diff := cmp.Diff(want, client.discarded.take(), cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("discarded events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMinLevel(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+		MinLevel:         LevelWarning,
+		BeforeSend: func(event *Event, hint *EventHint) *Event {
+			if event.Message == "raised" {
+				event.Level = LevelError
+			}
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(level Level, message string) {
+		event := NewEvent()
+		event.Level = level
+		event.Message = message
+		client.CaptureEvent(event, nil, nil)
+	}
+	send(LevelDebug, "debug")
+	send(LevelInfo, "info")
+	send(LevelInfo, "raised")
+	send(LevelWarning, "warning")
+	send(LevelFatal, "fatal")
+	send(Level("custom"), "custom")
+	client.CaptureEvent(&Event{Type: transactionType, Level: LevelInfo}, nil, nil)
+
+	var got []string
+	for _, event := range transport.Events() {
+		if event.Type == transactionType {
+			got = append(got, transactionType)
+			continue
+		}
+		got = append(got, event.Message)
+	}
+	assertEqual(t, got, []string{"raised", "warning", "fatal", "custom", transactionType})
+
+	want := map[discardedKey]uint64{
+		{reason: discardReasonEventProcessor, category: ratelimit.CategoryError}: 2,
+	}
+	if diff := cmp.Diff(want, client.discarded.take(), cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("discarded events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSampleRateCountsDiscardedEvents(t *testing.T) {
+	client, transport := newClientWithTransportMock(t, ClientOptions{SampleRate: 0.5})
+	for i := 0; i < 100; i++ {
+		client.CaptureMessage("sampled", nil, nil)
+	}
+
+	sent := len(transport.Events())
+	discarded := client.discarded.take()[discardedKey{reason: discardReasonSampleRate, category: ratelimit.CategoryError}]
+	if sent == 0 || discarded == 0 {
+		t.Errorf("sent %d and discarded %d events, want some of both", sent, discarded)
+	}
+	if sent+int(discarded) != 100 {
+		t.Errorf("sent %d and discarded %d events, want 100 in total", sent, discarded)
+	}
+}
+
+func TestSampleRateInvalid(t *testing.T) {
+	for _, rate := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, err := NewClient(ClientOptions{SampleRate: rate}); err == nil {
+			t.Errorf("SampleRate %v: expected an error", rate)
+		}
+	}
+}
+
+func TestMinLevelInvalid(t *testing.T) {
+	_, err := NewClient(ClientOptions{MinLevel: "warn"})
+	if err == nil {
+		t.Fatal("expected an error for an unknown MinLevel")
+	}
+}
+
+func TestNewClientInvalidDsn(t *testing.T) {
+	_, err := NewClient(ClientOptions{Dsn: "https://sentry.io/42"})
+	if !errors.Is(err, ErrDsnMissingPublicKey) {
+		t.Fatalf("got error %v, want %v", err, ErrDsnMissingPublicKey)
+	}
+	var parseErr *DsnParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("got error %T, want %T", err, parseErr)
+	}
+}
+
+func TestNewClientEmptyDsn(t *testing.T) {
+	t.Setenv("SENTRY_DSN", "")
+	client, err := NewClient(ClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.Transport.(*noopTransport); !ok {
+		t.Errorf("got transport %T, want %T", client.Transport, &noopTransport{})
+	}
+	if dsn := client.Options().Dsn; dsn != "" {
+		t.Errorf("got DSN %q, want none", dsn)
+	}
+}
+
+// processorIntegration is an integration that adds an event processor.
+type processorIntegration struct {
+	processor EventProcessor
+}
+
+func (i *processorIntegration) Name() string { return "Processor" }
+
+func (i *processorIntegration) SetupOnce(client *Client) {
+	client.AddEventProcessor(i.processor)
+}
+
+func TestNewClientEmptyDsnIntegrationProcessor(t *testing.T) {
+	t.Setenv("SENTRY_DSN", "")
+	var processed []string
+	client, err := NewClient(ClientOptions{
+		Integrations: func(integrations []Integration) []Integration {
+			return append(integrations, &processorIntegration{
+				processor: func(event *Event, hint *EventHint) *Event {
+					processed = append(processed, event.Message)
+					return event
+				},
+			})
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events are observed by the integration, so the client is not disabled.
+	client.CaptureMessage("message", nil, nil)
+	assertEqual(t, processed, []string{"message"})
+}
+
+// closingTransport is a TransportMock that records calls to Close.
+type closingTransport struct {
+	TransportMock
+	closed bool
+}
+
+func (t *closingTransport) Close() {
+	t.closed = true
+}
+
+func TestClientClose(t *testing.T) {
+	transport := &closingTransport{}
+	client, err := NewClient(ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.CaptureMessage("before close", nil, nil)
+	assertEqual(t, client.Close(context.Background()), true)
+	assertEqual(t, transport.closed, true)
+
+	if id := client.CaptureMessage("after close", nil, nil); id != nil {
+		t.Errorf("CaptureMessage after Close returned event ID %s, want nil", *id)
+	}
+	if got := len(transport.Events()); got != 1 {
+		t.Errorf("got %d events sent, want 1", got)
+	}
+}
+
+func TestServerName(t *testing.T) {
+	t.Run("Hostname", func(t *testing.T) {
+		t.Setenv("SENTRY_NAME", "")
+		client, transport := newClientWithTransportMock(t, ClientOptions{})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.ServerName, hostname)
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv("SENTRY_NAME", "from-env")
+		client, transport := newClientWithTransportMock(t, ClientOptions{})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.ServerName, "from-env")
+	})
+
+	t.Run("Option", func(t *testing.T) {
+		t.Setenv("SENTRY_NAME", "from-env")
+		client, transport := newClientWithTransportMock(t, ClientOptions{ServerName: "from-option"})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.ServerName, "from-option")
+	})
+}
+
+func TestCaptureReturnsEventID(t *testing.T) {
+	client, transport := newClientWithTransportMock(t, ClientOptions{
+		BeforeSend: func(event *Event, hint *EventHint) *Event {
+			if event.Message == "drop" {
+				return nil
+			}
+			return event
+		},
+	})
+	scope := NewScope()
+
+	captures := map[string]func() *EventID{
+		"CaptureMessage": func() *EventID {
+			return client.CaptureMessage("message", nil, scope)
+		},
+		"CaptureException": func() *EventID {
+			return client.CaptureException(errors.New("error"), nil, scope)
+		},
+		"CaptureEvent": func() *EventID {
+			return client.CaptureEvent(&Event{Message: "event"}, nil, scope)
+		},
+		"RecoverWithContext": func() *EventID {
+			return client.RecoverWithContext(context.Background(), "panic", nil, scope)
+		},
+	}
+	for name, capture := range captures {
+		capture := capture
+		t.Run(name, func(t *testing.T) {
+			id := capture()
+			if id == nil {
+				t.Fatal("got nil EventID")
+			}
+			assertEqual(t, *id, transport.lastEvent.EventID)
+		})
+	}
+
+	if id := client.CaptureMessage("drop", nil, scope); id != nil {
+		t.Errorf("got EventID %s for a dropped event, want nil", id)
+	}
+}
+
+func TestErrorSampler(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport:  transport,
+		SampleRate: 0.1,
+		SampleRand: func() float64 { return 0.5 },
+		ErrorSampler: func(event *Event, hint *EventHint) float64 {
+			if event.Request != nil && event.Request.URL == "http://example.com/poll" {
+				return 0.2
+			}
+			return 1.0
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{"http://example.com/poll", "http://example.com/orders"} {
+		event := NewEvent()
+		event.Message = url
+		event.Request = &Request{URL: url, Method: "GET"}
+		client.CaptureEvent(event, nil, NewScope())
+	}
+
+	events := transport.Events()
+	if len(events) != 1 || events[0].Message != "http://example.com/orders" {
+		t.Errorf("got %d events, want only the event of the endpoint sampled at 1.0", len(events))
+	}
+	want := map[discardedKey]uint64{
+		{reason: discardReasonSampleRate, category: ratelimit.CategoryError}: 1,
+	}
+	if diff := cmp.Diff(want, client.discarded.take(), cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("discarded events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSampleRand(t *testing.T) {
+	var mu sync.Mutex
+	values := []float64{0.4, 0.6, 0.2, 0.8}
+	next := func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+	transport := &TransportMock{}
+	ctx := NewTestContext(ClientOptions{
+		SampleRate:       0.5,
+		EnableTracing:    true,
+		TracesSampleRate: 0.5,
+		SampleRand:       next,
+		Transport:        transport,
+	})
+	hub := GetHubFromContext(ctx)
+
+	if id := hub.CaptureMessage("kept"); id == nil {
+		t.Error("event with random value 0.4 was dropped, want it sampled at 0.5")
+	}
+	if id := hub.CaptureMessage("dropped"); id != nil {
+		t.Error("event with random value 0.6 was sent, want it dropped at 0.5")
+	}
+	if tx := StartTransaction(ctx, "kept"); tx.Sampled != SampledTrue {
+		t.Errorf("transaction with random value 0.2 has Sampled = %v, want %v", tx.Sampled, SampledTrue)
+	}
+	if tx := StartTransaction(ctx, "dropped"); tx.Sampled != SampledFalse {
+		t.Errorf("transaction with random value 0.8 has Sampled = %v, want %v", tx.Sampled, SampledFalse)
+	}
+}
+
+func TestBufferSizeOption(t *testing.T) {
+	tests := map[string]struct {
+		options ClientOptions
+		want    int
+	}{
+		"Default":        {options: ClientOptions{}, want: defaultBufferSize},
+		"Tracing":        {options: ClientOptions{EnableTracing: true}, want: 1000},
+		"Option":         {options: ClientOptions{BufferSize: 200}, want: 200},
+		"TracingOption":  {options: ClientOptions{EnableTracing: true, BufferSize: 5000}, want: 5000},
+		"NegativeOption": {options: ClientOptions{BufferSize: -1}, want: defaultBufferSize},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			tt.options.Dsn = testDsn
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport, ok := client.Transport.(*HTTPTransport)
+			if !ok {
+				t.Fatalf("got transport %T, want *HTTPTransport", client.Transport)
+			}
+			assertEqual(t, transport.BufferSize, tt.want)
+		})
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Run("Unset", func(t *testing.T) {
+		t.Setenv("SENTRY_ENVIRONMENT", "")
+		client, transport := newClientWithTransportMock(t, ClientOptions{})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.Environment, "")
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv("SENTRY_ENVIRONMENT", "staging")
+		client, transport := newClientWithTransportMock(t, ClientOptions{})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.Environment, "staging")
+	})
+
+	t.Run("Option", func(t *testing.T) {
+		t.Setenv("SENTRY_ENVIRONMENT", "staging")
+		client, transport := newClientWithTransportMock(t, ClientOptions{Environment: "development"})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.Environment, "development")
+	})
+}
+
+func TestDist(t *testing.T) {
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv("SENTRY_DIST", "linux-amd64")
+		client, transport := newClientWithTransportMock(t, ClientOptions{})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.Dist, "linux-amd64")
+	})
+
+	t.Run("Option", func(t *testing.T) {
+		t.Setenv("SENTRY_DIST", "linux-amd64")
+		client, transport := newClientWithTransportMock(t, ClientOptions{Dist: "darwin-arm64"})
+		client.CaptureMessage("test", nil, nil)
+		assertEqual(t, transport.lastEvent.Dist, "darwin-arm64")
+	})
+
+	t.Run("Event", func(t *testing.T) {
+		client, transport := newClientWithTransportMock(t, ClientOptions{Dist: "darwin-arm64"})
+		event := NewEvent()
+		event.Dist = "windows-amd64"
+		client.CaptureEvent(event, nil, nil)
+		assertEqual(t, transport.lastEvent.Dist, "windows-amd64")
+	})
+}
+
+func TestClientOptionsFromEnvironment(t *testing.T) {
+	defer Logger.SetOutput(io.Discard)
+	t.Setenv("SENTRY_DSN", "https://public@example.com/1")
+	t.Setenv("SENTRY_ENVIRONMENT", "staging")
+	t.Setenv("SENTRY_RELEASE", "app@1.2.3")
+	t.Setenv("SENTRY_DEBUG", "1")
+
+	client, err := NewClient(ClientOptions{DebugWriter: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := client.Options()
+	assertEqual(t, options.Dsn, "https://public@example.com/1")
+	assertEqual(t, options.Environment, "staging")
+	assertEqual(t, options.Release, "app@1.2.3")
+	assertEqual(t, options.Debug, true)
+
+	t.Setenv("SENTRY_DEBUG", "not a bool")
+	client, err = NewClient(ClientOptions{Environment: "production"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options = client.Options()
+	assertEqual(t, options.Environment, "production")
+	assertEqual(t, options.Debug, false)
+}
+
+func TestClientOptionsStrictMode(t *testing.T) {
+	defer Logger.SetOutput(io.Discard)
+	t.Setenv("SENTRY_DSN", "")
+	dsn := "https://public@example.com/1"
+
+	tests := []struct {
+		name    string
+		options ClientOptions
+		wantErr bool
+	}{
+		{"valid", ClientOptions{Dsn: dsn, TracesSampleRate: 0.5}, false},
+		{"transport without Dsn", ClientOptions{Transport: &TransportMock{}}, false},
+		{"no Dsn", ClientOptions{}, true},
+		{"TracesSampleRate out of range", ClientOptions{Dsn: dsn, TracesSampleRate: 2}, true},
+		{"ProfilesSampleRate out of range", ClientOptions{Dsn: dsn, ProfilesSampleRate: -1}, true},
+		{"HTTPProxy without scheme", ClientOptions{Dsn: dsn, HTTPProxy: "localhost:3128"}, true},
+		{"invalid HTTPSProxy", ClientOptions{Dsn: dsn, HTTPSProxy: "http://proxy:port"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.DebugWriter = io.Discard
+			_, err := NewClient(tt.options)
+			assertEqual(t, err != nil, false)
+
+			tt.options.StrictMode = true
+			client, err := NewClient(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, want error: %t", err, tt.wantErr)
+			}
+			if err == nil {
+				assertEqual(t, client.Options().Debug, true)
+			}
+		})
+	}
+}
+
+func TestClientTagsAndContexts(t *testing.T) {
+	client, transport := newClientWithTransportMock(t, ClientOptions{
+		Tags: map[string]string{"service": "payments", "region": "eu"},
+		Contexts: map[string]Context{
+			"service": {"name": "payments", "team": "billing"},
+		},
+	})
+	scope := NewScope()
+	scope.SetTag("region", "us")
+	scope.SetContext("service", Context{"team": "checkout"})
+
+	event := NewEvent()
+	event.Contexts["service"] = Context{"version": "1.2.3"}
+	client.CaptureEvent(event, nil, scope)
+
+	got := transport.lastEvent
+	assertEqual(t, got.Tags, map[string]string{"service": "payments", "region": "us"})
+	assertEqual(t, got.Contexts["service"], Context{"name": "payments", "team": "checkout", "version": "1.2.3"})
+
+	// Events without a scope get them too, and the options are not modified.
+	client.CaptureMessage("no scope", nil, nil)
+	assertEqual(t, transport.lastEvent.Tags["service"], "payments")
+	assertEqual(t, client.options.Contexts["service"], Context{"name": "payments", "team": "billing"})
+}
+
+func TestMaxTransactionsPerSecond(t *testing.T) {
+	client, transport := newClientWithTransportMock(t, ClientOptions{MaxTransactionsPerSecond: 2})
+	now := time.Unix(0, 0)
+	client.transactionLimiter.last = now
+	client.transactionLimiter.now = func() time.Time { return now }
+
+	sent := func() int { return len(transport.Events()) }
+	captureTransaction := func() {
+		event := NewEvent()
+		event.Type = transactionType
+		client.CaptureEvent(event, nil, nil)
+	}
+
+	for i := 0; i < 3; i++ {
+		captureTransaction()
+	}
+	assertEqual(t, sent(), 2)
+	// Errors are not limited.
+	client.CaptureMessage("message", nil, nil)
+	assertEqual(t, sent(), 3)
+
+	now = now.Add(500 * time.Millisecond)
+	captureTransaction()
+	captureTransaction()
+	assertEqual(t, sent(), 4)
+
+	want := map[discardedKey]uint64{
+		{reason: discardReasonRateLimitBackoff, category: ratelimit.CategoryTransaction}: 2,
+	}
+	if diff := cmp.Diff(want, client.discarded.take(), cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("discarded events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMaxTransactionsPerSecondFractional(t *testing.T) {
+	client, transport := newClientWithTransportMock(t, ClientOptions{MaxTransactionsPerSecond: 0.5})
+	now := time.Unix(0, 0)
+	client.transactionLimiter.last = now
+	client.transactionLimiter.now = func() time.Time { return now }
+
+	sent := func() int { return len(transport.Events()) }
+	captureTransaction := func() {
+		event := NewEvent()
+		event.Type = transactionType
+		client.CaptureEvent(event, nil, nil)
+	}
+
+	captureTransaction()
+	captureTransaction()
+	assertEqual(t, sent(), 1)
+
+	now = now.Add(time.Second)
+	captureTransaction()
+	assertEqual(t, sent(), 1)
+
+	now = now.Add(time.Second)
+	captureTransaction()
+	captureTransaction()
+	assertEqual(t, sent(), 2)
+}
+
+func newClientWithTransportMock(t *testing.T, options ClientOptions) (*Client, *TransportMock) {
+	t.Helper()
+	transport := &TransportMock{}
+	options.Transport = transport
+	client, err := NewClient(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, transport
+}
+
+func TestLimitEventSize(t *testing.T) {
+	large := strings.Repeat("x", 1000)
+	newLargeEvent := func() *Event {
+		event := &Event{
+			Message: "mkey",
+			Tags:    map[string]string{"key": "value"},
+			Extra:   map[string]interface{}{"small": "value", "large": large},
+			Exception: []Exception{{
+				Stacktrace: &Stacktrace{
+					Frames: []Frame{{Function: "f", Vars: map[string]interface{}{"large": large}}},
+				},
+			}},
+		}
+		for i := 0; i < 10; i++ {
+			event.Breadcrumbs = append(event.Breadcrumbs, &Breadcrumb{Message: fmt.Sprintf("%d %s", i, large)})
+		}
+		return event
+	}
+
+	tests := map[string]struct {
+		maxSize         int
+		wantBreadcrumbs int
+		wantExtra       map[string]interface{}
+		wantVars        bool
+	}{
+		"NoLimit": {
+			maxSize:         0,
+			wantBreadcrumbs: 10,
+			wantExtra:       map[string]interface{}{"small": "value", "large": large},
+			wantVars:        true,
+		},
+		"Breadcrumbs": {
+			maxSize:         6000,
+			wantBreadcrumbs: 2,
+			wantExtra:       map[string]interface{}{"small": "value", "large": large},
+			wantVars:        true,
+		},
+		"Extra": {
+			maxSize:         1500,
+			wantBreadcrumbs: 0,
+			wantExtra:       map[string]interface{}{"small": "value"},
+			wantVars:        true,
+		},
+		"Vars": {
+			maxSize:         500,
+			wantBreadcrumbs: 0,
+			wantExtra:       map[string]interface{}{},
+			wantVars:        false,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			event := newLargeEvent()
+			var body []byte
+			if tt.maxSize > 0 {
+				limited := limitEventSize(event, tt.maxSize)
+				if limited == nil {
+					t.Fatal("event was dropped")
+				}
+				body = limited.sdkMetaData.body
+			} else {
+				body, _ = json.Marshal(event)
+			}
+			if tt.maxSize > 0 && len(body) > tt.maxSize {
+				t.Errorf("body is %d bytes, want at most %d", len(body), tt.maxSize)
+			}
+
+			var got Event
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, len(got.Breadcrumbs), tt.wantBreadcrumbs)
+			if tt.wantBreadcrumbs > 0 {
+				// The most recent breadcrumbs are kept.
+				assertEqual(t, got.Breadcrumbs[tt.wantBreadcrumbs-1].Message, "9 "+large)
+			}
+			if len(tt.wantExtra) > 0 || len(got.Extra) > 0 {
+				assertEqual(t, got.Extra, tt.wantExtra)
+			}
+			assertEqual(t, got.Exception[0].Stacktrace.Frames[0].Vars != nil, tt.wantVars)
+			wantTags := map[string]string{"key": "value"}
+			if name != "NoLimit" {
+				wantTags[truncatedTag] = "true"
+			}
+			assertEqual(t, got.Tags, wantTags)
+
+			// The original event is left untouched.
+			assertEqual(t, len(event.Breadcrumbs), 10)
+			assertEqual(t, len(event.Extra), 2)
+			assertEqual(t, event.Exception[0].Stacktrace.Frames[0].Vars != nil, true)
+			assertEqual(t, event.Tags, map[string]string{"key": "value"})
+		})
+	}
+
+	t.Run("TooLarge", func(t *testing.T) {
+		event := newLargeEvent()
+		event.Message = large
+		if limited := limitEventSize(event, 500); limited != nil {
+			t.Errorf("got event of %d bytes, want nil", len(limited.sdkMetaData.body))
+		}
+	})
+}
+
+func TestMaxEventSize(t *testing.T) {
+	client, transport := newClientWithTransportMock(t, ClientOptions{MaxEventSize: 2000})
+	event := NewEvent()
+	event.Extra["large"] = strings.Repeat("x", 3000)
+	client.CaptureEvent(event, nil, nil)
+
+	got := transport.lastEvent
+	assertEqual(t, got.Tags[truncatedTag], "true")
+	assertEqual(t, len(got.Extra), 0)
+	// The captured event is left untouched.
+	assertEqual(t, len(event.Extra), 1)
+	assertEqual(t, event.Tags[truncatedTag], "")
 }
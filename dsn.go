@@ -2,6 +2,7 @@ package sentry
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -27,16 +28,34 @@ func (scheme scheme) defaultPort() int {
 	}
 }
 
+// Errors wrapped by a DsnParseError, identifying the part of a DSN that is
+// invalid. Use errors.Is to check for them.
+var (
+	ErrDsnMalformedURL      = errors.New("malformed URL")
+	ErrDsnUnsupportedScheme = errors.New("unsupported scheme")
+	ErrDsnMissingPublicKey  = errors.New("missing public key")
+	ErrDsnMissingHost       = errors.New("missing host")
+	ErrDsnInvalidPort       = errors.New("invalid port")
+	ErrDsnMissingProjectID  = errors.New("missing project ID")
+)
+
 // DsnParseError represents an error that occurs if a Sentry
 // DSN cannot be parsed.
 type DsnParseError struct {
 	Message string
+	// Err is one of the ErrDsn errors, describing the reason of the error.
+	Err error
 }
 
 func (e DsnParseError) Error() string {
 	return "[Sentry] DsnParseError: " + e.Message
 }
 
+// Unwrap returns e.Err.
+func (e DsnParseError) Unwrap() error {
+	return e.Err
+}
+
 // Dsn is used as the remote address source to client transport.
 type Dsn struct {
 	scheme    scheme
@@ -55,7 +74,7 @@ func NewDsn(rawURL string) (*Dsn, error) {
 	// Parse
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, &DsnParseError{fmt.Sprintf("invalid url: %v", err)}
+		return nil, &DsnParseError{fmt.Sprintf("invalid url: %v", err), ErrDsnMalformedURL}
 	}
 
 	// Scheme
@@ -66,13 +85,13 @@ func NewDsn(rawURL string) (*Dsn, error) {
 	case "https":
 		scheme = schemeHTTPS
 	default:
-		return nil, &DsnParseError{"invalid scheme"}
+		return nil, &DsnParseError{"invalid scheme", ErrDsnUnsupportedScheme}
 	}
 
 	// PublicKey
 	publicKey := parsedURL.User.Username()
 	if publicKey == "" {
-		return nil, &DsnParseError{"empty username"}
+		return nil, &DsnParseError{"empty username", ErrDsnMissingPublicKey}
 	}
 
 	// SecretKey
@@ -84,7 +103,7 @@ func NewDsn(rawURL string) (*Dsn, error) {
 	// Host
 	host := parsedURL.Hostname()
 	if host == "" {
-		return nil, &DsnParseError{"empty host"}
+		return nil, &DsnParseError{"empty host", ErrDsnMissingHost}
 	}
 
 	// Port
@@ -92,7 +111,7 @@ func NewDsn(rawURL string) (*Dsn, error) {
 	if parsedURL.Port() != "" {
 		parsedPort, err := strconv.Atoi(parsedURL.Port())
 		if err != nil {
-			return nil, &DsnParseError{"invalid port"}
+			return nil, &DsnParseError{"invalid port", ErrDsnInvalidPort}
 		}
 		port = parsedPort
 	} else {
@@ -101,13 +120,13 @@ func NewDsn(rawURL string) (*Dsn, error) {
 
 	// ProjectID
 	if parsedURL.Path == "" || parsedURL.Path == "/" {
-		return nil, &DsnParseError{"empty project id"}
+		return nil, &DsnParseError{"empty project id", ErrDsnMissingProjectID}
 	}
 	pathSegments := strings.Split(parsedURL.Path[1:], "/")
 	projectID := pathSegments[len(pathSegments)-1]
 
 	if projectID == "" {
-		return nil, &DsnParseError{"empty project id"}
+		return nil, &DsnParseError{"empty project id", ErrDsnMissingProjectID}
 	}
 
 	// Path
@@ -127,6 +146,45 @@ func NewDsn(rawURL string) (*Dsn, error) {
 	}, nil
 }
 
+// dsnFromOptions parses the DSN of options, pointing it to options.IngestURL
+// if set.
+func dsnFromOptions(options ClientOptions) (*Dsn, error) {
+	dsn, err := NewDsn(options.Dsn)
+	if err != nil || options.IngestURL == "" {
+		return dsn, err
+	}
+	return dsn.withIngestURL(options.IngestURL)
+}
+
+// withIngestURL returns a copy of dsn whose API URLs start with rawURL instead
+// of the scheme, host, port and path of the DSN, see ClientOptions.IngestURL.
+func (dsn Dsn) withIngestURL(rawURL string) (*Dsn, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IngestURL %q: %w", rawURL, err)
+	}
+	switch parsedURL.Scheme {
+	case "http":
+		dsn.scheme = schemeHTTP
+	case "https":
+		dsn.scheme = schemeHTTPS
+	default:
+		return nil, fmt.Errorf("invalid IngestURL %q: unsupported scheme", rawURL)
+	}
+	dsn.host = parsedURL.Hostname()
+	if dsn.host == "" {
+		return nil, fmt.Errorf("invalid IngestURL %q: empty host", rawURL)
+	}
+	dsn.port = dsn.scheme.defaultPort()
+	if parsedURL.Port() != "" {
+		if dsn.port, err = strconv.Atoi(parsedURL.Port()); err != nil {
+			return nil, fmt.Errorf("invalid IngestURL %q: invalid port", rawURL)
+		}
+	}
+	dsn.path = strings.TrimSuffix(parsedURL.Path, "/")
+	return &dsn, nil
+}
+
 // String formats Dsn struct into a valid string url.
 func (dsn Dsn) String() string {
 	var url string
@@ -1,6 +1,7 @@
 package sentry
 
 import (
+	"math"
 	"strconv"
 	"strings"
 
@@ -58,6 +59,12 @@ func DynamicSamplingContextFromTransaction(span *Span) DynamicSamplingContext {
 	if sampleRate := span.sampleRate; sampleRate != 0 {
 		entries["sample_rate"] = strconv.FormatFloat(sampleRate, 'f', -1, 64)
 	}
+	if span.isTransaction && span.Sampled != SampledUndefined {
+		// Truncate rather than round, so that the value stays below 1.0.
+		sampleRand := math.Floor(span.sampleRand*1e6) / 1e6
+		entries["sample_rand"] = strconv.FormatFloat(sampleRand, 'f', 6, 64)
+		entries["sampled"] = strconv.FormatBool(span.Sampled.Bool())
+	}
 
 	if dsn := client.dsn; dsn != nil {
 		if publicKey := dsn.publicKey; publicKey != "" {
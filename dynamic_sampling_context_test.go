@@ -83,6 +83,7 @@ func TestDynamicSamplingContextFromTransaction(t *testing.T) {
 				ctx := NewTestContext(ClientOptions{
 					EnableTracing:    true,
 					TracesSampleRate: 0.5,
+					SampleRand:       func() float64 { return 0.25 },
 					Dsn:              "http://public@example.com/sentry/1",
 					Release:          "1.0.0",
 					Environment:      "test",
@@ -98,6 +99,8 @@ func TestDynamicSamplingContextFromTransaction(t *testing.T) {
 				Frozen: true,
 				Entries: map[string]string{
 					"sample_rate":  "0.5",
+					"sample_rand":  "0.250000",
+					"sampled":      "true",
 					"trace_id":     "d49d9bf66f13450b81f65bc51cf49c03",
 					"public_key":   "public",
 					"release":      "1.0.0",
@@ -113,6 +116,7 @@ func TestDynamicSamplingContextFromTransaction(t *testing.T) {
 				ctx := NewTestContext(ClientOptions{
 					EnableTracing:    true,
 					TracesSampleRate: 0.5,
+					SampleRand:       func() float64 { return 0.75 },
 					Dsn:              "http://public@example.com/sentry/1",
 					Release:          "1.0.0",
 				})
@@ -124,6 +128,8 @@ func TestDynamicSamplingContextFromTransaction(t *testing.T) {
 				Frozen: true,
 				Entries: map[string]string{
 					"sample_rate": "0.5",
+					"sample_rand": "0.750000",
+					"sampled":     "false",
 					"trace_id":    "d49d9bf66f13450b81f65bc51cf49c03",
 					"public_key":  "public",
 					"release":     "1.0.0",
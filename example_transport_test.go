@@ -0,0 +1,49 @@
+package sentry_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// WriterTransport is a sentry.Transport that writes events to an io.Writer
+// instead of sending them to Sentry over HTTP. The same approach can be used
+// to deliver events through a different channel, for example a Unix socket to
+// a sidecar process.
+type WriterTransport struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *WriterTransport) Configure(options sentry.ClientOptions) {}
+
+func (t *WriterTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "[%s] %s\n", event.Level, event.Message)
+}
+
+func (t *WriterTransport) Flush(timeout time.Duration) bool {
+	// Events are written synchronously, there is nothing to flush.
+	return true
+}
+
+// Initializing the SDK with a custom Transport replaces how events are
+// delivered entirely. This example writes events to standard output.
+func Example_customTransport() {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Transport: &WriterTransport{w: os.Stdout},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	client.CaptureMessage("Hello", nil, nil)
+	client.Flush(time.Second)
+	// Output: [info] Hello
+}
@@ -36,6 +36,11 @@ func TestIntegration(t *testing.T) {
 			WantEvent: &sentry.Event{
 				Level:   sentry.LevelFatal,
 				Message: "test",
+				Exception: []sentry.Exception{{
+					Type:      "panic",
+					Value:     "test",
+					Mechanism: &sentry.Mechanism{Type: "generic", Handled: new(bool)},
+				}},
 				Request: &sentry.Request{
 					URL:    "http://example.com/panic",
 					Method: "GET",
@@ -206,9 +211,13 @@ func TestIntegration(t *testing.T) {
 		cmpopts.IgnoreFields(
 			sentry.Event{},
 			"Contexts", "EventID", "Extra", "Platform", "Modules",
-			"Release", "Sdk", "ServerName", "Tags", "Timestamp",
+			"Release", "Sdk", "ServerName", "Tags", "Threads", "Timestamp",
 			"sdkMetaData",
 		),
+		cmpopts.IgnoreFields(
+			sentry.Exception{},
+			"Stacktrace",
+		),
 		cmpopts.IgnoreMapEntries(func(k string, v string) bool {
 			// fasthttp changed Content-Length behavior in
 			// https://github.com/valyala/fasthttp/commit/097fa05a697fc638624a14ab294f1336da9c29b0.
@@ -54,16 +54,14 @@ func (h *handler) handle(c *gin.Context) {
 		hub = sentry.CurrentHub().Clone()
 		ctx = sentry.SetHubOnContext(ctx, hub)
 	}
+	name, source := transactionName(c)
 	options := []sentry.SpanOption{
 		sentry.WithOpName("http.server"),
 		sentry.ContinueFromRequest(c.Request),
-		sentry.WithTransactionSource(sentry.SourceURL),
+		sentry.WithTransactionSource(source),
 	}
 
-	transaction := sentry.StartTransaction(ctx,
-		fmt.Sprintf("%s %s", c.Request.Method, c.Request.URL.Path),
-		options...,
-	)
+	transaction := sentry.StartTransaction(ctx, name, options...)
 	defer func() {
 		transaction.Status = sentry.HTTPtoSpanStatus(c.Writer.Status())
 		transaction.Finish()
@@ -76,6 +74,17 @@ func (h *handler) handle(c *gin.Context) {
 	c.Next()
 }
 
+// transactionName returns the name of the transaction of c and its source.
+// Requests are named after the matched route, for instance "GET /users/:id",
+// so that requests to the same route are grouped together, or after the URL
+// path if no route matched.
+func transactionName(c *gin.Context) (string, sentry.TransactionSource) {
+	if route := c.FullPath(); route != "" {
+		return fmt.Sprintf("%s %s", c.Request.Method, route), sentry.SourceRoute
+	}
+	return fmt.Sprintf("%s %s", c.Request.Method, c.Request.URL.Path), sentry.SourceURL
+}
+
 func (h *handler) recoverWithSentry(hub *sentry.Hub, r *http.Request) {
 	if err := recover(); err != nil {
 		if !isBrokenPipeError(err) {
@@ -49,11 +49,16 @@ func TestIntegration(t *testing.T) {
 						"User-Agent":      "Go-http-client/1.1",
 					},
 				},
-				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+				TransactionInfo: &sentry.TransactionInfo{Source: "route"},
 			},
 			WantEvent: &sentry.Event{
 				Level:   sentry.LevelFatal,
 				Message: "test",
+				Exception: []sentry.Exception{{
+					Type:      "panic",
+					Value:     "test",
+					Mechanism: &sentry.Mechanism{Type: "generic", Handled: new(bool)},
+				}},
 				Request: &sentry.Request{
 					URL:    "/panic",
 					Method: "GET",
@@ -92,7 +97,7 @@ func TestIntegration(t *testing.T) {
 						"User-Agent":      "Go-http-client/1.1",
 					},
 				},
-				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+				TransactionInfo: &sentry.TransactionInfo{Source: "route"},
 			},
 			WantEvent: &sentry.Event{
 				Level:   sentry.LevelInfo,
@@ -130,7 +135,7 @@ func TestIntegration(t *testing.T) {
 						"User-Agent":      "Go-http-client/1.1",
 					},
 				},
-				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+				TransactionInfo: &sentry.TransactionInfo{Source: "route"},
 			},
 			WantEvent: &sentry.Event{
 				Level:   sentry.LevelInfo,
@@ -171,7 +176,7 @@ func TestIntegration(t *testing.T) {
 						"User-Agent":      "Go-http-client/1.1",
 					},
 				},
-				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+				TransactionInfo: &sentry.TransactionInfo{Source: "route"},
 			},
 			WantEvent: &sentry.Event{
 				Level:   sentry.LevelInfo,
@@ -213,7 +218,7 @@ func TestIntegration(t *testing.T) {
 						"User-Agent":      "Go-http-client/1.1",
 					},
 				},
-				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+				TransactionInfo: &sentry.TransactionInfo{Source: "route"},
 			},
 			WantEvent: &sentry.Event{
 				Level:   sentry.LevelInfo,
@@ -250,7 +255,7 @@ func TestIntegration(t *testing.T) {
 						"User-Agent":      "Go-http-client/1.1",
 					},
 				},
-				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+				TransactionInfo: &sentry.TransactionInfo{Source: "route"},
 			},
 			WantEvent: nil,
 		},
@@ -329,13 +334,17 @@ func TestIntegration(t *testing.T) {
 		cmpopts.IgnoreFields(
 			sentry.Event{},
 			"Contexts", "EventID", "Extra", "Platform", "Modules",
-			"Release", "Sdk", "ServerName", "Tags", "Timestamp",
+			"Release", "Sdk", "ServerName", "Tags", "Threads", "Timestamp",
 			"sdkMetaData",
 		),
 		cmpopts.IgnoreFields(
 			sentry.Request{},
 			"Env",
 		),
+		cmpopts.IgnoreFields(
+			sentry.Exception{},
+			"Stacktrace",
+		),
 	}
 	if diff := cmp.Diff(want, got, opts); diff != "" {
 		t.Fatalf("Events mismatch (-want +got):\n%s", diff)
@@ -369,3 +378,51 @@ func TestIntegration(t *testing.T) {
 		t.Fatalf("Transaction status codes mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestTransactionName(t *testing.T) {
+	transactionsCh := make(chan *sentry.Event, 2)
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		BeforeSendTransaction: func(tx *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			transactionsCh <- tx
+			return tx
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(sentrygin.New(sentrygin.Options{}))
+	router.GET("/users/:id", func(c *gin.Context) {})
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	for _, path := range []string{"/users/123", "/missing"} {
+		res, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(transactionsCh)
+	var got []sentry.TransactionInfo
+	var names []string
+	for tx := range transactionsCh {
+		names = append(names, tx.Transaction)
+		got = append(got, *tx.TransactionInfo)
+	}
+	if diff := cmp.Diff([]string{"GET /users/:id", "GET /missing"}, names); diff != "" {
+		t.Errorf("Transaction names mismatch (-want +got):\n%s", diff)
+	}
+	want := []sentry.TransactionInfo{{Source: sentry.SourceRoute}, {Source: sentry.SourceURL}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Transaction sources mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,117 @@
+package sentrygrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/getsentry/sentry-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ClientOptions configure the client interceptors.
+type ClientOptions struct {
+	// ReportOn, if set, is called with the errors returned by calls, and
+	// reports whether they should be sent to Sentry. When nil, errors are not
+	// reported, leaving it to the server.
+	ReportOn func(err error) bool
+}
+
+// UnaryClientInterceptor returns an interceptor that propagates the trace of
+// the current transaction to the server, in the "sentry-trace" and "baggage"
+// metadata, and traces calls in child spans.
+//
+// Calls made from a context without a transaction are not traced.
+func UnaryClientInterceptor(options ClientOptions) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := startClientSpan(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishClientSpan(ctx, span, options, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that propagates traces to
+// the server and traces streams in child spans, like UnaryClientInterceptor.
+// The span of a stream is finished when the stream ends.
+func StreamClientInterceptor(options ClientOptions) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := startClientSpan(ctx, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishClientSpan(ctx, span, options, err)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream: cs,
+			finish: func(err error) {
+				finishClientSpan(ctx, span, options, err)
+			},
+		}, nil
+	}
+}
+
+// clientStream finishes the span of a stream when it ends.
+type clientStream struct {
+	grpc.ClientStream
+	once   sync.Once
+	finish func(err error)
+}
+
+func (cs *clientStream) RecvMsg(m interface{}) error {
+	err := cs.ClientStream.RecvMsg(m)
+	if err != nil {
+		// io.EOF means the stream ended successfully.
+		status := err
+		if errors.Is(err, io.EOF) {
+			status = nil
+		}
+		cs.once.Do(func() { cs.finish(status) })
+	}
+	return err
+}
+
+func (cs *clientStream) SendMsg(m interface{}) error {
+	err := cs.ClientStream.SendMsg(m)
+	// io.EOF means the stream was ended by the server, the actual status is
+	// returned by RecvMsg.
+	if err != nil && !errors.Is(err, io.EOF) {
+		cs.once.Do(func() { cs.finish(err) })
+	}
+	return err
+}
+
+// startClientSpan starts a span for a call, if ctx holds a transaction, and
+// returns a context with the trace metadata of the span.
+func startClientSpan(ctx context.Context, method string) (*sentry.Span, context.Context) {
+	if sentry.TransactionFromContext(ctx) == nil {
+		return nil, ctx
+	}
+	span := sentry.StartSpan(ctx, "grpc.client", sentry.WithDescription(method))
+	ctx = span.Context()
+	sentryTrace, baggage := sentry.TraceHeaders(ctx)
+	ctx = metadata.AppendToOutgoingContext(ctx, sentry.SentryTraceHeader, sentryTrace)
+	if baggage != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, sentry.SentryBaggageHeader, baggage)
+	}
+	return span, ctx
+}
+
+// finishClientSpan records the outcome of a call returning err.
+func finishClientSpan(ctx context.Context, span *sentry.Span, options ClientOptions, err error) {
+	if span != nil {
+		span.Status = toSpanStatus(status.Code(err))
+		span.Finish()
+	}
+	if err == nil || options.ReportOn == nil || !options.ReportOn(err) {
+		return
+	}
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	hub.CaptureException(err)
+}
@@ -0,0 +1,98 @@
+package sentrygrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	sentrygrpc "github.com/getsentry/sentry-go/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	transport := initSentry(t)
+
+	var got metadata.MD
+	srv := &healthServer{check: func(ctx context.Context) error {
+		got, _ = metadata.FromIncomingContext(ctx)
+		return status.Error(codes.Unavailable, "unavailable")
+	}}
+	client := newTestClient(t, srv, nil,
+		grpc.WithUnaryInterceptor(sentrygrpc.UnaryClientInterceptor(sentrygrpc.ClientOptions{
+			ReportOn: sentrygrpc.ReportServerErrors,
+		})),
+	)
+
+	// Calls without a transaction are not traced.
+	_, _ = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if v := got.Get(sentry.SentryTraceHeader); len(v) != 0 {
+		t.Errorf("unexpected %s metadata %v", sentry.SentryTraceHeader, v)
+	}
+	if events, _ := transport.Events(); len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+
+	tx := sentry.StartTransaction(context.Background(), "client")
+	_, _ = client.Check(tx.Context(), &grpc_health_v1.HealthCheckRequest{})
+	tx.Finish()
+
+	events, transactions := transport.Events()
+	if len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+	if len(transactions) != 1 || len(transactions[0].Spans) != 1 {
+		t.Fatalf("got transactions %v, want one with a span", transactions)
+	}
+	span := transactions[0].Spans[0]
+	if span.Op != "grpc.client" || span.Description != checkMethod {
+		t.Errorf("span op, description = %q, %q, want %q, %q", span.Op, span.Description, "grpc.client", checkMethod)
+	}
+	if span.Status != sentry.SpanStatusUnavailable {
+		t.Errorf("span status = %v, want %v", span.Status, sentry.SpanStatusUnavailable)
+	}
+	if v := got.Get(sentry.SentryTraceHeader); len(v) != 1 || v[0] != span.ToSentryTrace() {
+		t.Errorf("%s metadata = %v, want %q", sentry.SentryTraceHeader, v, span.ToSentryTrace())
+	}
+	if v := got.Get(sentry.SentryBaggageHeader); len(v) != 1 || v[0] == "" {
+		t.Errorf("%s metadata = %v, want baggage", sentry.SentryBaggageHeader, v)
+	}
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	transport := initSentry(t)
+
+	var got metadata.MD
+	srv := &healthServer{watch: func(stream grpc_health_v1.Health_WatchServer) error {
+		got, _ = metadata.FromIncomingContext(stream.Context())
+		return stream.Send(&grpc_health_v1.HealthCheckResponse{})
+	}}
+	client := newTestClient(t, srv, nil,
+		grpc.WithStreamInterceptor(sentrygrpc.StreamClientInterceptor(sentrygrpc.ClientOptions{})),
+	)
+
+	tx := sentry.StartTransaction(context.Background(), "client")
+	stream, err := client.Watch(tx.Context(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	tx.Finish()
+
+	_, transactions := transport.Events()
+	if len(transactions) != 1 || len(transactions[0].Spans) != 1 {
+		t.Fatalf("got transactions %v, want one with a span", transactions)
+	}
+	span := transactions[0].Spans[0]
+	if span.Status != sentry.SpanStatusOK {
+		t.Errorf("span status = %v, want %v", span.Status, sentry.SpanStatusOK)
+	}
+	if v := got.Get(sentry.SentryTraceHeader); len(v) != 1 || v[0] != span.ToSentryTrace() {
+		t.Errorf("%s metadata = %v, want %q", sentry.SentryTraceHeader, v, span.ToSentryTrace())
+	}
+}
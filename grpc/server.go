@@ -0,0 +1,255 @@
+// Package sentrygrpc provides Sentry integration for gRPC servers and clients
+// based on the google.golang.org/grpc package.
+package sentrygrpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServerOptions configure the server interceptors.
+type ServerOptions struct {
+	// Repanic configures whether to panic again after recovering from a panic
+	// in a handler. When false, the handler returns an Internal error instead,
+	// without the panic value.
+	Repanic bool
+	// WaitForDelivery indicates, in case of a panic, whether to block the
+	// current goroutine and wait until the panic event has been reported to
+	// Sentry before repanicking or returning an error.
+	WaitForDelivery bool
+	// Timeout for the delivery of panic events. Defaults to 2s. Only relevant
+	// when WaitForDelivery is true.
+	Timeout time.Duration
+	// ReportOn, if set, is called with the errors returned by handlers, and
+	// reports whether they should be sent to Sentry. Defaults to
+	// ReportServerErrors.
+	ReportOn func(err error) bool
+	// ScrubMetadata lists the keys of request metadata that are never sent to
+	// Sentry, compared case-insensitively. Defaults to DefaultScrubMetadata
+	// when nil; set it to an empty, non-nil slice to send all metadata.
+	ScrubMetadata []string
+}
+
+// DefaultScrubMetadata is the list of request metadata keys removed from
+// events when ServerOptions.ScrubMetadata is nil.
+var DefaultScrubMetadata = []string{
+	"authorization",
+	"cookie",
+	"x-api-key",
+}
+
+// ReportServerErrors reports whether err has a status code indicating a
+// server error: Unknown, Internal, Unimplemented, Unavailable or DataLoss.
+// Errors that are not gRPC status errors have the Unknown code.
+func ReportServerErrors(err error) bool {
+	switch status.Code(err) {
+	case codes.Unknown, codes.Internal, codes.Unimplemented, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+type server struct {
+	repanic         bool
+	waitForDelivery bool
+	timeout         time.Duration
+	reportOn        func(err error) bool
+	scrubMetadata   map[string]struct{}
+}
+
+func newServer(options ServerOptions) *server {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	reportOn := options.ReportOn
+	if reportOn == nil {
+		reportOn = ReportServerErrors
+	}
+	scrubMetadata := options.ScrubMetadata
+	if scrubMetadata == nil {
+		scrubMetadata = DefaultScrubMetadata
+	}
+	s := &server{
+		repanic:         options.Repanic,
+		waitForDelivery: options.WaitForDelivery,
+		timeout:         timeout,
+		reportOn:        reportOn,
+		scrubMetadata:   make(map[string]struct{}, len(scrubMetadata)),
+	}
+	for _, key := range scrubMetadata {
+		s.scrubMetadata[strings.ToLower(key)] = struct{}{}
+	}
+	return s
+}
+
+// UnaryServerInterceptor returns an interceptor that reports panics and
+// errors of unary handlers to Sentry.
+//
+// Each call gets its own hub, stored in the context passed to the handler
+// and available with sentry.GetHubFromContext, whose scope holds the method
+// name and request metadata. The call is traced in a transaction continuing
+// the trace propagated by the client, if any.
+func UnaryServerInterceptor(options ServerOptions) grpc.UnaryServerInterceptor {
+	s := newServer(options)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		hub, transaction := s.start(ctx, info.FullMethod)
+		defer transaction.Finish()
+		defer s.recoverWithSentry(hub, transaction, &err)
+
+		resp, err = handler(transaction.Context(), req)
+		s.finish(hub, transaction, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that reports panics and
+// errors of streaming handlers to Sentry, like UnaryServerInterceptor.
+func StreamServerInterceptor(options ServerOptions) grpc.StreamServerInterceptor {
+	s := newServer(options)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		hub, transaction := s.start(ss.Context(), info.FullMethod)
+		defer transaction.Finish()
+		defer s.recoverWithSentry(hub, transaction, &err)
+
+		err = handler(srv, &serverStream{ServerStream: ss, ctx: transaction.Context()})
+		s.finish(hub, transaction, err)
+		return err
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+// start returns the hub of a call and starts its transaction.
+func (s *server) start(ctx context.Context, method string) (*sentry.Hub, *sentry.Span) {
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub().Clone()
+		ctx = sentry.SetHubOnContext(ctx, hub)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	hub.Scope().SetTag("grpc.method", method)
+	hub.Scope().SetContext("grpc", sentry.Context{
+		"method":   method,
+		"metadata": s.metadata(md),
+	})
+
+	transaction := sentry.StartTransaction(ctx, method,
+		sentry.WithOpName("grpc.server"),
+		sentry.ContinueFromHeaders(first(md, sentry.SentryTraceHeader), first(md, sentry.SentryBaggageHeader)),
+		sentry.WithTransactionSource(sentry.SourceRoute),
+	)
+	return hub, transaction
+}
+
+// finish records the outcome of a call returning err.
+func (s *server) finish(hub *sentry.Hub, transaction *sentry.Span, err error) {
+	transaction.Status = toSpanStatus(status.Code(err))
+	if err != nil && s.reportOn(err) {
+		hub.WithScope(func(scope *sentry.Scope) {
+			scope.SetTag("grpc.code", status.Code(err).String())
+			hub.CaptureException(err)
+		})
+	}
+}
+
+// recoverWithSentry reports a panic in a handler. Unless repanicking, it
+// replaces the error returned by the handler with an Internal error. The panic
+// value is only reported to Sentry, as it may reveal details of the server to
+// clients.
+func (s *server) recoverWithSentry(hub *sentry.Hub, transaction *sentry.Span, err *error) {
+	if r := recover(); r != nil {
+		transaction.Status = sentry.SpanStatusInternalError
+		eventID := hub.RecoverWithContext(transaction.Context(), r)
+		if eventID != nil && s.waitForDelivery {
+			hub.Flush(s.timeout)
+		}
+		if s.repanic {
+			panic(r)
+		}
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
+
+// metadata returns a copy of md without the scrubbed keys.
+func (s *server) metadata(md metadata.MD) map[string]string {
+	m := make(map[string]string, len(md))
+	for key, values := range md {
+		if _, ok := s.scrubMetadata[strings.ToLower(key)]; ok {
+			continue
+		}
+		// Binary values are not readable.
+		if strings.HasSuffix(key, "-bin") {
+			continue
+		}
+		m[key] = strings.Join(values, ",")
+	}
+	return m
+}
+
+// first returns the first value of key in md, or the empty string.
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// toSpanStatus maps gRPC status codes to span statuses, which share the same
+// names.
+func toSpanStatus(code codes.Code) sentry.SpanStatus {
+	switch code {
+	case codes.OK:
+		return sentry.SpanStatusOK
+	case codes.Canceled:
+		return sentry.SpanStatusCanceled
+	case codes.Unknown:
+		return sentry.SpanStatusUnknown
+	case codes.InvalidArgument:
+		return sentry.SpanStatusInvalidArgument
+	case codes.DeadlineExceeded:
+		return sentry.SpanStatusDeadlineExceeded
+	case codes.NotFound:
+		return sentry.SpanStatusNotFound
+	case codes.AlreadyExists:
+		return sentry.SpanStatusAlreadyExists
+	case codes.PermissionDenied:
+		return sentry.SpanStatusPermissionDenied
+	case codes.ResourceExhausted:
+		return sentry.SpanStatusResourceExhausted
+	case codes.FailedPrecondition:
+		return sentry.SpanStatusFailedPrecondition
+	case codes.Aborted:
+		return sentry.SpanStatusAborted
+	case codes.OutOfRange:
+		return sentry.SpanStatusOutOfRange
+	case codes.Unimplemented:
+		return sentry.SpanStatusUnimplemented
+	case codes.Internal:
+		return sentry.SpanStatusInternalError
+	case codes.Unavailable:
+		return sentry.SpanStatusUnavailable
+	case codes.DataLoss:
+		return sentry.SpanStatusDataLoss
+	case codes.Unauthenticated:
+		return sentry.SpanStatusUnauthenticated
+	default:
+		return sentry.SpanStatusUnknown
+	}
+}
@@ -0,0 +1,263 @@
+package sentrygrpc_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	sentrygrpc "github.com/getsentry/sentry-go/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// transportMock records the events sent to Sentry.
+type transportMock struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *transportMock) Configure(sentry.ClientOptions) {}
+func (t *transportMock) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+func (t *transportMock) Flush(time.Duration) bool { return true }
+
+// Events returns the events and transactions sent since the last call.
+func (t *transportMock) Events() (events, transactions []*sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, event := range t.events {
+		if event.Type == "transaction" {
+			transactions = append(transactions, event)
+		} else {
+			events = append(events, event)
+		}
+	}
+	t.events = nil
+	return events, transactions
+}
+
+func initSentry(t *testing.T) *transportMock {
+	t.Helper()
+	transport := &transportMock{}
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport
+}
+
+// healthServer is a test service whose handlers are replaced by check and
+// watch.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	check func(ctx context.Context) error
+	watch func(stream grpc_health_v1.Health_WatchServer) error
+}
+
+func (s *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	return s.watch(stream)
+}
+
+// newTestClient runs srv with the given server options and returns a client
+// connected to it with the given dial options.
+func newTestClient(t *testing.T, srv *healthServer, serverOpts []grpc.ServerOption, dialOpts ...grpc.DialOption) grpc_health_v1.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(serverOpts...)
+	grpc_health_v1.RegisterHealthServer(s, srv)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	dialOpts = append(dialOpts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.Dial("bufnet", dialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return grpc_health_v1.NewHealthClient(conn)
+}
+
+func newInterceptedClient(t *testing.T, srv *healthServer, options sentrygrpc.ServerOptions) grpc_health_v1.HealthClient {
+	t.Helper()
+	return newTestClient(t, srv, []grpc.ServerOption{
+		grpc.UnaryInterceptor(sentrygrpc.UnaryServerInterceptor(options)),
+		grpc.StreamInterceptor(sentrygrpc.StreamServerInterceptor(options)),
+	})
+}
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	transport := initSentry(t)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantEvent  bool
+		wantStatus sentry.SpanStatus
+	}{
+		{"OK", nil, false, sentry.SpanStatusOK},
+		{"ClientError", status.Error(codes.NotFound, "not found"), false, sentry.SpanStatusNotFound},
+		{"ServerError", status.Error(codes.Internal, "boom"), true, sentry.SpanStatusInternalError},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &healthServer{check: func(ctx context.Context) error {
+				if sentry.GetHubFromContext(ctx) == nil {
+					t.Error("missing hub in handler context")
+				}
+				return tt.err
+			}}
+			client := newInterceptedClient(t, srv, sentrygrpc.ServerOptions{})
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(),
+				"authorization", "secret",
+				"x-request-id", "42",
+			)
+			_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if status.Code(err) != status.Code(tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			events, transactions := transport.Events()
+			if len(transactions) != 1 {
+				t.Fatalf("got %d transactions, want 1", len(transactions))
+			}
+			tx := transactions[0]
+			if tx.Transaction != checkMethod {
+				t.Errorf("transaction name = %q, want %q", tx.Transaction, checkMethod)
+			}
+			if got := tx.Contexts["trace"]["status"]; got != tt.wantStatus {
+				t.Errorf("transaction status = %v, want %v", got, tt.wantStatus)
+			}
+
+			if !tt.wantEvent {
+				if len(events) != 0 {
+					t.Errorf("got %d events, want 0", len(events))
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			event := events[0]
+			if got := event.Tags["grpc.method"]; got != checkMethod {
+				t.Errorf("grpc.method tag = %q, want %q", got, checkMethod)
+			}
+			md, _ := event.Contexts["grpc"]["metadata"].(map[string]string)
+			if md["x-request-id"] != "42" {
+				t.Errorf("metadata = %v, want x-request-id", md)
+			}
+			if _, ok := md["authorization"]; ok {
+				t.Errorf("metadata = %v, want authorization to be scrubbed", md)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorPanic(t *testing.T) {
+	transport := initSentry(t)
+
+	srv := &healthServer{check: func(ctx context.Context) error {
+		panic("oops")
+	}}
+	client := newInterceptedClient(t, srv, sentrygrpc.ServerOptions{})
+
+	_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if status.Code(err) != codes.Internal || strings.Contains(err.Error(), "oops") {
+		t.Fatalf("got error %v, want Internal without the panic value", err)
+	}
+	events, transactions := transport.Events()
+	if len(events) != 1 || events[0].Message != "oops" {
+		t.Errorf("got events %v, want the panic", events)
+	}
+	if len(transactions) != 1 || transactions[0].Contexts["trace"]["status"] != sentry.SpanStatusInternalError {
+		t.Errorf("got transactions %v, want one with internal_error status", transactions)
+	}
+}
+
+func TestUnaryServerInterceptorContinuesTrace(t *testing.T) {
+	const (
+		traceID      = "bc6d53f15eb88f4320054569b8c553d4"
+		parentSpanID = "b72fa28504b07285"
+	)
+	transport := initSentry(t)
+
+	srv := &healthServer{check: func(ctx context.Context) error { return nil }}
+	client := newInterceptedClient(t, srv, sentrygrpc.ServerOptions{})
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		sentry.SentryTraceHeader, traceID+"-"+parentSpanID+"-1",
+	)
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	_, transactions := transport.Events()
+	if len(transactions) != 1 {
+		t.Fatalf("got %d transactions, want 1", len(transactions))
+	}
+	trace := transactions[0].Contexts["trace"]
+	if got := trace["trace_id"].(sentry.TraceID).String(); got != traceID {
+		t.Errorf("trace_id = %s, want %s", got, traceID)
+	}
+	if got := trace["parent_span_id"].(sentry.SpanID).String(); got != parentSpanID {
+		t.Errorf("parent_span_id = %s, want %s", got, parentSpanID)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	transport := initSentry(t)
+
+	srv := &healthServer{watch: func(stream grpc_health_v1.Health_WatchServer) error {
+		if sentry.GetHubFromContext(stream.Context()) == nil {
+			t.Error("missing hub in stream context")
+		}
+		return status.Error(codes.Unavailable, "unavailable")
+	}}
+	client := newInterceptedClient(t, srv, sentrygrpc.ServerOptions{})
+
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("got error %v, want Unavailable", err)
+	}
+
+	events, transactions := transport.Events()
+	if len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+	if len(transactions) != 1 || transactions[0].Transaction != "/grpc.health.v1.Health/Watch" {
+		t.Errorf("got transactions %v, want one for the Watch method", transactions)
+	}
+}
@@ -0,0 +1,40 @@
+// Package sentrychi provides the route templates and parameters of requests
+// matched by go-chi/chi, for sentryhttp.Options.TransactionName and
+// sentryhttp.Options.RouteParams.
+package sentrychi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route returns the routing pattern of the chi route matched for r, for
+// example "/users/{id}", or the empty string if no route matched.
+//
+// Use it as sentryhttp.Options.TransactionName when the Handler is registered
+// with chi.Router.Use or chi.Router.With.
+func Route(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.RoutePattern()
+}
+
+// RouteParams returns the URL parameters of the chi route matched for r, for
+// example {"id": "123"} for the route "/users/{id}", or nil if no route
+// matched.
+//
+// Use it as sentryhttp.Options.RouteParams along with Route.
+func RouteParams(r *http.Request) map[string]string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || len(rctx.URLParams.Keys) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(rctx.URLParams.Keys))
+	for i, key := range rctx.URLParams.Keys {
+		params[key] = rctx.URLParams.Values[i]
+	}
+	return params
+}
@@ -0,0 +1,114 @@
+package sentryhttp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Transport is an http.RoundTripper that propagates the trace of the span
+// stored in the request context to downstream services, by adding the
+// "sentry-trace" and "baggage" headers to outgoing requests. Requests without
+// a span in their context are sent unchanged.
+//
+// Use it as the Transport of an http.Client, and send requests created with
+// http.NewRequestWithContext from the context of the current span:
+//
+//	client := &http.Client{Transport: &sentryhttp.Transport{}}
+//	req, err := http.NewRequestWithContext(span.Context(), http.MethodGet, url, nil)
+//
+// Use NewTransport to additionally record outgoing requests as spans.
+type Transport struct {
+	// Base is the RoundTripper used to send requests. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// spans is set by NewTransport.
+	spans bool
+}
+
+// NewTransport returns a Transport sending requests with base, or
+// http.DefaultTransport if nil, that also records every request sent with a
+// span in its context as a child span with op "http.client". The span holds
+// the request method and URL, without query string, and its status reflects
+// the response status code. The query string is recorded as the "http.query"
+// data of the span only if ClientOptions.SendDefaultPII is set. It finishes once the response headers are
+// received.
+//
+// Downstream services continue the trace from the child span.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	return &Transport{Base: base, spans: true}
+}
+
+// RoundTrip implements http.RoundTripper. Headers already set on the request
+// are not overwritten.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.spans {
+		if sentryTrace, _ := sentry.TraceHeaders(req.Context()); sentryTrace != "" {
+			return t.roundTripWithSpan(base, req)
+		}
+	}
+	return t.propagate(base, req)
+}
+
+// roundTripWithSpan sends req in a child span of the span in its context.
+func (t *Transport) roundTripWithSpan(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	span := sentry.StartSpan(req.Context(), "http.client",
+		sentry.WithDescription(fmt.Sprintf("%s %s", req.Method, u.String())),
+	)
+	defer span.Finish()
+	span.SetData("http.request.method", req.Method)
+	span.SetData("url", u.String())
+	if req.URL.RawQuery != "" && sendDefaultPII(req) {
+		span.SetData("http.query", req.URL.RawQuery)
+	}
+
+	res, err := t.propagate(base, req.WithContext(span.Context()))
+	if err != nil {
+		span.Status = sentry.SpanStatusInternalError
+		return nil, err
+	}
+	span.Status = sentry.HTTPtoSpanStatus(res.StatusCode)
+	span.SetData("http.response.status_code", strconv.Itoa(res.StatusCode))
+	return res, nil
+}
+
+// sendDefaultPII reports whether the client of the hub for req, stored in its
+// context or the current hub, has ClientOptions.SendDefaultPII set. Query
+// strings may hold personal data or secrets, like the URL user info.
+func sendDefaultPII(req *http.Request) bool {
+	hub := sentry.GetHubFromContext(req.Context())
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	client := hub.Client()
+	return client != nil && client.Options().SendDefaultPII
+}
+
+// propagate sends req with base, adding the trace headers of the span in the
+// request context.
+func (t *Transport) propagate(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	sentryTrace, baggage := sentry.TraceHeaders(req.Context())
+	if sentryTrace == "" || req.Header.Get(sentry.SentryTraceHeader) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it is given.
+	req = req.Clone(req.Context())
+	req.Header.Set(sentry.SentryTraceHeader, sentryTrace)
+	if baggage != "" && req.Header.Get(sentry.SentryBaggageHeader) == "" {
+		req.Header.Set(sentry.SentryBaggageHeader, baggage)
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,190 @@
+package sentryhttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	sentryhttp "github.com/getsentry/sentry-go/http"
+)
+
+func TestTransport(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: &sentryhttp.Transport{Base: srv.Client().Transport},
+		Timeout:   time.Second,
+	}
+	get := func(ctx context.Context, header http.Header) http.Header {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if len(req.Header) != len(header) {
+			t.Errorf("request headers were modified: %v", req.Header)
+		}
+		return <-headers
+	}
+
+	hub := sentry.NewHub(nil, sentry.NewScope())
+	sentryClient, err := sentry.NewClient(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Release:          "test-release",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.BindClient(sentryClient)
+	ctx := sentry.SetHubOnContext(context.Background(), hub)
+
+	t.Run("NoSpan", func(t *testing.T) {
+		got := get(ctx, nil)
+		if v := got.Get(sentry.SentryTraceHeader); v != "" {
+			t.Errorf("unexpected %s header %q", sentry.SentryTraceHeader, v)
+		}
+		if v := got.Get(sentry.SentryBaggageHeader); v != "" {
+			t.Errorf("unexpected %s header %q", sentry.SentryBaggageHeader, v)
+		}
+	})
+
+	tx := sentry.StartTransaction(ctx, "outgoing")
+	defer tx.Finish()
+	span := tx.StartChild("http.client")
+	defer span.Finish()
+
+	t.Run("Span", func(t *testing.T) {
+		got := get(span.Context(), nil)
+		if v, want := got.Get(sentry.SentryTraceHeader), span.ToSentryTrace(); v != want {
+			t.Errorf("%s header = %q, want %q", sentry.SentryTraceHeader, v, want)
+		}
+		if v, want := got.Get(sentry.SentryBaggageHeader), tx.ToBaggage(); !sameBaggage(v, want) || v == "" {
+			t.Errorf("%s header = %q, want %q", sentry.SentryBaggageHeader, v, want)
+		}
+	})
+
+	t.Run("ExistingHeaders", func(t *testing.T) {
+		got := get(span.Context(), http.Header{
+			"Sentry-Trace": {"existing"},
+		})
+		if v := got.Get(sentry.SentryTraceHeader); v != "existing" {
+			t.Errorf("%s header = %q, want %q", sentry.SentryTraceHeader, v, "existing")
+		}
+		if v := got.Get(sentry.SentryBaggageHeader); v != "" {
+			t.Errorf("unexpected %s header %q", sentry.SentryBaggageHeader, v)
+		}
+	})
+}
+
+// sameBaggage reports whether a and b hold the same baggage members,
+// regardless of their order.
+func sameBaggage(a, b string) bool {
+	split := func(s string) string {
+		members := strings.Split(s, ",")
+		sort.Strings(members)
+		return strings.Join(members, ",")
+	}
+	return split(a) == split(b)
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testNewTransport(t, false)
+	})
+	t.Run("SendDefaultPII", func(t *testing.T) {
+		testNewTransport(t, true)
+	})
+}
+
+func testNewTransport(t *testing.T, sendDefaultPII bool) {
+	headers := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	transactions := make(chan *sentry.Event, 1)
+	sentryClient, err := sentry.NewClient(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		SendDefaultPII:   sendDefaultPII,
+		BeforeSendTransaction: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			transactions <- event
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := sentry.NewHub(sentryClient, sentry.NewScope())
+	ctx := sentry.SetHubOnContext(context.Background(), hub)
+	client := &http.Client{
+		Transport: sentryhttp.NewTransport(srv.Client().Transport),
+		Timeout:   time.Second,
+	}
+
+	tx := sentry.StartTransaction(ctx, "outgoing")
+	req, err := http.NewRequestWithContext(tx.Context(), http.MethodGet, srv.URL+"/path?q=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	header := <-headers
+	tx.Finish()
+
+	event := <-transactions
+	if len(event.Spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(event.Spans))
+	}
+	span := event.Spans[0]
+	if span.Op != "http.client" || span.ParentSpanID != tx.SpanID {
+		t.Errorf("got span with op %q and parent %s, want op %q and parent %s", span.Op, span.ParentSpanID, "http.client", tx.SpanID)
+	}
+	if want := "GET " + srv.URL + "/path"; span.Description != want {
+		t.Errorf("Description = %q, want %q", span.Description, want)
+	}
+	if span.Status != sentry.SpanStatusNotFound {
+		t.Errorf("Status = %v, want %v", span.Status, sentry.SpanStatusNotFound)
+	}
+	wantData := map[string]interface{}{
+		"http.request.method":       "GET",
+		"url":                       srv.URL + "/path",
+		"http.response.status_code": "404",
+	}
+	if sendDefaultPII {
+		wantData["http.query"] = "q=1"
+	} else if got, ok := span.Data["http.query"]; ok {
+		t.Errorf("Data[%q] = %v without SendDefaultPII, want none", "http.query", got)
+	}
+	for k, want := range wantData {
+		if got := span.Data[k]; got != want {
+			t.Errorf("Data[%q] = %v, want %v", k, got, want)
+		}
+	}
+	if v, want := header.Get(sentry.SentryTraceHeader), span.ToSentryTrace(); v != want {
+		t.Errorf("%s header = %q, want %q", sentry.SentryTraceHeader, v, want)
+	}
+}
@@ -0,0 +1,36 @@
+// Package sentrygorillamux provides the route templates and variables of
+// requests matched by gorilla/mux, for sentryhttp.Options.TransactionName and
+// sentryhttp.Options.RouteParams.
+package sentrygorillamux
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Route returns the path template of the gorilla/mux route matched for r, for
+// example "/users/{id}", or the empty string if no route matched.
+//
+// Use it as sentryhttp.Options.TransactionName when the Handler is registered
+// with mux.Router.Use, such that it runs after the router matched the request.
+func Route(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return tmpl
+}
+
+// RouteParams returns the variables of the gorilla/mux route matched for r,
+// for example {"id": "123"} for the route "/users/{id}", or nil if no route
+// matched.
+//
+// Use it as sentryhttp.Options.RouteParams along with Route.
+func RouteParams(r *http.Request) map[string]string {
+	return mux.Vars(r)
+}
@@ -0,0 +1,70 @@
+package sentryhttp
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// GetHubFromRequest returns the request-specific hub stored in the context of r
+// by the Handler, or nil if r was not handled by a Handler.
+func GetHubFromRequest(r *http.Request) *sentry.Hub {
+	return sentry.GetHubFromContext(r.Context())
+}
+
+// Go runs f in a new goroutine, for background work spawned while handling r.
+//
+// The context passed to f carries a clone of the request-specific hub, such
+// that events reported by f include the request data, without f and the
+// handler modifying each other's scope. It also carries the values of the
+// request context, notably the request span, such that spans started by f
+// belong to the trace of the request. The context is not canceled when the
+// request ends.
+//
+// A panic in f is recovered and reported to Sentry with the cloned hub, see
+// Recover.
+func Go(r *http.Request, f func(ctx context.Context)) {
+	hub := GetHubFromRequest(r)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	hub = hub.Clone()
+	ctx := sentry.SetHubOnContext(detachedContext{r.Context()}, hub)
+	go func() {
+		defer Recover(hub)
+		f(ctx)
+	}()
+}
+
+// detachedContext carries the values of its parent context, but is never
+// canceled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+func (c detachedContext) Value(key interface{}) interface{}     { return c.parent.Value(key) }
+
+// Recover recovers from a panic and reports it to Sentry using the given hub.
+// It must be deferred directly at the top of a goroutine, as in:
+//
+//	hub := sentryhttp.GetHubFromRequest(r).Clone()
+//	go func() {
+//		defer sentryhttp.Recover(hub)
+//		// background work here
+//	}()
+//
+// Unlike the Handler, Recover never panics again, because an unrecovered panic
+// in a goroutine terminates the program.
+func Recover(hub *sentry.Hub) {
+	if err := recover(); err != nil {
+		if hub == nil {
+			hub = sentry.CurrentHub()
+		}
+		hub.Recover(err)
+	}
+}
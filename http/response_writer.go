@@ -0,0 +1,157 @@
+package sentryhttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// A statusRecorder is an http.ResponseWriter that records the status code of
+// the response.
+type statusRecorder interface {
+	http.ResponseWriter
+	// Status returns the status code written with WriteHeader, ignoring
+	// informational 1xx codes other than 101, or http.StatusOK if no final
+	// status code was written. Hijacked connections, typically upgraded to
+	// WebSocket, have the status http.StatusSwitchingProtocols.
+	Status() int
+	// WroteHeader reports whether the final status code was written, either
+	// explicitly with WriteHeader or implicitly with Write.
+	WroteHeader() bool
+	// Body returns the start of the response body written with Write, up to
+	// the maximum size given to newStatusRecorder.
+	Body() []byte
+}
+
+// newStatusRecorder wraps w to record the response status code, and the first
+// maxBodySize bytes of the response body, if positive. The body is copied as
+// it is written to w, unchanged.
+//
+// The returned value implements the optional http.Flusher, http.Hijacker and
+// http.Pusher interfaces when w implements them, such that streaming and
+// upgrading handlers keep working when wrapped by the middleware. Only the
+// combinations that are relevant in practice are supported: net/http serves
+// HTTP/1.x with a writer that is a Flusher and a Hijacker, and HTTP/2 with a
+// writer that is a Flusher and a Pusher.
+func newStatusRecorder(w http.ResponseWriter, protoMajor int, maxBodySize int) statusRecorder {
+	rw := &responseWriter{ResponseWriter: w, maxBodySize: maxBodySize}
+
+	_, fl := w.(http.Flusher)
+	if protoMajor == 2 {
+		_, ps := w.(http.Pusher)
+		if fl && ps {
+			return &http2ResponseWriter{rw}
+		}
+	} else {
+		_, hj := w.(http.Hijacker)
+		if fl && hj {
+			return &http1ResponseWriter{rw}
+		}
+	}
+	if fl {
+		return &flushResponseWriter{rw}
+	}
+	return rw
+}
+
+// responseWriter is the basic statusRecorder, not implementing any of the
+// optional http.ResponseWriter interfaces.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+	maxBodySize int
+	body        []byte
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// Informational responses, like 103 Early Hints, precede the final
+	// response, except for 101 Switching Protocols.
+	if !w.wroteHeader && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+		w.wroteHeader = true
+	}
+	n, err := w.ResponseWriter.Write(b)
+	if room := w.maxBodySize - len(w.body); room > 0 && n > 0 {
+		if n < room {
+			room = n
+		}
+		w.body = append(w.body, b[:room]...)
+	}
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *responseWriter) WroteHeader() bool {
+	return w.wroteHeader
+}
+
+func (w *responseWriter) Body() []byte {
+	return w.body
+}
+
+// Unwrap returns the original http.ResponseWriter. It is used by
+// http.ResponseController to access the methods of the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type flushResponseWriter struct {
+	*responseWriter
+}
+
+func (w *flushResponseWriter) Flush() {
+	// Flushing sends the headers, with an implicit 200 status if none was
+	// written before.
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type http1ResponseWriter struct {
+	*responseWriter
+}
+
+func (w *http1ResponseWriter) Flush() {
+	(&flushResponseWriter{w.responseWriter}).Flush()
+}
+
+func (w *http1ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	// The status is written by the handler directly to the connection, and
+	// nothing can be written with the ResponseWriter anymore.
+	if err == nil && !w.wroteHeader {
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+type http2ResponseWriter struct {
+	*responseWriter
+}
+
+func (w *http2ResponseWriter) Flush() {
+	(&flushResponseWriter{w.responseWriter}).Flush()
+}
+
+func (w *http2ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
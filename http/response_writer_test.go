@@ -0,0 +1,121 @@
+package sentryhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type flushPushWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (flushPushWriter) Push(string, *http.PushOptions) error { return nil }
+
+type hijackWriter struct {
+	*httptest.ResponseRecorder
+	http.Hijacker
+}
+
+func TestNewStatusRecorderInterfaces(t *testing.T) {
+	tests := []struct {
+		name        string
+		w           http.ResponseWriter
+		protoMajor  int
+		wantFlusher bool
+		wantHijack  bool
+		wantPusher  bool
+	}{
+		{
+			name:        "Flusher",
+			w:           httptest.NewRecorder(),
+			protoMajor:  1,
+			wantFlusher: true,
+		},
+		{
+			name:        "HTTP1",
+			w:           hijackWriter{ResponseRecorder: httptest.NewRecorder()},
+			protoMajor:  1,
+			wantFlusher: true,
+			wantHijack:  true,
+		},
+		{
+			name:        "HTTP2",
+			w:           flushPushWriter{httptest.NewRecorder()},
+			protoMajor:  2,
+			wantFlusher: true,
+			wantPusher:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rw := newStatusRecorder(tt.w, tt.protoMajor, 0)
+			if _, ok := rw.(http.Flusher); ok != tt.wantFlusher {
+				t.Errorf("http.Flusher = %t, want %t", ok, tt.wantFlusher)
+			}
+			if _, ok := rw.(http.Hijacker); ok != tt.wantHijack {
+				t.Errorf("http.Hijacker = %t, want %t", ok, tt.wantHijack)
+			}
+			if _, ok := rw.(http.Pusher); ok != tt.wantPusher {
+				t.Errorf("http.Pusher = %t, want %t", ok, tt.wantPusher)
+			}
+		})
+	}
+}
+
+func TestStatusRecorderStatus(t *testing.T) {
+	rw := newStatusRecorder(httptest.NewRecorder(), 1, 0)
+	if rw.WroteHeader() {
+		t.Error("WroteHeader() = true before writing")
+	}
+	if got := rw.Status(); got != http.StatusOK {
+		t.Errorf("Status() = %d, want %d", got, http.StatusOK)
+	}
+	rw.WriteHeader(http.StatusNotFound)
+	rw.WriteHeader(http.StatusInternalServerError) // superfluous, ignored
+	if got := rw.Status(); got != http.StatusNotFound {
+		t.Errorf("Status() = %d, want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestStatusRecorderInformationalStatus(t *testing.T) {
+	rw := newStatusRecorder(httptest.NewRecorder(), 1, 0)
+	rw.WriteHeader(http.StatusEarlyHints)
+	if rw.WroteHeader() {
+		t.Error("WroteHeader() = true after an informational status")
+	}
+	rw.WriteHeader(http.StatusCreated)
+	if got := rw.Status(); got != http.StatusCreated {
+		t.Errorf("Status() = %d, want %d", got, http.StatusCreated)
+	}
+
+	rw = newStatusRecorder(httptest.NewRecorder(), 1, 0)
+	rw.WriteHeader(http.StatusSwitchingProtocols)
+	if got := rw.Status(); got != http.StatusSwitchingProtocols {
+		t.Errorf("Status() = %d, want %d", got, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestStatusRecorderBody(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newStatusRecorder(w, 1, 8)
+	for _, b := range []string{"internal", " error"} {
+		if n, err := rw.Write([]byte(b)); n != len(b) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", b, n, err)
+		}
+	}
+	if got := string(rw.Body()); got != "internal" {
+		t.Errorf("Body() = %q, want %q", got, "internal")
+	}
+	// The response is left untouched.
+	if got := w.Body.String(); got != "internal error" {
+		t.Errorf("response body = %q, want %q", got, "internal error")
+	}
+
+	rw = newStatusRecorder(httptest.NewRecorder(), 1, 0)
+	_, _ = rw.Write([]byte("not recorded"))
+	if got := rw.Body(); got != nil {
+		t.Errorf("Body() = %q, want nil", got)
+	}
+}
@@ -0,0 +1,34 @@
+package sentryhttp
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ServeMuxRoute returns a function that returns the path of the pattern of the
+// handler of mux matching the request, for example "/users/", or the empty
+// string if no handler matched. A nil mux stands for http.DefaultServeMux.
+//
+// The method and host of the pattern are left out, such that the pattern
+// "GET example.com/users/{id}" gives the route "/users/{id}".
+//
+// Use it as Options.TransactionName when the Handler wraps mux.
+func ServeMuxRoute(mux *http.ServeMux) func(r *http.Request) string {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return patternPath(pattern)
+	}
+}
+
+// patternPath returns the path of a ServeMux pattern, of the form
+// "[METHOD ][HOST]/[PATH]".
+func patternPath(pattern string) string {
+	i := strings.IndexByte(pattern, '/')
+	if i < 0 {
+		return pattern
+	}
+	return pattern[i:]
+}
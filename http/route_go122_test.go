@@ -0,0 +1,60 @@
+//go:build go1.22
+
+//go:debug httpmuxgo121=0
+
+package sentryhttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	sentryhttp "github.com/getsentry/sentry-go/http"
+	"github.com/getsentry/sentry-go/sentrytest"
+)
+
+func TestServeMuxRouteMethodPattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST example.com/orders/", func(w http.ResponseWriter, r *http.Request) {})
+
+	route := sentryhttp.ServeMuxRoute(mux)
+	tests := []struct {
+		method, target string
+		want           string
+	}{
+		{http.MethodGet, "/users/123", "/users/{id}"},
+		{http.MethodPost, "http://example.com/orders/1", "/orders/"},
+		{http.MethodGet, "/unknown", ""},
+	}
+	for _, tt := range tests {
+		if got := route(httptest.NewRequest(tt.method, tt.target, nil)); got != tt.want {
+			t.Errorf("route(%s %s) = %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestWrapServeMuxMethodPattern(t *testing.T) {
+	transport := &sentrytest.Transport{}
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	sentryhttp.WrapServeMux(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	tx := transport.LastEvent()
+	if tx == nil {
+		t.Fatal("missing transaction")
+	}
+	if want := "GET /users/{id}"; tx.Transaction != want || tx.TransactionInfo.Source != sentry.SourceRoute {
+		t.Errorf("got transaction %q with source %q, want %q with source %q", tx.Transaction, tx.TransactionInfo.Source, want, sentry.SourceRoute)
+	}
+}
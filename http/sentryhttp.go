@@ -3,9 +3,15 @@
 package sentryhttp
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -14,9 +20,23 @@ import (
 // A Handler is an HTTP middleware factory that provides integration with
 // Sentry.
 type Handler struct {
-	repanic         bool
-	waitForDelivery bool
-	timeout         time.Duration
+	repanic             bool
+	waitForDelivery     bool
+	timeout             time.Duration
+	shouldCapture       func(r *http.Request, status int) bool
+	scrubHeaders        map[string]struct{}
+	maxRequestBodySize  int
+	maxResponseBodySize int
+	captureAbort        bool
+	captureServerError  bool
+	addEventIDHeader    bool
+	addResponseCrumb    bool
+	recoverHandler      func(hub *sentry.Hub, r *http.Request, recovered interface{}) *sentry.EventID
+	routeParams         func(r *http.Request) map[string]string
+	hub                 func(r *http.Request) *sentry.Hub
+	scopeModifier       func(r *http.Request, scope *sentry.Scope)
+	transactionName     func(r *http.Request) string
+	skipWrapping        func(r *http.Request) bool
 }
 
 // Options configure a Handler.
@@ -41,9 +61,180 @@ type Options struct {
 	// Timeout for the delivery of panic events. Defaults to 2s. Only relevant
 	// when WaitForDelivery is true.
 	//
-	// If the timeout is reached, the current goroutine is no longer blocked
-	// waiting, but the delivery is not canceled.
+	// If the timeout is reached, or the request context is done before, for
+	// example because the client disconnected or its deadline is sooner, the
+	// current goroutine is no longer blocked waiting, but the delivery is not
+	// canceled.
 	Timeout time.Duration
+	// ShouldCapture, if set, is called before the handler reports an event
+	// for a request, for example a recovered panic. The status is the HTTP
+	// response status code; for panics, it is the status written by the
+	// handler before panicking, or 500 if none was written. Returning false
+	// prevents the event from being sent, without affecting Repanic.
+	//
+	// By default, all events are reported.
+	ShouldCapture func(r *http.Request, status int) bool
+	// ScrubHeaders lists the names of request headers that are never sent to
+	// Sentry. Names are matched case-insensitively. Defaults to
+	// DefaultScrubHeaders when nil; set it to an empty, non-nil slice to send
+	// all headers.
+	//
+	// Note that, unless ClientOptions.SendDefaultPII is enabled, the SDK
+	// additionally omits a few headers known to contain personally
+	// identifiable information.
+	ScrubHeaders []string
+	// MaxRequestBodySize configures the middleware to read up to the given
+	// number of bytes from the request body before calling the wrapped
+	// handler, such that the body is reported even if the handler does not
+	// read it, for example if it panics early. The wrapped handler still sees
+	// the full body.
+	//
+	// Only textual bodies, like JSON or form data, are read. Bodies are
+	// reported in full or not at all: a body larger than MaxRequestBodySize
+	// is left out of events entirely rather than truncated, as a partial JSON
+	// or form body cannot be parsed. The SDK never reports bodies larger than
+	// 10 KB, regardless of this option.
+	//
+	// By default, the body is only reported if read by the wrapped handler.
+	MaxRequestBodySize int
+	// CaptureAbortHandler configures whether to report panics with the
+	// http.ErrAbortHandler value. By default, they are not reported, because
+	// net/http uses them to abort a response on purpose, and they are
+	// suppressed by the net/http server itself.
+	CaptureAbortHandler bool
+	// CaptureServerErrors configures whether to report responses with a 5xx
+	// status code written by handlers that returned without panicking. The
+	// event is a message with the status and the request method and path, or
+	// route if known, for example "500 Internal Server Error: GET /users/{id}".
+	//
+	// Responses are not reported if an event was already captured with the
+	// hub of the request while handling it, for example by the handler itself
+	// or by a nested Handler that recovered from a panic. ShouldCapture
+	// applies to these events as well.
+	CaptureServerErrors bool
+	// MaxResponseBodySize configures the middleware to record up to the given
+	// number of bytes of the response body written by the wrapped handler,
+	// and to attach them to the events reported for 5xx responses by
+	// CaptureServerErrors, in the "response" context, as the error payload
+	// often tells what went wrong. The response sent to the client is not
+	// changed.
+	//
+	// Only textual bodies, like JSON or plain text, are attached, based on
+	// the Content-Type of the response, or the type detected from its body
+	// when not set. Longer bodies are truncated, to at most 10 KB regardless
+	// of this option. By default, no response body is recorded.
+	MaxResponseBodySize int
+	// AddEventIDHeader configures whether to set the EventIDHeader response
+	// header to the ID of the event reported for a recovered panic, such that
+	// the event can be looked up from the response, for example by support
+	// staff. The header is set before repanicking or resuming normal
+	// execution, so it is sent if the response is written afterwards, for
+	// example by an outer panic handler.
+	//
+	// The header is not set if the wrapped handler already wrote the response
+	// headers before panicking.
+	AddEventIDHeader bool
+	// AddResponseBreadcrumb configures whether to add a breadcrumb with the
+	// method, path, status code and duration of the request to its hub when
+	// the handler returns, such that events captured later with the hub, for
+	// example by goroutines started by the handler, show how the request went
+	// without tracing it. Its level is error for 5xx responses, warning for 4xx
+	// responses and info otherwise.
+	AddResponseBreadcrumb bool
+	// RecoverHandler, if set, is called instead of the default reporting to
+	// turn a recovered panic into an event, with the request-specific hub, the
+	// request, and the value passed to panic. Use it to fully customize the
+	// event, for example to set its level or fingerprint based on the type of
+	// the panic value, and report it with hub.RecoverWithContext or
+	// hub.CaptureEvent. It returns the ID of the reported event, or nil if
+	// none was reported.
+	//
+	// The middleware still takes care of ShouldCapture, AddEventIDHeader,
+	// WaitForDelivery and Repanic.
+	RecoverHandler func(hub *sentry.Hub, r *http.Request, recovered interface{}) *sentry.EventID
+	// RouteParams, if set, returns the parameters extracted from the URL path
+	// by the router for a request, for example {"id": "123"} for the route
+	// "/users/{id}". They are stored in the event context under
+	// RouteParamsContextKey, keeping the concrete values available on events
+	// while transactions are named after the route template. See the
+	// sentrygorillamux and sentrychi packages.
+	//
+	// Like TransactionName, it must run after the router matched the request.
+	// If the router only matches the request after its middleware ran, as chi
+	// does, the parameters are only added to panics reported by the Handler.
+	RouteParams func(r *http.Request) map[string]string
+	// Hub, if set, returns the hub requests are reported to, for example the
+	// hub of the tenant of a request in a multi-tenant server, bound to a
+	// client with the DSN of the tenant with Hub.BindClient. The Handler uses
+	// a clone of the returned hub for the request, such that changes to the
+	// scope of a request never leak into the returned hub.
+	//
+	// Hub takes precedence over a hub stored in the request context by an
+	// outer middleware. When Hub is nil or returns nil, the hub stored in the
+	// request context, if any, is used as is, and a clone of the current hub
+	// otherwise.
+	Hub func(r *http.Request) *sentry.Hub
+	// ScopeModifier, if set, is called for every request before calling the
+	// wrapped handler. Use it to enrich all events reported for a request with
+	// data derived from the request, for example with scope.SetTag or
+	// scope.SetUser.
+	//
+	// The scope belongs to the request-specific hub, so changes never leak
+	// into other requests.
+	ScopeModifier func(r *http.Request, scope *sentry.Scope)
+	// TransactionName, if set, returns the name of the transaction recorded
+	// for a request. It is typically used to name transactions after route
+	// templates like "/users/{id}" instead of concrete URL paths like
+	// "/users/123", grouping related requests together. See ServeMuxRoute,
+	// and the sentrygorillamux and sentrychi packages for other routers.
+	//
+	// For route templates to be available, the Handler must be registered such
+	// that it runs after the router matched the request, for example as a
+	// router middleware.
+	//
+	// If TransactionName is nil or returns the empty string, transactions are
+	// named after the request method and URL path.
+	TransactionName func(r *http.Request) string
+	// SkipResponseWrapping, if set, returns true for the requests whose
+	// http.ResponseWriter is passed as is to the wrapped handler, instead of
+	// a wrapper recording the response status. Use it for routes relying on
+	// interfaces of the writer that the wrapper does not implement, for
+	// example with some WebSocket libraries. Their status is unknown to the
+	// Handler, so it is reported as 200 and CaptureServerErrors does not
+	// apply to them.
+	//
+	// Connections hijacked through the wrapper, as by WebSocket upgrades, are
+	// supported without it: the status is recorded as 101 Switching
+	// Protocols. Either way, the transaction of the request lasts until the
+	// wrapped handler returns, and panics are recovered until then, for the
+	// whole lifetime of a connection served by the handler.
+	SkipResponseWrapping func(r *http.Request) bool
+}
+
+// RouteSamplingContextKey is the key of the route of a request, as returned by
+// Options.TransactionName, in the custom data of the SamplingContext passed to
+// the TracesSampler of the client.
+const RouteSamplingContextKey = "http.route"
+
+// RouteParamsContextKey is the key of the event context holding the route
+// parameters returned by Options.RouteParams.
+const RouteParamsContextKey = "route_params"
+
+// maxResponseBodyBytes is the maximum size of the response bodies attached to
+// events, see Options.MaxResponseBodySize.
+const maxResponseBodyBytes = 10 * 1024
+
+// EventIDHeader is the response header set to the ID of the reported event when
+// Options.AddEventIDHeader is enabled.
+const EventIDHeader = "X-Sentry-Id"
+
+// DefaultScrubHeaders is the list of request headers removed from events when
+// Options.ScrubHeaders is nil.
+var DefaultScrubHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
 }
 
 // New returns a new Handler. Use the Handle and HandleFunc methods to wrap
@@ -53,16 +244,71 @@ func New(options Options) *Handler {
 	if timeout == 0 {
 		timeout = 2 * time.Second
 	}
-	return &Handler{
-		repanic:         options.Repanic,
-		timeout:         timeout,
-		waitForDelivery: options.WaitForDelivery,
+	scrubHeaders := options.ScrubHeaders
+	if scrubHeaders == nil {
+		scrubHeaders = DefaultScrubHeaders
+	}
+	h := &Handler{
+		repanic:            options.Repanic,
+		timeout:            timeout,
+		waitForDelivery:    options.WaitForDelivery,
+		shouldCapture:      options.ShouldCapture,
+		scrubHeaders:       make(map[string]struct{}, len(scrubHeaders)),
+		maxRequestBodySize: options.MaxRequestBodySize,
+		captureAbort:       options.CaptureAbortHandler,
+		captureServerError: options.CaptureServerErrors,
+		addEventIDHeader:   options.AddEventIDHeader,
+		addResponseCrumb:   options.AddResponseBreadcrumb,
+		recoverHandler:     options.RecoverHandler,
+		routeParams:        options.RouteParams,
+		hub:                options.Hub,
+		scopeModifier:      options.ScopeModifier,
+		transactionName:    options.TransactionName,
+		skipWrapping:       options.SkipResponseWrapping,
 	}
+	for _, name := range scrubHeaders {
+		h.scrubHeaders[strings.ToLower(name)] = struct{}{}
+	}
+	if options.CaptureServerErrors {
+		h.maxResponseBodySize = options.MaxResponseBodySize
+		if h.maxResponseBodySize > maxResponseBodyBytes {
+			h.maxResponseBodySize = maxResponseBodyBytes
+		}
+	}
+	return h
+}
+
+// WrapServeMux returns a handler recovering from and reporting panics of all
+// the handlers registered on mux, with default options, for existing apps
+// serving a single mux:
+//
+//	http.HandleFunc("/", handler)
+//	http.ListenAndServe(addr, sentryhttp.WrapServeMux(nil))
+//
+// A nil mux stands for http.DefaultServeMux. Transactions are named after the
+// pattern of the matched handler, see ServeMuxRoute. To configure the
+// middleware, use New(options).Handle(mux) instead.
+func WrapServeMux(mux *http.ServeMux) http.Handler {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	return New(Options{TransactionName: ServeMuxRoute(mux)}).Handle(mux)
 }
 
 // Handle works as a middleware that wraps an existing http.Handler. A wrapped
 // handler will recover from and report panics to Sentry, and provide access to
 // a request-specific hub to report messages and errors.
+//
+// When tracing is enabled in the SDK (see sentry.ClientOptions.EnableTracing),
+// every request is also recorded as a transaction with op "http.server". The
+// transaction is stored in the request context, so that wrapped handlers and
+// middleware can start child spans with sentry.StartSpan(r.Context(), ...), or
+// retrieve the transaction and the current span with
+// sentry.TransactionFromContext and sentry.SpanFromContext.
+//
+// If the request context is done when the wrapped handler returns or panics,
+// because its deadline was exceeded or it was canceled, a breadcrumb with the
+// elapsed time is added to the scope, surfacing slow or aborted requests.
 func (h *Handler) Handle(handler http.Handler) http.Handler {
 	return h.handle(handler)
 }
@@ -82,46 +328,366 @@ func (h *Handler) handle(handler http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		hub := sentry.GetHubFromContext(ctx)
+		if h.hub != nil {
+			if base := h.hub(r); base != nil {
+				hub = base.Clone()
+				ctx = sentry.SetHubOnContext(ctx, hub)
+			}
+		}
 		if hub == nil {
 			hub = sentry.CurrentHub().Clone()
 			ctx = sentry.SetHubOnContext(ctx, hub)
 		}
+		route := h.route(r)
+		name, source := h.name(r, route)
 		options := []sentry.SpanOption{
 			sentry.WithOpName("http.server"),
 			sentry.ContinueFromRequest(r),
-			sentry.WithTransactionSource(sentry.SourceURL),
+			sentry.WithTransactionSource(source),
+		}
+		if route != "" {
+			options = append(options, sentry.WithCustomSamplingContext(map[string]interface{}{
+				RouteSamplingContextKey: route,
+			}))
 		}
 		// We don't mind getting an existing transaction back so we don't need to
 		// check if it is.
-		transaction := sentry.StartTransaction(ctx,
-			fmt.Sprintf("%s %s", r.Method, r.URL.Path),
-			options...,
-		)
-		defer transaction.Finish()
-		// TODO(tracing): if the next handler.ServeHTTP panics, store
-		// information on the transaction accordingly (status, tag,
-		// level?, ...).
+		transaction := sentry.StartTransaction(ctx, name, options...)
+		defer func() {
+			// Some routers, for example chi, only match the route after
+			// their middleware ran, so try again to name the transaction
+			// after the route.
+			if source != sentry.SourceRoute {
+				if name, source := h.name(r, h.route(r)); source == sentry.SourceRoute {
+					transaction.Name, transaction.Source = name, source
+				}
+			}
+			transaction.Finish()
+		}()
 		r = r.WithContext(transaction.Context())
-		hub.Scope().SetRequest(r)
-		defer h.recoverWithSentry(hub, r)
-		// TODO(tracing): use custom response writer to intercept
-		// response. Use HTTP status to add tag to transaction; set span
-		// status.
-		handler.ServeHTTP(w, r)
+		hub.Scope().SetTransaction(name)
+		h.setRequest(hub.Scope(), r)
+		h.setRouteParams(hub.Scope(), r)
+		setContextTags(hub, r)
+		if h.scopeModifier != nil {
+			h.scopeModifier(r, hub.Scope())
+		}
+		rw := newStatusRecorder(w, r.ProtoMajor, h.maxResponseBodySize)
+		next := http.ResponseWriter(rw)
+		if h.skipWrapping != nil && h.skipWrapping(r) {
+			// The handler writes to w directly, so rw keeps the default
+			// status.
+			next = w
+		}
+		start := time.Now()
+		lastEventID := hub.LastEventID()
+		defer h.recoverWithSentry(hub, r, rw, transaction, start)
+		handler.ServeHTTP(next, r)
+		addContextDoneBreadcrumb(hub, r, start)
+		if h.addResponseCrumb {
+			addResponseBreadcrumb(hub, r, rw.Status(), start)
+		}
+		transaction.Status = sentry.HTTPtoSpanStatus(rw.Status())
+		if h.captureServerError && rw.Status() >= http.StatusInternalServerError &&
+			hub.LastEventID() == lastEventID && h.shouldReport(r, nil, rw.Status()) {
+			h.reportServerError(hub, r, rw)
+		}
+	}
+}
+
+// reportServerError sends an event for the response with a 5xx status
+// recorded by rw, written for r by a handler that returned without panicking.
+// See Options.CaptureServerErrors.
+func (h *Handler) reportServerError(hub *sentry.Hub, r *http.Request, rw statusRecorder) {
+	status := rw.Status()
+	// The route may only be known once the handler ran.
+	h.setRouteParams(hub.Scope(), r)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("http.status_code", strconv.Itoa(status))
+		if body := rw.Body(); len(body) > 0 && isTextResponse(rw, body) {
+			scope.SetContext("response", sentry.Context{
+				"status_code": status,
+				"data":        string(body),
+			})
+		}
+		name, source := h.name(r, h.route(r))
+		if source == sentry.SourceRoute {
+			scope.SetTransaction(name)
+		}
+		scope.SetLevel(sentry.LevelError)
+		hub.CaptureMessage(fmt.Sprintf("%d %s: %s", status, http.StatusText(status), name))
+	})
+}
+
+// name returns the transaction name for r, matching route if not empty, and
+// its source.
+func (h *Handler) name(r *http.Request, route string) (string, sentry.TransactionSource) {
+	if route != "" {
+		return fmt.Sprintf("%s %s", r.Method, route), sentry.SourceRoute
+	}
+	return fmt.Sprintf("%s %s", r.Method, r.URL.Path), sentry.SourceURL
+}
+
+// route returns the route matched by r, as returned by the TransactionName
+// option, or an empty string.
+func (h *Handler) route(r *http.Request) string {
+	if h.transactionName == nil {
+		return ""
+	}
+	return h.transactionName(r)
+}
+
+// setContextTags sets the tags returned by the ContextTags client option for
+// the context of r on the scope of hub.
+func setContextTags(hub *sentry.Hub, r *http.Request) {
+	client := hub.Client()
+	if client == nil {
+		return
+	}
+	if contextTags := client.Options().ContextTags; contextTags != nil {
+		hub.Scope().SetTags(contextTags(r.Context()))
+	}
+}
+
+// setRouteParams stores the route parameters of r, as returned by the
+// RouteParams option, in the scope context, unless there are none.
+func (h *Handler) setRouteParams(scope *sentry.Scope, r *http.Request) {
+	if h.routeParams == nil {
+		return
+	}
+	params := h.routeParams(r)
+	if len(params) == 0 {
+		return
+	}
+	ctx := make(sentry.Context, len(params))
+	for k, v := range params {
+		ctx[k] = v
+	}
+	scope.SetContext(RouteParamsContextKey, ctx)
+}
+
+// setRequest stores a copy of r without the scrubbed headers on the scope.
+//
+// If MaxRequestBodySize is set, the body is read upfront and r.Body is replaced
+// with a reader that returns the same bytes. Otherwise, the body of r is
+// replaced such that reads from the wrapped handler are recorded for the scope.
+func (h *Handler) setRequest(scope *sentry.Scope, r *http.Request) {
+	sr := *r
+	if len(h.scrubHeaders) > 0 {
+		sr.Header = make(http.Header, len(r.Header))
+		for name, values := range r.Header {
+			if _, ok := h.scrubHeaders[strings.ToLower(name)]; !ok {
+				sr.Header[name] = values
+			}
+		}
+	}
+	if h.maxRequestBodySize > 0 && isTextContent(r.Header.Get("Content-Type")) {
+		// Prevent the scope from buffering the body lazily, sr.Body is
+		// never read.
+		sr.Body = nil
+		scope.SetRequest(&sr)
+		if body, ok := readRequestBody(r, h.maxRequestBodySize); ok {
+			scope.SetRequestBody(body)
+		}
+		return
 	}
+	scope.SetRequest(&sr)
+	r.Body = sr.Body
 }
 
-func (h *Handler) recoverWithSentry(hub *sentry.Hub, r *http.Request) {
+// readRequestBody reads up to max bytes from r.Body and replaces r.Body with a
+// reader that yields the full original body. It returns false if the body is
+// larger than max bytes or could not be read.
+func readRequestBody(r *http.Request, max int) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength > int64(max) {
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(max)+1))
+	r.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(body), r.Body),
+		Closer: r.Body,
+	}
+	if err != nil || len(body) > max {
+		return nil, false
+	}
+	return body, true
+}
+
+// isTextContent reports whether a body is likely to be human readable, based
+// on its Content-Type. Binary and multipart bodies are not worth sending to
+// Sentry.
+func isTextContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		mediaType == "application/x-www-form-urlencoded",
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	default:
+		return false
+	}
+}
+
+// isTextResponse reports whether the response recorded by rw, starting with
+// body, is textual. Like net/http, it detects the content type from the body
+// if the handler did not set it.
+func isTextResponse(rw statusRecorder, body []byte) bool {
+	contentType := rw.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	return isTextContent(contentType)
+}
+
+// readCloser combines an io.Reader and an io.Closer to implement io.ReadCloser.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (h *Handler) recoverWithSentry(hub *sentry.Hub, r *http.Request, rw statusRecorder, transaction *sentry.Span, start time.Time) {
 	if err := recover(); err != nil {
-		eventID := hub.RecoverWithContext(
-			context.WithValue(r.Context(), sentry.RequestContextKey, r),
-			err,
-		)
-		if eventID != nil && h.waitForDelivery {
-			hub.Flush(h.timeout)
+		addContextDoneBreadcrumb(hub, r, start)
+		// The transaction is finished by a deferred call in handle, after
+		// recoverWithSentry returns or repanics. Mark it as failed so that
+		// the panic is reflected in the Performance dashboard.
+		transaction.Status = sentry.SpanStatusInternalError
+		status := http.StatusInternalServerError
+		if rw.WroteHeader() {
+			status = rw.Status()
+		}
+		if h.shouldReport(r, err, status) {
+			// The route may only be known once the handler ran.
+			h.setRouteParams(hub.Scope(), r)
+			var eventID *sentry.EventID
+			if h.recoverHandler != nil {
+				eventID = h.recoverHandler(hub, r, err)
+			} else {
+				eventID = h.report(hub, r, rw, status, err)
+			}
+			if eventID != nil && h.addEventIDHeader {
+				// Headers can no longer be changed once written.
+				if rw.WroteHeader() {
+					sentry.Logger.Printf("Could not set the %s header of event %s: the response headers were already written.", EventIDHeader, *eventID)
+				} else {
+					rw.Header().Set(EventIDHeader, string(*eventID))
+				}
+			}
+			if eventID != nil && h.waitForDelivery {
+				// Stop waiting early if the client disconnects, the
+				// server shuts down or the request deadline is sooner.
+				ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+				hub.FlushWithContext(ctx)
+				cancel()
+			}
 		}
 		if h.repanic {
 			panic(err)
 		}
 	}
 }
+
+// report sends the recovered panic value err to Sentry, unless
+// Options.RecoverHandler is set. The event is tagged with status, the status
+// code of the response sent for the panic.
+func (h *Handler) report(hub *sentry.Hub, r *http.Request, rw statusRecorder, status int, err interface{}) *sentry.EventID {
+	var eventID *sentry.EventID
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("http.status_code", strconv.Itoa(status))
+		// The route may only be known once the handler ran.
+		if name, source := h.name(r, h.route(r)); source == sentry.SourceRoute {
+			scope.SetTransaction(name)
+		}
+		// A panic is reported as fatal, unless the handler already
+		// committed to an error response.
+		var level sentry.Level
+		if rw.WroteHeader() {
+			level = levelForStatus(status)
+		}
+		eventID = hub.CaptureRecovered(
+			context.WithValue(r.Context(), sentry.RequestContextKey, r),
+			err,
+			level,
+		)
+	})
+	return eventID
+}
+
+// addContextDoneBreadcrumb adds a breadcrumb to the scope of hub if the context
+// of r is done, because its deadline was exceeded or it was canceled, for
+// example when the client disconnected. start is when handling r started.
+func addContextDoneBreadcrumb(hub *sentry.Hub, r *http.Request, start time.Time) {
+	ctx := r.Context()
+	err := ctx.Err()
+	if err == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	data := map[string]interface{}{
+		"elapsed_ms": elapsed.Milliseconds(),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		data["deadline"] = deadline.Format(time.RFC3339Nano)
+	}
+	message := fmt.Sprintf("Request context canceled after %s", elapsed.Round(time.Millisecond))
+	if errors.Is(err, context.DeadlineExceeded) {
+		message = fmt.Sprintf("Request deadline exceeded after %s", elapsed.Round(time.Millisecond))
+	}
+	hub.AddBreadcrumb(&sentry.Breadcrumb{
+		Category: "http",
+		Level:    sentry.LevelWarning,
+		Message:  message,
+		Data:     data,
+	}, nil)
+}
+
+// addResponseBreadcrumb records the response with the given status written
+// for r, started at start, see Options.AddResponseBreadcrumb.
+func addResponseBreadcrumb(hub *sentry.Hub, r *http.Request, status int, start time.Time) {
+	level := sentry.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = sentry.LevelError
+	case status >= http.StatusBadRequest:
+		level = sentry.LevelWarning
+	}
+	hub.AddBreadcrumb(&sentry.Breadcrumb{
+		Type:     "http",
+		Category: "http.server",
+		Level:    level,
+		Data: map[string]interface{}{
+			"method":      r.Method,
+			"url":         r.URL.Path,
+			"status_code": status,
+			"duration_ms": time.Since(start).Milliseconds(),
+		},
+	}, nil)
+}
+
+// shouldReport reports whether a recovered panic value err should be sent to
+// Sentry.
+func (h *Handler) shouldReport(r *http.Request, err interface{}, status int) bool {
+	if err == http.ErrAbortHandler && !h.captureAbort {
+		return false
+	}
+	return h.shouldCapture == nil || h.shouldCapture(r, status)
+}
+
+// levelForStatus returns the event level corresponding to an HTTP response
+// status code, or the empty string for non-error responses.
+func levelForStatus(code int) sentry.Level {
+	switch {
+	case code >= http.StatusInternalServerError:
+		return sentry.LevelError
+	case code >= http.StatusBadRequest:
+		return sentry.LevelWarning
+	default:
+		return ""
+	}
+}
@@ -1,18 +1,28 @@
 package sentryhttp_test
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	sentryhttp "github.com/getsentry/sentry-go/http"
+	sentrychi "github.com/getsentry/sentry-go/http/chi"
+	sentrygorillamux "github.com/getsentry/sentry-go/http/gorillamux"
+	"github.com/getsentry/sentry-go/sentrytest"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/gorilla/mux"
 )
 
 func TestIntegration(t *testing.T) {
@@ -24,7 +34,8 @@ func TestIntegration(t *testing.T) {
 		Body    string
 		Handler http.Handler
 
-		WantEvent *sentry.Event
+		WantEvent       *sentry.Event
+		WantTransaction *sentry.Event
 	}{
 		{
 			Path: "/panic",
@@ -32,9 +43,29 @@ func TestIntegration(t *testing.T) {
 				panic("test")
 			}),
 
+			WantTransaction: &sentry.Event{
+				Level:       sentry.LevelInfo,
+				Type:        "transaction",
+				Transaction: "GET /panic",
+				Request: &sentry.Request{
+					URL:    "/panic",
+					Method: "GET",
+					Headers: map[string]string{
+						"Accept-Encoding": "gzip",
+						"User-Agent":      "Go-http-client/1.1",
+					},
+				},
+				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+			},
 			WantEvent: &sentry.Event{
-				Level:   sentry.LevelFatal,
-				Message: "test",
+				Transaction: "GET /panic",
+				Level:       sentry.LevelFatal,
+				Message:     "test",
+				Exception: []sentry.Exception{{
+					Type:      "panic",
+					Value:     "test",
+					Mechanism: &sentry.Mechanism{Type: "generic", Handled: new(bool)},
+				}},
 				Request: &sentry.Request{
 					URL:    "/panic",
 					Method: "GET",
@@ -58,9 +89,26 @@ func TestIntegration(t *testing.T) {
 				hub.CaptureMessage("post: " + string(body))
 			}),
 
+			WantTransaction: &sentry.Event{
+				Level:       sentry.LevelInfo,
+				Type:        "transaction",
+				Transaction: "POST /post",
+				Request: &sentry.Request{
+					URL:    "/post",
+					Method: "POST",
+					Data:   "payload",
+					Headers: map[string]string{
+						"Accept-Encoding": "gzip",
+						"Content-Length":  "7",
+						"User-Agent":      "Go-http-client/1.1",
+					},
+				},
+				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+			},
 			WantEvent: &sentry.Event{
-				Level:   sentry.LevelInfo,
-				Message: "post: payload",
+				Transaction: "POST /post",
+				Level:       sentry.LevelInfo,
+				Message:     "post: payload",
 				Request: &sentry.Request{
 					URL:    "/post",
 					Method: "POST",
@@ -80,9 +128,24 @@ func TestIntegration(t *testing.T) {
 				hub.CaptureMessage("get")
 			}),
 
+			WantTransaction: &sentry.Event{
+				Level:       sentry.LevelInfo,
+				Type:        "transaction",
+				Transaction: "GET /get",
+				Request: &sentry.Request{
+					URL:    "/get",
+					Method: "GET",
+					Headers: map[string]string{
+						"Accept-Encoding": "gzip",
+						"User-Agent":      "Go-http-client/1.1",
+					},
+				},
+				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+			},
 			WantEvent: &sentry.Event{
-				Level:   sentry.LevelInfo,
-				Message: "get",
+				Transaction: "GET /get",
+				Level:       sentry.LevelInfo,
+				Message:     "get",
 				Request: &sentry.Request{
 					URL:    "/get",
 					Method: "GET",
@@ -106,9 +169,25 @@ func TestIntegration(t *testing.T) {
 				hub.CaptureMessage(fmt.Sprintf("post: %d KB", len(body)/1024))
 			}),
 
+			WantTransaction: &sentry.Event{
+				Level:       sentry.LevelInfo,
+				Type:        "transaction",
+				Transaction: "POST /post/large",
+				Request: &sentry.Request{
+					URL:    "/post/large",
+					Method: "POST",
+					Headers: map[string]string{
+						"Accept-Encoding": "gzip",
+						"Content-Length":  "15360",
+						"User-Agent":      "Go-http-client/1.1",
+					},
+				},
+				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+			},
 			WantEvent: &sentry.Event{
-				Level:   sentry.LevelInfo,
-				Message: "post: 15 KB",
+				Transaction: "POST /post/large",
+				Level:       sentry.LevelInfo,
+				Message:     "post: 15 KB",
 				Request: &sentry.Request{
 					URL:    "/post/large",
 					Method: "POST",
@@ -131,9 +210,25 @@ func TestIntegration(t *testing.T) {
 				hub.CaptureMessage("body ignored")
 			}),
 
+			WantTransaction: &sentry.Event{
+				Level:       sentry.LevelInfo,
+				Type:        "transaction",
+				Transaction: "POST /post/body-ignored",
+				Request: &sentry.Request{
+					URL:    "/post/body-ignored",
+					Method: "POST",
+					Headers: map[string]string{
+						"Accept-Encoding": "gzip",
+						"Content-Length":  "46",
+						"User-Agent":      "Go-http-client/1.1",
+					},
+				},
+				TransactionInfo: &sentry.TransactionInfo{Source: "url"},
+			},
 			WantEvent: &sentry.Event{
-				Level:   sentry.LevelInfo,
-				Message: "body ignored",
+				Transaction: "POST /post/body-ignored",
+				Level:       sentry.LevelInfo,
+				Message:     "body ignored",
 				Request: &sentry.Request{
 					URL:    "/post/body-ignored",
 					Method: "POST",
@@ -150,11 +245,18 @@ func TestIntegration(t *testing.T) {
 	}
 
 	eventsCh := make(chan *sentry.Event, len(tests))
+	transactionsCh := make(chan *sentry.Event, len(tests))
 	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
 		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
 			eventsCh <- event
 			return event
 		},
+		BeforeSendTransaction: func(tx *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			transactionsCh <- tx
+			return tx
+		},
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -177,11 +279,16 @@ func TestIntegration(t *testing.T) {
 	c.Timeout = time.Second
 
 	var want []*sentry.Event
+	var wanttrans []*sentry.Event
 	for _, tt := range tests {
 		wantRequest := tt.WantEvent.Request
 		wantRequest.URL = srv.URL + wantRequest.URL
 		wantRequest.Headers["Host"] = srv.Listener.Addr().String()
 		want = append(want, tt.WantEvent)
+		wantTransaction := tt.WantTransaction.Request
+		wantTransaction.URL = srv.URL + wantTransaction.URL
+		wantTransaction.Headers["Host"] = srv.Listener.Addr().String()
+		wanttrans = append(wanttrans, tt.WantTransaction)
 
 		req, err := http.NewRequest(tt.Method, srv.URL+tt.Path, strings.NewReader(tt.Body))
 		if err != nil {
@@ -209,15 +316,1444 @@ func TestIntegration(t *testing.T) {
 		cmpopts.IgnoreFields(
 			sentry.Event{},
 			"Contexts", "EventID", "Extra", "Platform", "Modules",
-			"Release", "Sdk", "ServerName", "Tags", "Timestamp",
+			"Release", "Sdk", "ServerName", "Tags", "Threads", "Timestamp",
 			"sdkMetaData",
 		),
 		cmpopts.IgnoreFields(
 			sentry.Request{},
 			"Env",
 		),
+		cmpopts.IgnoreFields(
+			sentry.Exception{},
+			"Stacktrace",
+		),
 	}
 	if diff := cmp.Diff(want, got, opts); diff != "" {
 		t.Fatalf("Events mismatch (-want +got):\n%s", diff)
 	}
+
+	close(transactionsCh)
+	var gott []*sentry.Event
+	for e := range transactionsCh {
+		gott = append(gott, e)
+	}
+	optstrans := cmp.Options{
+		cmpopts.IgnoreFields(
+			sentry.Event{},
+			"Contexts", "EventID", "Platform", "Modules",
+			"Release", "Sdk", "ServerName", "Timestamp",
+			"sdkMetaData", "StartTime", "Spans",
+		),
+		cmpopts.IgnoreFields(
+			sentry.Request{},
+			"Env",
+		),
+	}
+	if diff := cmp.Diff(wanttrans, gott, optstrans); diff != "" {
+		t.Fatalf("Transaction mismatch (-want +got):\n%s", diff)
+	}
+	if status := gott[0].Contexts["trace"]["status"]; status != sentry.SpanStatusInternalError {
+		t.Errorf("Transaction status for panic = %v, want %v", status, sentry.SpanStatusInternalError)
+	}
+}
+
+func TestRecoverAfterWriteHeader(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 1)
+	err := sentry.Init(sentry.ClientOptions{
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{})
+	srv := httptest.NewServer(sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		panic("test")
+	}))
+	defer srv.Close()
+
+	c := srv.Client()
+	c.Timeout = time.Second
+	res, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	event := <-eventsCh
+	if event == nil {
+		t.Fatal("missing event")
+	}
+	if event.Level != sentry.LevelError {
+		t.Errorf("Level = %q, want %q", event.Level, sentry.LevelError)
+	}
+	if got := event.Tags["http.status_code"]; got != "503" {
+		t.Errorf(`Tags["http.status_code"] = %q, want "503"`, got)
+	}
+}
+
+func TestRecoverBeforeWriteHeader(t *testing.T) {
+	transport := &sentrytest.Transport{}
+	if err := sentry.Init(sentry.ClientOptions{Transport: transport}); err != nil {
+		t.Fatal(err)
+	}
+
+	var captureStatus int
+	sentryHandler := sentryhttp.New(sentryhttp.Options{
+		ShouldCapture: func(r *http.Request, status int) bool {
+			captureStatus = status
+			return true
+		},
+	})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	event := transport.LastEvent()
+	if event == nil {
+		t.Fatal("missing event")
+	}
+	// The tag matches the status seen by ShouldCapture.
+	if got := event.Tags["http.status_code"]; got != "500" || captureStatus != http.StatusInternalServerError {
+		t.Errorf(`Tags["http.status_code"] = %q and ShouldCapture got %d, want "500" and 500`, got, captureStatus)
+	}
+}
+
+func TestShouldCapture(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 2)
+	err := sentry.Init(sentry.ClientOptions{
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var statuses []int
+	sentryHandler := sentryhttp.New(sentryhttp.Options{
+		ShouldCapture: func(r *http.Request, status int) bool {
+			statuses = append(statuses, status)
+			return status >= http.StatusInternalServerError
+		},
+	})
+	srv := httptest.NewServer(sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/notfound" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		panic(r.URL.Path)
+	}))
+	defer srv.Close()
+
+	c := srv.Client()
+	c.Timeout = time.Second
+	for _, path := range []string{"/notfound", "/panic"} {
+		res, err := c.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	var got []string
+	for e := range eventsCh {
+		got = append(got, e.Message)
+	}
+	if diff := cmp.Diff([]string{"/panic"}, got); diff != "" {
+		t.Errorf("Events mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{http.StatusNotFound, http.StatusInternalServerError}, statuses); diff != "" {
+		t.Errorf("ShouldCapture statuses mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAddEventIDHeader(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 2)
+	err := sentry.Init(sentry.ClientOptions{
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{AddEventIDHeader: true})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/written" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		panic("test")
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	written := httptest.NewRecorder()
+	handler.ServeHTTP(written, httptest.NewRequest(http.MethodGet, "/written", nil))
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	event := <-eventsCh
+	if event == nil {
+		t.Fatal("missing event")
+	}
+	if got := rec.Header().Get(sentryhttp.EventIDHeader); got != string(event.EventID) {
+		t.Errorf("%s header = %q, want %q", sentryhttp.EventIDHeader, got, event.EventID)
+	}
+	// The header cannot be added after the response headers were written.
+	if got := written.Result().Header.Get(sentryhttp.EventIDHeader); got != "" {
+		t.Errorf("%s header = %q, want none", sentryhttp.EventIDHeader, got)
+	}
+}
+
+// slowTransport never completes flushing, until the flush context is done.
+type slowTransport struct{}
+
+func (slowTransport) Configure(sentry.ClientOptions) {}
+func (slowTransport) SendEvent(*sentry.Event)        {}
+func (slowTransport) Flush(timeout time.Duration) bool {
+	time.Sleep(timeout)
+	return false
+}
+func (slowTransport) FlushWithContext(ctx context.Context) bool {
+	<-ctx.Done()
+	return false
+}
+
+func TestWaitForDeliveryCanceledRequest(t *testing.T) {
+	err := sentry.Init(sentry.ClientOptions{Transport: slowTransport{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{WaitForDelivery: true, Timeout: time.Minute})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler waited for delivery after the request was canceled")
+	}
+}
+
+func TestContextDoneBreadcrumb(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 1)
+	transactionsCh := make(chan *sentry.Event, 2)
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+		BeforeSendTransaction: func(tx *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			transactionsCh <- tx
+			return tx
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		if r.URL.Path == "/panic" {
+			panic("test")
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil).WithContext(ctx))
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/canceled", nil).WithContext(ctx))
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	close(transactionsCh)
+
+	checkBreadcrumb := func(name string, event *sentry.Event, wantMessage string, wantDeadline bool) {
+		t.Helper()
+		if event == nil {
+			t.Fatalf("missing %s", name)
+		}
+		if len(event.Breadcrumbs) != 1 {
+			t.Fatalf("%s has %d breadcrumbs, want 1", name, len(event.Breadcrumbs))
+		}
+		b := event.Breadcrumbs[0]
+		if b.Category != "http" || b.Level != sentry.LevelWarning || !strings.HasPrefix(b.Message, wantMessage) {
+			t.Errorf("%s breadcrumb = %+v, want a warning starting with %q", name, b, wantMessage)
+		}
+		if _, ok := b.Data["elapsed_ms"]; !ok {
+			t.Errorf("%s breadcrumb data %v is missing elapsed_ms", name, b.Data)
+		}
+		if _, ok := b.Data["deadline"]; ok != wantDeadline {
+			t.Errorf("%s breadcrumb data %v has deadline: %t, want %t", name, b.Data, ok, wantDeadline)
+		}
+	}
+	checkBreadcrumb("panic event", <-eventsCh, "Request deadline exceeded after", true)
+	<-transactionsCh
+	checkBreadcrumb("canceled transaction", <-transactionsCh, "Request context canceled after", false)
+}
+
+func TestAddResponseBreadcrumb(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 3)
+	err := sentry.Init(sentry.ClientOptions{
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	for _, add := range []bool{true, false} {
+		sentryHandler := sentryhttp.New(sentryhttp.Options{AddResponseBreadcrumb: add})
+		hub := sentry.CurrentHub().Clone()
+		for _, path := range []string{"/", "/missing"} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r = r.WithContext(sentry.SetHubOnContext(r.Context(), hub))
+			sentryHandler.HandleFunc(handler)(httptest.NewRecorder(), r)
+		}
+		hub.CaptureMessage("after the requests")
+	}
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	events := make([]*sentry.Event, 0, 2)
+	for e := range eventsCh {
+		events = append(events, e)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+
+	breadcrumbs := events[0].Breadcrumbs
+	if len(breadcrumbs) != 2 {
+		t.Fatalf("got %d breadcrumbs, want 2", len(breadcrumbs))
+	}
+	for i, want := range []struct {
+		url    string
+		status int
+		level  sentry.Level
+	}{
+		{"/", http.StatusOK, sentry.LevelInfo},
+		{"/missing", http.StatusNotFound, sentry.LevelWarning},
+	} {
+		b := breadcrumbs[i]
+		if b.Type != "http" || b.Category != "http.server" || b.Level != want.level {
+			t.Errorf("breadcrumb %d = %+v, want an http.server %s breadcrumb", i, b, want.level)
+		}
+		if b.Data["method"] != http.MethodGet || b.Data["url"] != want.url || b.Data["status_code"] != want.status {
+			t.Errorf("breadcrumb %d data = %v, want GET %s %d", i, b.Data, want.url, want.status)
+		}
+		if _, ok := b.Data["duration_ms"].(int64); !ok {
+			t.Errorf("breadcrumb %d data %v is missing duration_ms", i, b.Data)
+		}
+	}
+	if len(events[1].Breadcrumbs) != 0 {
+		t.Errorf("got breadcrumbs %v without AddResponseBreadcrumb, want none", events[1].Breadcrumbs)
+	}
+}
+
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e statusError) Error() string { return e.msg }
+
+func TestRecoverHandler(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 1)
+	err := sentry.Init(sentry.ClientOptions{
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handlerID *sentry.EventID
+	sentryHandler := sentryhttp.New(sentryhttp.Options{
+		AddEventIDHeader: true,
+		RecoverHandler: func(hub *sentry.Hub, r *http.Request, recovered interface{}) *sentry.EventID {
+			serr, ok := recovered.(statusError)
+			if !ok {
+				t.Errorf("recovered %#v, want a statusError", recovered)
+				return nil
+			}
+			hub.WithScope(func(scope *sentry.Scope) {
+				scope.SetLevel(sentry.LevelWarning)
+				scope.SetFingerprint([]string{"status", strconv.Itoa(serr.status)})
+				handlerID = hub.RecoverWithContext(r.Context(), serr)
+			})
+			return handlerID
+		},
+	})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(statusError{status: http.StatusConflict, msg: "conflict"})
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	event := <-eventsCh
+	if event == nil {
+		t.Fatal("missing event")
+	}
+	if event.Level != sentry.LevelWarning {
+		t.Errorf("Level = %q, want %q", event.Level, sentry.LevelWarning)
+	}
+	if diff := cmp.Diff([]string{"status", "409"}, event.Fingerprint); diff != "" {
+		t.Errorf("Fingerprint mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := event.Tags["http.status_code"]; ok {
+		t.Errorf("got tags %v, want none set by the default handler", event.Tags)
+	}
+	// The middleware still sets the header with the returned event ID.
+	if handlerID == nil || rec.Header().Get(sentryhttp.EventIDHeader) != string(*handlerID) {
+		t.Errorf("%s header = %q, want %v", sentryhttp.EventIDHeader, rec.Header().Get(sentryhttp.EventIDHeader), handlerID)
+	}
+}
+
+func TestContinueTraceFromHeaders(t *testing.T) {
+	const (
+		traceID      = "bc6d53f15eb88f4320054569b8c553d4"
+		parentSpanID = "b72fa28504b07285"
+	)
+
+	eventsCh := make(chan *sentry.Event, 1)
+	transactionsCh := make(chan *sentry.Event, 1)
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 0.0, // only sampled because of the incoming header
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+		BeforeSendTransaction: func(tx *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			transactionsCh <- tx
+			return tx
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{})
+	srv := httptest.NewServer(sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentry.GetHubFromContext(r.Context()).CaptureMessage("continued")
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(sentry.SentryTraceHeader, traceID+"-"+parentSpanID+"-1")
+	req.Header.Set(sentry.SentryBaggageHeader, "sentry-trace_id="+traceID+",sentry-public_key=public")
+	c := srv.Client()
+	c.Timeout = time.Second
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	close(transactionsCh)
+
+	tx := <-transactionsCh
+	if tx == nil {
+		t.Fatal("missing transaction")
+	}
+	txTrace := tx.Contexts["trace"]
+	if got := fmt.Sprint(txTrace["trace_id"]); got != traceID {
+		t.Errorf("transaction trace_id = %s, want %s", got, traceID)
+	}
+	if got := fmt.Sprint(txTrace["parent_span_id"]); got != parentSpanID {
+		t.Errorf("transaction parent_span_id = %s, want %s", got, parentSpanID)
+	}
+
+	event := <-eventsCh
+	if event == nil {
+		t.Fatal("missing event")
+	}
+	if got := fmt.Sprint(event.Contexts["trace"]["trace_id"]); got != traceID {
+		t.Errorf("event trace_id = %s, want %s", got, traceID)
+	}
+}
+
+func TestScrubHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		scrubHeaders []string
+		wantHeaders  []string
+		dropHeaders  []string
+	}{
+		{
+			name:        "Default",
+			wantHeaders: []string{"X-Custom"},
+			dropHeaders: []string{"Authorization", "Cookie", "X-Api-Key"},
+		},
+		{
+			name:         "Custom",
+			scrubHeaders: []string{"x-custom"},
+			wantHeaders:  []string{"Authorization", "Cookie", "X-Api-Key"},
+			dropHeaders:  []string{"X-Custom"},
+		},
+		{
+			name:         "Disabled",
+			scrubHeaders: []string{},
+			wantHeaders:  []string{"Authorization", "Cookie", "X-Api-Key", "X-Custom"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			eventsCh := make(chan *sentry.Event, 1)
+			err := sentry.Init(sentry.ClientOptions{
+				SendDefaultPII: true,
+				BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+					eventsCh <- event
+					return event
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sentryHandler := sentryhttp.New(sentryhttp.Options{ScrubHeaders: tt.scrubHeaders})
+			srv := httptest.NewServer(sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") == "" {
+					t.Error("Authorization header removed from the original request")
+				}
+				sentry.GetHubFromContext(r.Context()).CaptureMessage("headers")
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("Cookie", "session=secret")
+			req.Header.Set("X-API-KEY", "secret")
+			req.Header.Set("X-Custom", "value")
+			c := srv.Client()
+			c.Timeout = time.Second
+			res, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if ok := sentry.Flush(time.Second); !ok {
+				t.Fatal("sentry.Flush timed out")
+			}
+			close(eventsCh)
+			event := <-eventsCh
+			if event == nil {
+				t.Fatal("missing event")
+			}
+			for _, name := range tt.wantHeaders {
+				if _, ok := event.Request.Headers[name]; !ok {
+					t.Errorf("missing header %q", name)
+				}
+			}
+			for _, name := range tt.dropHeaders {
+				if _, ok := event.Request.Headers[name]; ok {
+					t.Errorf("unexpected header %q", name)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxRequestBodySize(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantData    string
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body:        `{"key":"value"}`,
+			wantData:    `{"key":"value"}`,
+		},
+		{
+			name:        "TooLarge",
+			contentType: "text/plain; charset=utf-8",
+			body:        strings.Repeat("x", 33),
+			wantData:    "",
+		},
+		{
+			name:        "Multipart",
+			contentType: "multipart/form-data; boundary=xyz",
+			body:        "--xyz--",
+			wantData:    "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			eventsCh := make(chan *sentry.Event, 1)
+			err := sentry.Init(sentry.ClientOptions{
+				BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+					eventsCh <- event
+					return event
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sentryHandler := sentryhttp.New(sentryhttp.Options{MaxRequestBodySize: 32})
+			srv := httptest.NewServer(sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+				hub := sentry.GetHubFromContext(r.Context())
+				hub.CaptureMessage("before reading the body")
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				if string(body) != tt.body {
+					t.Errorf("handler got body %q, want %q", body, tt.body)
+				}
+			}))
+			defer srv.Close()
+
+			c := srv.Client()
+			c.Timeout = time.Second
+			res, err := c.Post(srv.URL, tt.contentType, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if ok := sentry.Flush(time.Second); !ok {
+				t.Fatal("sentry.Flush timed out")
+			}
+			close(eventsCh)
+			event := <-eventsCh
+			if event == nil {
+				t.Fatal("missing event")
+			}
+			if event.Request.Data != tt.wantData {
+				t.Errorf("Request.Data = %q, want %q", event.Request.Data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestIgnoreAbortHandler(t *testing.T) {
+	for _, capture := range []bool{false, true} {
+		capture := capture
+		t.Run(fmt.Sprintf("CaptureAbortHandler=%t", capture), func(t *testing.T) {
+			eventsCh := make(chan *sentry.Event, 1)
+			err := sentry.Init(sentry.ClientOptions{
+				BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+					eventsCh <- event
+					return event
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var repanicked interface{}
+			sentryHandler := sentryhttp.New(sentryhttp.Options{
+				Repanic:             true,
+				CaptureAbortHandler: capture,
+			})
+			handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic(http.ErrAbortHandler)
+			})
+			func() {
+				defer func() { repanicked = recover() }()
+				handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}()
+			if repanicked != http.ErrAbortHandler {
+				t.Errorf("repanicked with %v, want %v", repanicked, http.ErrAbortHandler)
+			}
+
+			if ok := sentry.Flush(time.Second); !ok {
+				t.Fatal("sentry.Flush timed out")
+			}
+			close(eventsCh)
+			if got := len(eventsCh); (got == 1) != capture {
+				t.Errorf("got %d events, CaptureAbortHandler = %t", got, capture)
+			}
+		})
+	}
+}
+
+func TestCaptureServerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		capture bool
+		handler http.HandlerFunc
+		want    []string
+	}{
+		{
+			name:    "ServerError",
+			capture: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			want: []string{"503 Service Unavailable: GET /orders"},
+		},
+		{
+			name:    "Disabled",
+			capture: false,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		},
+		{
+			name:    "ClientError",
+			capture: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+		},
+		{
+			name:    "AlreadyReported",
+			capture: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sentry.GetHubFromContext(r.Context()).CaptureException(errors.New("query failed"))
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			want: []string{"query failed"},
+		},
+		{
+			name:    "Panic",
+			capture: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			want: []string{"boom"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+			handler := sentryhttp.New(sentryhttp.Options{
+				CaptureServerErrors: tt.capture,
+				Hub:                 func(r *http.Request) *sentry.Hub { return hub },
+			}).Handle(tt.handler)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+			var got []string
+			for _, event := range transport.Events() {
+				message := event.Message
+				if len(event.Exception) > 0 {
+					message = event.Exception[0].Value
+				}
+				got = append(got, message)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Events mismatch (-want +got):\n%s", diff)
+			}
+			if tt.name == "ServerError" {
+				event := transport.LastEvent()
+				if event.Level != sentry.LevelError || event.Tags["http.status_code"] != "503" || event.Request == nil {
+					t.Errorf("got level %q, tags %v and request %v, want error with status tag and request", event.Level, event.Tags, event.Request)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxResponseBodySize(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        interface{}
+	}{
+		{"JSON", "application/json", `{"error":"database unavailable"}`, `{"error":"database`},
+		{"Detected", "", "database unavailable", "database unavailab"},
+		{"Binary", "application/octet-stream", "database unavailable", nil},
+		{"Empty", "text/plain", "", nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+			handler := sentryhttp.New(sentryhttp.Options{
+				CaptureServerErrors: true,
+				MaxResponseBodySize: 18,
+				Hub:                 func(r *http.Request) *sentry.Hub { return hub },
+			}).HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("response body = %q, want %q", got, tt.body)
+			}
+			event := transport.LastEvent()
+			if event == nil {
+				t.Fatal("no event reported")
+			}
+			var got interface{}
+			if response, ok := event.Contexts["response"]; ok {
+				got = response["data"]
+				if status := response["status_code"]; status != http.StatusInternalServerError {
+					t.Errorf("status_code = %v, want %d", status, http.StatusInternalServerError)
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("response body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContextTags(t *testing.T) {
+	type tenantKey struct{}
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{
+		ContextTags: func(ctx context.Context) map[string]string {
+			tenant, _ := ctx.Value(tenantKey{}).(string)
+			return map[string]string{"tenant": tenant}
+		},
+	})
+	handler := sentryhttp.New(sentryhttp.Options{
+		Hub: func(r *http.Request) *sentry.Hub { return hub },
+	}).HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentry.GetHubFromContext(r.Context()).CaptureMessage("tagged")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), tenantKey{}, "acme"))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	event := transport.LastEvent()
+	if event == nil {
+		t.Fatal("missing event")
+	}
+	if got := event.Tags["tenant"]; got != "acme" {
+		t.Errorf(`Tags["tenant"] = %q, want "acme"`, got)
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func TestHijackedConnection(t *testing.T) {
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	handler := sentryhttp.New(sentryhttp.Options{
+		Hub: func(r *http.Request) *sentry.Hub { return hub },
+	}).HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		panic("connection lost")
+	})
+
+	handler.ServeHTTP(hijackRecorder{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	event := transport.LastEvent()
+	if event == nil {
+		t.Fatal("missing event")
+	}
+	if event.Message != "connection lost" || event.Tags["http.status_code"] != "101" {
+		t.Errorf("got event %q with status %q, want %q with status 101", event.Message, event.Tags["http.status_code"], "connection lost")
+	}
+}
+
+func TestSkipResponseWrapping(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		recorder := httptest.NewRecorder()
+		var got http.ResponseWriter
+		handler := sentryhttp.New(sentryhttp.Options{
+			SkipResponseWrapping: func(r *http.Request) bool { return skip },
+		}).HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = w
+		})
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+		if unwrapped := got == http.ResponseWriter(recorder); unwrapped != skip {
+			t.Errorf("SkipResponseWrapping returning %t: handler got the original writer = %t", skip, unwrapped)
+		}
+	}
+}
+
+// blockingTransport is a transport whose flushes never complete before the
+// context is done.
+type blockingTransport struct {
+	sentrytest.Transport
+}
+
+func (t *blockingTransport) FlushWithContext(ctx context.Context) bool {
+	<-ctx.Done()
+	return false
+}
+
+func TestWaitForDeliveryRequestDeadline(t *testing.T) {
+	client, err := sentry.NewClient(sentry.ClientOptions{Transport: &blockingTransport{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := sentry.NewHub(client, sentry.NewScope())
+	handler := sentryhttp.New(sentryhttp.Options{
+		WaitForDelivery: true,
+		Timeout:         time.Minute,
+		Hub:             func(r *http.Request) *sentry.Hub { return hub },
+	}).HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("handler waited %s for delivery, want it to stop at the request deadline", elapsed)
+	}
+}
+
+// panicking panics with v, it is not inlined to appear in stack traces.
+//
+//go:noinline
+func panicking(v interface{}) {
+	panic(v)
+}
+
+func TestPanicStacktrace(t *testing.T) {
+	for _, v := range []interface{}{"boom", errors.New("boom")} {
+		v := v
+		t.Run(fmt.Sprintf("%T", v), func(t *testing.T) {
+			hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+			handler := sentryhttp.New(sentryhttp.Options{
+				Hub: func(r *http.Request) *sentry.Hub { return hub },
+			}).HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+				panicking(v)
+			})
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			event := transport.LastEvent()
+			if event == nil {
+				t.Fatal("missing event")
+			}
+			var stacktrace *sentry.Stacktrace
+			if len(event.Exception) > 0 {
+				stacktrace = event.Exception[len(event.Exception)-1].Stacktrace
+			} else if len(event.Threads) > 0 {
+				stacktrace = event.Threads[0].Stacktrace
+			}
+			if stacktrace == nil || len(stacktrace.Frames) == 0 {
+				t.Fatal("missing stack trace")
+			}
+			// The innermost frame is the last one.
+			if got := stacktrace.Frames[len(stacktrace.Frames)-1].Function; got != "panicking" {
+				t.Errorf("innermost frame is %q, want the function that panicked", got)
+			}
+		})
+	}
+}
+
+func TestScopeModifier(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 2)
+	err := sentry.Init(sentry.ClientOptions{
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{
+		ScopeModifier: func(r *http.Request, scope *sentry.Scope) {
+			if tenant := r.Header.Get("X-Tenant"); tenant != "" {
+				scope.SetTag("tenant", tenant)
+			}
+		},
+	})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentry.GetHubFromContext(r.Context()).CaptureMessage("message")
+	})
+
+	for _, tenant := range []string{"acme", ""} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tenant != "" {
+			r.Header.Set("X-Tenant", tenant)
+		}
+		handler(httptest.NewRecorder(), r)
+	}
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	var got []string
+	for e := range eventsCh {
+		got = append(got, e.Tags["tenant"])
+	}
+	// The tag must not leak into the second request.
+	if diff := cmp.Diff([]string{"acme", ""}, got); diff != "" {
+		t.Errorf("Tags mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTransactionName(t *testing.T) {
+	newGorillaMux := func(h *sentryhttp.Handler, handler http.HandlerFunc) http.Handler {
+		router := mux.NewRouter()
+		router.Use(h.Handle)
+		router.HandleFunc("/users/{id}", handler)
+		return router
+	}
+	newChi := func(h *sentryhttp.Handler, handler http.HandlerFunc) http.Handler {
+		router := chi.NewRouter()
+		router.Use(h.Handle)
+		router.Get("/users/{id}", handler)
+		return router
+	}
+
+	tests := []struct {
+		name            string
+		transactionName func(*http.Request) string
+		newRouter       func(*sentryhttp.Handler, http.HandlerFunc) http.Handler
+		wantName        string
+		wantSource      sentry.TransactionSource
+	}{
+		{
+			name:       "Default",
+			newRouter:  newGorillaMux,
+			wantName:   "GET /users/123",
+			wantSource: sentry.SourceURL,
+		},
+		{
+			name:            "GorillaMux",
+			transactionName: sentrygorillamux.Route,
+			newRouter:       newGorillaMux,
+			wantName:        "GET /users/{id}",
+			wantSource:      sentry.SourceRoute,
+		},
+		{
+			name:            "Chi",
+			transactionName: sentrychi.Route,
+			newRouter:       newChi,
+			wantName:        "GET /users/{id}",
+			wantSource:      sentry.SourceRoute,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			transactionsCh := make(chan *sentry.Event, 1)
+			eventsCh := make(chan *sentry.Event, 1)
+			err := sentry.Init(sentry.ClientOptions{
+				EnableTracing:    true,
+				TracesSampleRate: 1.0,
+				BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+					eventsCh <- event
+					return event
+				},
+				BeforeSendTransaction: func(tx *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+					transactionsCh <- tx
+					return tx
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sentryHandler := sentryhttp.New(sentryhttp.Options{TransactionName: tt.transactionName})
+			router := tt.newRouter(sentryHandler, func(w http.ResponseWriter, r *http.Request) {
+				panic("test")
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+			if ok := sentry.Flush(time.Second); !ok {
+				t.Fatal("sentry.Flush timed out")
+			}
+			close(transactionsCh)
+			close(eventsCh)
+			// Errors are labeled with the transaction name as well.
+			event := <-eventsCh
+			if event == nil {
+				t.Fatal("missing event")
+			}
+			if event.Transaction != tt.wantName {
+				t.Errorf("event Transaction = %q, want %q", event.Transaction, tt.wantName)
+			}
+			tx := <-transactionsCh
+			if tx == nil {
+				t.Fatal("missing transaction")
+			}
+			if tx.Transaction != tt.wantName {
+				t.Errorf("Transaction = %q, want %q", tx.Transaction, tt.wantName)
+			}
+			if tx.TransactionInfo.Source != tt.wantSource {
+				t.Errorf("TransactionInfo.Source = %q, want %q", tx.TransactionInfo.Source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestWrapServeMux(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 1)
+	transactionsCh := make(chan *sentry.Event, 1)
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+		BeforeSendTransaction: func(tx *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			transactionsCh <- tx
+			return tx
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	})
+	sentryhttp.WrapServeMux(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(eventsCh)
+	close(transactionsCh)
+	event := <-eventsCh
+	if event == nil || event.Message != "test" {
+		t.Fatalf("got event %v, want the recovered panic", event)
+	}
+	tx := <-transactionsCh
+	if tx == nil {
+		t.Fatal("missing transaction")
+	}
+	if want := "GET /users/"; tx.Transaction != want || tx.TransactionInfo.Source != sentry.SourceRoute {
+		t.Errorf("got transaction %q with source %q, want %q with source %q", tx.Transaction, tx.TransactionInfo.Source, want, sentry.SourceRoute)
+	}
+}
+
+func TestRouteParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		routeParams func(*http.Request) map[string]string
+		newRouter   func(*sentryhttp.Handler, http.HandlerFunc) http.Handler
+	}{
+		{
+			name:        "GorillaMux",
+			routeParams: sentrygorillamux.RouteParams,
+			newRouter: func(h *sentryhttp.Handler, handler http.HandlerFunc) http.Handler {
+				router := mux.NewRouter()
+				router.Use(h.Handle)
+				router.HandleFunc("/users/{id}/posts/{post}", handler)
+				return router
+			},
+		},
+		{
+			name:        "Chi",
+			routeParams: sentrychi.RouteParams,
+			newRouter: func(h *sentryhttp.Handler, handler http.HandlerFunc) http.Handler {
+				router := chi.NewRouter()
+				router.Use(h.Handle)
+				router.Get("/users/{id}/posts/{post}", handler)
+				return router
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			eventsCh := make(chan *sentry.Event, 1)
+			err := sentry.Init(sentry.ClientOptions{
+				BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+					eventsCh <- event
+					return event
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sentryHandler := sentryhttp.New(sentryhttp.Options{RouteParams: tt.routeParams})
+			router := tt.newRouter(sentryHandler, func(w http.ResponseWriter, r *http.Request) {
+				panic("test")
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123/posts/456", nil))
+
+			if ok := sentry.Flush(time.Second); !ok {
+				t.Fatal("sentry.Flush timed out")
+			}
+			close(eventsCh)
+			event := <-eventsCh
+			if event == nil {
+				t.Fatal("missing event")
+			}
+			want := sentry.Context{"id": "123", "post": "456"}
+			if diff := cmp.Diff(want, event.Contexts[sentryhttp.RouteParamsContextKey]); diff != "" {
+				t.Errorf("Contexts[%q] mismatch (-want +got):\n%s", sentryhttp.RouteParamsContextKey, diff)
+			}
+		})
+	}
+}
+
+func TestTracesSamplerSamplingContext(t *testing.T) {
+	var got sentry.SamplingContext
+	transactionsCh := make(chan *sentry.Event, 2)
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing: true,
+		TracesSampler: func(ctx sentry.SamplingContext) float64 {
+			got = ctx
+			if ctx.Request.URL.Path == "/healthz" {
+				return 0
+			}
+			return 1
+		},
+		BeforeSendTransaction: func(tx *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			transactionsCh <- tx
+			return tx
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{TransactionName: sentrygorillamux.Route})
+	router := mux.NewRouter()
+	router.Use(sentryHandler.Handle)
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+	if got.Request == nil || got.Request.URL.Path != "/users/123" {
+		t.Errorf("SamplingContext.Request = %v, want the request for /users/123", got.Request)
+	}
+	if route := got.Custom[sentryhttp.RouteSamplingContextKey]; route != "/users/{id}" {
+		t.Errorf("SamplingContext.Custom[%q] = %v, want %q", sentryhttp.RouteSamplingContextKey, route, "/users/{id}")
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if ok := sentry.Flush(time.Second); !ok {
+		t.Fatal("sentry.Flush timed out")
+	}
+	close(transactionsCh)
+	var names []string
+	for tx := range transactionsCh {
+		names = append(names, tx.Transaction)
+	}
+	if len(names) != 1 || names[0] != "GET /users/{id}" {
+		t.Errorf("got transactions %q, want only %q", names, "GET /users/{id}")
+	}
+}
+
+func TestGo(t *testing.T) {
+	eventsCh := make(chan *sentry.Event, 1)
+	err := sentry.Init(sentry.ClientOptions{
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			eventsCh <- event
+			return event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentryHandler := sentryhttp.New(sentryhttp.Options{})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentryhttp.GetHubFromRequest(r).Scope().SetTag("handler", "yes")
+		sentryhttp.Go(r, func(ctx context.Context) {
+			sentry.GetHubFromContext(ctx).Scope().SetTag("goroutine", "yes")
+			panic("background")
+		})
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/background", nil))
+
+	// The event is captured asynchronously, after the goroutine panics.
+	var event *sentry.Event
+	select {
+	case event = <-eventsCh:
+	case <-time.After(time.Second):
+		t.Fatal("missing event")
+	}
+	if event.Message != "background" {
+		t.Errorf("Message = %q, want %q", event.Message, "background")
+	}
+	if event.Request == nil || !strings.HasSuffix(event.Request.URL, "/background") {
+		t.Errorf("Request = %#v, want request data", event.Request)
+	}
+	if diff := cmp.Diff(map[string]string{"handler": "yes", "goroutine": "yes"}, event.Tags); diff != "" {
+		t.Errorf("Tags mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGoTrace(t *testing.T) {
+	err := sentry.Init(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        &sentrytest.Transport{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		requestSpan, span *sentry.Span
+		err               error
+	}
+	resultCh := make(chan result, 1)
+	requestDone := make(chan struct{})
+	sentryHandler := sentryhttp.New(sentryhttp.Options{})
+	handler := sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestSpan := sentry.SpanFromContext(r.Context())
+		sentryhttp.Go(r, func(ctx context.Context) {
+			<-requestDone
+			resultCh <- result{requestSpan, sentry.SpanFromContext(ctx), ctx.Err()}
+		})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/background", nil).WithContext(ctx))
+	cancel()
+	close(requestDone)
+
+	got := <-resultCh
+	if got.requestSpan == nil || got.span != got.requestSpan {
+		t.Errorf("got span %v, want the request span %v", got.span, got.requestSpan)
+	}
+	if got.err != nil {
+		t.Errorf("got context error %v after the request ended, want nil", got.err)
+	}
+}
+
+func TestHubPerRequest(t *testing.T) {
+	hubs := make(map[string]*sentry.Hub)
+	transports := make(map[string]*sentrytest.Transport)
+	for _, tenant := range []string{"a", "b"} {
+		hubs[tenant], transports[tenant] = sentrytest.NewHub(t, sentry.ClientOptions{})
+	}
+	handler := sentryhttp.New(sentryhttp.Options{
+		Hub: func(r *http.Request) *sentry.Hub {
+			return hubs[r.Header.Get("X-Tenant")]
+		},
+	}).HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub := sentry.GetHubFromContext(r.Context())
+		hub.Scope().SetTag("path", r.URL.Path)
+		hub.CaptureMessage("tenant " + r.Header.Get("X-Tenant"))
+	})
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		r := httptest.NewRequest(http.MethodGet, "/"+tenant, nil)
+		r.Header.Set("X-Tenant", tenant)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	for tenant, want := range map[string]int{"a": 2, "b": 1} {
+		events := transports[tenant].Events()
+		if len(events) != want {
+			t.Errorf("tenant %s: got %d events, want %d", tenant, len(events), want)
+		}
+		for _, event := range events {
+			if event.Message != "tenant "+tenant {
+				t.Errorf("tenant %s: got event %q from another tenant", tenant, event.Message)
+			}
+		}
+		// The scope of each request is a clone of the hub's scope.
+		hubs[tenant].ConfigureScope(func(scope *sentry.Scope) {
+			event := scope.ApplyToEvent(sentry.NewEvent(), nil)
+			if _, ok := event.Tags["path"]; ok {
+				t.Errorf("tenant %s: request tag leaked into the hub scope", tenant)
+			}
+		})
+	}
+}
+
+// BenchmarkHandler measures the overhead of the handler for a request that
+// neither panics nor is sampled for tracing.
+func BenchmarkHandler(b *testing.B) {
+	if err := sentry.Init(sentry.ClientOptions{}); err != nil {
+		b.Fatal(err)
+	}
+	handler := sentryhttp.New(sentryhttp.Options{}).Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
 }
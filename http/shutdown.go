@@ -0,0 +1,45 @@
+package sentryhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Shutdown gracefully shuts down srv, as with srv.Shutdown(ctx), then flushes
+// the events buffered by the current hub using the remaining time until ctx is
+// done. In-flight requests may report events until they complete, so
+// flushing only after srv.Shutdown returns ensures these events are not lost
+// when the program exits.
+//
+// Events are flushed even if srv.Shutdown returns an error. Shutdown returns
+// that error or, if some events could not be sent before ctx was done,
+// ctx.Err().
+//
+// Use it when the program receives a termination signal, for example during a
+// rolling deployment in Kubernetes:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+//	defer stop()
+//	go srv.ListenAndServe()
+//	<-ctx.Done()
+//
+//	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	if err := sentryhttp.Shutdown(shutdownCtx, srv); err != nil {
+//		log.Printf("shutdown: %v", err)
+//	}
+//
+// Functions registered with srv.RegisterOnShutdown are not waited for by
+// srv.Shutdown, so flushing from such a function may not complete before the
+// program exits.
+func Shutdown(ctx context.Context, srv *http.Server) error {
+	err := srv.Shutdown(ctx)
+	// Flush even if srv.Shutdown failed, for example when closing a listener
+	// returned an error, as the events of completed requests are still buffered.
+	if !sentry.FlushWithContext(ctx) && err == nil {
+		return ctx.Err()
+	}
+	return err
+}
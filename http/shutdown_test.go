@@ -0,0 +1,120 @@
+package sentryhttp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	sentryhttp "github.com/getsentry/sentry-go/http"
+	"github.com/getsentry/sentry-go/sentrytest"
+)
+
+func TestShutdown(t *testing.T) {
+	transport := &sentrytest.Transport{}
+	if err := sentry.Init(sentry.ClientOptions{Transport: transport}); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	sentryHandler := sentryhttp.New(sentryhttp.Options{})
+	srv := httptest.NewServer(sentryHandler.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		sentry.GetHubFromContext(r.Context()).CaptureMessage("in-flight")
+	}))
+	defer srv.Close()
+
+	go func() {
+		res, err := srv.Client().Get(srv.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sentryhttp.Shutdown(ctx, srv.Config); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	// The event reported by the in-flight request was flushed.
+	if events := transport.Events(); len(events) != 1 || events[0].Message != "in-flight" {
+		t.Errorf("got events %v, want the in-flight event", events)
+	}
+}
+
+func TestShutdownFlushTimeout(t *testing.T) {
+	if err := sentry.Init(sentry.ClientOptions{Transport: slowTransport{}}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sentryhttp.Shutdown(ctx, srv.Config); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// failingListener fails to close, which makes http.Server.Shutdown fail.
+type failingListener struct {
+	net.Listener
+}
+
+func (l failingListener) Close() error {
+	_ = l.Listener.Close()
+	return errListenerClose
+}
+
+var errListenerClose = errors.New("close failed")
+
+// flushRecordingTransport records whether it was flushed.
+type flushRecordingTransport struct {
+	sentrytest.Transport
+	flushed int32
+}
+
+func (t *flushRecordingTransport) FlushWithContext(ctx context.Context) bool {
+	atomic.StoreInt32(&t.flushed, 1)
+	return true
+}
+
+func TestShutdownError(t *testing.T) {
+	transport := &flushRecordingTransport{}
+	if err := sentry.Init(sentry.ClientOptions{Transport: transport}); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = srv.Serve(failingListener{ln})
+	}()
+	// Wait for srv to track the listener.
+	res, err := http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sentryhttp.Shutdown(ctx, srv); !errors.Is(err, errListenerClose) {
+		t.Errorf("Shutdown() = %v, want %v", err, errListenerClose)
+	}
+	if atomic.LoadInt32(&transport.flushed) == 0 {
+		t.Error("events were not flushed after Shutdown failed")
+	}
+	<-served
+}
@@ -2,6 +2,7 @@ package sentry
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -70,13 +71,20 @@ type stack []*layer
 
 // NewHub returns an instance of a Hub with provided Client and Scope bound.
 func NewHub(client *Client, scope *Scope) *Hub {
-	hub := Hub{
-		stack: &stack{{
-			client: client,
-			scope:  scope,
-		}},
-	}
-	return &hub
+	// The hub, its stack and its first layer are allocated at once, as a hub
+	// is typically created for every request served.
+	h := &struct {
+		hub    Hub
+		stack  stack
+		layers [1]*layer
+		top    layer
+	}{}
+	h.top.client = client
+	h.top.scope = scope
+	h.layers[0] = &h.top
+	h.stack = h.layers[:]
+	h.hub.stack = &h.stack
+	return &h.hub
 }
 
 // CurrentHub returns an instance of previously initialized Hub stored in the global namespace.
@@ -84,8 +92,9 @@ func CurrentHub() *Hub {
 	return currentHub
 }
 
-// LastEventID returns the ID of the last event (error or message) captured
-// through the hub and sent to the underlying transport.
+// LastEventID returns the ID of the last event (error, message or recovered
+// panic) captured through the hub and sent to the underlying transport. It is
+// safe for concurrent use.
 //
 // Transactions and events dropped by sampling or event processors do not change
 // the last event ID.
@@ -103,6 +112,17 @@ func (hub *Hub) LastEventID() EventID {
 	return hub.lastEventID
 }
 
+// setLastEventID records id as the last event ID, unless it is nil.
+func (hub *Hub) setLastEventID(id *EventID) {
+	if id == nil {
+		return
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.lastEventID = *id
+}
+
 // stackTop returns the top layer of the hub stack. Valid hubs always have at
 // least one layer, therefore stackTop always return a non-nil pointer.
 func (hub *Hub) stackTop() *layer {
@@ -116,6 +136,14 @@ func (hub *Hub) stackTop() *layer {
 }
 
 // Clone returns a copy of the current Hub with top-most scope and client copied over.
+//
+// The scope of the new Hub is a copy made with Scope.Clone, so the clone can be
+// modified from another goroutine, e.g. for the duration of a request, without
+// affecting the original Hub.
+//
+// Clones are deliberately not pooled and reused: the hub of a request remains
+// reachable from its context, spans and goroutines after the request is
+// served, so a reused hub could leak the scope of one request into another.
 func (hub *Hub) Clone() *Hub {
 	top := hub.stackTop()
 	scope := top.scope
@@ -180,6 +208,11 @@ func (hub *Hub) PopScope() {
 }
 
 // BindClient binds a new Client for the current Hub.
+//
+// Events captured through the hub are sent by the transport of the bound
+// client, so a program can report to several Sentry projects, for example one
+// per tenant, by binding clients created with different DSNs to different
+// hubs. Hubs cloned from the hub share its client.
 func (hub *Hub) BindClient(client *Client) {
 	top := hub.stackTop()
 	top.SetClient(client)
@@ -215,7 +248,8 @@ func (hub *Hub) ConfigureScope(f func(scope *Scope)) {
 
 // CaptureEvent calls the method of a same name on currently bound Client instance
 // passing it a top-level Scope.
-// Returns EventID if successfully, or nil if there's no Scope or Client available.
+// Returns the EventID of the event, or nil if there's no Scope or Client
+// available or the event was not accepted.
 func (hub *Hub) CaptureEvent(event *Event) *EventID {
 	client, scope := hub.Client(), hub.Scope()
 	if client == nil || scope == nil {
@@ -223,17 +257,16 @@ func (hub *Hub) CaptureEvent(event *Event) *EventID {
 	}
 	eventID := client.CaptureEvent(event, nil, scope)
 
-	if event.Type != transactionType && eventID != nil {
-		hub.mu.Lock()
-		hub.lastEventID = *eventID
-		hub.mu.Unlock()
+	if event.Type != transactionType {
+		hub.setLastEventID(eventID)
 	}
 	return eventID
 }
 
 // CaptureMessage calls the method of a same name on currently bound Client instance
 // passing it a top-level Scope.
-// Returns EventID if successfully, or nil if there's no Scope or Client available.
+// Returns the EventID of the event, or nil if there's no Scope or Client
+// available or the event was not accepted.
 func (hub *Hub) CaptureMessage(message string) *EventID {
 	client, scope := hub.Client(), hub.Scope()
 	if client == nil || scope == nil {
@@ -241,32 +274,120 @@ func (hub *Hub) CaptureMessage(message string) *EventID {
 	}
 	eventID := client.CaptureMessage(message, nil, scope)
 
-	if eventID != nil {
-		hub.mu.Lock()
-		hub.lastEventID = *eventID
-		hub.mu.Unlock()
+	hub.setLastEventID(eventID)
+	return eventID
+}
+
+// CaptureExceptionWith captures an error like CaptureException, with options
+// applied to a temporary scope for this capture only. It is a shorthand for
+// capturing an error within WithScope:
+//
+//	hub.CaptureExceptionWith(err,
+//		sentry.WithTag("job", "import"),
+//		sentry.WithLevel(sentry.LevelWarning),
+//	)
+func (hub *Hub) CaptureExceptionWith(exception error, options ...CaptureOption) *EventID {
+	var eventID *EventID
+	hub.WithScope(func(scope *Scope) {
+		for _, option := range options {
+			option(scope)
+		}
+		eventID = hub.CaptureException(exception)
+	})
+	return eventID
+}
+
+// CaptureValidationError reports the invalid fields of an input as a single
+// warning event. Events are grouped by the endpoint and the set of invalid
+// fields, so that each kind of invalid input is one issue, whatever the
+// values. Only report validation errors where they point to a bug, for
+// instance of a client sending requests, as they are usually expected.
+// Returns the EventID of the event, or nil if there's no Scope or Client
+// available or the event was not accepted.
+func (hub *Hub) CaptureValidationError(err *ValidationError) *EventID {
+	client, scope := hub.Client(), hub.Scope()
+	if client == nil || scope == nil || err == nil || len(err.Fields) == 0 {
+		return nil
 	}
+	eventID := client.CaptureEvent(client.eventFromValidationError(err), &EventHint{OriginalException: err}, scope)
+
+	hub.setLastEventID(eventID)
 	return eventID
 }
 
 // CaptureException calls the method of a same name on currently bound Client instance
 // passing it a top-level Scope.
-// Returns EventID if successfully, or nil if there's no Scope or Client available.
+// Returns the EventID of the event, or nil if there's no Scope or Client
+// available or the event was not accepted.
 func (hub *Hub) CaptureException(exception error) *EventID {
 	client, scope := hub.Client(), hub.Scope()
-	if client == nil || scope == nil {
+	if client == nil || scope == nil || client.disabled() {
 		return nil
 	}
 	eventID := client.CaptureException(exception, &EventHint{OriginalException: exception}, scope)
 
-	if eventID != nil {
-		hub.mu.Lock()
-		hub.lastEventID = *eventID
-		hub.mu.Unlock()
+	hub.setLastEventID(eventID)
+	return eventID
+}
+
+// CaptureUnhandledException captures an error like CaptureException, marking
+// it as unhandled instead of handled. Sentry counts events with an unhandled
+// exception as crashes, like panics recovered by the Recover methods and the
+// integrations. Use it for errors that crash the program or abort the work
+// in progress without a panic, for example an error returned by run in:
+//
+//	if err := run(); err != nil {
+//		sentry.CaptureUnhandledException(err)
+//		sentry.Flush(2 * time.Second)
+//		os.Exit(1)
+//	}
+func (hub *Hub) CaptureUnhandledException(exception error) *EventID {
+	client, scope := hub.Client(), hub.Scope()
+	if client == nil || scope == nil || client.disabled() {
+		return nil
 	}
+	event := client.EventFromException(exception, LevelError)
+	eventID := client.captureExceptionEvent(event, exception, &EventHint{OriginalException: exception}, scope, false)
+
+	hub.setLastEventID(eventID)
 	return eventID
 }
 
+// CaptureExceptionAndFlush calls CaptureException, then Flush with the given
+// timeout. It returns true only if the event was accepted and sent before the
+// timeout was reached.
+//
+// It is meant for errors reported right before the program exits, for example
+// with os.Exit or log.Fatal, which would otherwise not be delivered by an
+// asynchronous transport.
+func (hub *Hub) CaptureExceptionAndFlush(exception error, timeout time.Duration) bool {
+	if hub.CaptureException(exception) == nil {
+		return false
+	}
+	return hub.Flush(timeout)
+}
+
+// CaptureCheckIn calls the method of a same name on currently bound Client
+// instance. It returns the ID of the check-in, or nil if there's no Client
+// available or the check-in was not sent.
+func (hub *Hub) CaptureCheckIn(checkIn *CheckIn, monitorConfig *MonitorConfig) *EventID {
+	client := hub.Client()
+	if client == nil {
+		return nil
+	}
+	return client.CaptureCheckIn(checkIn, monitorConfig)
+}
+
+// CaptureUserFeedback sends feedback from a user about an event with the
+// client bound to the hub, see Client.CaptureUserFeedback.
+func (hub *Hub) CaptureUserFeedback(feedback *UserFeedback) {
+	client := hub.Client()
+	if client == nil {
+		return
+	}
+	client.CaptureUserFeedback(feedback)
+}
+
 // AddBreadcrumb records a new breadcrumb.
 //
 // The total number of breadcrumbs that can be recorded are limited by the
@@ -280,8 +401,10 @@ func (hub *Hub) AddBreadcrumb(breadcrumb *Breadcrumb, hint *BreadcrumbHint) {
 		return
 	}
 
+	// Breadcrumbs are only ever sent with events, which a disabled client
+	// does not assemble.
 	max := client.options.MaxBreadcrumbs
-	if max < 0 {
+	if max < 0 || client.disabled() {
 		return
 	}
 
@@ -289,52 +412,167 @@ func (hub *Hub) AddBreadcrumb(breadcrumb *Breadcrumb, hint *BreadcrumbHint) {
 		if hint == nil {
 			hint = &BreadcrumbHint{}
 		}
-		if breadcrumb = client.options.BeforeBreadcrumb(breadcrumb, hint); breadcrumb == nil {
+		if breadcrumb = client.callBeforeBreadcrumb(breadcrumb, hint); breadcrumb == nil {
 			Logger.Println("breadcrumb dropped due to BeforeBreadcrumb callback.")
 			return
 		}
 	}
 
+	if min := client.options.BreadcrumbLevel; min != "" {
+		level := breadcrumb.Level
+		if level == "" {
+			level = LevelInfo
+		}
+		if level.severity() >= 0 && level.severity() < min.severity() {
+			return
+		}
+	}
+
 	if max == 0 {
 		max = defaultMaxBreadcrumbs
 	} else if max > maxBreadcrumbs {
 		max = maxBreadcrumbs
 	}
 
-	hub.Scope().AddBreadcrumb(breadcrumb, max)
+	categoryMax, ok := client.options.MaxBreadcrumbsPerCategory[breadcrumb.Category]
+	if ok && categoryMax <= 0 {
+		return
+	}
+
+	evicted := hub.Scope().addBreadcrumb(breadcrumb, max, categoryMax)
+	if client.options.OnBreadcrumbEvicted != nil {
+		for _, b := range evicted {
+			client.options.OnBreadcrumbEvicted(b)
+		}
+	}
+}
+
+// AddErrorBreadcrumbs records a breadcrumb of category "error" for err and for
+// each error it wraps, with the type and message of the error, starting with
+// the innermost cause such that the trail leads up to err. Use it to keep the
+// chain of an error as context of a message event, without reporting its
+// errors as exceptions:
+//
+//	hub.AddErrorBreadcrumbs(err)
+//	hub.CaptureMessage("checkout degraded")
+//
+// Errors are unwrapped with their Unwrap() error or Cause() error method, up to
+// ClientOptions.MaxErrorDepth errors. An error wrapping several errors, like
+// one returned by errors.Join, is recorded without its wrapped errors.
+func (hub *Hub) AddErrorBreadcrumbs(err error) {
+	depth := maxErrorDepth
+	if client := hub.Client(); client != nil {
+		depth = client.options.MaxErrorDepth
+	}
+	var chain []error
+	for err != nil && len(chain) < depth && !containsError(chain, err) {
+		chain = append(chain, err)
+		switch previous := err.(type) {
+		case interface{ Unwrap() error }:
+			err = previous.Unwrap()
+		case interface{ Cause() error }:
+			err = previous.Cause()
+		default:
+			err = nil
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		hub.AddBreadcrumb(&Breadcrumb{
+			Type:     "error",
+			Category: "error",
+			Message:  chain[i].Error(),
+			Level:    LevelError,
+			Data:     map[string]interface{}{"type": reflect.TypeOf(chain[i]).String()},
+		}, nil)
+	}
 }
 
 // Recover calls the method of a same name on currently bound Client instance
 // passing it a top-level Scope.
-// Returns EventID if successfully, or nil if there's no Scope or Client available.
+// Returns the EventID of the event, or nil if there's no Scope or Client
+// available or the event was not accepted.
 func (hub *Hub) Recover(err interface{}) *EventID {
 	if err == nil {
 		err = recover()
 	}
 	client, scope := hub.Client(), hub.Scope()
-	if client == nil || scope == nil {
+	if client == nil || scope == nil || client.disabled() {
 		return nil
 	}
-	return client.Recover(err, &EventHint{RecoveredException: err}, scope)
+	eventID := client.Recover(err, &EventHint{RecoveredException: err}, scope)
+	hub.setLastEventID(eventID)
+	return eventID
 }
 
 // RecoverWithContext calls the method of a same name on currently bound Client instance
-// passing it a top-level Scope.
-// Returns EventID if successfully, or nil if there's no Scope or Client available.
+// passing it a top-level Scope. The event is linked to the trace of the span
+// stored in ctx, if any.
+// Returns the EventID of the event, or nil if there's no Scope or Client
+// available or the event was not accepted.
 func (hub *Hub) RecoverWithContext(ctx context.Context, err interface{}) *EventID {
 	if err == nil {
 		err = recover()
 	}
-	client, scope := hub.Client(), hub.Scope()
-	if client == nil || scope == nil {
+	var eventID *EventID
+	hub.withSpanFromContext(ctx, func() {
+		client, scope := hub.Client(), hub.Scope()
+		if client == nil || scope == nil || client.disabled() {
+			return
+		}
+		eventID = client.RecoverWithContext(ctx, err, &EventHint{RecoveredException: err}, scope)
+		hub.setLastEventID(eventID)
+	})
+	return eventID
+}
+
+// withSpanFromContext calls f, capturing events with hub, within a temporary
+// scope linked to the trace of the span stored in ctx, see WithSpan. The span
+// may differ from the one of the scope, for instance if other spans were
+// started with hub since.
+func (hub *Hub) withSpanFromContext(ctx context.Context, f func()) {
+	var span *Span
+	if ctx != nil {
+		span = SpanFromContext(ctx)
+	}
+	if span == nil {
+		f()
+		return
+	}
+	hub.WithScope(func(scope *Scope) {
+		WithSpan(span)(scope)
+		f()
+	})
+}
+
+// CaptureRecovered reports recovered, a value returned by the built-in
+// recover, like RecoverWithContext, but with the given level instead of
+// LevelFatal. An empty level keeps the default. It returns nil if recovered is
+// nil, and never panics again: the caller decides how to go on.
+//
+// It is the panic-to-event logic shared by integrations, for code that
+// recovers from panics with its own recover call, such as a rendering layer
+// turning panics into error pages.
+func (hub *Hub) CaptureRecovered(ctx context.Context, recovered interface{}, level Level) *EventID {
+	if recovered == nil {
 		return nil
 	}
-	return client.RecoverWithContext(ctx, err, &EventHint{RecoveredException: err}, scope)
+	if level == "" {
+		return hub.RecoverWithContext(ctx, recovered)
+	}
+	var eventID *EventID
+	hub.WithScope(func(scope *Scope) {
+		scope.SetLevel(level)
+		eventID = hub.RecoverWithContext(ctx, recovered)
+	})
+	return eventID
 }
 
 // Flush waits until the underlying Transport sends any buffered events to the
 // Sentry server, blocking for at most the given timeout. It returns false if
 // the timeout was reached. In that case, some events may not have been sent.
+// Only the transport of the client bound to hub is flushed, such that hubs
+// bound to different clients, for instance one per tenant, can be flushed
+// independently.
 //
 // Flush should be called before terminating the program to avoid
 // unintentionally dropping events.
@@ -353,6 +591,19 @@ func (hub *Hub) Flush(timeout time.Duration) bool {
 	return client.Flush(timeout)
 }
 
+// FlushWithContext works like Flush, but waits until ctx is done instead of a
+// fixed timeout. It returns true only if all buffered events were sent before
+// ctx was done.
+func (hub *Hub) FlushWithContext(ctx context.Context) bool {
+	client := hub.Client()
+
+	if client == nil {
+		return false
+	}
+
+	return client.FlushWithContext(ctx)
+}
+
 // HasHubOnContext checks whether Hub instance is bound to a given Context struct.
 func HasHubOnContext(ctx context.Context) bool {
 	_, ok := ctx.Value(HubContextKey).(*Hub)
@@ -2,9 +2,12 @@ package sentry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -119,6 +122,21 @@ func TestWithScopeCreatesIsolatedScope(t *testing.T) {
 	assertEqual(t, len(*hub.stack), 1)
 }
 
+func TestWithScopePopsScopeOnPanic(t *testing.T) {
+	hub, _, _ := setupHubTest()
+
+	func() {
+		defer func() { _ = recover() }()
+		hub.WithScope(func(scope *Scope) {
+			scope.SetTag("leaked", "yes")
+			panic("oops")
+		})
+	}()
+
+	assertEqual(t, len(*hub.stack), 1)
+	assertEqual(t, map[string]string{}, hub.Scope().tags)
+}
+
 func TestWithScopeBindClient(t *testing.T) {
 	hub, client, _ := setupHubTest()
 
@@ -190,6 +208,12 @@ func TestLastEventIDUpdatesAfterCaptures(t *testing.T) {
 
 	eventID := hub.CaptureEvent(&Event{Message: "wat"})
 	assertEqual(t, *eventID, hub.LastEventID())
+
+	recoverID := hub.Recover("wat")
+	assertEqual(t, *recoverID, hub.LastEventID())
+
+	recoverWithContextID := hub.RecoverWithContext(context.Background(), "wat")
+	assertEqual(t, *recoverWithContextID, hub.LastEventID())
 }
 
 func TestLastEventIDNotChangedForTransactions(t *testing.T) {
@@ -218,6 +242,49 @@ func TestLastEventIDDoesNotReset(t *testing.T) {
 	assertEqual(t, hub.LastEventID(), *id1) // last event ID must not have changed
 }
 
+func TestAddBreadcrumbBeforeBreadcrumbPanic(t *testing.T) {
+	for _, drop := range []bool{false, true} {
+		hub, client, scope := setupHubTest()
+		client.options.DropOnCallbackPanic = drop
+		client.options.BeforeBreadcrumb = func(breadcrumb *Breadcrumb, hint *BreadcrumbHint) *Breadcrumb {
+			panic("BeforeBreadcrumb bug")
+		}
+
+		hub.AddBreadcrumb(&Breadcrumb{Message: "Breadcrumb"}, nil)
+
+		if drop {
+			assertEqual(t, len(scope.breadcrumbs), 0)
+			continue
+		}
+		assertEqual(t, len(scope.breadcrumbs), 1)
+		assertEqual(t, scope.breadcrumbs[0].Message, "Breadcrumb")
+	}
+}
+
+func TestAddBreadcrumbRespectBreadcrumbLevelOption(t *testing.T) {
+	hub, client, scope := setupHubTest()
+	client.options.BreadcrumbLevel = LevelInfo
+	client.options.BeforeBreadcrumb = func(breadcrumb *Breadcrumb, hint *BreadcrumbHint) *Breadcrumb {
+		if breadcrumb.Message == "promoted" {
+			breadcrumb.Level = LevelWarning
+		}
+		return breadcrumb
+	}
+
+	hub.AddBreadcrumb(&Breadcrumb{Message: "debug", Level: LevelDebug}, nil)
+	hub.AddBreadcrumb(&Breadcrumb{Message: "promoted", Level: LevelDebug}, nil)
+	hub.AddBreadcrumb(&Breadcrumb{Message: "no level"}, nil)
+	hub.AddBreadcrumb(&Breadcrumb{Message: "info", Level: LevelInfo}, nil)
+	hub.AddBreadcrumb(&Breadcrumb{Message: "error", Level: LevelError}, nil)
+	hub.AddBreadcrumb(&Breadcrumb{Message: "custom", Level: "trace"}, nil)
+
+	var got []string
+	for _, b := range scope.breadcrumbs {
+		got = append(got, b.Message)
+	}
+	assertEqual(t, got, []string{"promoted", "no level", "info", "error", "custom"})
+}
+
 func TestAddBreadcrumbRespectMaxBreadcrumbsOption(t *testing.T) {
 	hub, client, scope := setupHubTest()
 	client.options.MaxBreadcrumbs = 2
@@ -231,6 +298,29 @@ func TestAddBreadcrumbRespectMaxBreadcrumbsOption(t *testing.T) {
 	assertEqual(t, len(scope.breadcrumbs), 2)
 }
 
+func TestAddBreadcrumbOnBreadcrumbEvicted(t *testing.T) {
+	hub, client, scope := setupHubTest()
+	client.options.MaxBreadcrumbs = 3
+	client.options.MaxBreadcrumbsPerCategory = map[string]int{"http": 1}
+	var evicted []string
+	client.options.OnBreadcrumbEvicted = func(breadcrumb *Breadcrumb) {
+		evicted = append(evicted, breadcrumb.Message)
+	}
+
+	for _, message := range []string{"1", "2", "3", "4"} {
+		hub.AddBreadcrumb(&Breadcrumb{Message: message}, nil)
+	}
+	hub.AddBreadcrumb(&Breadcrumb{Category: "http", Message: "http 1"}, nil)
+	hub.AddBreadcrumb(&Breadcrumb{Category: "http", Message: "http 2"}, nil)
+
+	assertEqual(t, evicted, []string{"1", "2", "http 1"})
+	var kept []string
+	for _, b := range scope.breadcrumbs {
+		kept = append(kept, b.Message)
+	}
+	assertEqual(t, kept, []string{"3", "4", "http 2"})
+}
+
 func TestAddBreadcrumbSkipAllBreadcrumbsIfMaxBreadcrumbsIsLessThanZero(t *testing.T) {
 	hub, client, scope := setupHubTest()
 	client.options.MaxBreadcrumbs = -1
@@ -244,6 +334,52 @@ func TestAddBreadcrumbSkipAllBreadcrumbsIfMaxBreadcrumbsIsLessThanZero(t *testin
 	assertEqual(t, len(scope.breadcrumbs), 0)
 }
 
+func TestAddBreadcrumbRespectMaxBreadcrumbsPerCategoryOption(t *testing.T) {
+	hub, client, scope := setupHubTest()
+	client.options.MaxBreadcrumbs = 5
+	client.options.MaxBreadcrumbsPerCategory = map[string]int{
+		"http":  2,
+		"query": 0,
+	}
+
+	hub.AddBreadcrumb(&Breadcrumb{Category: "ui", Message: "ui 1"}, nil)
+	for i := 1; i <= 3; i++ {
+		hub.AddBreadcrumb(&Breadcrumb{Category: "http", Message: fmt.Sprintf("http %d", i)}, nil)
+		hub.AddBreadcrumb(&Breadcrumb{Category: "query", Message: fmt.Sprintf("query %d", i)}, nil)
+	}
+	hub.AddBreadcrumb(&Breadcrumb{Category: "ui", Message: "ui 2"}, nil)
+
+	var got []string
+	for _, b := range scope.breadcrumbs {
+		got = append(got, b.Message)
+	}
+	// The oldest "http" breadcrumb was evicted, and the other categories
+	// kept.
+	assertEqual(t, got, []string{"ui 1", "http 2", "http 3", "ui 2"})
+}
+
+func TestAddErrorBreadcrumbs(t *testing.T) {
+	hub, _, scope := setupHubTest()
+
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("charge card: %w", fmt.Errorf("call payment API: %w", cause))
+	hub.AddErrorBreadcrumbs(err)
+
+	var messages []string
+	for _, b := range scope.breadcrumbs {
+		assertEqual(t, b.Category, "error")
+		assertEqual(t, b.Level, LevelError)
+		messages = append(messages, b.Message)
+	}
+	assertEqual(t, messages, []string{
+		"connection refused",
+		"call payment API: connection refused",
+		"charge card: call payment API: connection refused",
+	})
+	assertEqual(t, scope.breadcrumbs[0].Data["type"], "*errors.errorString")
+	assertEqual(t, scope.breadcrumbs[2].Data["type"], "*fmt.wrapError")
+}
+
 func TestAddBreadcrumbShouldNeverExceedMaxBreadcrumbsConst(t *testing.T) {
 	hub, client, scope := setupHubTest()
 	client.options.MaxBreadcrumbs = 1000
@@ -410,3 +546,253 @@ func TestConcurrentHubClone(t *testing.T) {
 		t.Errorf("Events mismatch (-want +got):\n%s", diff)
 	}
 }
+
+// BenchmarkHubClone measures the cost of cloning a hub, as integrations do for
+// every request they serve.
+func BenchmarkHubClone(b *testing.B) {
+	hub, _, scope := setupHubTest()
+	scope.SetTag("key", "value")
+	scope.SetContext("runtime", Context{"name": "go"})
+	scope.SetExtra("key", "value")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hub.Clone()
+	}
+}
+
+// flushResultTransport is a TransportMock whose Flush returns ok.
+type flushResultTransport struct {
+	TransportMock
+	ok bool
+}
+
+func (t *flushResultTransport) Flush(timeout time.Duration) bool {
+	return t.ok
+}
+
+// flushCountingTransport is a TransportMock counting calls to Flush.
+type flushCountingTransport struct {
+	TransportMock
+	flushes int32
+}
+
+func (t *flushCountingTransport) Flush(timeout time.Duration) bool {
+	atomic.AddInt32(&t.flushes, 1)
+	return true
+}
+
+func (t *flushCountingTransport) FlushWithContext(ctx context.Context) bool {
+	return t.Flush(0)
+}
+
+func TestHubFlushOnlyFlushesItsClient(t *testing.T) {
+	var hubs [2]*Hub
+	var transports [2]*flushCountingTransport
+	for i := range hubs {
+		transports[i] = &flushCountingTransport{}
+		client, err := NewClient(ClientOptions{Transport: transports[i]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hubs[i] = NewHub(client, NewScope())
+	}
+
+	if !hubs[0].Flush(time.Second) {
+		t.Error("Flush() = false, want true")
+	}
+	if !hubs[0].FlushWithContext(context.Background()) {
+		t.Error("FlushWithContext() = false, want true")
+	}
+	assertEqual(t, atomic.LoadInt32(&transports[0].flushes), int32(2))
+	assertEqual(t, atomic.LoadInt32(&transports[1].flushes), int32(0))
+
+	if NewHub(nil, NewScope()).Flush(time.Second) {
+		t.Error("Flush() without a client = true, want false")
+	}
+}
+
+func TestCaptureExceptionAndFlush(t *testing.T) {
+	tests := []struct {
+		name       string
+		beforeSend func(event *Event, hint *EventHint) *Event
+		flushed    bool
+		want       bool
+	}{
+		{name: "Sent", flushed: true, want: true},
+		{name: "FlushTimeout", flushed: false, want: false},
+		{
+			name:       "Dropped",
+			beforeSend: func(event *Event, hint *EventHint) *Event { return nil },
+			flushed:    true,
+			want:       false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &flushResultTransport{ok: tt.flushed}
+			client, err := NewClient(ClientOptions{Transport: transport, BeforeSend: tt.beforeSend})
+			if err != nil {
+				t.Fatal(err)
+			}
+			hub := NewHub(client, NewScope())
+
+			if got := hub.CaptureExceptionAndFlush(errors.New("fatal"), time.Second); got != tt.want {
+				t.Errorf("CaptureExceptionAndFlush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptureExceptionWith(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := NewScope()
+	scope.SetTag("service", "api")
+	hub := NewHub(client, scope)
+
+	hub.CaptureExceptionWith(errors.New("import failed"),
+		WithTag("job", "import"),
+		WithExtra("rows", 42),
+		WithLevel(LevelWarning),
+		WithFingerprint("import", "failed"),
+	)
+
+	event := transport.lastEvent
+	assertEqual(t, event.Tags, map[string]string{"service": "api", "job": "import"})
+	assertEqual(t, event.Extra, map[string]interface{}{"rows": 42})
+	assertEqual(t, event.Level, LevelWarning)
+	assertEqual(t, event.Fingerprint, []string{"import", "failed"})
+
+	// The options only applied to the captured event.
+	hub.CaptureException(errors.New("other"))
+	event = transport.lastEvent
+	assertEqual(t, event.Tags, map[string]string{"service": "api"})
+	assertEqual(t, event.Level, LevelError)
+}
+
+func TestGoWithHub(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := NewScope()
+	scope.SetTag("request", "GET /orders")
+	hub := NewHub(client, scope)
+
+	errFailed := errors.New("failed")
+	if err := GoWithHub(hub, func() error { return errFailed })(); err != errFailed {
+		t.Errorf("got error %v, want %v", err, errFailed)
+	}
+	assertEqual(t, len(transport.Events()), 0)
+
+	done := make(chan error)
+	go func() {
+		done <- GoWithHub(hub, func() error { panic("boom") })()
+	}()
+	if err := <-done; err == nil || err.Error() != "panic: boom" {
+		t.Errorf("got error %v, want the panic", err)
+	}
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	assertEqual(t, events[0].Message, "boom")
+	assertEqual(t, events[0].Tags["request"], "GET /orders")
+	assertEqual(t, hub.LastEventID(), events[0].EventID)
+}
+
+func TestCaptureUnhandledException(t *testing.T) {
+	hub, client, _ := setupHubTest()
+	transport := &TransportMock{}
+	client.Transport = transport
+
+	hub.CaptureException(errors.New("handled"))
+	mechanism := transport.lastEvent.Exception[0].Mechanism
+	if mechanism == nil || mechanism.Handled == nil || !*mechanism.Handled {
+		t.Errorf("CaptureException() mechanism = %+v, want handled", mechanism)
+	}
+
+	id := hub.CaptureUnhandledException(fmt.Errorf("fatal: %w", errors.New("cause")))
+	assertEqual(t, hub.LastEventID(), *id)
+	exceptions := transport.lastEvent.Exception
+	assertEqual(t, len(exceptions), 2)
+	assertEqual(t, exceptions[0].Mechanism, (*Mechanism)(nil))
+	mechanism = exceptions[1].Mechanism
+	if mechanism == nil || mechanism.Handled == nil || *mechanism.Handled {
+		t.Errorf("CaptureUnhandledException() mechanism = %+v, want unhandled", mechanism)
+	}
+}
+
+func TestCaptureWithContext(t *testing.T) {
+	hub, client, scope := setupHubTest()
+	transport := &TransportMock{}
+	client.Transport = transport
+	scope.SetTag("request", "GET /orders")
+	ctx := SetHubOnContext(context.Background(), hub)
+
+	CaptureExceptionWithContext(ctx, errors.New("failed"))
+	assertEqual(t, transport.lastEvent.Tags["request"], "GET /orders")
+	assertEqual(t, hub.LastEventID(), transport.lastEvent.EventID)
+
+	CaptureMessageWithContext(ctx, "message")
+	assertEqual(t, transport.lastEvent.Message, "message")
+	assertEqual(t, transport.lastEvent.Tags["request"], "GET /orders")
+
+	// Without a hub in the context, events go to the current hub.
+	currentTransport := &TransportMock{}
+	currentClient, err := NewClient(ClientOptions{Transport: currentTransport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentHub.BindClient(currentClient)
+	defer currentHub.stackTop().SetClient(nil)
+
+	CaptureExceptionWithContext(context.Background(), errors.New("failed"))
+	assertEqual(t, len(currentTransport.Events()), 1)
+	assertEqual(t, len(transport.Events()), 2)
+}
+
+func TestCaptureRecovered(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub(client, NewScope())
+	ctx := SetHubOnContext(context.Background(), hub)
+
+	if id := CaptureRecovered(ctx, nil, LevelError); id != nil {
+		t.Errorf("CaptureRecovered(nil) = %v, want nil", *id)
+	}
+	func() {
+		defer func() {
+			CaptureRecovered(ctx, recover(), LevelError)
+		}()
+		panic(errors.New("template failed"))
+	}()
+	hub.CaptureRecovered(ctx, "boom", "")
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if got := events[0]; got.Level != LevelError || len(got.Exception) == 0 || got.Exception[0].Value != "template failed" {
+		t.Errorf("got event with level %q and exceptions %v, want level %q with the panic error", got.Level, got.Exception, LevelError)
+	}
+	if got := events[1]; got.Level != LevelFatal || got.Message != "boom" {
+		t.Errorf("got event %q with level %q, want %q with level %q", got.Message, got.Level, "boom", LevelFatal)
+	}
+	if hub.Scope().level != "" {
+		t.Errorf("got scope level %q, want the level to apply to the event only", hub.Scope().level)
+	}
+	if hub.LastEventID() != events[1].EventID {
+		t.Errorf("LastEventID() = %q, want %q", hub.LastEventID(), events[1].EventID)
+	}
+}
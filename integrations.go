@@ -5,8 +5,10 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/debug"
+	"runtime/metrics"
 	"strings"
 	"sync"
+	"time"
 )
 
 // ================================
@@ -26,17 +28,20 @@ func (mi *modulesIntegration) SetupOnce(client *Client) {
 	client.AddEventProcessor(mi.processor)
 }
 
+// processor sets the modules of the binary, read once from its build info,
+// on events that do not list modules already.
 func (mi *modulesIntegration) processor(event *Event, hint *EventHint) *Event {
-	if len(event.Modules) == 0 {
-		mi.once.Do(func() {
-			info, ok := debug.ReadBuildInfo()
-			if !ok {
-				Logger.Print("The Modules integration is not available in binaries built without module support.")
-				return
-			}
-			mi.modules = extractModules(info)
-		})
+	if len(event.Modules) > 0 {
+		return event
 	}
+	mi.once.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			Logger.Print("The Modules integration is not available in binaries built without module support.")
+			return
+		}
+		mi.modules = extractModules(info)
+	})
 	event.Modules = mi.modules
 	return event
 }
@@ -177,6 +182,67 @@ func getIgnoreErrorsSuspects(event *Event) []string {
 	return suspects
 }
 
+// ================================
+// In-App Frames Integration
+// ================================
+
+type inAppFramesIntegration struct {
+	include []string
+	exclude []string
+}
+
+func (ifi *inAppFramesIntegration) Name() string {
+	return "InAppFrames"
+}
+
+func (ifi *inAppFramesIntegration) SetupOnce(client *Client) {
+	ifi.include = client.options.InAppInclude
+	ifi.exclude = client.options.InAppExclude
+	if len(ifi.include) == 0 && len(ifi.exclude) == 0 {
+		return
+	}
+	client.AddEventProcessor(ifi.processor)
+}
+
+func (ifi *inAppFramesIntegration) processor(event *Event, hint *EventHint) *Event {
+	for _, ex := range event.Exception {
+		if ex.Stacktrace != nil {
+			ifi.classify(ex.Stacktrace.Frames)
+		}
+	}
+	for _, th := range event.Threads {
+		if th.Stacktrace != nil {
+			ifi.classify(th.Stacktrace.Frames)
+		}
+	}
+	return event
+}
+
+// classify sets the InApp field of frames matching InAppExclude or
+// InAppInclude, leaving other frames unchanged.
+func (ifi *inAppFramesIntegration) classify(frames []Frame) {
+	for i := range frames {
+		switch {
+		case matchesAnyPackage(frames[i].Module, ifi.exclude):
+			frames[i].InApp = false
+		case matchesAnyPackage(frames[i].Module, ifi.include):
+			frames[i].InApp = true
+		}
+	}
+}
+
+// matchesAnyPackage reports whether the package path pkg is one of prefixes
+// or a subpackage of one of them.
+func matchesAnyPackage(pkg string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // ================================
 // Contextify Frames Integration
 // ================================
@@ -291,3 +357,240 @@ func (cfi *contextifyFramesIntegration) addContextLinesToFrame(frame Frame, line
 	}
 	return frame
 }
+
+// ================================
+// Dedupe Integration
+// ================================
+
+// defaultDedupeWindow is the window of the Dedupe integration when none is
+// given to NewDedupeIntegration.
+const defaultDedupeWindow = time.Second
+
+type dedupeIntegration struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// seen maps the keys of the events sent in the last window to when they
+	// were sent.
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewDedupeIntegration returns an integration that drops duplicate error
+// events sent within window of each other, such that code reporting the same
+// error in a tight loop reports it at most once per window. Events are
+// duplicates if they have the same fingerprint, message and exceptions, with
+// the same type, value and topmost stack frame. Transactions are never
+// dropped.
+//
+// A window of zero defaults to one second. Dropped events are counted in
+// client reports, like events dropped by other event processors.
+//
+// The integration is not installed by default. Install it with the
+// Integrations client option:
+//
+//	sentry.Init(sentry.ClientOptions{
+//		Integrations: func(integrations []sentry.Integration) []sentry.Integration {
+//			return append(integrations, sentry.NewDedupeIntegration(0))
+//		},
+//	})
+func NewDedupeIntegration(window time.Duration) Integration {
+	if window <= 0 {
+		window = defaultDedupeWindow
+	}
+	return &dedupeIntegration{
+		window: window,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+func (di *dedupeIntegration) Name() string {
+	return "Dedupe"
+}
+
+func (di *dedupeIntegration) SetupOnce(client *Client) {
+	client.AddEventProcessor(di.processor)
+}
+
+func (di *dedupeIntegration) processor(event *Event, hint *EventHint) *Event {
+	if event.Type == transactionType {
+		return event
+	}
+	key := dedupeKey(event)
+	if key == "" {
+		return event
+	}
+
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	now := di.now()
+	if now.Sub(di.lastPrune) >= di.window {
+		for k, sent := range di.seen {
+			if now.Sub(sent) >= di.window {
+				delete(di.seen, k)
+			}
+		}
+		di.lastPrune = now
+	}
+	if sent, ok := di.seen[key]; ok && now.Sub(sent) < di.window {
+		Logger.Printf("Event dropped as a duplicate of an event sent %s ago.", now.Sub(sent))
+		return nil
+	}
+	di.seen[key] = now
+	return event
+}
+
+// dedupeKey returns the key identifying duplicates of event, or the empty
+// string if event has neither a message nor exceptions.
+func dedupeKey(event *Event) string {
+	if event.Message == "" && len(event.Exception) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, fp := range event.Fingerprint {
+		fmt.Fprintf(&b, "%s\x00", fp)
+	}
+	fmt.Fprintf(&b, "\x01%s", event.Message)
+	for _, ex := range event.Exception {
+		fmt.Fprintf(&b, "\x01%s\x00%s", ex.Type, ex.Value)
+		// The topmost frame is the last one.
+		if ex.Stacktrace != nil && len(ex.Stacktrace.Frames) > 0 {
+			f := ex.Stacktrace.Frames[len(ex.Stacktrace.Frames)-1]
+			fmt.Fprintf(&b, "\x00%s\x00%s\x00%s\x00%d", f.Module, f.Function, f.AbsPath, f.Lineno)
+		}
+	}
+	return b.String()
+}
+
+// ================================
+// Runtime Metrics Integration
+// ================================
+
+// runtimeMetricsContextKey is the key of the event context set by the
+// RuntimeMetrics integration.
+const runtimeMetricsContextKey = "go"
+
+// runtimeMetrics maps the names of runtime/metrics read by the RuntimeMetrics
+// integration to their key in its context.
+var runtimeMetrics = map[string]string{
+	"/memory/classes/heap/objects:bytes": "heap_alloc_bytes",
+	"/memory/classes/total:bytes":        "sys_bytes",
+	"/gc/heap/goal:bytes":                "heap_goal_bytes",
+	"/gc/cycles/total:gc-cycles":         "gc_cycles",
+}
+
+type runtimeMetricsIntegration struct{}
+
+// NewRuntimeMetricsIntegration returns an integration that attaches a snapshot
+// of the Go runtime to error events, in a "go" context: the number of
+// goroutines, the heap size and goal, the memory obtained from the OS and the
+// number of completed GC cycles. Transactions are left as is.
+//
+// The snapshot is read with runtime/metrics, which neither stops the world
+// like runtime.ReadMemStats nor triggers a garbage collection.
+//
+// The integration is not installed by default. Install it with the
+// Integrations client option:
+//
+//	sentry.Init(sentry.ClientOptions{
+//		Integrations: func(integrations []sentry.Integration) []sentry.Integration {
+//			return append(integrations, sentry.NewRuntimeMetricsIntegration())
+//		},
+//	})
+func NewRuntimeMetricsIntegration() Integration {
+	return runtimeMetricsIntegration{}
+}
+
+func (runtimeMetricsIntegration) Name() string {
+	return "RuntimeMetrics"
+}
+
+func (ri runtimeMetricsIntegration) SetupOnce(client *Client) {
+	client.AddEventProcessor(ri.processor)
+}
+
+func (runtimeMetricsIntegration) processor(event *Event, _ *EventHint) *Event {
+	if event.Type == transactionType {
+		return event
+	}
+	if _, ok := event.Contexts[runtimeMetricsContextKey]; ok {
+		return event
+	}
+
+	samples := make([]metrics.Sample, 0, len(runtimeMetrics))
+	for name := range runtimeMetrics {
+		samples = append(samples, metrics.Sample{Name: name})
+	}
+	metrics.Read(samples)
+
+	context := Context{"num_goroutine": runtime.NumGoroutine()}
+	for _, sample := range samples {
+		// Metrics unsupported by the Go version have a KindBad value.
+		if sample.Value.Kind() == metrics.KindUint64 {
+			context[runtimeMetrics[sample.Name]] = sample.Value.Uint64()
+		}
+	}
+	if event.Contexts == nil {
+		event.Contexts = make(map[string]Context)
+	}
+	event.Contexts[runtimeMetricsContextKey] = context
+	return event
+}
+
+// ================================
+// Platform Tags Integration
+// ================================
+
+type platformTagsIntegration struct {
+	tags map[string]string
+}
+
+// NewPlatformTagsIntegration returns an integration that tags events with the
+// Go version the program was built with and the operating system and
+// architecture it runs on, as "go.version", "go.os" and "go.arch". Unlike the
+// "runtime", "os" and "device" contexts set by the default Environment
+// integration, tags can be searched and compared across a fleet of machines,
+// for instance to tell apart issues only happening on arm64.
+//
+// The tags are computed once, when the integration is set up. They do not
+// replace tags of the same name set on the event or the scope.
+//
+// The integration is not installed by default. Install it with the
+// Integrations client option:
+//
+//	sentry.Init(sentry.ClientOptions{
+//		Integrations: func(integrations []sentry.Integration) []sentry.Integration {
+//			return append(integrations, sentry.NewPlatformTagsIntegration())
+//		},
+//	})
+func NewPlatformTagsIntegration() Integration {
+	return &platformTagsIntegration{}
+}
+
+func (*platformTagsIntegration) Name() string {
+	return "PlatformTags"
+}
+
+func (pi *platformTagsIntegration) SetupOnce(client *Client) {
+	pi.tags = map[string]string{
+		"go.version": runtime.Version(),
+		"go.os":      runtime.GOOS,
+		"go.arch":    runtime.GOARCH,
+	}
+	client.AddEventProcessor(pi.processor)
+}
+
+func (pi *platformTagsIntegration) processor(event *Event, _ *EventHint) *Event {
+	if event.Tags == nil {
+		event.Tags = make(map[string]string, len(pi.tags))
+	}
+	for key, value := range pi.tags {
+		if _, ok := event.Tags[key]; !ok {
+			event.Tags[key] = value
+		}
+	}
+	return event
+}
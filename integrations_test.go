@@ -2,11 +2,15 @@ package sentry
 
 import (
 	"encoding/json"
+	"errors"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"runtime/debug"
 	"testing"
+	"time"
 
+	"github.com/getsentry/sentry-go/internal/ratelimit"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -173,6 +177,147 @@ func TestIgnoreErrorsIntegration(t *testing.T) {
 	}
 }
 
+func TestDedupeIntegration(t *testing.T) {
+	now := time.Unix(0, 0)
+	di := NewDedupeIntegration(time.Second).(*dedupeIntegration)
+	di.now = func() time.Time { return now }
+
+	newEvent := func(value string, lineno int) *Event {
+		return &Event{
+			Exception: []Exception{{
+				Type:  "*errors.errorString",
+				Value: value,
+				Stacktrace: &Stacktrace{Frames: []Frame{
+					{Function: "main", Lineno: 1},
+					{Function: "retry", Lineno: lineno},
+				}},
+			}},
+		}
+	}
+
+	if di.processor(newEvent("error", 10), &EventHint{}) == nil {
+		t.Error("first event should not be dropped")
+	}
+	if di.processor(newEvent("error", 10), &EventHint{}) != nil {
+		t.Error("duplicate event should be dropped")
+	}
+	if di.processor(newEvent("other error", 10), &EventHint{}) == nil {
+		t.Error("event with a different value should not be dropped")
+	}
+	if di.processor(newEvent("error", 20), &EventHint{}) == nil {
+		t.Error("event with a different top frame should not be dropped")
+	}
+	withFingerprint := newEvent("error", 10)
+	withFingerprint.Fingerprint = []string{"custom"}
+	if di.processor(withFingerprint, &EventHint{}) == nil {
+		t.Error("event with a different fingerprint should not be dropped")
+	}
+	transaction := &Event{Type: transactionType, Message: "transaction"}
+	for i := 0; i < 2; i++ {
+		if di.processor(transaction, &EventHint{}) == nil {
+			t.Error("transactions should never be dropped")
+		}
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if di.processor(newEvent("error", 10), &EventHint{}) != nil {
+		t.Error("duplicate event within the window should be dropped")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if di.processor(newEvent("error", 10), &EventHint{}) == nil {
+		t.Error("duplicate event after the window should not be dropped")
+	}
+	if len(di.seen) != 1 {
+		t.Errorf("got %d remembered events, want expired events to be pruned", len(di.seen))
+	}
+}
+
+func TestDedupeIntegrationClientReports(t *testing.T) {
+	client, scope, transport := setupClientTest()
+	NewDedupeIntegration(time.Minute).SetupOnce(client)
+
+	for i := 0; i < 3; i++ {
+		client.CaptureException(errors.New("error"), nil, scope)
+	}
+
+	assertEqual(t, len(transport.Events()), 1)
+	want := map[discardedKey]uint64{
+		{reason: discardReasonEventProcessor, category: ratelimit.CategoryError}: 2,
+	}
+	if diff := cmp.Diff(want, client.discarded.take(), cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("discarded events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRuntimeMetricsIntegration(t *testing.T) {
+	client, scope, transport := setupClientTest()
+	NewRuntimeMetricsIntegration().SetupOnce(client)
+
+	client.CaptureException(errors.New("error"), nil, scope)
+	context := transport.lastEvent.Contexts["go"]
+	if n, ok := context["num_goroutine"].(int); !ok || n < 1 {
+		t.Errorf("got num_goroutine %v, want a positive number", context["num_goroutine"])
+	}
+	for _, key := range []string{"heap_alloc_bytes", "sys_bytes", "heap_goal_bytes", "gc_cycles"} {
+		if _, ok := context[key].(uint64); !ok {
+			t.Errorf("got %s %v, want a number", key, context[key])
+		}
+	}
+
+	transaction := NewEvent()
+	transaction.Type = transactionType
+	client.CaptureEvent(transaction, nil, scope)
+	if _, ok := transport.lastEvent.Contexts["go"]; ok {
+		t.Error("transaction has a go context, want none")
+	}
+}
+
+func TestPlatformTagsIntegration(t *testing.T) {
+	client, scope, transport := setupClientTest()
+	NewPlatformTagsIntegration().SetupOnce(client)
+	event := NewEvent()
+	event.Tags["go.os"] = "custom"
+
+	client.CaptureEvent(event, nil, scope)
+	tags := transport.lastEvent.Tags
+	assertEqual(t, tags["go.version"], runtime.Version())
+	assertEqual(t, tags["go.os"], "custom")
+	assertEqual(t, tags["go.arch"], runtime.GOARCH)
+}
+
+func TestInAppFramesIntegration(t *testing.T) {
+	ifi := inAppFramesIntegration{
+		include: []string{"github.com/example/app", "github.com/example/lib/"},
+		exclude: []string{"github.com/example/app/vendor"},
+	}
+
+	event := &Event{
+		Exception: []Exception{{
+			Stacktrace: &Stacktrace{Frames: []Frame{
+				{Module: "github.com/example/app", InApp: false},
+				{Module: "github.com/example/app/handlers", InApp: false},
+				{Module: "github.com/example/app/vendor/dep", InApp: true},
+				{Module: "github.com/example/lib", InApp: false},
+				{Module: "github.com/example/application", InApp: false},
+				{Module: "main", InApp: true},
+			}},
+		}},
+		Threads: []Thread{{
+			Stacktrace: &Stacktrace{Frames: []Frame{
+				{Module: "github.com/example/app/vendor", InApp: true},
+			}},
+		}},
+	}
+	event = ifi.processor(event, &EventHint{})
+
+	var got []bool
+	for _, frame := range event.Exception[0].Stacktrace.Frames {
+		got = append(got, frame.InApp)
+	}
+	got = append(got, event.Threads[0].Stacktrace.Frames[0].InApp)
+	assertEqual(t, got, []bool{true, true, false, true, false, true, false})
+}
+
 func TestContextifyFrames(t *testing.T) {
 	cfi := contextifyFramesIntegration{
 		sr:           newSourceReader(),
@@ -344,6 +489,82 @@ func TestExtractModules(t *testing.T) {
 	}
 }
 
+func TestIntegrationsOption(t *testing.T) {
+	var defaults []string
+	client, err := NewClient(ClientOptions{
+		Integrations: func(integrations []Integration) []Integration {
+			var kept []Integration
+			for _, integration := range integrations {
+				defaults = append(defaults, integration.Name())
+				if integration.Name() != "Modules" {
+					kept = append(kept, integration)
+				}
+			}
+			return append(kept, NewDedupeIntegration(0))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, defaults, []string{"InAppFrames", "ContextifyFrames", "Environment", "Modules", "IgnoreErrors"})
+	assertEqual(t, client.listIntegrations(), []string{"ContextifyFrames", "Dedupe", "Environment", "IgnoreErrors", "InAppFrames"})
+}
+
+func TestDisableDefaultIntegrations(t *testing.T) {
+	client, transport := newClientWithTransportMock(t, ClientOptions{DisableDefaultIntegrations: true})
+	assertEqual(t, client.listIntegrations(), []string{"InAppFrames"})
+
+	client.CaptureException(errors.New("error"), nil, nil)
+	event := transport.lastEvent
+	if event == nil {
+		t.Fatal("no event captured")
+	}
+	assertEqual(t, len(event.Modules), 0)
+	if _, ok := event.Contexts["runtime"]; ok {
+		t.Error("got a runtime context, want none without the Environment integration")
+	}
+
+	client, transport = newClientWithTransportMock(t, ClientOptions{
+		DisableDefaultIntegrations: true,
+		IgnoreErrors:               []string{"ignored"},
+	})
+	assertEqual(t, client.listIntegrations(), []string{"IgnoreErrors", "InAppFrames"})
+	client.CaptureException(errors.New("ignored"), nil, nil)
+	assertEqual(t, len(transport.Events()), 0)
+}
+
+func TestModulesIntegration(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport: transport,
+		Integrations: func([]Integration) []Integration {
+			return []Integration{new(modulesIntegration)}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.CaptureMessage("modules", nil, nil)
+	event := NewEvent()
+	event.Message = "custom modules"
+	event.Modules = map[string]string{"example.com/custom": "v1.0.0"}
+	client.CaptureEvent(event, nil, nil)
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	// Test binaries are built with module support.
+	if len(events[0].Modules) == 0 {
+		t.Error("got no modules, want the modules of the test binary")
+	}
+	if diff := cmp.Diff(map[string]string{"example.com/custom": "v1.0.0"}, events[1].Modules); diff != "" {
+		t.Errorf("Modules mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestEnvironmentIntegrationDoesNotOverrideExistingContexts(t *testing.T) {
 	transport := &TransportMock{}
 	client, err := NewClient(ClientOptions{
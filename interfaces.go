@@ -22,6 +22,12 @@ const eventType = "event"
 
 const profileType = "profile"
 
+// attachmentType is the type of an attachment envelope item.
+const attachmentType = "attachment"
+
+// clientReportType is the type of a client report envelope item.
+const clientReportType = "client_report"
+
 // Level marks the severity of the event.
 type Level string
 
@@ -34,6 +40,25 @@ const (
 	LevelFatal   Level = "fatal"
 )
 
+// severity returns the rank of l among the known levels, from 0 for
+// LevelDebug to 4 for LevelFatal, or -1 if l is not a known level.
+func (l Level) severity() int {
+	switch l {
+	case LevelDebug:
+		return 0
+	case LevelInfo:
+		return 1
+	case LevelWarning:
+		return 2
+	case LevelError:
+		return 3
+	case LevelFatal:
+		return 4
+	default:
+		return -1
+	}
+}
+
 func getSensitiveHeaders() map[string]bool {
 	return map[string]bool{
 		"Authorization":   true,
@@ -160,16 +185,69 @@ type Request struct {
 	Env         map[string]string `json:"env,omitempty"`
 }
 
+// RequestFields is a set of fields of Request, combined with the | operator.
+// See ClientOptions.OmitRequestFields.
+type RequestFields uint8
+
+// Fields of Request that can be omitted from events.
+const (
+	RequestQueryString RequestFields = 1 << iota
+	RequestHeaders
+	RequestCookies
+	RequestEnv
+	RequestData
+)
+
+// omit returns a copy of r without the given fields, or r itself if it has
+// none of them.
+func (r *Request) omit(fields RequestFields) *Request {
+	c := *r
+	if fields&RequestQueryString != 0 {
+		c.QueryString = ""
+	}
+	if fields&RequestHeaders != 0 {
+		c.Headers = nil
+	}
+	if fields&RequestCookies != 0 {
+		c.Cookies = ""
+	}
+	if fields&RequestEnv != 0 {
+		c.Env = nil
+	}
+	if fields&RequestData != 0 {
+		c.Data = ""
+	}
+	if c.QueryString == r.QueryString && len(c.Headers) == len(r.Headers) &&
+		c.Cookies == r.Cookies && len(c.Env) == len(r.Env) && c.Data == r.Data {
+		return r
+	}
+	return &c
+}
+
 // NewRequest returns a new Sentry Request from the given http.Request.
 //
 // NewRequest avoids operations that depend on network access. In particular, it
 // does not read r.Body.
+//
+// It also accepts requests created outside of an HTTP server, for example
+// with http.NewRequest by code replaying a saved request, whose host may only
+// be known from r.URL.
 func NewRequest(r *http.Request) *Request {
 	protocol := schemeHTTP
 	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
 		protocol = schemeHTTPS
 	}
-	url := fmt.Sprintf("%s://%s%s", protocol, r.Host, r.URL.Path)
+	host, path, query := r.Host, "", ""
+	if r.URL != nil {
+		if host == "" {
+			host = r.URL.Host
+		}
+		if r.URL.Scheme == string(schemeHTTPS) && r.TLS == nil {
+			protocol = schemeHTTPS
+		}
+		path, query = r.URL.Path, r.URL.RawQuery
+	}
+	url := fmt.Sprintf("%s://%s%s", protocol, host, path)
 
 	var cookies string
 	var env map[string]string
@@ -198,12 +276,12 @@ func NewRequest(r *http.Request) *Request {
 		}
 	}
 
-	headers["Host"] = r.Host
+	headers["Host"] = host
 
 	return &Request{
 		URL:         url,
 		Method:      r.Method,
-		QueryString: r.URL.RawQuery,
+		QueryString: query,
 		Cookies:     cookies,
 		Headers:     headers,
 		Env:         env,
@@ -217,6 +295,14 @@ type Mechanism struct {
 	HelpLink    string                 `json:"help_link,omitempty"`
 	Handled     *bool                  `json:"handled,omitempty"`
 	Data        map[string]interface{} `json:"data,omitempty"`
+	// ExceptionID, ParentID, Source and IsExceptionGroup link the exceptions
+	// of an event reporting an error that wraps several errors, such as one
+	// returned by errors.Join. ExceptionID is omitted when zero, the ID of the
+	// outermost exception, as for events with a single exception.
+	ExceptionID      int    `json:"exception_id,omitempty"`
+	ParentID         *int   `json:"parent_id,omitempty"`
+	Source           string `json:"source,omitempty"`
+	IsExceptionGroup bool   `json:"is_exception_group,omitempty"`
 }
 
 // SetUnhandled indicates that the exception is an unhandled exception, i.e.
@@ -241,6 +327,43 @@ type Exception struct {
 type SDKMetaData struct {
 	dsc                DynamicSamplingContext
 	transactionProfile *profileInfo
+	// envelopeHeaders are the headers returned by
+	// ClientOptions.EnvelopeHeaders.
+	envelopeHeaders map[string]interface{}
+	// body is the event serialized by the client to enforce
+	// ClientOptions.MaxEventSize, reused by the transports.
+	body []byte
+}
+
+// MeasurementUnit is the unit of a Measurement.
+type MeasurementUnit string
+
+// Standard measurement units. Sentry also accepts custom units.
+const (
+	UnitNone MeasurementUnit = "none"
+
+	// Duration units.
+	UnitNanosecond  MeasurementUnit = "nanosecond"
+	UnitMicrosecond MeasurementUnit = "microsecond"
+	UnitMillisecond MeasurementUnit = "millisecond"
+	UnitSecond      MeasurementUnit = "second"
+
+	// Information units.
+	UnitByte     MeasurementUnit = "byte"
+	UnitKilobyte MeasurementUnit = "kilobyte"
+	UnitMegabyte MeasurementUnit = "megabyte"
+
+	// Fraction units.
+	UnitRatio   MeasurementUnit = "ratio"
+	UnitPercent MeasurementUnit = "percent"
+)
+
+// Measurement is a numeric value measured during a transaction, shown in the
+// Performance UI, for example the number of cache hits or the size of a
+// payload.
+type Measurement struct {
+	Value float64         `json:"value"`
+	Unit  MeasurementUnit `json:"unit,omitempty"`
 }
 
 // Contains information about how the name of the transaction was determined.
@@ -278,10 +401,44 @@ type DebugMetaImage struct {
 
 // EventID is a hexadecimal string representing a unique uuid4 for an Event.
 // An EventID must be 32 characters long, lowercase and not have any dashes.
+//
+// The capture functions return a pointer to the ID of the captured event, or
+// nil if the event was not accepted, for example because the SDK is disabled
+// or the event was dropped by sampling, BeforeSend or an event processor.
+// A non-nil ID does not guarantee delivery: the Transport may still fail to
+// send the event.
 type EventID string
 
+// String returns the ID in the form it is displayed in Sentry, so that it can
+// be shown to users for reference, for example on an error page:
+//
+//	id := sentry.CaptureException(err)
+//	fmt.Fprintf(w, "Something went wrong (reference: %s)", id)
+//
+// It returns an empty string if id is nil, that is, if the event was not
+// accepted.
+func (id *EventID) String() string {
+	if id == nil {
+		return ""
+	}
+	return string(*id)
+}
+
 type Context = map[string]interface{}
 
+// Attachment is a file sent to Sentry alongside an event.
+//
+// See https://docs.sentry.io/platforms/go/enriching-events/attachments/.
+type Attachment struct {
+	// Filename is the name of the file shown in Sentry. It is required.
+	Filename string
+	// ContentType is the media type of Payload. If empty, Sentry assumes
+	// application/octet-stream.
+	ContentType string
+	// Payload is the content of the file.
+	Payload []byte
+}
+
 // Event is the fundamental data structure that is sent to Sentry.
 type Event struct {
 	Breadcrumbs []*Breadcrumb          `json:"breadcrumbs,omitempty"`
@@ -310,39 +467,83 @@ type Event struct {
 
 	// The fields below are only relevant for transactions.
 
-	Type            string           `json:"type,omitempty"`
-	StartTime       time.Time        `json:"start_timestamp"`
-	Spans           []*Span          `json:"spans,omitempty"`
-	TransactionInfo *TransactionInfo `json:"transaction_info,omitempty"`
+	Type            string                 `json:"type,omitempty"`
+	StartTime       time.Time              `json:"start_timestamp"`
+	Spans           []*Span                `json:"spans,omitempty"`
+	TransactionInfo *TransactionInfo       `json:"transaction_info,omitempty"`
+	Measurements    map[string]Measurement `json:"measurements,omitempty"`
 
 	// The fields below are not part of the final JSON payload.
 
+	// Attachments are sent as separate envelope items along with the event.
+	Attachments []*Attachment `json:"-"`
+
 	sdkMetaData SDKMetaData
 }
 
 // SetException appends the unwrapped errors to the event's exception list.
 //
-// maxErrorDepth is the maximum depth of the error chain we will look
-// into while unwrapping the errors.
+// Errors are unwrapped with their Unwrap() error, Unwrap() []error or
+// Cause() error method. When an error wraps several errors, for instance one
+// returned by errors.Join, each of them is unwrapped in turn and the
+// exceptions are linked by their Mechanism so that Sentry shows them as a
+// group.
+//
+// maxErrorDepth is the maximum number of errors we will look into while
+// unwrapping the errors. Errors found more than once, as in a cycle, are only
+// reported the first time.
 func (e *Event) SetException(exception error, maxErrorDepth int) {
-	err := exception
-	if err == nil {
+	if exception == nil {
 		return
 	}
 
-	for i := 0; i < maxErrorDepth && err != nil; i++ {
-		e.Exception = append(e.Exception, Exception{
+	var exceptions []Exception
+	// errs holds the error of each of exceptions.
+	var errs []error
+	var group bool
+	var walk func(err error, parentID int, source string)
+	walk = func(err error, parentID int, source string) {
+		if err == nil || len(exceptions) >= maxErrorDepth || containsError(errs, err) {
+			return
+		}
+		id := len(exceptions)
+		exceptions = append(exceptions, Exception{
 			Value:      err.Error(),
 			Type:       reflect.TypeOf(err).String(),
 			Stacktrace: ExtractStacktrace(err),
+			Mechanism: &Mechanism{
+				ExceptionID: id,
+				ParentID:    &parentID,
+				Source:      source,
+			},
 		})
+		errs = append(errs, err)
 		switch previous := err.(type) {
+		case interface{ Unwrap() []error }:
+			group = true
+			exceptions[id].Mechanism.IsExceptionGroup = true
+			for i, wrapped := range previous.Unwrap() {
+				walk(wrapped, id, fmt.Sprintf("errors[%d]", i))
+			}
 		case interface{ Unwrap() error }:
-			err = previous.Unwrap()
+			walk(previous.Unwrap(), id, "")
 		case interface{ Cause() error }:
-			err = previous.Cause()
-		default:
-			err = nil
+			walk(previous.Cause(), id, "")
+		}
+	}
+	walk(exception, 0, "")
+
+	// Chains of errors wrapping a single error are reported as is, only
+	// groups need their exceptions linked.
+	for i := range exceptions {
+		if !group {
+			exceptions[i].Mechanism = nil
+			continue
+		}
+		exceptions[i].Mechanism.Type = "chained"
+		if i == 0 {
+			exceptions[i].Mechanism.Type = "generic"
+			exceptions[i].Mechanism.ParentID = nil
 		}
 	}
 
@@ -350,12 +551,41 @@ func (e *Event) SetException(exception error, maxErrorDepth int) {
 	// it doesn't have a stack trace yet.
 	// We only add to the most recent error to avoid duplication and because the
 	// current stack is most likely unrelated to errors deeper in the chain.
-	if e.Exception[0].Stacktrace == nil {
-		e.Exception[0].Stacktrace = NewStacktrace()
+	if exceptions[0].Stacktrace == nil {
+		exceptions[0].Stacktrace = NewStacktrace()
 	}
 
 	// event.Exception should be sorted such that the most recent error is last.
-	reverse(e.Exception)
+	reverse(exceptions)
+	e.Exception = append(e.Exception, exceptions...)
+}
+
+// setHandled marks the most recent exception of the event as handled or not,
+// which tells Sentry whether the event is a crash, for instance in the
+// crash-free rates of releases.
+func (e *Event) setHandled(handled bool) {
+	if len(e.Exception) == 0 {
+		return
+	}
+	exception := &e.Exception[len(e.Exception)-1]
+	if exception.Mechanism == nil {
+		exception.Mechanism = &Mechanism{Type: "generic"}
+	}
+	exception.Mechanism.Handled = &handled
+}
+
+// containsError reports whether err is one of errs. Errors of types that are
+// not comparable are never reported as found.
+func containsError(errs []error, err error) bool {
+	if !reflect.TypeOf(err).Comparable() {
+		return false
+	}
+	for _, e := range errs {
+		if e == err {
+			return true
+		}
+	}
+	return false
 }
 
 // TODO: Event.Contexts map[string]interface{} => map[string]EventContext,
@@ -373,7 +603,8 @@ func (e *Event) MarshalJSON() ([]byte, error) {
 	//
 	// We overcome the limitation and achieve what we want by shadowing fields
 	// and a few type tricks.
-	if e.Type == transactionType {
+	switch e.Type {
+	case transactionType:
 		return e.transactionMarshalJSON()
 	}
 	return e.defaultMarshalJSON()
@@ -401,6 +632,7 @@ func (e *Event) defaultMarshalJSON() ([]byte, error) {
 		StartTime       json.RawMessage `json:"start_timestamp,omitempty"`
 		Spans           json.RawMessage `json:"spans,omitempty"`
 		TransactionInfo json.RawMessage `json:"transaction_info,omitempty"`
+		Measurements    json.RawMessage `json:"measurements,omitempty"`
 	}
 
 	x := errorEvent{event: (*event)(e)}
@@ -470,12 +702,32 @@ type Thread struct {
 }
 
 // EventHint contains information that can be associated with an Event.
+//
+// Event processors and the BeforeSend and BeforeSendTransaction callbacks get
+// the hint of the event, to act on the original values the event was created
+// from, for instance to drop errors of a given type:
+//
+//	BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+//		if errors.Is(hint.OriginalException, context.Canceled) {
+//			return nil
+//		}
+//		return event
+//	},
 type EventHint struct {
-	Data               interface{}
-	EventID            string
-	OriginalException  error
+	// Data and EventID are not set by the SDK, they are free for callers to
+	// pass information to their event processors.
+	Data    interface{}
+	EventID string
+	// OriginalException is the error passed to CaptureException.
+	OriginalException error
+	// RecoveredException is the value recovered from a panic, as passed to
+	// Recover, RecoverWithContext and CaptureRecovered.
 	RecoveredException interface{}
-	Context            context.Context
-	Request            *http.Request
-	Response           *http.Response
+	// Context is the context passed to RecoverWithContext and
+	// CaptureRecovered, or set by integrations.
+	Context context.Context
+	// Request and Response are not set by the SDK either, they are meant for
+	// callers reporting HTTP requests and responses.
+	Request  *http.Request
+	Response *http.Response
 }
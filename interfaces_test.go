@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
@@ -134,6 +135,28 @@ func TestNewRequestWithNoPII(t *testing.T) {
 	}
 }
 
+func TestNewRequestOutsideOfServer(t *testing.T) {
+	r, err := http.NewRequest("POST", "https://example.com/orders?id=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := NewRequest(r)
+	want := &Request{
+		URL:         "https://example.com/orders",
+		Method:      "POST",
+		QueryString: "id=1",
+		Headers:     map[string]string{"Host": "example.com"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Request mismatch (-want +got):\n%s", diff)
+	}
+
+	// A request without URL does not make NewRequest panic.
+	got = NewRequest(&http.Request{Method: "GET"})
+	assertEqual(t, got.URL, "http://")
+	assertEqual(t, got.Method, "GET")
+}
+
 func TestEventMarshalJSON(t *testing.T) {
 	event := NewEvent()
 	event.Spans = []*Span{{
@@ -377,3 +400,13 @@ func TestStructSnapshots(t *testing.T) {
 		})
 	}
 }
+
+func TestEventIDString(t *testing.T) {
+	id := EventID("d3c4f0d4b8a24a6b9b3c6f3e5b1e0a2f")
+	assertEqual(t, id.String(), "d3c4f0d4b8a24a6b9b3c6f3e5b1e0a2f")
+	assertEqual(t, fmt.Sprintf("reference: %s", &id), "reference: d3c4f0d4b8a24a6b9b3c6f3e5b1e0a2f")
+
+	var missing *EventID
+	assertEqual(t, missing.String(), "")
+	assertEqual(t, fmt.Sprintf("reference: %s", missing), "reference: ")
+}
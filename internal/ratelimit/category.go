@@ -20,6 +20,8 @@ const (
 	CategoryAll         Category = ""
 	CategoryError       Category = "error"
 	CategoryTransaction Category = "transaction"
+	CategoryLog         Category = "log_item"
+	CategoryMonitor     Category = "monitor"
 )
 
 // knownCategories is the set of currently known categories. Other categories
@@ -28,6 +30,8 @@ var knownCategories = map[Category]struct{}{
 	CategoryAll:         {},
 	CategoryError:       {},
 	CategoryTransaction: {},
+	CategoryLog:         {},
+	CategoryMonitor:     {},
 }
 
 // String returns the category formatted for debugging.
@@ -38,7 +42,10 @@ func (c Category) String() string {
 	default:
 		caser := cases.Title(language.English)
 		rv := "Category"
-		for _, w := range strings.Fields(string(c)) {
+		words := strings.FieldsFunc(string(c), func(r rune) bool {
+			return r == ' ' || r == '_'
+		})
+		for _, w := range words {
 			rv += caser.String(w)
 		}
 		return rv
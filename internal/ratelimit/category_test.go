@@ -10,6 +10,8 @@ func TestCategoryString(t *testing.T) {
 		{CategoryAll, "CategoryAll"},
 		{CategoryError, "CategoryError"},
 		{CategoryTransaction, "CategoryTransaction"},
+		{CategoryLog, "CategoryLogItem"},
+		{CategoryMonitor, "CategoryMonitor"},
 		{Category("unknown"), "CategoryUnknown"},
 		{Category("two words"), "CategoryTwoWords"},
 	}
@@ -0,0 +1,317 @@
+package sentry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// logType is the type of the envelope item holding a batch of logs.
+const logType = "log"
+
+// logContentType is the content type of the envelope item holding a batch of
+// logs.
+const logContentType = "application/vnd.sentry.items.log+json"
+
+// Logs are sent in batches of at most logBatchSize logs, at least every
+// logFlushInterval.
+const (
+	logBatchSize     = 100
+	logFlushInterval = 5 * time.Second
+)
+
+// LogLevel is the severity of a Log.
+type LogLevel string
+
+// Log levels, from the least to the most severe.
+const (
+	LogLevelTrace LogLevel = "trace"
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+	LogLevelFatal LogLevel = "fatal"
+)
+
+// severityNumber returns the OpenTelemetry severity number of l, or 0 if l is
+// unknown.
+func (l LogLevel) severityNumber() int {
+	switch l {
+	case LogLevelTrace:
+		return 1
+	case LogLevelDebug:
+		return 5
+	case LogLevelInfo:
+		return 9
+	case LogLevelWarn:
+		return 13
+	case LogLevelError:
+		return 17
+	case LogLevelFatal:
+		return 21
+	}
+	return 0
+}
+
+// Log is a structured log record, sent to Sentry as part of a batch of logs.
+// See NewLogger.
+type Log struct {
+	Timestamp  time.Time               `json:"timestamp"`
+	TraceID    TraceID                 `json:"trace_id"`
+	Level      LogLevel                `json:"level"`
+	Severity   int                     `json:"severity_number,omitempty"`
+	Body       string                  `json:"body"`
+	Attributes map[string]LogAttribute `json:"attributes,omitempty"`
+}
+
+// logBatch is the payload of a log envelope item.
+type logBatch struct {
+	Items []Log `json:"items"`
+}
+
+// MarshalJSON converts the Log struct to JSON, with the timestamp in seconds
+// since the Unix epoch, as expected by Sentry.
+func (l *Log) MarshalJSON() ([]byte, error) {
+	// log aliases Log to allow calling json.Marshal without an infinite loop.
+	type log Log
+	return json.Marshal(struct {
+		*log
+		Timestamp float64 `json:"timestamp"`
+	}{
+		log:       (*log)(l),
+		Timestamp: float64(l.Timestamp.UnixNano()) / 1e9,
+	})
+}
+
+// LogAttribute is the value of an attribute of a Log, along with its type:
+// "string", "boolean", "integer" or "double".
+type LogAttribute struct {
+	Value interface{} `json:"value"`
+	Type  string      `json:"type"`
+}
+
+// logAttribute returns the attribute holding v. Values of types other than
+// strings, booleans, integers and floats are formatted as strings.
+func logAttribute(v interface{}) LogAttribute {
+	switch v := v.(type) {
+	case string:
+		return LogAttribute{Value: v, Type: "string"}
+	case bool:
+		return LogAttribute{Value: v, Type: "boolean"}
+	case int:
+		return LogAttribute{Value: int64(v), Type: "integer"}
+	case int8:
+		return LogAttribute{Value: int64(v), Type: "integer"}
+	case int16:
+		return LogAttribute{Value: int64(v), Type: "integer"}
+	case int32:
+		return LogAttribute{Value: int64(v), Type: "integer"}
+	case int64:
+		return LogAttribute{Value: v, Type: "integer"}
+	case uint8:
+		return LogAttribute{Value: int64(v), Type: "integer"}
+	case uint16:
+		return LogAttribute{Value: int64(v), Type: "integer"}
+	case uint32:
+		return LogAttribute{Value: int64(v), Type: "integer"}
+	case float32:
+		return LogAttribute{Value: float64(v), Type: "double"}
+	case float64:
+		return LogAttribute{Value: v, Type: "double"}
+	case error:
+		return LogAttribute{Value: v.Error(), Type: "string"}
+	case fmt.Stringer:
+		return LogAttribute{Value: v.String(), Type: "string"}
+	}
+	return LogAttribute{Value: fmt.Sprint(v), Type: "string"}
+}
+
+// StructuredLogger sends structured logs to Sentry. Logs are not events: they
+// do not create issues, are not subject to sampling, BeforeSend or event
+// processors, and are sent in batches in the background.
+//
+// It is safe for concurrent use.
+type StructuredLogger struct {
+	ctx     context.Context
+	hub     *Hub
+	traceID TraceID
+}
+
+// NewLogger returns a logger sending structured logs with the client of the hub
+// stored in ctx, or of the current hub. Logs are only sent when the
+// ClientOptions.EnableLogs option of the client is set.
+//
+// When a span is stored in ctx, the logs are attached to its trace, such that
+// they show up along with the trace in Sentry. Otherwise, all logs of the
+// logger share a random trace ID.
+//
+//	logger := sentry.NewLogger(ctx)
+//	logger.Info("order placed", "order_id", order.ID, "total", order.Total)
+//
+// Note that sentry.Logger is unrelated, it is the logger used to debug the SDK
+// itself.
+func NewLogger(ctx context.Context) *StructuredLogger {
+	var traceID TraceID
+	if span := SpanFromContext(ctx); span != nil {
+		traceID = span.TraceID
+	} else {
+		_, _ = rand.Read(traceID[:])
+	}
+	return &StructuredLogger{
+		ctx:     ctx,
+		hub:     hubFromContext(ctx),
+		traceID: traceID,
+	}
+}
+
+// Trace sends a log with level LogLevelTrace. See Info.
+func (l *StructuredLogger) Trace(msg string, keyvals ...interface{}) {
+	l.log(LogLevelTrace, msg, keyvals)
+}
+
+// Debug sends a log with level LogLevelDebug. See Info.
+func (l *StructuredLogger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LogLevelDebug, msg, keyvals)
+}
+
+// Info sends a log with level LogLevelInfo and the message msg. keyvals are
+// alternating attribute names and values, set as attributes of the log along
+// with the environment, release and server name of the client.
+func (l *StructuredLogger) Info(msg string, keyvals ...interface{}) {
+	l.log(LogLevelInfo, msg, keyvals)
+}
+
+// Warn sends a log with level LogLevelWarn. See Info.
+func (l *StructuredLogger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LogLevelWarn, msg, keyvals)
+}
+
+// Error sends a log with level LogLevelError. See Info.
+//
+// Unlike CaptureException, it does not report an error event.
+func (l *StructuredLogger) Error(msg string, keyvals ...interface{}) {
+	l.log(LogLevelError, msg, keyvals)
+}
+
+func (l *StructuredLogger) log(level LogLevel, msg string, keyvals []interface{}) {
+	client := l.hub.Client()
+	if client == nil || client.logs == nil || client.noop {
+		return
+	}
+
+	attrs := make(map[string]LogAttribute, len(keyvals)/2+6)
+	options := client.options
+	if options.Environment != "" {
+		attrs["sentry.environment"] = logAttribute(options.Environment)
+	}
+	if options.Release != "" {
+		attrs["sentry.release"] = logAttribute(options.Release)
+	}
+	serverName := options.ServerName
+	if serverName == "" {
+		serverName = hostname
+	}
+	if serverName != "" {
+		attrs["server.address"] = logAttribute(serverName)
+	}
+	attrs["sentry.sdk.name"] = logAttribute(SDKIdentifier)
+	attrs["sentry.sdk.version"] = logAttribute(SDKVersion)
+
+	traceID := l.traceID
+	if span := SpanFromContext(l.ctx); span != nil {
+		traceID = span.TraceID
+		attrs["sentry.trace.parent_span_id"] = logAttribute(span.SpanID.String())
+	}
+
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			// Same as log/slog for a key without value.
+			attrs["!BADKEY"] = logAttribute(keyvals[i])
+			break
+		}
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		attrs[key] = logAttribute(keyvals[i+1])
+	}
+
+	client.logs.add(Log{
+		Timestamp:  time.Now(),
+		TraceID:    traceID,
+		Level:      level,
+		Severity:   level.severityNumber(),
+		Body:       msg,
+		Attributes: attrs,
+	})
+}
+
+// logBuffer batches the logs of a client.
+type logBuffer struct {
+	client *Client
+
+	mu    sync.Mutex
+	logs  []Log
+	timer *time.Timer
+}
+
+// add buffers log, sending the buffered logs if there are logBatchSize of
+// them. Otherwise, they are sent after logFlushInterval.
+func (b *logBuffer) add(log Log) {
+	if atomic.LoadInt32(&b.client.closed) == 1 {
+		return
+	}
+
+	b.mu.Lock()
+	b.logs = append(b.logs, log)
+	if len(b.logs) < logBatchSize {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(logFlushInterval, b.flush)
+		}
+		b.mu.Unlock()
+		return
+	}
+	logs := b.take()
+	b.mu.Unlock()
+
+	b.send(logs)
+}
+
+// flush sends the buffered logs, if any.
+func (b *logBuffer) flush() {
+	b.mu.Lock()
+	logs := b.take()
+	b.mu.Unlock()
+
+	if len(logs) > 0 {
+		b.send(logs)
+	}
+}
+
+// take returns the buffered logs and empties the buffer. It must be called
+// with b.mu held.
+func (b *logBuffer) take() []Log {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	logs := b.logs
+	b.logs = nil
+	return logs
+}
+
+// send hands logs over to the transport of the client, as a single envelope
+// item of type logType.
+func (b *logBuffer) send(logs []Log) {
+	sendItem(b.client.Transport, &envelopeItem{
+		eventID:     EventID(uuid()),
+		itemType:    logType,
+		count:       len(logs),
+		payload:     logBatch{Items: logs},
+		description: fmt.Sprintf("%d logs", len(logs)),
+	})
+}
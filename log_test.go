@@ -0,0 +1,176 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newLogTestContext(t *testing.T, options ClientOptions) (context.Context, *TransportMock) {
+	t.Helper()
+	transport := &TransportMock{}
+	options.Transport = transport
+	client, err := NewClient(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SetHubOnContext(context.Background(), NewHub(client, NewScope())), transport
+}
+
+// sentLogs returns the batches of logs sent to transport.
+func sentLogs(t *testing.T, transport *TransportMock) [][]Log {
+	t.Helper()
+	var batches [][]Log
+	for _, item := range transport.Items() {
+		batch, ok := item.payload.(logBatch)
+		if item.itemType != logType || !ok || item.count != len(batch.Items) {
+			t.Fatalf("got item of type %q with payload %T, want a batch of logs", item.itemType, item.payload)
+		}
+		batches = append(batches, batch.Items)
+	}
+	return batches
+}
+
+func TestStructuredLogger(t *testing.T) {
+	ctx, transport := newLogTestContext(t, ClientOptions{
+		EnableLogs:    true,
+		EnableTracing: true,
+		Environment:   "production",
+		Release:       "v1.2.3",
+		ServerName:    "host",
+	})
+	span := StartTransaction(ctx, "Test Transaction")
+	logger := NewLogger(span.Context())
+
+	logger.Info("order placed", "order_id", 42, "total", 9.5, "paid", true, "err", errors.New("e"), "dangling")
+	logger.Error("payment failed")
+
+	if batches := sentLogs(t, transport); len(batches) != 0 {
+		t.Fatalf("got %d batches before Flush, want 0", len(batches))
+	}
+	hubFromContext(ctx).Flush(time.Second)
+
+	batches := sentLogs(t, transport)
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("got %d batches, want a single batch of 2 logs", len(batches))
+	}
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("got %d events, want logs to be sent as envelope items only", len(events))
+	}
+	logs := batches[0]
+
+	info := logs[0]
+	if info.Level != LogLevelInfo || info.Severity != 9 || info.Body != "order placed" {
+		t.Errorf("got log %q with level %q (%d), want %q with level %q (9)", info.Body, info.Level, info.Severity, "order placed", LogLevelInfo)
+	}
+	if info.TraceID != span.TraceID {
+		t.Errorf("got trace ID %s, want the trace ID of the span %s", info.TraceID, span.TraceID)
+	}
+	want := map[string]LogAttribute{
+		"sentry.environment":          {Value: "production", Type: "string"},
+		"sentry.release":              {Value: "v1.2.3", Type: "string"},
+		"sentry.sdk.name":             {Value: SDKIdentifier, Type: "string"},
+		"sentry.sdk.version":          {Value: SDKVersion, Type: "string"},
+		"server.address":              {Value: "host", Type: "string"},
+		"sentry.trace.parent_span_id": {Value: span.SpanID.String(), Type: "string"},
+		"order_id":                    {Value: int64(42), Type: "integer"},
+		"total":                       {Value: 9.5, Type: "double"},
+		"paid":                        {Value: true, Type: "boolean"},
+		"err":                         {Value: "e", Type: "string"},
+		"!BADKEY":                     {Value: "dangling", Type: "string"},
+	}
+	if diff := cmp.Diff(want, info.Attributes); diff != "" {
+		t.Errorf("Attributes mismatch (-want +got):\n%s", diff)
+	}
+	if got := logs[1]; got.Level != LogLevelError || got.Severity != 17 {
+		t.Errorf("got level %q (%d), want %q (17)", got.Level, got.Severity, LogLevelError)
+	}
+}
+
+func TestStructuredLoggerWithoutSpan(t *testing.T) {
+	ctx, transport := newLogTestContext(t, ClientOptions{EnableLogs: true})
+	logger := NewLogger(ctx)
+
+	logger.Debug("first")
+	logger.Warn("second")
+	hubFromContext(ctx).Flush(time.Second)
+
+	batches := sentLogs(t, transport)
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("got %d batches, want a single batch of 2 logs", len(batches))
+	}
+	logs := batches[0]
+	if logs[0].TraceID == (TraceID{}) || logs[0].TraceID != logs[1].TraceID {
+		t.Errorf("got trace IDs %s and %s, want the same non-zero trace ID", logs[0].TraceID, logs[1].TraceID)
+	}
+	if _, ok := logs[0].Attributes["sentry.trace.parent_span_id"]; ok {
+		t.Error("got a parent span ID without span")
+	}
+}
+
+func TestStructuredLoggerDisabled(t *testing.T) {
+	ctx, transport := newLogTestContext(t, ClientOptions{})
+	NewLogger(ctx).Info("message")
+	hubFromContext(ctx).Flush(time.Second)
+
+	if batches := sentLogs(t, transport); len(batches) != 0 {
+		t.Errorf("got %d batches, want 0 without EnableLogs", len(batches))
+	}
+}
+
+func TestStructuredLoggerBatchSize(t *testing.T) {
+	ctx, transport := newLogTestContext(t, ClientOptions{EnableLogs: true})
+	logger := NewLogger(ctx)
+
+	for i := 0; i < logBatchSize+1; i++ {
+		logger.Info("message")
+	}
+	batches := sentLogs(t, transport)
+	if len(batches) != 1 || len(batches[0]) != logBatchSize {
+		t.Fatalf("got %d batches, want a single batch of %d logs without Flush", len(batches), logBatchSize)
+	}
+
+	hubFromContext(ctx).Flush(time.Second)
+	if batches := sentLogs(t, transport); len(batches) != 2 || len(batches[1]) != 1 {
+		t.Errorf("got %d batches, want a second batch with the remaining log", len(batches))
+	}
+}
+
+func TestStructuredLoggerClosedClient(t *testing.T) {
+	ctx, transport := newLogTestContext(t, ClientOptions{EnableLogs: true})
+	logger := NewLogger(ctx)
+
+	logger.Info("before")
+	hubFromContext(ctx).Client().Close(context.Background())
+	logger.Info("after")
+
+	batches := sentLogs(t, transport)
+	if len(batches) != 1 || len(batches[0]) != 1 || batches[0][0].Body != "before" {
+		t.Errorf("got %d batches, want the log sent before Close only", len(batches))
+	}
+}
+
+func TestLogBatchMarshalJSON(t *testing.T) {
+	batch := logBatch{
+		Items: []Log{{
+			Timestamp:  time.Unix(1, 500000000),
+			Level:      LogLevelInfo,
+			Severity:   9,
+			Body:       "message",
+			Attributes: map[string]LogAttribute{"key": {Value: "value", Type: "string"}},
+		}},
+	}
+	b, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	want := `{"items":[{"trace_id":"00000000000000000000000000000000","level":"info","severity_number":9,"body":"message","attributes":{"key":{"value":"value","type":"string"}},"timestamp":1.5}]}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package sentrylogrus
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -26,6 +27,9 @@ const (
 	// FieldFingerprint holds a string slice ([]string), used to dictate the
 	// grouping of this event.
 	FieldFingerprint = "fingerprint"
+	// FieldLogger holds the name of the logger or subsystem reporting the
+	// event as a string.
+	FieldLogger = "logger"
 
 	// These fields are simply omitted, as they are duplicated by the Sentry SDK.
 	FieldGoVersion = "go_version"
@@ -37,10 +41,13 @@ const (
 // It is not safe to configure the hook while logging is happening. Please
 // perform all configuration before using it.
 type Hook struct {
-	hub      *sentry.Hub
-	fallback FallbackFunc
-	keys     map[string]string
-	levels   []logrus.Level
+	hub              *sentry.Hub
+	fallback         FallbackFunc
+	keys             map[string]string
+	levels           []logrus.Level
+	breadcrumbLevels []logrus.Level
+	tagFields        map[string]struct{}
+	fatalTimeout     time.Duration
 }
 
 var _ logrus.Hook = &Hook{}
@@ -58,10 +65,17 @@ func New(levels []logrus.Level, opts sentry.ClientOptions) (*Hook, error) {
 // NewFromClient initializes a new Logrus hook which sends logs to the provided
 // sentry client.
 func NewFromClient(levels []logrus.Level, client *sentry.Client) *Hook {
+	return NewFromHub(levels, sentry.NewHub(client, sentry.NewScope()))
+}
+
+// NewFromHub initializes a new Logrus hook which sends logs with the provided
+// hub, using its client and scope.
+func NewFromHub(levels []logrus.Level, hub *sentry.Hub) *Hook {
 	h := &Hook{
-		levels: levels,
-		hub:    sentry.NewHub(client, sentry.NewScope()),
-		keys:   make(map[string]string),
+		levels:    levels,
+		hub:       hub,
+		keys:      make(map[string]string),
+		tagFields: make(map[string]struct{}),
 	}
 	return h
 }
@@ -101,6 +115,31 @@ func (h *Hook) SetKey(oldKey, newKey string) {
 	h.keys[oldKey] = newKey
 }
 
+// SetBreadcrumbLevels sets the logging levels that are recorded as
+// breadcrumbs instead of being sent as events. The breadcrumbs are added to
+// the hook's scope, and are sent along with the next event. Levels also
+// passed to New are sent as events.
+func (h *Hook) SetBreadcrumbLevels(levels []logrus.Level) {
+	h.breadcrumbLevels = levels
+}
+
+// SetTagFields sets the log fields that are sent as event tags instead of
+// extra data. Field values are converted to strings with fmt.Sprint.
+func (h *Hook) SetTagFields(fields ...string) {
+	h.tagFields = make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		h.tagFields[f] = struct{}{}
+	}
+}
+
+// SetFlushTimeoutOnFatal makes the hook wait for events logged at the Fatal
+// and Panic levels to be sent, blocking for at most the given timeout, before
+// logrus exits the program or panics. A zero timeout, the default, disables
+// waiting.
+func (h *Hook) SetFlushTimeoutOnFatal(timeout time.Duration) {
+	h.fatalTimeout = timeout
+}
+
 func (h *Hook) key(key string) string {
 	if val := h.keys[key]; val != "" {
 		return val
@@ -109,15 +148,39 @@ func (h *Hook) key(key string) string {
 }
 
 // Levels returns the list of logging levels that will be sent to
-// Sentry.
+// Sentry, as events or breadcrumbs.
 func (h *Hook) Levels() []logrus.Level {
-	return h.levels
+	levels := make([]logrus.Level, 0, len(h.levels)+len(h.breadcrumbLevels))
+	levels = append(levels, h.levels...)
+	for _, l := range h.breadcrumbLevels {
+		if !containsLevel(h.levels, l) {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+func containsLevel(levels []logrus.Level, level logrus.Level) bool {
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
 }
 
 // Fire sends entry to Sentry.
 func (h *Hook) Fire(entry *logrus.Entry) error {
+	if !containsLevel(h.levels, entry.Level) {
+		h.hub.AddBreadcrumb(h.entryToBreadcrumb(entry), nil)
+		return nil
+	}
 	event := h.entryToEvent(entry)
-	if id := h.hub.CaptureEvent(event); id == nil {
+	id := h.hub.CaptureEvent(event)
+	if h.fatalTimeout > 0 && entry.Level <= logrus.FatalLevel {
+		h.Flush(h.fatalTimeout)
+	}
+	if id == nil && canSend(h.hub) {
 		if h.fallback != nil {
 			return h.fallback(entry)
 		}
@@ -126,6 +189,38 @@ func (h *Hook) Fire(entry *logrus.Entry) error {
 	return nil
 }
 
+// canSend reports whether events captured with hub could have been sent. A
+// client without a DSN nor custom transport drops all events, which is not a
+// failure.
+func canSend(hub *sentry.Hub) bool {
+	client := hub.Client()
+	if client == nil {
+		return true
+	}
+	options := client.Options()
+	return options.Dsn != "" || options.Transport != nil
+}
+
+func (h *Hook) entryToBreadcrumb(l *logrus.Entry) *sentry.Breadcrumb {
+	data := make(map[string]interface{}, len(l.Data))
+	for k, v := range l.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		data[k] = v
+	}
+	delete(data, h.key(FieldRequest))
+	delete(data, FieldGoVersion)
+	delete(data, FieldMaxProcs)
+	return &sentry.Breadcrumb{
+		Category:  "log",
+		Level:     levelMap[l.Level],
+		Message:   l.Message,
+		Data:      data,
+		Timestamp: l.Time,
+	}
+}
+
 var levelMap = map[logrus.Level]sentry.Level{
 	logrus.TraceLevel: sentry.LevelDebug,
 	logrus.DebugLevel: sentry.LevelDebug,
@@ -147,6 +242,17 @@ func (h *Hook) entryToEvent(l *logrus.Entry) *sentry.Event {
 		Message:   l.Message,
 		Timestamp: l.Time,
 	}
+	for k := range h.tagFields {
+		v, ok := s.Extra[k]
+		if !ok {
+			continue
+		}
+		delete(s.Extra, k)
+		if s.Tags == nil {
+			s.Tags = make(map[string]string)
+		}
+		s.Tags[k] = fmt.Sprint(v)
+	}
 	key := h.key(FieldRequest)
 	if req, ok := s.Extra[key].(*http.Request); ok {
 		delete(s.Extra, key)
@@ -176,6 +282,11 @@ func (h *Hook) entryToEvent(l *logrus.Entry) *sentry.Event {
 		delete(s.Extra, key)
 		s.Fingerprint = fp
 	}
+	key = h.key(FieldLogger)
+	if logger, ok := s.Extra[key].(string); ok {
+		delete(s.Extra, key)
+		s.Logger = logger
+	}
 	delete(s.Extra, FieldGoVersion)
 	delete(s.Extra, FieldMaxProcs)
 	return s
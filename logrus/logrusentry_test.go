@@ -1,8 +1,10 @@
 package sentrylogrus
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -15,6 +17,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/getsentry/sentry-go/sentrytest"
 )
 
 func TestNew(t *testing.T) {
@@ -33,8 +36,9 @@ func TestNew(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if id := h.hub.CaptureEvent(&sentry.Event{}); id == nil {
-			t.Error("CaptureEvent failed")
+		// Without a DSN, events are dropped without reporting an error.
+		if err := h.Fire(&logrus.Entry{Level: logrus.ErrorLevel}); err != nil {
+			t.Errorf("Fire failed: %v", err)
 		}
 		if !h.Flush(5 * time.Second) {
 			t.Error("flush failed")
@@ -233,6 +237,19 @@ func Test_entryToEvent(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "logger",
+			entry: &logrus.Entry{
+				Data: map[string]interface{}{
+					FieldLogger: "payments",
+				},
+			},
+			want: &sentry.Event{
+				Level:  "fatal",
+				Extra:  map[string]interface{}{},
+				Logger: "payments",
+			},
+		},
 	}
 
 	h, err := New(nil, sentry.ClientOptions{
@@ -336,3 +353,107 @@ func Test_exceptions(t *testing.T) {
 		})
 	}
 }
+
+// flushCountingTransport is a sentrytest.Transport that counts flushes.
+type flushCountingTransport struct {
+	sentrytest.Transport
+	flushes int
+}
+
+func (t *flushCountingTransport) Flush(time.Duration) bool {
+	t.flushes++
+	return true
+}
+
+func (t *flushCountingTransport) FlushWithContext(context.Context) bool {
+	t.flushes++
+	return true
+}
+
+func newRecordingHook(t *testing.T, levels []logrus.Level) (*Hook, *flushCountingTransport) {
+	t.Helper()
+	transport := &flushCountingTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewFromClient(levels, client), transport
+}
+
+func TestBreadcrumbLevels(t *testing.T) {
+	t.Parallel()
+	hook, transport := newRecordingHook(t, []logrus.Level{logrus.ErrorLevel})
+	hook.SetBreadcrumbLevels([]logrus.Level{logrus.InfoLevel, logrus.ErrorLevel})
+
+	wantLevels := []logrus.Level{logrus.ErrorLevel, logrus.InfoLevel}
+	if diff := cmp.Diff(wantLevels, hook.Levels()); diff != "" {
+		t.Errorf("Levels() mismatch (-want +got):\n%s", diff)
+	}
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	logger.WithField("user_id", 42).Info("logged in")
+	logger.Error("failed")
+
+	if len(transport.Events()) != 1 {
+		t.Fatalf("got %d events, want 1", len(transport.Events()))
+	}
+	got := transport.Events()[0].Breadcrumbs
+	want := []*sentry.Breadcrumb{{
+		Category: "log",
+		Level:    sentry.LevelInfo,
+		Message:  "logged in",
+		Data:     map[string]interface{}{"user_id": 42},
+	}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(sentry.Breadcrumb{}, "Timestamp")); diff != "" {
+		t.Errorf("Breadcrumbs mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetTagFields(t *testing.T) {
+	t.Parallel()
+	hook, _ := newRecordingHook(t, nil)
+	hook.SetTagFields("component", "attempt")
+
+	event := hook.entryToEvent(&logrus.Entry{
+		Data: logrus.Fields{
+			"component": "billing",
+			"attempt":   3,
+			"other":     "extra",
+		},
+	})
+	if diff := cmp.Diff(map[string]string{"component": "billing", "attempt": "3"}, event.Tags); diff != "" {
+		t.Errorf("Tags mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"other": "extra"}, event.Extra); diff != "" {
+		t.Errorf("Extra mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFlushTimeoutOnFatal(t *testing.T) {
+	t.Parallel()
+	levels := []logrus.Level{logrus.FatalLevel, logrus.ErrorLevel}
+
+	hook, transport := newRecordingHook(t, levels)
+	if err := hook.Fire(&logrus.Entry{Level: logrus.FatalLevel}); err != nil {
+		t.Fatal(err)
+	}
+	if transport.flushes != 0 {
+		t.Errorf("got %d flushes without a timeout, want 0", transport.flushes)
+	}
+
+	hook.SetFlushTimeoutOnFatal(time.Second)
+	if err := hook.Fire(&logrus.Entry{Level: logrus.ErrorLevel}); err != nil {
+		t.Fatal(err)
+	}
+	if transport.flushes != 0 {
+		t.Errorf("got %d flushes for an error, want 0", transport.flushes)
+	}
+	if err := hook.Fire(&logrus.Entry{Level: logrus.FatalLevel}); err != nil {
+		t.Fatal(err)
+	}
+	if transport.flushes != 1 {
+		t.Errorf("got %d flushes, want 1", transport.flushes)
+	}
+}
@@ -100,6 +100,13 @@ func TestTransactionEventMarshalJSON(t *testing.T) {
 		{
 			Type: transactionType,
 		},
+		{
+			Type: transactionType,
+			Measurements: map[string]Measurement{
+				"cache.hits":   {Value: 42, Unit: UnitNone},
+				"payload.size": {Value: 1.5, Unit: UnitKilobyte},
+			},
+		},
 	}
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -25,6 +25,7 @@ type TransportMock struct {
 	mu        sync.Mutex
 	events    []*Event
 	lastEvent *Event
+	items     []*envelopeItem
 }
 
 func (t *TransportMock) Configure(options ClientOptions) {}
@@ -34,6 +35,11 @@ func (t *TransportMock) SendEvent(event *Event) {
 	t.events = append(t.events, event)
 	t.lastEvent = event
 }
+func (t *TransportMock) sendItem(item *envelopeItem) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = append(t.items, item)
+}
 func (t *TransportMock) Flush(timeout time.Duration) bool {
 	return true
 }
@@ -42,3 +48,8 @@ func (t *TransportMock) Events() []*Event {
 	defer t.mu.Unlock()
 	return t.events
 }
+func (t *TransportMock) Items() []*envelopeItem {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.items
+}
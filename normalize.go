@@ -0,0 +1,360 @@
+package sentry
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxDepthValue replaces the values of extra data and contexts nested deeper
+// than ClientOptions.MaxValueDepth.
+const maxDepthValue = "[MaxDepth]"
+
+// circularValue replaces the values of extra data and contexts that reference
+// one of the values containing them.
+const circularValue = "[Circular]"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// normalizeValues bounds the depth of the values of the extra data and
+// contexts of event to max and breaks their reference cycles, which would
+// otherwise fail or blow up serialization. Maps may be shared with the scope,
+// so they are copied before being modified, and only assigned to event if
+// normalized, as the event may still be referenced by the caller of
+// BeforeSend.
+func normalizeValues(event *Event, max int) {
+	if extra, ok := normalizeInterfaceMap(event.Extra, max); ok {
+		event.Extra = extra
+	}
+
+	var contexts map[string]Context
+	for key, context := range event.Contexts {
+		normalized, ok := normalizeInterfaceMap(context, max)
+		if !ok {
+			continue
+		}
+		if contexts == nil {
+			contexts = make(map[string]Context, len(event.Contexts))
+			for k, v := range event.Contexts {
+				contexts[k] = v
+			}
+		}
+		contexts[key] = normalized
+	}
+	if contexts != nil {
+		event.Contexts = contexts
+	}
+}
+
+// normalizeInterfaceMap returns a copy of m with normalized values and true,
+// or m itself and false if all values were within bounds and acyclic. Values
+// that need no normalization are kept as is, such that they are serialized
+// exactly like before.
+func normalizeInterfaceMap(m map[string]interface{}, max int) (map[string]interface{}, bool) {
+	if len(m) == 0 {
+		return m, false
+	}
+	n := normalizer{max: max, seen: make(map[visit]struct{})}
+	// The map itself is the outermost value, which its values may reference.
+	n.enter(reflect.ValueOf(m))
+
+	var c map[string]interface{}
+	for k, v := range m {
+		rv := reflect.ValueOf(v)
+		if !n.check(rv, 1) {
+			continue
+		}
+		if c == nil {
+			c = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				c[k] = v
+			}
+		}
+		c[k] = n.normalize(rv, 1)
+	}
+	if c == nil {
+		return m, false
+	}
+	return c, true
+}
+
+// A visit identifies a pointer, map or slice being walked by a normalizer.
+// The type tells apart a struct and its first field, and the length slices
+// of different lengths sharing their first element.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// A normalizer walks values like encoding/json does, detecting values nested
+// deeper than max and references to the pointers, maps and slices in seen,
+// the ones containing the value being walked.
+type normalizer struct {
+	max  int
+	seen map[visit]struct{}
+}
+
+// enter records that v, a pointer, map or slice, is being walked. It returns
+// false if v was already being walked, that is if there is a cycle.
+func (n *normalizer) enter(v reflect.Value) bool {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if _, ok := n.seen[key]; ok {
+		return false
+	}
+	n.seen[key] = struct{}{}
+	return true
+}
+
+func (n *normalizer) leave(v reflect.Value) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	delete(n.seen, key)
+}
+
+// isLeaf reports whether v is serialized without walking nested values:
+// scalars, nil values and values marshaling themselves.
+func isLeaf(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map:
+		return v.IsNil()
+	case reflect.Slice:
+		// Byte slices are serialized as base64 strings.
+		return v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8
+	case reflect.Array, reflect.Struct:
+		return false
+	default:
+		return true
+	}
+}
+
+// check reports whether v, at the given depth, has to be normalized.
+func (n *normalizer) check(v reflect.Value, depth int) bool {
+	if isLeaf(v) {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return n.check(v.Elem(), depth)
+	case reflect.Ptr:
+		if !n.enter(v) {
+			return true
+		}
+		defer n.leave(v)
+		return n.check(v.Elem(), depth)
+	}
+
+	if depth > n.max {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if !n.enter(v) {
+			return true
+		}
+		defer n.leave(v)
+		iter := v.MapRange()
+		for iter.Next() {
+			if n.check(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if !n.enter(v) {
+				return true
+			}
+			defer n.leave(v)
+		}
+		for i := 0; i < v.Len(); i++ {
+			if n.check(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for _, f := range jsonFields(v) {
+			if n.check(f.value, depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalize returns v, at the given depth, as nil, a scalar, a value
+// marshaling itself, map[string]interface{} or []interface{}, with values
+// nested too deep or referencing a container replaced with maxDepthValue or
+// circularValue.
+func (n *normalizer) normalize(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if isLeaf(v) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return n.normalize(v.Elem(), depth)
+	case reflect.Ptr:
+		if !n.enter(v) {
+			return circularValue
+		}
+		defer n.leave(v)
+		return n.normalize(v.Elem(), depth)
+	}
+
+	if depth > n.max {
+		return maxDepthValue
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if !n.enter(v) {
+			return circularValue
+		}
+		defer n.leave(v)
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[mapKeyString(iter.Key())] = n.normalize(iter.Value(), depth+1)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if !n.enter(v) {
+				return circularValue
+			}
+			defer n.leave(v)
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = n.normalize(v.Index(i), depth+1)
+		}
+		return s
+	default: // reflect.Struct
+		fields := jsonFields(v)
+		m := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			m[f.name] = n.normalize(f.value, depth+1)
+		}
+		return m
+	}
+}
+
+// mapKeyString returns the key of a map as encoding/json does.
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if b, err := tm.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+type jsonField struct {
+	name  string
+	value reflect.Value
+}
+
+// jsonFields returns the fields of the struct v serialized by encoding/json,
+// following their json tags. The fields of embedded structs are promoted,
+// unless a field of the same name is already set.
+func jsonFields(v reflect.Value) []jsonField {
+	t := v.Type()
+	var fields []jsonField
+	names := make(map[string]struct{}, t.NumField())
+	var embedded []reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// Values of unexported fields, including embedded ones, cannot be
+		// read through reflection.
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						continue
+					}
+					fv = fv.Elem()
+				}
+				embedded = append(embedded, fv)
+				continue
+			}
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		names[name] = struct{}{}
+		fields = append(fields, jsonField{name: name, value: fv})
+	}
+	for _, ev := range embedded {
+		for _, f := range jsonFields(ev) {
+			if _, ok := names[f.name]; ok {
+				continue
+			}
+			names[f.name] = struct{}{}
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// isEmptyValue reports whether v is empty for the omitempty option of
+// encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
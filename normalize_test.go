@@ -0,0 +1,89 @@
+package sentry
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type normalizeNode struct {
+	Name     string         `json:"name"`
+	Next     *normalizeNode `json:"next,omitempty"`
+	internal int
+}
+
+func TestNormalizeValues(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{Transport: transport, MaxValueDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := &normalizeNode{Name: "a", internal: 1}
+	node.Next = &normalizeNode{Name: "b", Next: node}
+	loop := map[string]interface{}{"name": "loop"}
+	loop["self"] = loop
+	shared := &normalizeNode{Name: "shared"}
+	type pair struct {
+		Left, Right *normalizeNode
+		At          time.Time `json:"at"`
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	event := NewEvent()
+	event.Extra["node"] = node
+	event.Extra["loop"] = loop
+	event.Extra["deep"] = map[string]interface{}{"a": []interface{}{map[int]string{1: "one"}, "b"}}
+	event.Extra["shared"] = pair{Left: shared, Right: shared, At: at}
+	event.Extra["keys"] = map[int]interface{}{1: loop}
+	event.Extra["scalar"] = 42
+	event.Contexts["loop"] = Context{"value": loop}
+	client.CaptureEvent(event, nil, nil)
+
+	got := transport.lastEvent
+	want := map[string]interface{}{
+		"node": map[string]interface{}{
+			"name": "a",
+			"next": map[string]interface{}{"name": "b", "next": circularValue},
+		},
+		"loop": map[string]interface{}{"name": "loop", "self": circularValue},
+		"deep": map[string]interface{}{"a": []interface{}{maxDepthValue, "b"}},
+		"keys": map[string]interface{}{
+			"1": map[string]interface{}{"name": "loop", "self": maxDepthValue},
+		},
+		// Values that need no normalization are kept as is.
+		"shared": pair{Left: shared, Right: shared, At: at},
+		"scalar": 42,
+	}
+	if diff := cmp.Diff(want, got.Extra, cmp.AllowUnexported(normalizeNode{})); diff != "" {
+		t.Errorf("Extra mismatch (-want +got):\n%s", diff)
+	}
+	wantContext := Context{"value": map[string]interface{}{"name": "loop", "self": circularValue}}
+	if diff := cmp.Diff(wantContext, got.Contexts["loop"]); diff != "" {
+		t.Errorf("Contexts mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := json.Marshal(got); err != nil {
+		t.Errorf("json.Marshal() = %v, want the event to serialize", err)
+	}
+	// The original values are left untouched.
+	if node.Next.Next != node {
+		t.Error("node was modified")
+	}
+}
+
+func TestNormalizeValuesDisabled(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{Transport: transport, MaxValueDepth: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deep := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{}}}
+
+	event := NewEvent()
+	event.Extra["deep"] = deep
+	client.CaptureEvent(event, nil, nil)
+
+	assertEqual(t, transport.lastEvent.Extra["deep"], deep)
+}
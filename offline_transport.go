@@ -0,0 +1,343 @@
+package sentry
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go/internal/ratelimit"
+)
+
+const (
+	defaultOfflineMaxFiles = 100
+	defaultOfflineMaxAge   = 24 * time.Hour
+)
+
+// File name extensions of envelopes stored by OfflineTransport.
+const (
+	envelopeExt     = ".envelope"
+	envelopeGzipExt = ".envelope.gz"
+)
+
+// envelopeTransport is implemented by the transports that can be wrapped by
+// OfflineTransport.
+type envelopeTransport interface {
+	// setDeliveryHooks must be called before Configure.
+	setDeliveryHooks(hooks deliveryHooks)
+	// sendEnvelope sends an envelope serialized before by the transport. It
+	// returns false if the envelope was not accepted for delivery.
+	sendEnvelope(envelope []byte, compressed bool, category ratelimit.Category) bool
+}
+
+func (t *HTTPTransport) setDeliveryHooks(hooks deliveryHooks) {
+	t.hooks = hooks
+}
+
+func (t *HTTPSyncTransport) setDeliveryHooks(hooks deliveryHooks) {
+	t.hooks = hooks
+}
+
+// OfflineTransport wraps HTTPTransport or HTTPSyncTransport and stores the
+// envelopes that could not be delivered, because of a network error or a
+// server error response, as files in a directory. Stored envelopes are sent
+// again when the transport is configured, typically the next time the
+// program starts, and whenever Sentry is reachable again after a failure.
+//
+// Envelopes are not stored when Sentry rejects them explicitly, for instance
+// because of rate limits.
+type OfflineTransport struct {
+	dir   string
+	inner Transport
+
+	// Maximum number of stored envelopes. When the limit is reached, the
+	// oldest envelopes are removed. Defaults to 100.
+	MaxFiles int
+	// Maximum age of stored envelopes. Older envelopes are removed instead of
+	// being sent. Defaults to 24 hours.
+	MaxAge time.Duration
+
+	// mu serializes access to the directory.
+	mu sync.Mutex
+	// pending is 1 when there may be stored envelopes to replay.
+	pending int32
+	// replaying is 1 while stored envelopes are being replayed.
+	replaying int32
+	// failures counts failed deliveries, to stop replaying envelopes as
+	// soon as one of them fails.
+	failures uint32
+}
+
+// NewOfflineTransport returns a new pre-configured instance of
+// OfflineTransport that stores undelivered envelopes in dir and sends events
+// with inner.
+//
+// The directory is created if it does not exist. inner must be an
+// HTTPTransport or an HTTPSyncTransport, otherwise events are sent with inner
+// but never stored.
+func NewOfflineTransport(dir string, inner Transport) *OfflineTransport {
+	t := &OfflineTransport{
+		dir:      dir,
+		inner:    inner,
+		MaxFiles: defaultOfflineMaxFiles,
+		MaxAge:   defaultOfflineMaxAge,
+	}
+	if et, ok := inner.(envelopeTransport); ok {
+		et.setDeliveryHooks(deliveryHooks{
+			failed:    t.store,
+			delivered: t.delivered,
+		})
+	}
+	return t
+}
+
+// Configure configures the wrapped transport and starts sending any envelopes
+// stored by a previous run in the background.
+func (t *OfflineTransport) Configure(options ClientOptions) {
+	t.inner.Configure(options)
+
+	et, ok := t.inner.(envelopeTransport)
+	if !ok {
+		Logger.Printf("OfflineTransport: %T does not support storing envelopes", t.inner)
+		return
+	}
+	if err := os.MkdirAll(t.dir, 0o700); err != nil {
+		Logger.Printf("OfflineTransport: %v", err)
+		return
+	}
+	go t.replay(et)
+}
+
+func (t *OfflineTransport) setClientDiscardedEvents(d *discardedEvents) {
+	if source, ok := t.inner.(clientReportSource); ok {
+		source.setClientDiscardedEvents(d)
+	}
+}
+
+// SendEvent sends the event with the wrapped transport.
+func (t *OfflineTransport) SendEvent(event *Event) {
+	t.inner.SendEvent(event)
+}
+
+// sendItem implements itemTransport, sending item with the wrapped transport.
+func (t *OfflineTransport) sendItem(item *envelopeItem) {
+	sendItem(t.inner, item)
+}
+
+// Flush flushes the wrapped transport.
+func (t *OfflineTransport) Flush(timeout time.Duration) bool {
+	return t.inner.Flush(timeout)
+}
+
+// FlushWithContext flushes the wrapped transport until ctx is done.
+func (t *OfflineTransport) FlushWithContext(ctx context.Context) bool {
+	return flushTransport(ctx, t.inner)
+}
+
+// Close closes the wrapped transport, if it has a Close method. Stored
+// envelopes are kept for the next run.
+func (t *OfflineTransport) Close() {
+	if ct, ok := t.inner.(interface{ Close() }); ok {
+		ct.Close()
+	}
+}
+
+// store writes the body of an undelivered request to a new file. It reports
+// whether it did.
+func (t *OfflineTransport) store(request *http.Request) bool {
+	atomic.AddUint32(&t.failures, 1)
+
+	if request.GetBody == nil {
+		return false
+	}
+	body, err := request.GetBody()
+	if err != nil {
+		return false
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return false
+	}
+
+	ext := envelopeExt
+	if request.Header.Get("Content-Encoding") == "gzip" {
+		ext = envelopeGzipExt
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The file name starts with the time, such that sorting names sorts
+	// envelopes from oldest to newest. The file is renamed after it is
+	// written, such that replay never reads partial files.
+	name := filepath.Join(t.dir, fmt.Sprintf("%020d-%.8s%s", time.Now().UnixNano(), uuid(), ext))
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		Logger.Printf("OfflineTransport: %v", err)
+		return false
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		Logger.Printf("OfflineTransport: %v", err)
+		_ = os.Remove(tmp)
+		return false
+	}
+	Logger.Printf("OfflineTransport: stored undelivered envelope %s", filepath.Base(name))
+	atomic.StoreInt32(&t.pending, 1)
+
+	files := t.files()
+	for len(files) > t.MaxFiles {
+		Logger.Printf("OfflineTransport: removing %s, too many stored envelopes", filepath.Base(files[0]))
+		_ = os.Remove(files[0])
+		files = files[1:]
+	}
+	return true
+}
+
+// delivered replays stored envelopes, if any, after a successful delivery.
+func (t *OfflineTransport) delivered() {
+	if atomic.LoadInt32(&t.pending) == 0 {
+		return
+	}
+	if et, ok := t.inner.(envelopeTransport); ok {
+		go t.replay(et)
+	}
+}
+
+// replay sends stored envelopes from oldest to newest, removing envelopes that
+// are too old or corrupt. It stops early when an envelope cannot be sent. Only
+// one replay runs at a time.
+func (t *OfflineTransport) replay(et envelopeTransport) {
+	if !atomic.CompareAndSwapInt32(&t.replaying, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&t.replaying, 0)
+	atomic.StoreInt32(&t.pending, 0)
+
+	t.mu.Lock()
+	files := t.files()
+	t.mu.Unlock()
+
+	failures := atomic.LoadUint32(&t.failures)
+	for i, name := range files {
+		if atomic.LoadUint32(&t.failures) != failures {
+			// Sentry is not reachable, the failed envelope was stored again.
+			atomic.StoreInt32(&t.pending, 1)
+			return
+		}
+
+		envelope, compressed, category, ok := t.load(name)
+		if !ok {
+			continue
+		}
+		if !et.sendEnvelope(envelope, compressed, category) {
+			// Keep this and the remaining envelopes for later.
+			Logger.Printf("OfflineTransport: %d stored envelopes left to send", len(files)-i)
+			atomic.StoreInt32(&t.pending, 1)
+			return
+		}
+		// The envelope is stored again if delivery fails.
+		t.mu.Lock()
+		_ = os.Remove(name)
+		t.mu.Unlock()
+	}
+}
+
+// load reads a stored envelope. Envelopes that are too old or corrupt are
+// removed and reported as not ok.
+func (t *OfflineTransport) load(name string) (envelope []byte, compressed bool, category ratelimit.Category, ok bool) {
+	remove := func(reason string) {
+		Logger.Printf("OfflineTransport: removing %s, %s", filepath.Base(name), reason)
+		t.mu.Lock()
+		_ = os.Remove(name)
+		t.mu.Unlock()
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, false, "", false
+	}
+	if time.Since(info.ModTime()) > t.MaxAge {
+		remove("envelope is too old")
+		return nil, false, "", false
+	}
+	envelope, err = os.ReadFile(name)
+	if err != nil {
+		return nil, false, "", false
+	}
+
+	compressed = strings.HasSuffix(name, envelopeGzipExt)
+	category, err = envelopeCategory(envelope, compressed)
+	if err != nil {
+		remove(fmt.Sprintf("corrupt envelope: %v", err))
+		return nil, false, "", false
+	}
+	return envelope, compressed, category, true
+}
+
+// files returns the stored envelopes sorted from oldest to newest. It must be
+// called with t.mu held.
+func (t *OfflineTransport) files() []string {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, envelopeExt) || strings.HasSuffix(name, envelopeGzipExt)) {
+			continue
+		}
+		files = append(files, filepath.Join(t.dir, name))
+	}
+	sort.Strings(files)
+	return files
+}
+
+// envelopeCategory validates the headers of a serialized envelope and returns
+// the rate limit category of its first item.
+func envelopeCategory(envelope []byte, compressed bool) (ratelimit.Category, error) {
+	var r io.Reader = bytes.NewReader(envelope)
+	if compressed {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return "", err
+		}
+		r = zr
+	}
+
+	// The first line is the envelope header, the second one the header of
+	// the first item.
+	s := bufio.NewScanner(r)
+	var lines [][]byte
+	for len(lines) < 2 && s.Scan() {
+		lines = append(lines, append([]byte(nil), s.Bytes()...))
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	if len(lines) < 2 || !json.Valid(lines[0]) {
+		return "", errors.New("missing envelope header")
+	}
+	var item struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(lines[1], &item); err != nil || item.Type == "" {
+		return "", errors.New("missing item header")
+	}
+	if item.Type == eventType {
+		return ratelimit.CategoryError, nil
+	}
+	return categoryFor(item.Type), nil
+}
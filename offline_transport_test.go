@@ -0,0 +1,286 @@
+package sentry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go/internal/ratelimit"
+)
+
+// offlineTestServer is a Sentry server that can be taken offline, responding
+// with 503 Service Unavailable. It records the IDs of received events.
+type offlineTestServer struct {
+	*httptest.Server
+	offline int32
+
+	mu  sync.Mutex
+	ids []string
+}
+
+func newOfflineTestServer(t *testing.T) *offlineTestServer {
+	ts := &offlineTestServer{}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&ts.offline) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := requestBody(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var header struct {
+			EventID string `json:"event_id"`
+		}
+		if err := json.NewDecoder(body).Decode(&header); err != nil {
+			t.Error(err)
+			return
+		}
+		ts.mu.Lock()
+		ts.ids = append(ts.ids, header.EventID)
+		ts.mu.Unlock()
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *offlineTestServer) SetOffline(offline bool) {
+	var v int32
+	if offline {
+		v = 1
+	}
+	atomic.StoreInt32(&ts.offline, v)
+}
+
+func (ts *offlineTestServer) IDs() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.ids...)
+}
+
+func (ts *offlineTestServer) Options() ClientOptions {
+	return ClientOptions{
+		Dsn: strings.Replace(ts.URL, "//", "//pubkey@", 1) + "/1",
+	}
+}
+
+func storedEnvelopes(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.envelope*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+// waitFor polls cond until it returns true or a timeout is reached.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOfflineTransport(t *testing.T) {
+	t.Run("AsyncTransport", func(t *testing.T) {
+		testOfflineTransport(t, func() Transport { return NewHTTPTransport() })
+	})
+	t.Run("SyncTransport", func(t *testing.T) {
+		testOfflineTransport(t, func() Transport { return NewHTTPSyncTransport() })
+	})
+}
+
+func testOfflineTransport(t *testing.T, newInner func() Transport) {
+	srv := newOfflineTestServer(t)
+	dir := t.TempDir()
+
+	var sendErrors int32
+	options := srv.Options()
+	options.OnSendError = func(event *Event, err error) {
+		atomic.AddInt32(&sendErrors, 1)
+	}
+	tr := NewOfflineTransport(dir, newInner())
+	tr.Configure(options)
+
+	// Events sent while Sentry is unreachable are stored, and not reported as
+	// send errors.
+	srv.SetOffline(true)
+	tr.SendEvent(&Event{EventID: "00000000000000000000000000000001"})
+	tr.SendEvent(&Event{EventID: "00000000000000000000000000000002"})
+	if !tr.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+	if n := len(storedEnvelopes(t, dir)); n != 2 {
+		t.Fatalf("got %d stored envelopes, want 2", n)
+	}
+
+	// Once Sentry is reachable again, stored events are sent after the next
+	// successful delivery.
+	srv.SetOffline(false)
+	tr.SendEvent(&Event{EventID: "00000000000000000000000000000003"})
+	waitFor(t, "stored envelopes to be sent", func() bool {
+		tr.Flush(time.Second)
+		return len(srv.IDs()) == 3 && len(storedEnvelopes(t, dir)) == 0
+	})
+
+	// Events stored by a previous run are sent when the transport is
+	// configured.
+	srv.SetOffline(true)
+	tr.SendEvent(&Event{EventID: "00000000000000000000000000000004"})
+	if !tr.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+	tr.Close()
+	if n := len(storedEnvelopes(t, dir)); n != 1 {
+		t.Fatalf("got %d stored envelopes, want 1", n)
+	}
+
+	srv.SetOffline(false)
+	tr = NewOfflineTransport(dir, newInner())
+	tr.Configure(srv.Options())
+	waitFor(t, "stored envelopes to be sent", func() bool {
+		tr.Flush(time.Second)
+		return len(srv.IDs()) == 4 && len(storedEnvelopes(t, dir)) == 0
+	})
+	tr.Close()
+
+	want := map[string]bool{
+		"00000000000000000000000000000001": true,
+		"00000000000000000000000000000002": true,
+		"00000000000000000000000000000003": true,
+		"00000000000000000000000000000004": true,
+	}
+	for _, id := range srv.IDs() {
+		if !want[id] {
+			t.Errorf("unexpected or duplicate event %s", id)
+		}
+		delete(want, id)
+	}
+	if n := atomic.LoadInt32(&sendErrors); n != 0 {
+		t.Errorf("got %d send errors, want 0 for stored events", n)
+	}
+}
+
+func TestOfflineTransportMaxFiles(t *testing.T) {
+	srv := newOfflineTestServer(t)
+	srv.SetOffline(true)
+	dir := t.TempDir()
+
+	tr := NewOfflineTransport(dir, NewHTTPSyncTransport())
+	tr.MaxFiles = 2
+	tr.Configure(srv.Options())
+
+	for i := 0; i < 5; i++ {
+		tr.SendEvent(&Event{})
+	}
+	if n := len(storedEnvelopes(t, dir)); n != 2 {
+		t.Errorf("got %d stored envelopes, want 2", n)
+	}
+}
+
+func TestOfflineTransportPrunesFiles(t *testing.T) {
+	srv := newOfflineTestServer(t)
+	dir := t.TempDir()
+
+	writeEnvelope := func(name string, b []byte) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := []byte(`{"event_id":"00000000000000000000000000000001"}
+{"type":"event","length":2}
+{}
+`)
+	writeEnvelope("00000000000000000001-corrupt.envelope", []byte("not an envelope"))
+	writeEnvelope("00000000000000000002-corrupt.envelope.gz", valid)
+	old := writeEnvelope("00000000000000000003-old.envelope", valid)
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewOfflineTransport(dir, NewHTTPSyncTransport())
+	tr.Configure(srv.Options())
+	waitFor(t, "stored envelopes to be pruned", func() bool {
+		return len(storedEnvelopes(t, dir)) == 0
+	})
+	if ids := srv.IDs(); len(ids) != 0 {
+		t.Errorf("got events %v, want none", ids)
+	}
+}
+
+func TestEnvelopeCategory(t *testing.T) {
+	gzipped := func(s string) []byte {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		_, _ = io.WriteString(zw, s)
+		_ = zw.Close()
+		return b.Bytes()
+	}
+
+	tests := []struct {
+		name       string
+		envelope   []byte
+		compressed bool
+		want       ratelimit.Category
+		wantErr    bool
+	}{
+		{
+			name:     "Event",
+			envelope: []byte("{}\n{\"type\":\"event\",\"length\":2}\n{}\n"),
+			want:     ratelimit.CategoryError,
+		},
+		{
+			name:       "CompressedTransaction",
+			envelope:   gzipped("{}\n{\"type\":\"transaction\",\"length\":2}\n{}\n"),
+			compressed: true,
+			want:       ratelimit.CategoryTransaction,
+		},
+		{
+			name:     "MissingItem",
+			envelope: []byte("{}\n"),
+			wantErr:  true,
+		},
+		{
+			name:     "InvalidHeader",
+			envelope: []byte("garbage\n{\"type\":\"event\"}\n"),
+			wantErr:  true,
+		},
+		{
+			name:       "NotCompressed",
+			envelope:   []byte("{}\n{\"type\":\"event\"}\n"),
+			compressed: true,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := envelopeCategory(tt.envelope, tt.compressed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got category %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
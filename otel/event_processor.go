@@ -7,11 +7,23 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-// linkTraceContextToErrorEvent is a Sentry event processor that attaches trace information
-// to the error event.
+// LinkTraceContext is a Sentry event processor that attaches the trace
+// information of the OpenTelemetry span in hint.Context to error events, so
+// that Sentry issues can be correlated with OpenTelemetry traces.
 //
-// Caveat: hint.Context should contain a valid context populated by OpenTelemetry's span context.
-func linkTraceContextToErrorEvent(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+// If the span is recorded by the Sentry span processor, the event is linked to
+// its Sentry span. Otherwise, for example when spans are exported to another
+// tracing backend, the trace ID and span ID of the OpenTelemetry span are used
+// as is.
+//
+// NewSentrySpanProcessor registers it as a global event processor. When not
+// using the Sentry span processor, register it with
+// sentry.AddGlobalEventProcessor or Scope.AddEventProcessor.
+//
+// Caveat: hint.Context should contain a valid context populated by
+// OpenTelemetry's span context, as done by Hub.RecoverWithContext or by
+// passing a sentry.EventHint with the Context field set to Client methods.
+func LinkTraceContext(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
 	if hint == nil || hint.Context == nil {
 		return event
 	}
@@ -20,21 +32,29 @@ func linkTraceContextToErrorEvent(event *sentry.Event, hint *sentry.EventHint) *
 		return event
 	}
 	otelSpanContext := trace.SpanContextFromContext(hint.Context)
-	var sentrySpan *sentry.Span
-	if otelSpanContext.IsValid() {
-		sentrySpan, _ = sentrySpanMap.Get(otelSpanContext.SpanID())
-	}
-	if sentrySpan == nil {
+	if !otelSpanContext.IsValid() {
 		return event
 	}
 
-	traceContext, found := event.Contexts["trace"]
-	if !found {
-		event.Contexts["trace"] = make(map[string]interface{})
-		traceContext = event.Contexts["trace"]
+	// The existing trace context may be shared with the scope, so modify a
+	// copy.
+	traceContext := make(sentry.Context, 3)
+	for k, v := range event.Contexts["trace"] {
+		traceContext[k] = v
+	}
+	if event.Contexts == nil {
+		event.Contexts = make(map[string]sentry.Context)
+	}
+	event.Contexts["trace"] = traceContext
+	if sentrySpan, ok := sentrySpanMap.Get(otelSpanContext.SpanID()); ok {
+		traceContext["trace_id"] = sentrySpan.TraceID.String()
+		traceContext["span_id"] = sentrySpan.SpanID.String()
+		traceContext["parent_span_id"] = sentrySpan.ParentSpanID.String()
+		return event
 	}
-	traceContext["trace_id"] = sentrySpan.TraceID.String()
-	traceContext["span_id"] = sentrySpan.SpanID.String()
-	traceContext["parent_span_id"] = sentrySpan.ParentSpanID.String()
+	traceContext["trace_id"] = otelSpanContext.TraceID().String()
+	traceContext["span_id"] = otelSpanContext.SpanID().String()
+	// The parent of a span is not part of its OpenTelemetry span context.
+	delete(traceContext, "parent_span_id")
 	return event
 }
@@ -8,9 +8,10 @@ import (
 	"testing"
 
 	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func TestLinkTraceContextToErrorEventSetsContext(t *testing.T) {
+func TestLinkTraceContextSetsContext(t *testing.T) {
 
 	withExistingContextOptions := []bool{false, true}
 
@@ -55,3 +56,40 @@ func TestLinkTraceContextToErrorEventSetsContext(t *testing.T) {
 		})
 	}
 }
+
+func TestLinkTraceContextWithoutSentrySpan(t *testing.T) {
+	sentrySpanMap.Clear()
+	ctx := emptyContextWithSentry()
+	hub := sentry.GetHubFromContext(ctx)
+	client, scope := hub.Client(), hub.Scope()
+	scope.AddEventProcessor(LinkTraceContext)
+	scope.SetContext("trace", map[string]interface{}{"parent_span_id": "123"})
+
+	// The span context of a span recorded by another OpenTelemetry exporter.
+	otelSpanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    otelTraceIDFromHex("d4cda95b652f4a1592b449dd92ffda3b"),
+		SpanID:     otelSpanIDFromHex("6e0c63257de34c92"),
+		TraceFlags: trace.FlagsSampled,
+	})
+	client.CaptureException(
+		errors.New("error"),
+		&sentry.EventHint{Context: trace.ContextWithSpanContext(ctx, otelSpanContext)},
+		scope,
+	)
+
+	events := client.Transport.(*TransportMock).Events()
+	assertEqual(t, len(events), 1)
+	assertEqual(t,
+		events[0].Contexts["trace"],
+		map[string]interface{}{
+			"trace_id": "d4cda95b652f4a1592b449dd92ffda3b",
+			"span_id":  "6e0c63257de34c92",
+		},
+	)
+
+	// The trace context of the scope is left untouched.
+	client.CaptureException(errors.New("error"), &sentry.EventHint{Context: ctx}, scope)
+	events = client.Transport.(*TransportMock).Events()
+	assertEqual(t, len(events), 2)
+	assertEqual(t, events[1].Contexts["trace"], sentry.Context{"parent_span_id": "123"})
+}
@@ -22,7 +22,7 @@ func NewSentrySpanProcessor() otelSdkTrace.SpanProcessor {
 	if sentrySpanProcessorInstance != nil {
 		return sentrySpanProcessorInstance
 	}
-	sentry.AddGlobalEventProcessor(linkTraceContextToErrorEvent)
+	sentry.AddGlobalEventProcessor(LinkTraceContext)
 	sentrySpanProcessorInstance := &sentrySpanProcessor{}
 	return sentrySpanProcessorInstance
 }
@@ -42,6 +42,7 @@ func emptyContextWithSentry() context.Context {
 		Release:          "1.2.3",
 		EnableTracing:    true,
 		TracesSampleRate: 1.0,
+		SampleRand:       func() float64 { return 0.25 },
 		Transport:        &TransportMock{},
 	})
 	hub := sentry.NewHub(client, sentry.NewScope())
@@ -124,7 +125,7 @@ func TestOnStartRootSpan(t *testing.T) {
 	testutils.AssertBaggageStringsEqual(
 		t,
 		sentrySpan.ToBaggage(),
-		"sentry-transaction=spanName,sentry-environment=testing,sentry-public_key=abc,sentry-release=1.2.3,sentry-sample_rate=1,sentry-trace_id="+otelTraceId.String(),
+		"sentry-transaction=spanName,sentry-environment=testing,sentry-public_key=abc,sentry-release=1.2.3,sentry-sample_rate=1,sentry-sample_rand=0.250000,sentry-sampled=true,sentry-trace_id="+otelTraceId.String(),
 	)
 }
 
@@ -2,6 +2,7 @@ package sentry
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"sync"
@@ -31,7 +32,12 @@ type Scope struct {
 	extra       map[string]interface{}
 	fingerprint []string
 	level       Level
+	transaction string
+	logger      string
 	request     *http.Request
+	// requestSnapshot is the request set with SetRequestSnapshot, used when
+	// the http.Request is not available.
+	requestSnapshot *Request
 	// requestBody holds a reference to the original request.Body.
 	requestBody interface {
 		// Bytes returns bytes from the original body, lazily buffered as the
@@ -42,6 +48,10 @@ type Scope struct {
 		Overflow() bool
 	}
 	eventProcessors []EventProcessor
+	attachments     []*Attachment
+	// span is the last span started with the hub of the scope, whose
+	// transaction provides the dynamic sampling context of error events.
+	span *Span
 }
 
 // NewScope creates a new Scope.
@@ -52,6 +62,7 @@ func NewScope() *Scope {
 		contexts:    make(map[string]Context),
 		extra:       make(map[string]interface{}),
 		fingerprint: make([]string, 0),
+		attachments: make([]*Attachment, 0),
 	}
 
 	return &scope
@@ -60,6 +71,14 @@ func NewScope() *Scope {
 // AddBreadcrumb adds new breadcrumb to the current scope
 // and optionally throws the old one if limit is reached.
 func (scope *Scope) AddBreadcrumb(breadcrumb *Breadcrumb, limit int) {
+	scope.addBreadcrumb(breadcrumb, limit, 0)
+}
+
+// addBreadcrumb is like AddBreadcrumb, but if categoryLimit is positive, it
+// also throws the oldest breadcrumb of the same category if the number of
+// breadcrumbs of that category exceeds categoryLimit. It returns the thrown
+// breadcrumbs, oldest first.
+func (scope *Scope) addBreadcrumb(breadcrumb *Breadcrumb, limit, categoryLimit int) (evicted []*Breadcrumb) {
 	if breadcrumb.Timestamp.IsZero() {
 		breadcrumb.Timestamp = time.Now()
 	}
@@ -67,10 +86,27 @@ func (scope *Scope) AddBreadcrumb(breadcrumb *Breadcrumb, limit int) {
 	scope.mu.Lock()
 	defer scope.mu.Unlock()
 
+	if categoryLimit > 0 {
+		count, oldest := 0, -1
+		for i, b := range scope.breadcrumbs {
+			if b.Category == breadcrumb.Category {
+				if oldest < 0 {
+					oldest = i
+				}
+				count++
+			}
+		}
+		if count >= categoryLimit {
+			evicted = append(evicted, scope.breadcrumbs[oldest])
+			scope.breadcrumbs = append(scope.breadcrumbs[:oldest], scope.breadcrumbs[oldest+1:]...)
+		}
+	}
 	scope.breadcrumbs = append(scope.breadcrumbs, breadcrumb)
-	if len(scope.breadcrumbs) > limit {
-		scope.breadcrumbs = scope.breadcrumbs[1 : limit+1]
+	if n := len(scope.breadcrumbs) - limit; n > 0 {
+		evicted = append(evicted, scope.breadcrumbs[:n]...)
+		scope.breadcrumbs = scope.breadcrumbs[n:]
 	}
+	return evicted
 }
 
 // ClearBreadcrumbs clears all breadcrumbs from the current scope.
@@ -89,12 +125,73 @@ func (scope *Scope) SetUser(user User) {
 	scope.user = user
 }
 
-// SetRequest sets the request for the current scope.
+// RemoveUser removes the user from the current scope.
+func (scope *Scope) RemoveUser() {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	scope.user = User{}
+}
+
+// SetTransaction sets the transaction name for the current scope. It is applied
+// to error and message events that do not have a transaction name of their
+// own, and is used by Sentry to label and group issues, for example by the
+// route of the HTTP request that was being handled.
+//
+// Transaction events are not affected, see WithTransactionName to name them.
+func (scope *Scope) SetTransaction(name string) {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	scope.transaction = name
+}
+
+// SetLogger sets the name of the logger or subsystem reporting events for the
+// current scope, such as "payments" or "db", by which Sentry can filter issues.
+// It is applied to events that do not have a logger name of their own, like
+// those set by the logging integrations.
+func (scope *Scope) SetLogger(name string) {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	scope.logger = name
+}
+
+// Transaction returns the transaction name for the current scope.
+func (scope *Scope) Transaction() string {
+	scope.mu.RLock()
+	defer scope.mu.RUnlock()
+
+	return scope.transaction
+}
+
+// setSpan sets the span whose trace context is set on the scope.
+func (scope *Scope) setSpan(span *Span) {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	scope.span = span
+}
+
+// clearSpan unsets the span of the scope if it belongs to the given
+// transaction.
+func (scope *Scope) clearSpan(transaction *Span) {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	if scope.span != nil && scope.span.GetTransaction() == transaction {
+		scope.span = nil
+	}
+}
+
+// SetRequest sets the request for the current scope. See
+// ClientOptions.OmitRequestFields to leave fields of the request out of events.
 func (scope *Scope) SetRequest(r *http.Request) {
 	scope.mu.Lock()
 	defer scope.mu.Unlock()
 
 	scope.request = r
+	scope.requestSnapshot = nil
 
 	if r == nil {
 		return
@@ -116,11 +213,42 @@ func (scope *Scope) SetRequest(r *http.Request) {
 	scope.requestBody = buf
 }
 
+// SetRequestSnapshot sets the request for the current scope from a request
+// already in its Sentry representation, for code reporting errors about a
+// request without the original http.Request at hand, such as a background
+// worker processing a request saved in a queue:
+//
+//	scope.SetRequestSnapshot(&sentry.Request{
+//		Method:  job.Method,
+//		URL:     job.URL,
+//		Headers: job.Headers,
+//		Data:    job.Body,
+//	})
+//
+// It replaces the request and body set with SetRequest and SetRequestBody.
+// Like them, the request is only attached to events without a request of
+// their own, and sensitive headers and cookies are removed unless
+// ClientOptions.SendDefaultPII is set.
+func (scope *Scope) SetRequestSnapshot(r *Request) {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	scope.request = nil
+	scope.requestBody = nil
+	scope.requestSnapshot = r
+}
+
 // SetRequestBody sets the request body for the current scope.
 //
 // This method should only be called when the body bytes are already available
-// in memory. Typically, the request body is buffered lazily from the
-// Request.Body from SetRequest.
+// in memory, for example by framework integrations that buffer or parse the
+// body themselves. Typically, the request body is buffered lazily from the
+// Request.Body from SetRequest. When both are used, SetRequestBody must be
+// called after SetRequest.
+//
+// The body is attached to the request of events, or to an otherwise empty
+// request if SetRequest was not called. As for bodies buffered by SetRequest,
+// bodies larger than 10 KB are not reported.
 func (scope *Scope) SetRequestBody(b []byte) {
 	scope.mu.Lock()
 	defer scope.mu.Unlock()
@@ -198,6 +326,15 @@ func (scope *Scope) SetTags(tags map[string]string) {
 	}
 }
 
+// SetTagf adds a tag to the current scope, with the value formatted according
+// to format, like fmt.Sprintf. It is a shorthand for tags with non-string
+// values:
+//
+//	scope.SetTagf("retries", "%d", retries)
+func (scope *Scope) SetTagf(key, format string, args ...interface{}) {
+	scope.SetTag(key, fmt.Sprintf(format, args...))
+}
+
 // RemoveTag removes a tag from the current scope.
 func (scope *Scope) RemoveTag(key string) {
 	scope.mu.Lock()
@@ -258,12 +395,25 @@ func (scope *Scope) RemoveExtra(key string) {
 	delete(scope.extra, key)
 }
 
-// SetFingerprint sets new fingerprint for the current scope.
+// SetFingerprint sets new fingerprint for the current scope. The fingerprint
+// controls how Sentry groups events into issues, and is applied to events that
+// do not have a fingerprint of their own.
+//
+// Include the special "{{ default }}" value to extend the default grouping
+// instead of replacing it, for example to split an issue by an error code:
+//
+//	scope.SetFingerprint([]string{"{{ default }}", code})
+//
+// To compute the fingerprint from the content of events, set Event.Fingerprint
+// in ClientOptions.BeforeSend instead. It is called after the scope is applied,
+// so it takes precedence.
+//
+// See https://docs.sentry.io/platforms/go/usage/sdk-fingerprinting/.
 func (scope *Scope) SetFingerprint(fingerprint []string) {
 	scope.mu.Lock()
 	defer scope.mu.Unlock()
 
-	scope.fingerprint = fingerprint
+	scope.fingerprint = append([]string(nil), fingerprint...)
 }
 
 // SetLevel sets new level for the current scope.
@@ -274,20 +424,45 @@ func (scope *Scope) SetLevel(level Level) {
 	scope.level = level
 }
 
+// AddAttachment adds an attachment to the current scope. Attachments are sent
+// with every error event captured with the scope, but not with transactions.
+func (scope *Scope) AddAttachment(attachment *Attachment) {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	scope.attachments = append(scope.attachments, attachment)
+}
+
+// ClearAttachments clears all attachments from the current scope.
+func (scope *Scope) ClearAttachments() {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	scope.attachments = []*Attachment{}
+}
+
 // Clone returns a copy of the current scope with all data copied over.
+//
+// Tags, extra, contexts, breadcrumbs, fingerprint and user data are copied, so
+// that changes made to the clone never affect the original scope and vice
+// versa. Values stored in extra, contexts and breadcrumb data are copied as
+// is. The request and attachments are shared.
 func (scope *Scope) Clone() *Scope {
 	scope.mu.RLock()
 	defer scope.mu.RUnlock()
 
 	clone := NewScope()
 	clone.user = scope.user
+	clone.user.Data = cloneStringMap(scope.user.Data)
 	clone.breadcrumbs = make([]*Breadcrumb, len(scope.breadcrumbs))
-	copy(clone.breadcrumbs, scope.breadcrumbs)
+	for i, b := range scope.breadcrumbs {
+		clone.breadcrumbs[i] = cloneBreadcrumb(b)
+	}
 	for key, value := range scope.tags {
 		clone.tags[key] = value
 	}
 	for key, value := range scope.contexts {
-		clone.contexts[key] = value
+		clone.contexts[key] = cloneContext(value)
 	}
 	for key, value := range scope.extra {
 		clone.extra[key] = value
@@ -295,18 +470,100 @@ func (scope *Scope) Clone() *Scope {
 	clone.fingerprint = make([]string, len(scope.fingerprint))
 	copy(clone.fingerprint, scope.fingerprint)
 	clone.level = scope.level
+	clone.transaction = scope.transaction
+	clone.logger = scope.logger
 	clone.request = scope.request
+	clone.requestSnapshot = scope.requestSnapshot
 	clone.requestBody = scope.requestBody
-	clone.eventProcessors = scope.eventProcessors
+	// Processors are only ever appended. Limiting the capacity makes the
+	// first append to the clone reallocate, instead of writing into the
+	// backing array of the parent.
+	clone.eventProcessors = scope.eventProcessors[:len(scope.eventProcessors):len(scope.eventProcessors)]
+	clone.attachments = make([]*Attachment, len(scope.attachments))
+	copy(clone.attachments, scope.attachments)
+	clone.span = scope.span
 	return clone
 }
 
-// Clear removes the data from the current scope. Not safe for concurrent use.
+// cloneContext returns a copy of c. Values are copied as is.
+func cloneContext(c Context) Context {
+	if c == nil {
+		return nil
+	}
+	clone := make(Context, len(c))
+	for key, value := range c {
+		clone[key] = value
+	}
+	return clone
+}
+
+// cloneBreadcrumb returns a copy of b with its own Data map.
+func cloneBreadcrumb(b *Breadcrumb) *Breadcrumb {
+	if b == nil {
+		return nil
+	}
+	clone := *b
+	if b.Data != nil {
+		clone.Data = make(map[string]interface{}, len(b.Data))
+		for key, value := range b.Data {
+			clone.Data[key] = value
+		}
+	}
+	return &clone
+}
+
+func cloneStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
+
+// Clear removes the data from the current scope: breadcrumbs, user, tags,
+// contexts, extra, fingerprint, level, transaction name, logger, request, event
+// processors and attachments. It is useful to reuse a long-lived scope, for
+// example between requests handled by a pooled hub.
 func (scope *Scope) Clear() {
-	*scope = *NewScope()
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+
+	empty := NewScope()
+	scope.breadcrumbs = empty.breadcrumbs
+	scope.user = empty.user
+	scope.tags = empty.tags
+	scope.contexts = empty.contexts
+	scope.extra = empty.extra
+	scope.fingerprint = empty.fingerprint
+	scope.level = empty.level
+	scope.transaction = empty.transaction
+	scope.logger = empty.logger
+	scope.request = empty.request
+	scope.requestSnapshot = empty.requestSnapshot
+	scope.requestBody = empty.requestBody
+	scope.eventProcessors = empty.eventProcessors
+	scope.attachments = empty.attachments
+	scope.span = empty.span
 }
 
-// AddEventProcessor adds an event processor to the current scope.
+// AddEventProcessor adds an event processor to the current scope. Event
+// processors of the scope run in the order they were added, when the scope is
+// applied to an event, after the scope data was set on the event. Each can
+// modify the event or return nil to drop it, in which case later processors
+// are skipped, for example to enrich, redact or sample events conditionally:
+//
+//	scope.AddEventProcessor(func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+//		if event.Tags["component"] == "poller" && rand.Float64() >= 0.1 {
+//			return nil // keep 10% of the events of the poller
+//		}
+//		return event
+//	})
+//
+// Event processors of the scope run before those of the client and before
+// ClientOptions.BeforeSend. Dropped events are counted in client reports.
 func (scope *Scope) AddEventProcessor(processor EventProcessor) {
 	scope.mu.Lock()
 	defer scope.mu.Unlock()
@@ -315,7 +572,31 @@ func (scope *Scope) AddEventProcessor(processor EventProcessor) {
 }
 
 // ApplyToEvent takes the data from the current scope and attaches it to the event.
+// It then runs the event processors of the scope, and returns nil if one of them
+// dropped the event.
+//
+// Data already set on the event takes precedence over the scope, except for
+// tags and the level, which the scope overrides. Extra values of the scope are
+// only added under keys the event does not have. Contexts under the same key
+// are merged, keeping the event value of fields set on both.
 func (scope *Scope) ApplyToEvent(event *Event, hint *EventHint) *Event {
+	// Event processors run without holding the lock, so that they can use
+	// the scope.
+	for _, processor := range scope.applyDataToEvent(event) {
+		id := event.EventID
+		event = processor(event, hint)
+		if event == nil {
+			Logger.Printf("Event dropped by one of the Scope EventProcessors: %s\n", id)
+			return nil
+		}
+	}
+
+	return event
+}
+
+// applyDataToEvent attaches the data from the current scope to event, and
+// returns the event processors of the scope.
+func (scope *Scope) applyDataToEvent(event *Event) []EventProcessor {
 	scope.mu.RLock()
 	defer scope.mu.RUnlock()
 
@@ -348,10 +629,21 @@ func (scope *Scope) ApplyToEvent(event *Event, hint *EventHint) *Event {
 				continue
 			}
 
-			// Ensure we are not overwriting event fields
-			if _, ok := event.Contexts[key]; !ok {
+			eventValue, ok := event.Contexts[key]
+			if !ok {
 				event.Contexts[key] = value
+				continue
+			}
+			// Merge both contexts into a new one, without overwriting
+			// event fields. Either may be shared with other events.
+			merged := make(Context, len(value)+len(eventValue))
+			for k, v := range value {
+				merged[k] = v
+			}
+			for k, v := range eventValue {
+				merged[k] = v
 			}
+			event.Contexts[key] = merged
 		}
 	}
 
@@ -361,7 +653,10 @@ func (scope *Scope) ApplyToEvent(event *Event, hint *EventHint) *Event {
 		}
 
 		for key, value := range scope.extra {
-			event.Extra[key] = value
+			// Ensure we are not overwriting event fields
+			if _, ok := event.Extra[key]; !ok {
+				event.Extra[key] = value
+			}
 		}
 	}
 
@@ -377,6 +672,14 @@ func (scope *Scope) ApplyToEvent(event *Event, hint *EventHint) *Event {
 		event.Level = scope.level
 	}
 
+	if event.Transaction == "" && event.Type != transactionType {
+		event.Transaction = scope.transaction
+	}
+
+	if event.Logger == "" {
+		event.Logger = scope.logger
+	}
+
 	if event.Request == nil && scope.request != nil {
 		event.Request = NewRequest(scope.request)
 		// NOTE: The SDK does not attempt to send partial request body data.
@@ -391,16 +694,28 @@ func (scope *Scope) ApplyToEvent(event *Event, hint *EventHint) *Event {
 		if scope.requestBody != nil && !scope.requestBody.Overflow() {
 			event.Request.Data = string(scope.requestBody.Bytes())
 		}
+	} else if event.Request == nil && scope.requestSnapshot != nil {
+		// Events are processed further, so they get their own copy.
+		request := *scope.requestSnapshot
+		request.Headers = cloneStringMap(request.Headers)
+		request.Env = cloneStringMap(request.Env)
+		event.Request = &request
+	} else if event.Request == nil && scope.requestBody != nil && !scope.requestBody.Overflow() {
+		// The body was set with SetRequestBody without a request.
+		event.Request = &Request{Data: string(scope.requestBody.Bytes())}
 	}
 
-	for _, processor := range scope.eventProcessors {
-		id := event.EventID
-		event = processor(event, hint)
-		if event == nil {
-			Logger.Printf("Event dropped by one of the Scope EventProcessors: %s\n", id)
-			return nil
+	if len(scope.attachments) > 0 && event.Type != transactionType {
+		event.Attachments = append(event.Attachments, scope.attachments...)
+	}
+
+	// Error events carry the dynamic sampling context of the trace they
+	// belong to in their envelope, like transactions.
+	if scope.span != nil && event.Type != transactionType && !event.sdkMetaData.dsc.HasEntries() {
+		if transaction := scope.span.GetTransaction(); transaction != nil {
+			event.sdkMetaData.dsc = transaction.frozenDynamicSamplingContext()
 		}
 	}
 
-	return event
+	return scope.eventProcessors
 }
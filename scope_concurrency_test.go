@@ -60,5 +60,6 @@ func touchScope(scope *sentry.Scope, x int) {
 	sentry.CaptureException(fmt.Errorf("error %d", x))
 
 	scope.ClearBreadcrumbs()
+	scope.RemoveUser()
 	scope.Clone()
 }
@@ -1,8 +1,12 @@
 package sentry
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -75,6 +79,34 @@ func TestScopeSetRequestOverrides(t *testing.T) {
 	assertEqual(t, r2, scope.request)
 }
 
+func TestScopeSetRequestSnapshot(t *testing.T) {
+	snapshot := &Request{
+		URL:     "https://example.com/orders",
+		Method:  "POST",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Data:    `{"id":1}`,
+	}
+	scope := NewScope()
+	scope.SetRequest(httptest.NewRequest("GET", "/foo", nil))
+	scope.SetRequestSnapshot(snapshot)
+
+	event := scope.ApplyToEvent(NewEvent(), nil)
+	assertEqual(t, event.Request, snapshot)
+	// The event has its own copy of the snapshot.
+	event.Request.Headers["Content-Type"] = "text/plain"
+	assertEqual(t, snapshot.Headers["Content-Type"], "application/json")
+
+	// An event with a request keeps it.
+	request := &Request{URL: "https://example.com/other"}
+	event = NewEvent()
+	event.Request = request
+	assertEqual(t, scope.ApplyToEvent(event, nil).Request, request)
+
+	// SetRequest replaces the snapshot.
+	scope.SetRequest(httptest.NewRequest("GET", "/foo", nil))
+	assertEqual(t, scope.ApplyToEvent(NewEvent(), nil).Request.URL, "http://example.com/foo")
+}
+
 func TestScopeSetTag(t *testing.T) {
 	scope := NewScope()
 	scope.SetTag("a", "foo")
@@ -98,6 +130,14 @@ func TestScopeSetTagOverrides(t *testing.T) {
 	assertEqual(t, map[string]string{"a": "bar"}, scope.tags)
 }
 
+func TestScopeSetTagf(t *testing.T) {
+	scope := NewScope()
+	scope.SetTag("a", "foo")
+	scope.SetTagf("b", "%d/%t", 42, true)
+
+	assertEqual(t, map[string]string{"a": "foo", "b": "42/true"}, scope.tags)
+}
+
 func TestScopeSetTags(t *testing.T) {
 	scope := NewScope()
 	scope.SetTags(map[string]string{"a": "foo"})
@@ -168,6 +208,26 @@ func TestScopeSetContextOverrides(t *testing.T) {
 	assertEqual(t, map[string]Context{"a": {"foo": 2}}, scope.contexts)
 }
 
+func TestScopeSetContextNestedJSON(t *testing.T) {
+	scope := NewScope()
+	scope.SetContext("checkout", Context{
+		"cart": map[string]interface{}{
+			"items": []string{"a", "b"},
+			"total": 42,
+		},
+		"step": "payment",
+	})
+
+	event := scope.ApplyToEvent(&Event{}, nil)
+	b, err := json.Marshal(event.Contexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"checkout":{"cart":{"items":["a","b"],"total":42},"step":"payment"}}`
+	assertEqual(t, string(b), want)
+}
+
 func TestScopeSetContexts(t *testing.T) {
 	scope := NewScope()
 	scope.SetContexts(map[string]Context{"a": {"b": 1}})
@@ -300,6 +360,29 @@ func TestScopeSetFingerprintOverrides(t *testing.T) {
 	assertEqual(t, []string{"def"}, scope.fingerprint)
 }
 
+func TestScopeSetFingerprintCopies(t *testing.T) {
+	scope := NewScope()
+	fingerprint := []string{"abc"}
+	scope.SetFingerprint(fingerprint)
+	fingerprint[0] = "def"
+
+	assertEqual(t, []string{"abc"}, scope.fingerprint)
+}
+
+func TestScopeSetFingerprintDefaultToken(t *testing.T) {
+	scope := NewScope()
+	scope.SetFingerprint([]string{"{{ default }}", "connection-refused"})
+
+	event := scope.ApplyToEvent(NewEvent(), nil)
+	assertEqual(t, []string{"{{ default }}", "connection-refused"}, event.Fingerprint)
+
+	// Events with a fingerprint of their own keep it.
+	event = NewEvent()
+	event.Fingerprint = []string{"custom"}
+	event = scope.ApplyToEvent(event, nil)
+	assertEqual(t, []string{"custom"}, event.Fingerprint)
+}
+
 func TestScopeSetLevel(t *testing.T) {
 	scope := NewScope()
 	scope.SetLevel(LevelInfo)
@@ -483,6 +566,38 @@ func TestClear(t *testing.T) {
 	assertEqual(t, (*http.Request)(nil), scope.request)
 }
 
+func TestClearConcurrent(t *testing.T) {
+	scope := fillScopeWithData(NewScope())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			scope.Clear()
+		}()
+		go func() {
+			defer wg.Done()
+			scope.SetTag("foo", "bar")
+			scope.ApplyToEvent(NewEvent(), nil)
+		}()
+	}
+	wg.Wait()
+	scope.Clear()
+
+	assertEqual(t, map[string]string{}, scope.tags)
+	assertEqual(t, []EventProcessor(nil), scope.eventProcessors)
+}
+
+func TestRemoveUser(t *testing.T) {
+	scope := NewScope()
+	scope.SetUser(User{ID: "foo"})
+	scope.RemoveUser()
+
+	assertEqual(t, User{}, scope.user)
+	assertEqual(t, User{}, scope.ApplyToEvent(NewEvent(), nil).User)
+}
+
 func TestClearAndReconfigure(t *testing.T) {
 	scope := fillScopeWithData(NewScope())
 	scope.Clear()
@@ -514,6 +629,203 @@ func TestClearBreadcrumbs(t *testing.T) {
 	assertEqual(t, []*Breadcrumb{}, scope.breadcrumbs)
 }
 
+func TestScopeAddAttachment(t *testing.T) {
+	scope := NewScope()
+	attachment := &Attachment{Filename: "foo.txt", Payload: []byte("foo")}
+	scope.AddAttachment(attachment)
+
+	assertEqual(t, []*Attachment{attachment}, scope.attachments)
+}
+
+func TestClearAttachments(t *testing.T) {
+	scope := NewScope()
+	scope.AddAttachment(&Attachment{Filename: "foo.txt", Payload: []byte("foo")})
+	scope.ClearAttachments()
+
+	assertEqual(t, []*Attachment{}, scope.attachments)
+}
+
+func TestScopeCloneCopiesAttachments(t *testing.T) {
+	parent := NewScope()
+	parent.AddAttachment(&Attachment{Filename: "foo.txt"})
+	child := parent.Clone()
+	child.AddAttachment(&Attachment{Filename: "bar.txt"})
+
+	assertEqual(t, []*Attachment{{Filename: "foo.txt"}}, parent.attachments)
+	assertEqual(t, []*Attachment{{Filename: "foo.txt"}, {Filename: "bar.txt"}}, child.attachments)
+}
+
+func TestScopeCloneIsDeepCopy(t *testing.T) {
+	parent := NewScope()
+	parent.SetTag("tag", "parent")
+	parent.SetExtra("extra", "parent")
+	parent.SetContext("ctx", Context{"key": "parent"})
+	parent.SetFingerprint([]string{"parent"})
+	parent.SetUser(User{ID: "parent", Data: map[string]string{"key": "parent"}})
+	parent.AddBreadcrumb(&Breadcrumb{Timestamp: testNow, Data: map[string]interface{}{"key": "parent"}}, maxBreadcrumbs)
+	parent.eventProcessors = make([]EventProcessor, 0, 10)
+
+	const goroutineCount = 50
+	var wg sync.WaitGroup
+	wg.Add(goroutineCount)
+	for i := 0; i < goroutineCount; i++ {
+		go func(i int) {
+			defer wg.Done()
+			clone := parent.Clone()
+			value := fmt.Sprintf("clone %d", i)
+			clone.SetTag("tag", value)
+			clone.SetExtra("extra", value)
+			clone.contexts["ctx"]["key"] = value
+			clone.fingerprint[0] = value
+			clone.fingerprint = append(clone.fingerprint, value)
+			clone.user.Data["key"] = value
+			clone.breadcrumbs[0].Data["key"] = value
+			clone.AddBreadcrumb(&Breadcrumb{Timestamp: testNow, Message: value}, maxBreadcrumbs)
+			clone.AddEventProcessor(func(event *Event, hint *EventHint) *Event { return event })
+		}(i)
+	}
+	wg.Wait()
+
+	assertEqual(t, parent.tags, map[string]string{"tag": "parent"})
+	assertEqual(t, parent.extra, map[string]interface{}{"extra": "parent"})
+	assertEqual(t, parent.contexts, map[string]Context{"ctx": {"key": "parent"}})
+	assertEqual(t, parent.fingerprint, []string{"parent"})
+	assertEqual(t, parent.user, User{ID: "parent", Data: map[string]string{"key": "parent"}})
+	assertEqual(t, parent.breadcrumbs, []*Breadcrumb{{Timestamp: testNow, Data: map[string]interface{}{"key": "parent"}}})
+	if parent.eventProcessors[:1][0] != nil {
+		t.Error("clones must not write to the event processors of the parent")
+	}
+}
+
+func TestApplyToEventRequestBody(t *testing.T) {
+	t.Run("WithRequest", func(t *testing.T) {
+		scope := NewScope()
+		scope.SetRequest(httptest.NewRequest(http.MethodPost, "/foo", nil))
+		scope.SetRequestBody([]byte(`{"key":"value"}`))
+
+		event := scope.ApplyToEvent(NewEvent(), nil)
+		assertEqual(t, event.Request.URL, "http://example.com/foo")
+		assertEqual(t, event.Request.Data, `{"key":"value"}`)
+	})
+
+	t.Run("WithoutRequest", func(t *testing.T) {
+		scope := NewScope()
+		scope.SetRequestBody([]byte(`{"key":"value"}`))
+
+		event := scope.ApplyToEvent(NewEvent(), nil)
+		assertEqual(t, event.Request, &Request{Data: `{"key":"value"}`})
+	})
+
+	t.Run("EventRequest", func(t *testing.T) {
+		scope := NewScope()
+		scope.SetRequestBody([]byte(`{"key":"value"}`))
+
+		event := NewEvent()
+		event.Request = &Request{URL: "http://example.com/bar"}
+		event = scope.ApplyToEvent(event, nil)
+		assertEqual(t, event.Request, &Request{URL: "http://example.com/bar"})
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		scope := NewScope()
+		scope.SetRequestBody(bytes.Repeat([]byte("x"), maxRequestBodyBytes+1))
+
+		event := scope.ApplyToEvent(NewEvent(), nil)
+		if event.Request != nil {
+			t.Errorf("got request %+v, want none", event.Request)
+		}
+	})
+}
+
+func TestApplyToEventEventProcessors(t *testing.T) {
+	scope := NewScope()
+	var calls []string
+	scope.AddEventProcessor(func(event *Event, hint *EventHint) *Event {
+		calls = append(calls, "enrich")
+		event.Tags["user"] = event.User.ID
+		// Processors may use the scope, which is not locked while they
+		// run.
+		scope.SetTag("processed", "true")
+		return event
+	})
+	scope.AddEventProcessor(func(event *Event, hint *EventHint) *Event {
+		calls = append(calls, "sample")
+		if event.Tags["noisy"] == "true" {
+			return nil
+		}
+		return event
+	})
+	scope.AddEventProcessor(func(event *Event, hint *EventHint) *Event {
+		calls = append(calls, "redact")
+		event.Message = "[redacted]"
+		return event
+	})
+	scope.SetUser(User{ID: "42"})
+
+	event := scope.ApplyToEvent(&Event{Message: "secret", Tags: map[string]string{}}, nil)
+	assertEqual(t, calls, []string{"enrich", "sample", "redact"})
+	assertEqual(t, event.Message, "[redacted]")
+	assertEqual(t, event.Tags["user"], "42")
+
+	calls = nil
+	event = scope.ApplyToEvent(&Event{Tags: map[string]string{"noisy": "true"}}, nil)
+	if event != nil {
+		t.Errorf("ApplyToEvent() = %v, want nil", event)
+	}
+	// Processors following the one that dropped the event are skipped.
+	assertEqual(t, calls, []string{"enrich", "sample"})
+}
+
+func TestApplyToEventTransaction(t *testing.T) {
+	scope := NewScope()
+	scope.SetTransaction("GET /users/{id}")
+	assertEqual(t, scope.Clone().Transaction(), "GET /users/{id}")
+
+	event := scope.ApplyToEvent(NewEvent(), nil)
+	assertEqual(t, event.Transaction, "GET /users/{id}")
+
+	event = NewEvent()
+	event.Transaction = "custom"
+	event = scope.ApplyToEvent(event, nil)
+	assertEqual(t, event.Transaction, "custom")
+
+	transaction := NewEvent()
+	transaction.Type = transactionType
+	transaction = scope.ApplyToEvent(transaction, nil)
+	assertEqual(t, transaction.Transaction, "")
+}
+
+func TestApplyToEventLogger(t *testing.T) {
+	scope := NewScope()
+	scope.SetLogger("payments")
+	assertEqual(t, scope.Clone().logger, "payments")
+
+	event := scope.ApplyToEvent(NewEvent(), nil)
+	assertEqual(t, event.Logger, "payments")
+
+	event = NewEvent()
+	event.Logger = "db"
+	event = scope.ApplyToEvent(event, nil)
+	assertEqual(t, event.Logger, "db")
+
+	scope.Clear()
+	assertEqual(t, scope.ApplyToEvent(NewEvent(), nil).Logger, "")
+}
+
+func TestApplyToEventAttachments(t *testing.T) {
+	scope := NewScope()
+	attachment := &Attachment{Filename: "foo.txt", Payload: []byte("foo")}
+	scope.AddAttachment(attachment)
+
+	event := scope.ApplyToEvent(NewEvent(), nil)
+	assertEqual(t, []*Attachment{attachment}, event.Attachments)
+
+	transaction := NewEvent()
+	transaction.Type = transactionType
+	transaction = scope.ApplyToEvent(transaction, nil)
+	assertEqual(t, []*Attachment(nil), transaction.Attachments)
+}
+
 func TestApplyToEventWithCorrectScopeAndEvent(t *testing.T) {
 	scope := fillScopeWithData(NewScope())
 	event := fillEventWithData(NewEvent())
@@ -531,6 +843,26 @@ func TestApplyToEventWithCorrectScopeAndEvent(t *testing.T) {
 	assertNotEqual(t, processedEvent.Fingerprint, scope.fingerprint, "should use event fingerprints if they exist")
 }
 
+func TestApplyToEventMergePrecedence(t *testing.T) {
+	scope := NewScope()
+	scope.SetContext("app", Context{"name": "scope", "version": "1.0"})
+	scope.SetExtras(map[string]interface{}{"shared": "scope", "scope": 1})
+	scopeContext := scope.contexts["app"]
+
+	event := NewEvent()
+	eventContext := Context{"name": "event", "build": "42"}
+	event.Contexts["app"] = eventContext
+	event.Extra["shared"] = "event"
+
+	processedEvent := scope.ApplyToEvent(event, nil)
+
+	assertEqual(t, processedEvent.Contexts["app"], Context{"name": "event", "version": "1.0", "build": "42"})
+	assertEqual(t, processedEvent.Extra, map[string]interface{}{"shared": "event", "scope": 1})
+	// Neither context is modified in place.
+	assertEqual(t, scopeContext, Context{"name": "scope", "version": "1.0"})
+	assertEqual(t, eventContext, Context{"name": "event", "build": "42"})
+}
+
 func TestApplyToEventUsingEmptyScope(t *testing.T) {
 	scope := NewScope()
 	event := fillEventWithData(NewEvent())
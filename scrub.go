@@ -0,0 +1,284 @@
+package sentry
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// filteredValue replaces the parts of values matched by
+// ClientOptions.DataScrubbers.
+const filteredValue = "[Filtered]"
+
+// CreditCardNumberPattern matches credit card numbers of 13 to 19 digits,
+// optionally grouped with spaces or dashes. Use it in
+// ClientOptions.DataScrubbers.
+var CreditCardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+
+// EmailAddressPattern matches email addresses. Use it in
+// ClientOptions.DataScrubbers.
+var EmailAddressPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// A scrubber replaces the matches of its patterns in string values with
+// filteredValue, see ClientOptions.DataScrubbers.
+type scrubber []*regexp.Regexp
+
+// scrubEvent scrubs the message, tags, extra data, contexts, exception values,
+// breadcrumbs, request and spans of event. The event may still be referenced
+// by the caller of BeforeSend, and its maps, slices and request shared with
+// the scope or live spans, so only the fields with matches are assigned, with
+// copies of the values they hold.
+func (s scrubber) scrubEvent(event *Event) {
+	if message, ok := s.scrubString(event.Message); ok {
+		event.Message = message
+	}
+	if transaction, ok := s.scrubString(event.Transaction); ok {
+		event.Transaction = transaction
+	}
+	if tags, ok := s.scrubStringMap(event.Tags); ok {
+		event.Tags = tags
+	}
+	if extra, ok := s.scrubInterfaceMap(event.Extra); ok {
+		event.Extra = extra
+	}
+
+	var contexts map[string]Context
+	for key, context := range event.Contexts {
+		scrubbed, ok := s.scrubInterfaceMap(context)
+		if !ok {
+			continue
+		}
+		if contexts == nil {
+			contexts = make(map[string]Context, len(event.Contexts))
+			for k, v := range event.Contexts {
+				contexts[k] = v
+			}
+		}
+		contexts[key] = scrubbed
+	}
+	if contexts != nil {
+		event.Contexts = contexts
+	}
+
+	var exceptions []Exception
+	for i, e := range event.Exception {
+		value, ok := s.scrubString(e.Value)
+		if !ok {
+			continue
+		}
+		if exceptions == nil {
+			exceptions = make([]Exception, len(event.Exception))
+			copy(exceptions, event.Exception)
+		}
+		exceptions[i].Value = value
+	}
+	if exceptions != nil {
+		event.Exception = exceptions
+	}
+
+	var breadcrumbs []*Breadcrumb
+	for i, b := range event.Breadcrumbs {
+		message, messageScrubbed := s.scrubString(b.Message)
+		data, dataScrubbed := s.scrubInterfaceMap(b.Data)
+		if !messageScrubbed && !dataScrubbed {
+			continue
+		}
+		if breadcrumbs == nil {
+			breadcrumbs = make([]*Breadcrumb, len(event.Breadcrumbs))
+			copy(breadcrumbs, event.Breadcrumbs)
+		}
+		c := *b
+		c.Message, c.Data = message, data
+		breadcrumbs[i] = &c
+	}
+	if breadcrumbs != nil {
+		event.Breadcrumbs = breadcrumbs
+	}
+
+	if r := event.Request; r != nil {
+		c := *r
+		var scrubbed [6]bool
+		c.URL, scrubbed[0] = s.scrubString(r.URL)
+		c.QueryString, scrubbed[1] = s.scrubString(r.QueryString)
+		c.Data, scrubbed[2] = s.scrubString(r.Data)
+		c.Cookies, scrubbed[3] = s.scrubString(r.Cookies)
+		c.Headers, scrubbed[4] = s.scrubStringMap(r.Headers)
+		c.Env, scrubbed[5] = s.scrubStringMap(r.Env)
+		if scrubbed != [6]bool{} {
+			event.Request = &c
+		}
+	}
+
+	// Spans are still referenced by their transaction, and possibly by the
+	// caller, so scrubbed spans are replaced with copies.
+	var spans []*Span
+	for i, span := range event.Spans {
+		description, descriptionScrubbed := s.scrubString(span.Description)
+		data, dataScrubbed := s.scrubInterfaceMap(span.Data)
+		if !descriptionScrubbed && !dataScrubbed {
+			continue
+		}
+		if spans == nil {
+			spans = make([]*Span, len(event.Spans))
+			copy(spans, event.Spans)
+		}
+		c := span.serializedCopy()
+		c.Description, c.Data = description, data
+		spans[i] = c
+	}
+	if spans != nil {
+		event.Spans = spans
+	}
+}
+
+// scrubString returns v with the matches of the patterns replaced and whether
+// any matched.
+func (s scrubber) scrubString(v string) (string, bool) {
+	scrubbed := false
+	for _, pattern := range s {
+		if pattern.MatchString(v) {
+			v = pattern.ReplaceAllLiteralString(v, filteredValue)
+			scrubbed = true
+		}
+	}
+	return v, scrubbed
+}
+
+// scrubStringMap returns a copy of m with scrubbed values and true, or m itself
+// and false if no value matched.
+func (s scrubber) scrubStringMap(m map[string]string) (map[string]string, bool) {
+	var c map[string]string
+	for k, v := range m {
+		v, scrubbed := s.scrubString(v)
+		if !scrubbed {
+			continue
+		}
+		if c == nil {
+			c = make(map[string]string, len(m))
+			for k, v := range m {
+				c[k] = v
+			}
+		}
+		c[k] = v
+	}
+	if c == nil {
+		return m, false
+	}
+	return c, true
+}
+
+// scrubInterfaceMap is like scrubStringMap, walking nested values with
+// scrubValue.
+func (s scrubber) scrubInterfaceMap(m map[string]interface{}) (map[string]interface{}, bool) {
+	var c map[string]interface{}
+	for k, v := range m {
+		v, scrubbed := s.scrubValue(v)
+		if !scrubbed {
+			continue
+		}
+		if c == nil {
+			c = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				c[k] = v
+			}
+		}
+		c[k] = v
+	}
+	if c == nil {
+		return m, false
+	}
+	return c, true
+}
+
+// scrubValue scrubs strings, maps and slices, recursively. Values of other
+// types are scrubbed in their JSON representation, which replaces them if any
+// of their strings matched.
+func (s scrubber) scrubValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v, false
+	case string:
+		return s.scrubString(v)
+	case map[string]string:
+		return s.scrubStringMap(v)
+	case map[string]interface{}:
+		return s.scrubInterfaceMap(v)
+	case []interface{}:
+		var c []interface{}
+		for i, e := range v {
+			e, scrubbed := s.scrubValue(e)
+			if !scrubbed {
+				continue
+			}
+			if c == nil {
+				c = make([]interface{}, len(v))
+				copy(c, v)
+			}
+			c[i] = e
+		}
+		if c == nil {
+			return v, false
+		}
+		return c, true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v, false
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return v, false
+	}
+	scrubbed, ok := s.scrubValue(generic)
+	if !ok {
+		return v, false
+	}
+	return scrubbed, true
+}
+
+// scrubQueryStrings filters the query string parameter values of the request
+// and transaction name of event, see ClientOptions.ScrubQueryString. The
+// request may be shared with the scope, so it is copied before being modified,
+// and fields are only assigned if filtered.
+func scrubQueryStrings(event *Event) {
+	if transaction := scrubURLQuery(event.Transaction); transaction != event.Transaction {
+		event.Transaction = transaction
+	}
+	if r := event.Request; r != nil {
+		c := *r
+		c.QueryString = scrubQuery(r.QueryString)
+		c.URL = scrubURLQuery(r.URL)
+		if c.QueryString != r.QueryString || c.URL != r.URL {
+			event.Request = &c
+		}
+	}
+}
+
+// scrubURLQuery filters the query string of s, the part after the first "?",
+// if any. A fragment is kept as is.
+func scrubURLQuery(s string) string {
+	i := strings.IndexByte(s, '?')
+	if i < 0 {
+		return s
+	}
+	query, fragment := s[i+1:], ""
+	if j := strings.IndexByte(query, '#'); j >= 0 {
+		query, fragment = query[:j], query[j:]
+	}
+	return s[:i+1] + scrubQuery(query) + fragment
+}
+
+// scrubQuery replaces the non-empty values of the parameters of query with
+// filteredValue, keeping their names and order.
+func scrubQuery(query string) string {
+	if query == "" {
+		return query
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		if name, value, ok := strings.Cut(param, "="); ok && value != "" {
+			params[i] = name + "=" + filteredValue
+		}
+	}
+	return strings.Join(params, "&")
+}
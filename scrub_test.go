@@ -0,0 +1,103 @@
+package sentry
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDataScrubbers(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport:     transport,
+		DataScrubbers: []*regexp.Regexp{CreditCardNumberPattern, EmailAddressPattern},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := NewScope()
+	scope.SetExtra("card", map[string]interface{}{
+		"numbers": []interface{}{"4111 1111 1111 1111", 42},
+	})
+	scope.SetExtra("order", struct {
+		Email string `json:"email"`
+	}{"bob@example.com"})
+	scope.AddBreadcrumb(&Breadcrumb{Message: "mail sent to bob@example.com"}, 10)
+
+	event := NewEvent()
+	event.Message = "payment with 4111-1111-1111-1111 failed"
+	event.Request = &Request{Data: `{"email":"bob@example.com"}`, Method: "POST"}
+	event.Tags["kept"] = "order 1234"
+	client.CaptureEvent(event, nil, scope)
+
+	got := transport.lastEvent
+	assertEqual(t, got.Message, "payment with [Filtered] failed")
+	assertEqual(t, got.Tags["kept"], "order 1234")
+	assertEqual(t, got.Request.Data, `{"email":"[Filtered]"}`)
+	assertEqual(t, got.Breadcrumbs[0].Message, "mail sent to [Filtered]")
+	want := map[string]interface{}{
+		"card": map[string]interface{}{
+			"numbers": []interface{}{"[Filtered]", 42},
+		},
+		"order": map[string]interface{}{"email": "[Filtered]"},
+	}
+	if diff := cmp.Diff(want, got.Extra); diff != "" {
+		t.Errorf("Extra mismatch (-want +got):\n%s", diff)
+	}
+
+	// The scope is left untouched.
+	if b := scope.breadcrumbs[0]; b.Message != "mail sent to bob@example.com" {
+		t.Errorf("scope breadcrumb was scrubbed: %q", b.Message)
+	}
+}
+
+func TestDataScrubbersLeaveSharedValuesUntouched(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport:     transport,
+		DataScrubbers: []*regexp.Regexp{EmailAddressPattern},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := &Span{Description: "mail to bob@example.com", Data: map[string]interface{}{"to": "bob@example.com"}}
+	exceptions := []Exception{{Type: "error", Value: "invalid address bob@example.com"}}
+	event := NewEvent()
+	event.Message = "nothing to scrub"
+	event.Exception = exceptions
+	event.Spans = []*Span{span}
+	client.CaptureEvent(event, nil, nil)
+
+	got := transport.lastEvent
+	assertEqual(t, got.Exception[0].Value, "invalid address [Filtered]")
+	assertEqual(t, got.Spans[0].Description, "mail to [Filtered]")
+	assertEqual(t, got.Spans[0].Data["to"], "[Filtered]")
+	assertEqual(t, exceptions[0].Value, "invalid address bob@example.com")
+	assertEqual(t, span.Description, "mail to bob@example.com")
+	assertEqual(t, span.Data["to"], "bob@example.com")
+}
+
+func TestScrubQueryString(t *testing.T) {
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{Transport: transport, ScrubQueryString: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &Request{
+		URL:         "https://example.com/reset?token=abc123&lang=en#form",
+		QueryString: "token=abc123&email=bob%40example.com&debug&empty=",
+		Method:      "GET",
+	}
+	event := NewEvent()
+	event.Transaction = "GET /reset?token=abc123"
+	event.Request = request
+	client.CaptureEvent(event, nil, nil)
+
+	got := transport.lastEvent
+	assertEqual(t, got.Transaction, "GET /reset?token=[Filtered]")
+	assertEqual(t, got.Request.URL, "https://example.com/reset?token=[Filtered]&lang=[Filtered]#form")
+	assertEqual(t, got.Request.QueryString, "token=[Filtered]&email=[Filtered]&debug&empty=")
+	// The original request is left untouched.
+	assertEqual(t, request.QueryString, "token=abc123&email=bob%40example.com&debug&empty=")
+}
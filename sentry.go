@@ -2,6 +2,7 @@ package sentry
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -42,38 +43,189 @@ func AddBreadcrumb(breadcrumb *Breadcrumb) {
 	hub.AddBreadcrumb(breadcrumb, nil)
 }
 
+// AddErrorBreadcrumbs records a breadcrumb for err and each error it wraps,
+// see Hub.AddErrorBreadcrumbs.
+func AddErrorBreadcrumbs(err error) {
+	CurrentHub().AddErrorBreadcrumbs(err)
+}
+
 // CaptureMessage captures an arbitrary message.
+// It returns the EventID of the event, or nil if the event was not accepted.
 func CaptureMessage(message string) *EventID {
 	hub := CurrentHub()
 	return hub.CaptureMessage(message)
 }
 
 // CaptureException captures an error.
+// It returns the EventID of the event, or nil if the event was not accepted.
 func CaptureException(exception error) *EventID {
 	hub := CurrentHub()
 	return hub.CaptureException(exception)
 }
 
+// CaptureMessageWithContext captures an arbitrary message on the hub stored in
+// ctx, such as the hub of the request set by the HTTP integrations, or on the
+// current hub if ctx has none. The event is linked to the trace of the span
+// stored in ctx, if any.
+// It returns the EventID of the event, or nil if the event was not accepted.
+func CaptureMessageWithContext(ctx context.Context, message string) *EventID {
+	hub := hubFromContext(ctx)
+	var eventID *EventID
+	hub.withSpanFromContext(ctx, func() {
+		eventID = hub.CaptureMessage(message)
+	})
+	return eventID
+}
+
+// CaptureExceptionWithContext captures an error on the hub stored in ctx, such
+// as the hub of the request set by the HTTP integrations, or on the current
+// hub if ctx has none. The event is linked to the trace of the span stored in
+// ctx, if any:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		if err := process(r); err != nil {
+//			sentry.CaptureExceptionWithContext(r.Context(), err)
+//		}
+//	}
+//
+// It returns the EventID of the event, or nil if the event was not accepted.
+func CaptureExceptionWithContext(ctx context.Context, exception error) *EventID {
+	hub := hubFromContext(ctx)
+	var eventID *EventID
+	hub.withSpanFromContext(ctx, func() {
+		eventID = hub.CaptureException(exception)
+	})
+	return eventID
+}
+
+// CaptureUnhandledException captures an error marked as unhandled, which
+// Sentry counts as a crash, see Hub.CaptureUnhandledException.
+// It returns the EventID of the event, or nil if the event was not accepted.
+func CaptureUnhandledException(exception error) *EventID {
+	hub := CurrentHub()
+	return hub.CaptureUnhandledException(exception)
+}
+
+// CaptureExceptionWith captures an error with options applying to this capture
+// only, see Hub.CaptureExceptionWith.
+func CaptureExceptionWith(exception error, options ...CaptureOption) *EventID {
+	hub := CurrentHub()
+	return hub.CaptureExceptionWith(exception, options...)
+}
+
+// CaptureValidationError reports the invalid fields of an input as a single
+// warning event, grouped by endpoint and set of fields, for instance in an HTTP
+// handler opting in:
+//
+//	if err := validate(order); err != nil {
+//		sentry.GetHubFromContext(r.Context()).CaptureValidationError(&sentry.ValidationError{
+//			Endpoint: "POST /orders",
+//			Fields:   err.Fields,
+//		})
+//		http.Error(w, err.Error(), http.StatusBadRequest)
+//		return
+//	}
+//
+// See Hub.CaptureValidationError.
+func CaptureValidationError(err *ValidationError) *EventID {
+	hub := CurrentHub()
+	return hub.CaptureValidationError(err)
+}
+
+// CaptureExceptionAndFlush captures an error like CaptureException, then waits
+// for at most timeout until it is sent, like Flush. It returns true only if the
+// event was accepted and sent in time.
+//
+// Use it for errors reported right before the program exits:
+//
+//	if err := run(); err != nil {
+//		sentry.CaptureExceptionAndFlush(err, 2*time.Second)
+//		os.Exit(1)
+//	}
+func CaptureExceptionAndFlush(exception error, timeout time.Duration) bool {
+	hub := CurrentHub()
+	return hub.CaptureExceptionAndFlush(exception, timeout)
+}
+
 // CaptureEvent captures an event on the currently active client if any.
 //
 // The event must already be assembled. Typically code would instead use
 // the utility methods like CaptureException. The return value is the
 // event ID. In case Sentry is disabled or event was dropped, the return value will be nil.
+//
+// A Timestamp already set on the event is kept, see Client.CaptureEvent.
 func CaptureEvent(event *Event) *EventID {
 	hub := CurrentHub()
 	return hub.CaptureEvent(event)
 }
 
-// Recover captures a panic.
+// CaptureCheckIn sends a check-in of a scheduled job to Sentry Crons, along with
+// the configuration of its monitor unless monitorConfig is nil. It returns the
+// ID of the check-in, or nil if it was not sent.
+//
+//	id := sentry.CaptureCheckIn(&sentry.CheckIn{
+//		MonitorSlug: "nightly-report",
+//		Status:      sentry.CheckInStatusInProgress,
+//	}, &sentry.MonitorConfig{Schedule: sentry.CrontabSchedule("0 3 * * *")})
+//	err := generateReport()
+//	status := sentry.CheckInStatusOK
+//	if err != nil {
+//		status = sentry.CheckInStatusError
+//	}
+//	sentry.CaptureCheckIn(&sentry.CheckIn{ID: *id, MonitorSlug: "nightly-report", Status: status}, nil)
+//
+// See WithCheckIn for a shorter way to do the same.
+func CaptureCheckIn(checkIn *CheckIn, monitorConfig *MonitorConfig) *EventID {
+	hub := CurrentHub()
+	return hub.CaptureCheckIn(checkIn, monitorConfig)
+}
+
+// CaptureUserFeedback sends feedback from a user about an event, typically an
+// error the user just saw, such that the feedback shows up on the event in
+// Sentry:
+//
+//	sentry.CaptureUserFeedback(&sentry.UserFeedback{
+//		EventID:  eventID, // for instance from the sentryhttp.EventIDHeader
+//		Name:     "Jane",
+//		Email:    "jane@example.com",
+//		Comments: "I was trying to check out.",
+//	})
+func CaptureUserFeedback(feedback *UserFeedback) {
+	hub := CurrentHub()
+	hub.CaptureUserFeedback(feedback)
+}
+
+// Recover captures a panic with the current hub.
+// It returns the EventID of the event, or nil if there's no panic or the event
+// was not accepted.
+//
+// Recover must be deferred directly, in the goroutine where the panic happens,
+// as panics do not propagate across goroutines:
+//
+//	go func() {
+//		defer sentry.Recover()
+//		// ...
+//	}()
+//
+// If the ClientOptions.Repanic option is set, Recover flushes the captured
+// event and panics again with the same value.
 func Recover() *EventID {
 	if err := recover(); err != nil {
 		hub := CurrentHub()
-		return hub.Recover(err)
+		eventID := hub.Recover(err)
+		repanic(hub, err)
+		return eventID
 	}
 	return nil
 }
 
 // RecoverWithContext captures a panic and passes relevant context object.
+// It uses the hub stored in ctx, if any, or the current hub otherwise.
+// It returns the EventID of the event, or nil if there's no panic or the event
+// was not accepted.
+//
+// Like Recover, it must be deferred directly in the goroutine where the panic
+// happens, and panics again if the ClientOptions.Repanic option is set.
 func RecoverWithContext(ctx context.Context) *EventID {
 	if err := recover(); err != nil {
 		var hub *Hub
@@ -84,11 +236,75 @@ func RecoverWithContext(ctx context.Context) *EventID {
 			hub = CurrentHub()
 		}
 
-		return hub.RecoverWithContext(ctx, err)
+		eventID := hub.RecoverWithContext(ctx, err)
+		repanic(hub, err)
+		return eventID
 	}
 	return nil
 }
 
+// CaptureRecovered reports recovered, a value returned by the built-in recover,
+// as an event with the given level, or LevelFatal if level is empty. It uses the
+// hub stored in ctx, if any, or the current hub otherwise. Unlike Recover, it
+// need not be deferred directly and never panics again:
+//
+//	defer func() {
+//		if recovered := recover(); recovered != nil {
+//			sentry.CaptureRecovered(ctx, recovered, sentry.LevelError)
+//			http.Error(w, "rendering failed", http.StatusInternalServerError)
+//		}
+//	}()
+//	tmpl.Execute(w, data)
+//
+// It returns the EventID of the event, or nil if recovered is nil or the event
+// was not accepted.
+func CaptureRecovered(ctx context.Context, recovered interface{}, level Level) *EventID {
+	return hubFromContext(ctx).CaptureRecovered(ctx, recovered, level)
+}
+
+// GoWithHub wraps f, for work running concurrently to the code using hub, such
+// as the goroutines of an errgroup.Group fanning out the work of a request:
+//
+//	g, ctx := errgroup.WithContext(ctx)
+//	g.Go(sentry.GoWithHub(hub, func() error {
+//		return fetchOrders(ctx)
+//	}))
+//
+// A panic in f is recovered and reported with hub, or the current hub if hub
+// is nil, and turned into an error returned by the wrapped function, such
+// that the group fails instead of the program crashing. Errors returned by f
+// are returned as is, without being reported.
+func GoWithHub(hub *Hub, f func() error) func() error {
+	return func() (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				h := hub
+				if h == nil {
+					h = CurrentHub()
+				}
+				h.Recover(recovered)
+				err = fmt.Errorf("panic: %v", recovered)
+			}
+		}()
+		return f()
+	}
+}
+
+// repanicFlushTimeout is how long Recover and RecoverWithContext wait for the
+// recovered panic to be sent before panicking again.
+const repanicFlushTimeout = 2 * time.Second
+
+// repanic panics with err if the Repanic option of the client bound to hub is
+// set, after flushing buffered events.
+func repanic(hub *Hub, err interface{}) {
+	client := hub.Client()
+	if client == nil || !client.options.Repanic {
+		return
+	}
+	client.Flush(repanicFlushTimeout)
+	panic(err)
+}
+
 // WithScope is a shorthand for CurrentHub().WithScope.
 func WithScope(f func(scope *Scope)) {
 	hub := CurrentHub()
@@ -129,6 +345,29 @@ func Flush(timeout time.Duration) bool {
 	return hub.Flush(timeout)
 }
 
+// FlushWithContext works like Flush, but waits until ctx is done instead of a
+// fixed timeout. It returns true only if all buffered events were sent before
+// ctx was done.
+func FlushWithContext(ctx context.Context) bool {
+	hub := CurrentHub()
+	return hub.FlushWithContext(ctx)
+}
+
+// Close flushes any buffered events of the client bound to the current hub,
+// waiting until ctx is done, and then closes the client such that subsequent
+// events are dropped. It returns false if ctx was done before all events were
+// sent, or if there is no client.
+//
+// Close should be called before terminating a program that does not use the
+// SDK anymore, for example at the end of a graceful shutdown.
+func Close(ctx context.Context) bool {
+	client := CurrentHub().Client()
+	if client == nil {
+		return false
+	}
+	return client.Close(ctx)
+}
+
 // LastEventID returns an ID of last captured event.
 func LastEventID() EventID {
 	hub := CurrentHub()
@@ -0,0 +1,115 @@
+// Package sentrytest provides utilities for testing code that reports events
+// to Sentry.
+//
+// A Transport records events in memory instead of sending them over the
+// network, such that tests can make assertions about the reported events:
+//
+//	func TestHandler(t *testing.T) {
+//		hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+//		ctx := sentry.SetHubOnContext(context.Background(), hub)
+//
+//		handle(ctx) // code under test, reporting to the hub in ctx
+//
+//		events := transport.Events()
+//		if len(events) != 1 || events[0].Level != sentry.LevelError {
+//			t.Errorf("got events %v, want a single error", events)
+//		}
+//	}
+package sentrytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	sentry "github.com/getsentry/sentry-go"
+)
+
+// Transport is a sentry.Transport that records the events it is given, in
+// memory. It is safe for concurrent use.
+//
+// The zero value is ready to use.
+type Transport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+// Configure implements sentry.Transport. It does nothing.
+func (t *Transport) Configure(options sentry.ClientOptions) {}
+
+// SendEvent implements sentry.Transport. It records event.
+func (t *Transport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, event)
+}
+
+// Flush implements sentry.Transport. Events are recorded synchronously, so it
+// always returns true immediately.
+func (t *Transport) Flush(timeout time.Duration) bool {
+	return true
+}
+
+// FlushWithContext is like Flush. It always returns true immediately.
+func (t *Transport) FlushWithContext(ctx context.Context) bool {
+	return true
+}
+
+// Events returns the events recorded since the Transport was created or last
+// reset, in the order they were sent. Transactions are included, with Type
+// "transaction". Structured logs and check-ins are not events and are not
+// recorded.
+func (t *Transport) Events() []*sentry.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*sentry.Event(nil), t.events...)
+}
+
+// LastEvent returns the last recorded event, or nil if there is none.
+func (t *Transport) LastEvent() *sentry.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.events) == 0 {
+		return nil
+	}
+	return t.events[len(t.events)-1]
+}
+
+// Reset discards all recorded events.
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = nil
+}
+
+// NewClient returns a client created with options that records events in the
+// returned Transport. Any Dsn or Transport set in options is ignored. The test
+// fails immediately if the client cannot be created, for example because of
+// invalid options.
+func NewClient(tb testing.TB, options sentry.ClientOptions) (*sentry.Client, *Transport) {
+	tb.Helper()
+
+	transport := &Transport{}
+	options.Dsn = ""
+	options.Transport = transport
+	client, err := sentry.NewClient(options)
+	if err != nil {
+		tb.Fatalf("sentrytest: %v", err)
+	}
+	return client, transport
+}
+
+// NewHub is like NewClient, but returns a new hub bound to the client, with an
+// empty scope. Use sentry.SetHubOnContext to pass the hub to the code under
+// test.
+func NewHub(tb testing.TB, options sentry.ClientOptions) (*sentry.Hub, *Transport) {
+	tb.Helper()
+
+	client, transport := NewClient(tb, options)
+	return sentry.NewHub(client, sentry.NewScope()), transport
+}
@@ -0,0 +1,72 @@
+package sentrytest_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	sentry "github.com/getsentry/sentry-go"
+	"github.com/getsentry/sentry-go/sentrytest"
+)
+
+func TestTransport(t *testing.T) {
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	if transport.LastEvent() != nil {
+		t.Fatal("got an event before capturing any")
+	}
+
+	hub.ConfigureScope(func(scope *sentry.Scope) {
+		scope.SetTag("key", "value")
+		scope.SetFingerprint([]string{"fingerprint"})
+	})
+	hub.CaptureMessage("message")
+	id := hub.CaptureException(errors.New("error"))
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Message != "message" || events[0].Level != sentry.LevelInfo {
+		t.Errorf("got first event %q with level %q, want %q with level %q", events[0].Message, events[0].Level, "message", sentry.LevelInfo)
+	}
+	last := transport.LastEvent()
+	if last != events[1] || last.EventID != *id {
+		t.Errorf("LastEvent() = %v, want the captured exception", last)
+	}
+	if last.Tags["key"] != "value" || len(last.Fingerprint) != 1 || last.Fingerprint[0] != "fingerprint" {
+		t.Errorf("got tags %v and fingerprint %v, want the scope data", last.Tags, last.Fingerprint)
+	}
+
+	transport.Reset()
+	if got := transport.Events(); len(got) != 0 {
+		t.Errorf("got %d events after Reset, want 0", len(got))
+	}
+	// Events returned before Reset are left untouched.
+	if len(events) != 2 {
+		t.Errorf("got %d previously returned events after Reset, want 2", len(events))
+	}
+}
+
+func TestTransportConcurrent(t *testing.T) {
+	client, transport := sentrytest.NewClient(t, sentry.ClientOptions{})
+	const goroutineCount = 10
+
+	var wg sync.WaitGroup
+	wg.Add(goroutineCount)
+	for i := 0; i < goroutineCount; i++ {
+		go func() {
+			defer wg.Done()
+			client.CaptureMessage("message", nil, sentry.NewScope())
+			transport.Events()
+		}()
+	}
+	wg.Wait()
+
+	if !client.FlushWithContext(context.Background()) {
+		t.Error("FlushWithContext returned false")
+	}
+	if got := len(transport.Events()); got != goroutineCount {
+		t.Errorf("got %d events, want %d", got, goroutineCount)
+	}
+}
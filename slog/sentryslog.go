@@ -0,0 +1,243 @@
+//go:build go1.21
+
+// Package sentryslog provides a log/slog Handler that forwards log records to
+// Sentry.
+package sentryslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// ErrorKey is the attribute key holding the error of a log record. Records
+// with an error value under this key are sent with the error as exception.
+const ErrorKey = "error"
+
+// LoggerKey is the attribute key holding the name of the logger or subsystem
+// of a log record. Records with a string value under this key are sent with
+// it as the event logger, see Options.Logger.
+const LoggerKey = "logger"
+
+// Options configure a Handler.
+type Options struct {
+	// Hub is used to send records logged with a context that does not carry a
+	// hub, see sentry.GetHubFromContext. Defaults to sentry.CurrentHub().
+	Hub *sentry.Hub
+	// EventLevel is the minimum level of records sent as events. Defaults to
+	// slog.LevelError.
+	EventLevel slog.Leveler
+	// BreadcrumbLevel is the minimum level of records recorded as breadcrumbs,
+	// when below EventLevel. Defaults to slog.LevelInfo.
+	BreadcrumbLevel slog.Leveler
+	// TagKeys lists the top-level attributes sent as event tags instead of
+	// extra data. Values are converted to strings with fmt.Sprint.
+	TagKeys []string
+	// Logger is the logger name of events sent for records without a
+	// LoggerKey attribute. Defaults to none, in which case the logger set on
+	// the scope, if any, is used.
+	Logger string
+}
+
+// Handler is a slog.Handler that sends records at or above the event level as
+// Sentry events, and records records at or above the breadcrumb level as
+// breadcrumbs.
+//
+// Top-level attributes are sent as extra data, or tags, see Options.TagKeys.
+// Attributes in a group are sent as an event context named after the group,
+// nested groups as nested maps.
+type Handler struct {
+	opts    Options
+	tagKeys map[string]struct{}
+	// attrs holds the attributes added with WithAttrs, each with the groups
+	// that were open at the time.
+	attrs []groupedAttrs
+	// groups holds the groups opened with WithGroup.
+	groups []string
+}
+
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+var _ slog.Handler = (*Handler)(nil)
+
+// NewHandler returns a new Handler configured according to opts.
+func NewHandler(opts Options) *Handler {
+	if opts.EventLevel == nil {
+		opts.EventLevel = slog.LevelError
+	}
+	if opts.BreadcrumbLevel == nil {
+		opts.BreadcrumbLevel = slog.LevelInfo
+	}
+	tagKeys := make(map[string]struct{}, len(opts.TagKeys))
+	for _, k := range opts.TagKeys {
+		tagKeys[k] = struct{}{}
+	}
+	return &Handler{opts: opts, tagKeys: tagKeys}
+}
+
+// Enabled reports whether records of the given level are sent to Sentry, as
+// events or breadcrumbs.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.EventLevel.Level() || level >= h.opts.BreadcrumbLevel.Level()
+}
+
+// Handle sends r to Sentry, using the hub from ctx, if any.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = h.opts.Hub
+	}
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+
+	attrs := h.attributes(r)
+	if r.Level >= h.opts.EventLevel.Level() {
+		hub.CaptureEvent(h.event(hub, r, attrs))
+		return nil
+	}
+	if r.Level >= h.opts.BreadcrumbLevel.Level() {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category:  "log",
+			Level:     level(r.Level),
+			Message:   r.Message,
+			Data:      attrs,
+			Timestamp: r.Time,
+		}, nil)
+	}
+	return nil
+}
+
+// WithAttrs returns a new Handler that adds attrs to every record, in the
+// current group.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], groupedAttrs{
+		groups: h.groups,
+		attrs:  attrs,
+	})
+	return &h2
+}
+
+// WithGroup returns a new Handler that nests the attributes added later in a
+// group named name.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// attributes returns the attributes of the handler and of r as a tree of
+// maps.
+func (h *Handler) attributes(r slog.Record) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, ga := range h.attrs {
+		addAttrs(group(m, ga.groups), ga.attrs)
+	}
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+	addAttrs(group(m, h.groups), recordAttrs)
+	return m
+}
+
+// group returns the map of the nested group at path in m, creating it if
+// needed.
+func group(m map[string]interface{}, path []string) map[string]interface{} {
+	for _, name := range path {
+		g, ok := m[name].(map[string]interface{})
+		if !ok {
+			g = make(map[string]interface{})
+			m[name] = g
+		}
+		m = g
+	}
+	return m
+}
+
+// addAttrs adds attrs to m, following the rules of slog.Handler: empty
+// attributes are ignored, and groups with an empty key are inlined.
+func addAttrs(m map[string]interface{}, attrs []slog.Attr) {
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		if v.Kind() != slog.KindGroup {
+			if a.Key != "" {
+				m[a.Key] = v.Any()
+			}
+			continue
+		}
+		groupAttrs := v.Group()
+		if len(groupAttrs) == 0 {
+			continue
+		}
+		if a.Key == "" {
+			addAttrs(m, groupAttrs)
+			continue
+		}
+		addAttrs(group(m, []string{a.Key}), groupAttrs)
+	}
+}
+
+// event converts a record and its attributes into an event.
+func (h *Handler) event(hub *sentry.Hub, r slog.Record, attrs map[string]interface{}) *sentry.Event {
+	event := sentry.NewEvent()
+	event.Level = level(r.Level)
+	event.Message = r.Message
+	event.Timestamp = r.Time
+	event.Logger = h.opts.Logger
+	if logger, ok := attrs[LoggerKey].(string); ok {
+		delete(attrs, LoggerKey)
+		event.Logger = logger
+	}
+
+	if err, ok := attrs[ErrorKey].(error); ok {
+		delete(attrs, ErrorKey)
+		maxErrorDepth := 10
+		if client := hub.Client(); client != nil {
+			maxErrorDepth = client.Options().MaxErrorDepth
+		}
+		event.SetException(err, maxErrorDepth)
+	}
+
+	for k, v := range attrs {
+		if _, ok := h.tagKeys[k]; ok {
+			event.Tags[k] = fmt.Sprint(v)
+			continue
+		}
+		if g, ok := v.(map[string]interface{}); ok {
+			event.Contexts[k] = g
+			continue
+		}
+		event.Extra[k] = v
+	}
+	return event
+}
+
+// level maps slog levels to Sentry levels.
+func level(l slog.Level) sentry.Level {
+	switch {
+	case l < slog.LevelInfo:
+		return sentry.LevelDebug
+	case l < slog.LevelWarn:
+		return sentry.LevelInfo
+	case l < slog.LevelError:
+		return sentry.LevelWarning
+	case l < slog.LevelError+4:
+		return sentry.LevelError
+	default:
+		return sentry.LevelFatal
+	}
+}
@@ -0,0 +1,183 @@
+//go:build go1.21
+
+package sentryslog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/getsentry/sentry-go/sentrytest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerEvent(t *testing.T) {
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	logger := slog.New(NewHandler(Options{Hub: hub, TagKeys: []string{"component"}}))
+
+	logger.
+		With("component", "billing", "attempt", 3).
+		WithGroup("request").
+		With("method", "POST").
+		WithGroup("").
+		Error("payment failed",
+			slog.Group("user", "id", 42),
+			slog.Group("", "path", "/pay"),
+			slog.Group("empty"),
+			slog.Attr{},
+		)
+
+	if len(transport.Events()) != 1 {
+		t.Fatalf("got %d events, want 1", len(transport.Events()))
+	}
+	event := transport.Events()[0]
+	if event.Level != sentry.LevelError {
+		t.Errorf("Level = %q, want %q", event.Level, sentry.LevelError)
+	}
+	if event.Message != "payment failed" {
+		t.Errorf("Message = %q, want %q", event.Message, "payment failed")
+	}
+	if diff := cmp.Diff(map[string]string{"component": "billing"}, event.Tags); diff != "" {
+		t.Errorf("Tags mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"attempt": int64(3)}, event.Extra); diff != "" {
+		t.Errorf("Extra mismatch (-want +got):\n%s", diff)
+	}
+	wantRequest := sentry.Context{
+		"method": "POST",
+		"path":   "/pay",
+		"user":   map[string]interface{}{"id": int64(42)},
+	}
+	if diff := cmp.Diff(wantRequest, event.Contexts["request"]); diff != "" {
+		t.Errorf("request context mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandlerError(t *testing.T) {
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	logger := slog.New(NewHandler(Options{Hub: hub}))
+
+	err := errors.New("connection refused")
+	logger.Error("query failed", ErrorKey, err)
+
+	if len(transport.Events()) != 1 {
+		t.Fatalf("got %d events, want 1", len(transport.Events()))
+	}
+	event := transport.Events()[0]
+	if len(event.Exception) != 1 || event.Exception[0].Value != err.Error() {
+		t.Errorf("Exception = %+v, want %q", event.Exception, err)
+	}
+	if _, ok := event.Extra[ErrorKey]; ok {
+		t.Errorf("error also sent as extra data")
+	}
+}
+
+func TestHandlerLogger(t *testing.T) {
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	logger := slog.New(NewHandler(Options{Hub: hub, Logger: "app"}))
+
+	logger.Error("charge declined", LoggerKey, "payments")
+	logger.Error("query failed")
+	logger.Error("not a name", LoggerKey, 42)
+
+	if len(transport.Events()) != 3 {
+		t.Fatalf("got %d events, want 3", len(transport.Events()))
+	}
+	for i, want := range []string{"payments", "app", "app"} {
+		if got := transport.Events()[i].Logger; got != want {
+			t.Errorf("events[%d].Logger = %q, want %q", i, got, want)
+		}
+	}
+	if _, ok := transport.Events()[0].Extra[LoggerKey]; ok {
+		t.Errorf("logger also sent as extra data")
+	}
+	if diff := cmp.Diff(map[string]interface{}{LoggerKey: int64(42)}, transport.Events()[2].Extra); diff != "" {
+		t.Errorf("Extra mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandlerBreadcrumbs(t *testing.T) {
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	logger := slog.New(NewHandler(Options{
+		Hub:             hub,
+		EventLevel:      slog.LevelWarn,
+		BreadcrumbLevel: slog.LevelDebug,
+	}))
+
+	logger.Debug("cache miss", "key", "user:42")
+	logger.WithGroup("db").Info("query", "rows", 1)
+	if len(transport.Events()) != 0 {
+		t.Fatalf("got %d events, want 0", len(transport.Events()))
+	}
+	logger.Warn("slow request")
+
+	if len(transport.Events()) != 1 {
+		t.Fatalf("got %d events, want 1", len(transport.Events()))
+	}
+	event := transport.Events()[0]
+	if event.Level != sentry.LevelWarning {
+		t.Errorf("Level = %q, want %q", event.Level, sentry.LevelWarning)
+	}
+	var got []*sentry.Breadcrumb
+	for _, b := range event.Breadcrumbs {
+		got = append(got, &sentry.Breadcrumb{Category: b.Category, Level: b.Level, Message: b.Message, Data: b.Data})
+	}
+	want := []*sentry.Breadcrumb{
+		{Category: "log", Level: sentry.LevelDebug, Message: "cache miss", Data: map[string]interface{}{"key": "user:42"}},
+		{Category: "log", Level: sentry.LevelInfo, Message: "query", Data: map[string]interface{}{"db": map[string]interface{}{"rows": int64(1)}}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Breadcrumbs mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandlerHubFromContext(t *testing.T) {
+	defaultHub, defaultTransport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	hub, transport := sentrytest.NewHub(t, sentry.ClientOptions{})
+	logger := slog.New(NewHandler(Options{Hub: defaultHub}))
+
+	ctx := sentry.SetHubOnContext(context.Background(), hub)
+	logger.ErrorContext(ctx, "failed")
+
+	if len(transport.Events()) != 1 || len(defaultTransport.Events()) != 0 {
+		t.Errorf("got %d events with the context hub and %d with the default hub, want 1 and 0",
+			len(transport.Events()), len(defaultTransport.Events()))
+	}
+}
+
+func TestHandlerEnabled(t *testing.T) {
+	h := NewHandler(Options{})
+	tests := []struct {
+		level slog.Level
+		want  bool
+	}{
+		{slog.LevelDebug, false},
+		{slog.LevelInfo, true},
+		{slog.LevelError, true},
+	}
+	for _, tt := range tests {
+		if got := h.Enabled(context.Background(), tt.level); got != tt.want {
+			t.Errorf("Enabled(%s) = %t, want %t", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  sentry.Level
+	}{
+		{slog.LevelDebug, sentry.LevelDebug},
+		{slog.LevelInfo, sentry.LevelInfo},
+		{slog.LevelWarn, sentry.LevelWarning},
+		{slog.LevelError, sentry.LevelError},
+		{slog.LevelError + 4, sentry.LevelFatal},
+	}
+	for _, tt := range tests {
+		if got := level(tt.level); got != tt.want {
+			t.Errorf("level(%s) = %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
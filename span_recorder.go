@@ -10,13 +10,18 @@ type spanRecorder struct {
 	mu           sync.Mutex
 	spans        []*Span
 	overflowOnce sync.Once
+	// dropped counts the spans not stored because of ClientOptions.MaxSpans.
+	dropped int
 }
 
 // record stores a span. The first stored span is assumed to be the root of a
 // span tree.
+//
+// Spans are dropped once the limit set by ClientOptions.MaxSpans of the client
+// bound to the hub of the span is reached.
 func (r *spanRecorder) record(s *Span) {
 	maxSpans := defaultMaxSpans
-	if client := CurrentHub().Client(); client != nil {
+	if client := hubFromContext(s.Context()).Client(); client != nil && client.options.MaxSpans > 0 {
 		maxSpans = client.options.MaxSpans
 	}
 	r.mu.Lock()
@@ -27,8 +32,7 @@ func (r *spanRecorder) record(s *Span) {
 			Logger.Printf("Too many spans: dropping spans from transaction with TraceID=%s SpanID=%s limit=%d",
 				root.TraceID, root.SpanID, maxSpans)
 		})
-		// TODO(tracing): mark the transaction event in some way to
-		// communicate that spans were dropped.
+		r.dropped++
 		return
 	}
 	r.spans = append(r.spans, s)
@@ -54,3 +58,11 @@ func (r *spanRecorder) children() []*Span {
 	}
 	return r.spans[1:]
 }
+
+// droppedSpans returns the number of spans dropped because of
+// ClientOptions.MaxSpans.
+func (r *spanRecorder) droppedSpans() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
@@ -61,3 +61,29 @@ func Test_spanRecorder_record(t *testing.T) {
 		})
 	}
 }
+
+func TestMaxSpansDropsSpans(t *testing.T) {
+	transport := &TransportMock{}
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		MaxSpans:         3,
+		Transport:        transport,
+	})
+	transaction := StartSpan(ctx, "top", WithTransactionName("Test Transaction"))
+	transaction.SetTag("key", "value")
+	for i := 0; i < 5; i++ {
+		transaction.StartChild(fmt.Sprintf("child %d", i)).Finish()
+	}
+	transaction.Finish()
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("sent %d events, want 1", len(events))
+	}
+	// The limit includes the transaction itself.
+	assertEqual(t, len(events[0].Spans), 2)
+	assertEqual(t, events[0].Tags, map[string]string{"key": "value", "spans_dropped": "3"})
+	// The tags of the transaction are left untouched.
+	assertEqual(t, transaction.Tags, map[string]string{"key": "value"})
+}
@@ -0,0 +1,197 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultSpotlightURL is the URL of the Spotlight sidecar run locally with its
+// default settings.
+const defaultSpotlightURL = "http://localhost:8969/stream"
+
+// spotlightTimeout is the timeout of requests to the Spotlight sidecar.
+const spotlightTimeout = 5 * time.Second
+
+// spotlightBufferSize is the maximum number of envelopes waiting to be sent to
+// Spotlight. Envelopes are dropped when the buffer is full.
+const spotlightBufferSize = defaultBufferSize
+
+// spotlightTransport sends events with an inner transport and mirrors them to
+// a Spotlight sidecar, see ClientOptions.EnableSpotlight. Failures to reach
+// Spotlight are logged and never affect the inner transport.
+type spotlightTransport struct {
+	inner  Transport
+	url    string
+	dsn    *Dsn
+	client *http.Client
+
+	// queue holds the envelopes waiting to be sent to Spotlight by a single
+	// worker goroutine, along with the markers queued by Flush.
+	queue     chan spotlightItem
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// spotlightItem is an envelope to send to Spotlight, or a marker queued by
+// Flush if envelope is nil. The worker closes flushed when it reaches the
+// marker, once the envelopes queued before it were sent.
+type spotlightItem struct {
+	envelope *bytes.Buffer
+	flushed  chan struct{}
+}
+
+func newSpotlightTransport(inner Transport, dsn *Dsn, url string) *spotlightTransport {
+	if url == "" {
+		url = defaultSpotlightURL
+	}
+	t := &spotlightTransport{
+		inner:  inner,
+		url:    url,
+		dsn:    dsn,
+		client: &http.Client{Timeout: spotlightTimeout},
+		queue:  make(chan spotlightItem, spotlightBufferSize),
+		done:   make(chan struct{}),
+	}
+	go t.worker()
+	return t
+}
+
+// Configure configures the inner transport.
+func (t *spotlightTransport) Configure(options ClientOptions) {
+	t.inner.Configure(options)
+}
+
+func (t *spotlightTransport) setClientDiscardedEvents(d *discardedEvents) {
+	if source, ok := t.inner.(clientReportSource); ok {
+		source.setClientDiscardedEvents(d)
+	}
+}
+
+// SendEvent sends the event with the inner transport and to Spotlight, in the
+// background.
+func (t *spotlightTransport) SendEvent(event *Event) {
+	// The envelope is encoded before the inner transport gets the event, as
+	// it may trim the event.
+	var envelope *bytes.Buffer
+	if body := getRequestBodyFromEvent(event); body != nil {
+		var err error
+		envelope, err = envelopeFromBody(event, t.dsn, time.Now(), body)
+		if err != nil {
+			Logger.Printf("Could not encode event %s for Spotlight: %v", event.EventID, err)
+		}
+	}
+
+	t.inner.SendEvent(event)
+
+	if envelope != nil {
+		t.mirror(envelope)
+	}
+}
+
+// sendItem implements itemTransport, sending item with the inner transport and
+// to Spotlight, in the background.
+func (t *spotlightTransport) sendItem(item *envelopeItem) {
+	sendItem(t.inner, item)
+
+	envelope, err := envelopeFromItem(item, t.dsn, time.Now())
+	if err != nil {
+		Logger.Printf("Could not encode %s %s for Spotlight: %v", item.description, item.eventID, err)
+		return
+	}
+	t.mirror(envelope)
+}
+
+// mirror queues envelope to be sent to Spotlight in the background. It drops
+// envelope if the buffer is full or the transport is closed.
+func (t *spotlightTransport) mirror(envelope *bytes.Buffer) {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	select {
+	case t.queue <- spotlightItem{envelope: envelope}:
+	default:
+		Logger.Println("Event dropped for Spotlight due to its buffer being full.")
+	}
+}
+
+// worker sends the queued envelopes to Spotlight, one at a time, until the
+// transport is closed.
+func (t *spotlightTransport) worker() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case item := <-t.queue:
+			if item.envelope == nil {
+				close(item.flushed)
+				continue
+			}
+			t.send(item.envelope)
+		}
+	}
+}
+
+// send posts envelope to Spotlight.
+func (t *spotlightTransport) send(envelope *bytes.Buffer) {
+	request, err := http.NewRequest(http.MethodPost, t.url, envelope)
+	if err != nil {
+		Logger.Printf("Could not send event to Spotlight: %v", err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/x-sentry-envelope")
+	request.Header.Set("User-Agent", userAgent)
+	response, err := t.client.Do(request)
+	if err != nil {
+		Logger.Printf("Could not send event to Spotlight: %v", err)
+		return
+	}
+	_, _ = io.CopyN(io.Discard, response.Body, maxDrainResponseBytes)
+	response.Body.Close()
+}
+
+// Flush flushes the inner transport and waits for the envelopes queued for
+// Spotlight to be sent, blocking for at most the given timeout. Its result only
+// reflects the inner transport.
+func (t *spotlightTransport) Flush(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return t.FlushWithContext(ctx)
+}
+
+// FlushWithContext works like Flush, but waits until ctx is done instead of a
+// fixed timeout.
+func (t *spotlightTransport) FlushWithContext(ctx context.Context) bool {
+	ok := flushTransport(ctx, t.inner)
+
+	flushed := make(chan struct{})
+	select {
+	case t.queue <- spotlightItem{flushed: flushed}:
+	case <-ctx.Done():
+		return ok
+	case <-t.done:
+		return ok
+	}
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+	case <-t.done:
+	}
+	return ok
+}
+
+// Close stops sending envelopes to Spotlight and closes the inner transport,
+// if it has a Close method.
+func (t *spotlightTransport) Close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+	if ct, ok := t.inner.(interface{ Close() }); ok {
+		ct.Close()
+	}
+}
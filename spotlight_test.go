@@ -0,0 +1,155 @@
+package sentry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpotlight(t *testing.T) {
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Type"); got != "application/x-sentry-envelope" {
+			t.Errorf("got Content-Type %q, want an envelope", got)
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		received <- string(b)
+	}))
+	defer srv.Close()
+
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport:       transport,
+		EnableSpotlight: true,
+		SpotlightURL:    srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := client.CaptureMessage("spotlight", nil, nil)
+	if !client.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+
+	if len(transport.Events()) != 1 {
+		t.Errorf("got %d events sent with the transport, want 1", len(transport.Events()))
+	}
+	select {
+	case envelope := <-received:
+		lines := strings.Split(envelope, "\n")
+		if !strings.Contains(lines[0], string(*id)) || strings.Contains(lines[0], `"dsn"`) {
+			t.Errorf("got envelope header %s, want the event ID and no DSN", lines[0])
+		}
+		if !strings.Contains(envelope, `"message":"spotlight"`) {
+			t.Errorf("got envelope %s, want the event", envelope)
+		}
+	default:
+		t.Error("Spotlight did not receive the event before Flush returned")
+	}
+}
+
+func TestSpotlightUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	transport := &TransportMock{}
+	client, err := NewClient(ClientOptions{
+		Transport:       transport,
+		EnableSpotlight: true,
+		SpotlightURL:    url,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.CaptureMessage("spotlight", nil, nil)
+	if !client.Flush(time.Second) {
+		t.Error("Flush = false, want true as the transport delivered the event")
+	}
+	if len(transport.Events()) != 1 {
+		t.Errorf("got %d events sent with the transport, want 1", len(transport.Events()))
+	}
+}
+
+func TestSpotlightWithoutDsn(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(ClientOptions{
+		EnableSpotlight: true,
+		SpotlightURL:    srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.CaptureMessage("spotlight", nil, nil) == nil {
+		t.Fatal("CaptureMessage = nil, want events to be captured for Spotlight")
+	}
+	client.Flush(time.Second)
+	select {
+	case <-received:
+	default:
+		t.Error("Spotlight did not receive the event")
+	}
+}
+
+func TestSpotlightBufferFull(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		mu.Lock()
+		received++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	tr := newSpotlightTransport(&TransportMock{}, nil, srv.URL)
+	defer tr.Close()
+
+	// The first event blocks the worker, the next ones fill the buffer and
+	// the last one is dropped.
+	tr.SendEvent(&Event{})
+	<-started
+	for i := 0; i < spotlightBufferSize+1; i++ {
+		tr.SendEvent(&Event{})
+	}
+	close(release)
+	tr.Flush(time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := spotlightBufferSize + 1; received != want {
+		t.Errorf("got %d envelopes, want %d", received, want)
+	}
+}
+
+func TestSpotlightClosed(t *testing.T) {
+	tr := newSpotlightTransport(&TransportMock{}, nil, "http://localhost:0")
+	tr.Close()
+	tr.SendEvent(&Event{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tr.FlushWithContext(ctx)
+	if ctx.Err() != nil {
+		t.Error("FlushWithContext blocked after Close")
+	}
+}
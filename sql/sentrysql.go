@@ -0,0 +1,332 @@
+// Package sentrysql provides Sentry tracing for database/sql drivers.
+//
+// Queries and statements executed with a context holding a span, for example
+// the transaction started by one of the HTTP integrations, are recorded as
+// child spans with the "db.sql.query" operation and the SQL statement as
+// description. Query arguments are never recorded.
+//
+// Queries executed without a context, as with DB.Query and DB.Exec, run with
+// context.Background() and are therefore not traced.
+package sentrysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Open opens a database like sql.Open, wrapping the driver registered as
+// driverName to trace queries.
+func Open(driverName, dataSourceName string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	_ = db.Close()
+
+	if dc, ok := d.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(NewConnector(connector)), nil
+	}
+	return sql.OpenDB(dsnConnector{name: dataSourceName, driver: WrapDriver(d)}), nil
+}
+
+// NewConnector returns a connector opening connections with c, tracing their
+// queries. Use it with sql.OpenDB.
+func NewConnector(c driver.Connector) driver.Connector {
+	return &connector{Connector: c}
+}
+
+// WrapDriver returns a driver opening connections with d, tracing their
+// queries. Use it with sql.Register.
+func WrapDriver(d driver.Driver) driver.Driver {
+	return &wrappedDriver{Driver: d}
+}
+
+type wrappedDriver struct {
+	driver.Driver
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c}, nil
+}
+
+type connector struct {
+	driver.Connector
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return WrapDriver(c.Connector.Driver())
+}
+
+// dsnConnector is the connector used by database/sql for drivers that do not
+// implement driver.DriverContext.
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// conn traces the queries of a driver.Conn. It implements the optional
+// interfaces of database/sql/driver, delegating to the wrapped connection when
+// it implements them, and otherwise falling back to what database/sql does
+// when they are missing.
+type conn struct {
+	driver.Conn
+}
+
+var (
+	_ driver.Connector          = (*connector)(nil)
+	_ driver.Connector          = dsnConnector{}
+	_ driver.Driver             = (*wrappedDriver)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+	_ driver.StmtExecContext    = (*stmt)(nil)
+	_ driver.StmtQueryContext   = (*stmt)(nil)
+	_ driver.NamedValueChecker  = (*stmt)(nil)
+)
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	var err error
+	start := time.Now()
+	switch execer := c.Conn.(type) {
+	case driver.ExecerContext:
+		result, err = execer.ExecContext(ctx, query, args)
+	case driver.Execer: //nolint:staticcheck // Support legacy drivers.
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err != nil {
+			return nil, err
+		}
+		result, err = execer.Exec(query, values)
+	default:
+		return nil, driver.ErrSkip
+	}
+	recordQuery(ctx, query, start, result, err)
+	return result, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	start := time.Now()
+	switch queryer := c.Conn.(type) {
+	case driver.QueryerContext:
+		rows, err = queryer.QueryContext(ctx, query, args)
+	case driver.Queryer: //nolint:staticcheck // Support legacy drivers.
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err != nil {
+			return nil, err
+		}
+		rows, err = queryer.Query(query, values)
+	default:
+		return nil, driver.ErrSkip
+	}
+	recordQuery(ctx, query, start, nil, err)
+	return rows, err
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = preparer.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: s, conn: c.Conn, query: query}, nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("sentrysql: driver does not support non-default transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck // Support legacy drivers.
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// stmt traces the executions of a prepared statement.
+//
+// Statements implementing the deprecated driver.ColumnConverter interface
+// have their arguments converted by the default database/sql rules instead.
+type stmt struct {
+	driver.Stmt
+	conn  driver.Conn
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	var err error
+	start := time.Now()
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err != nil {
+			return nil, err
+		}
+		result, err = s.Stmt.Exec(values) //nolint:staticcheck // Support legacy drivers.
+	}
+	recordQuery(ctx, s.query, start, result, err)
+	return result, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	start := time.Now()
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err != nil {
+			return nil, err
+		}
+		rows, err = s.Stmt.Query(values) //nolint:staticcheck // Support legacy drivers.
+	}
+	recordQuery(ctx, s.query, start, nil, err)
+	return rows, err
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+// CheckNamedValue checks arguments with the statement or, like database/sql
+// does, with its connection.
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// recordQuery records a query that started at start and returned result and
+// err as a child span of the span in ctx, if any.
+//
+// The span is only started after the query has returned, because drivers may
+// return driver.ErrSkip to have database/sql prepare and execute the query
+// as a statement instead, which is recorded separately.
+func recordQuery(ctx context.Context, query string, start time.Time, result driver.Result, err error) {
+	if errors.Is(err, driver.ErrSkip) || sentry.TransactionFromContext(ctx) == nil {
+		return
+	}
+	span := sentry.StartSpan(ctx, "db.sql.query", sentry.WithDescription(query))
+	span.StartTime = start
+
+	switch {
+	case err == nil:
+		span.Status = sentry.SpanStatusOK
+	case errors.Is(err, context.Canceled):
+		span.Status = sentry.SpanStatusCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		span.Status = sentry.SpanStatusDeadlineExceeded
+	default:
+		span.Status = sentry.SpanStatusInternalError
+		span.SetData("db.error", err.Error())
+	}
+	if result != nil {
+		if n, err := result.RowsAffected(); err == nil {
+			span.SetData("db.rows_affected", strconv.FormatInt(n, 10))
+		}
+	}
+	span.Finish()
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sentrysql: driver does not support the use of named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
@@ -0,0 +1,178 @@
+package sentrysql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	sentrysql "github.com/getsentry/sentry-go/sql"
+)
+
+var errQuery = errors.New("syntax error")
+
+// fakeConn is a legacy connection, only supporting prepared statements.
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+// fakeContextConn executes queries without arguments directly, and returns
+// driver.ErrSkip for queries with arguments, to have them prepared.
+type fakeContextConn struct {
+	fakeConn
+}
+
+func (fakeContextConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	return fakeStmt{query: query}.Exec(nil)
+}
+
+func (fakeContextConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	return fakeStmt{query: query}.Query(nil)
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query == "invalid" {
+		return nil, errQuery
+	}
+	return driver.RowsAffected(3), nil
+}
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.query == "invalid" {
+		return nil, errQuery
+	}
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return []string{"n"} }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+type fakeDriver struct {
+	conn driver.Conn
+}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) { return d.conn, nil }
+
+type transportMock struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *transportMock) Configure(sentry.ClientOptions) {}
+func (t *transportMock) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+func (t *transportMock) Flush(time.Duration) bool { return true }
+
+func TestTracing(t *testing.T) {
+	sql.Register("sentrysql-fake", fakeDriver{conn: fakeConn{}})
+	sql.Register("sentrysql-fake-context", fakeDriver{conn: fakeContextConn{}})
+
+	for _, driverName := range []string{"sentrysql-fake", "sentrysql-fake-context"} {
+		driverName := driverName
+		t.Run(driverName, func(t *testing.T) {
+			db, err := sentrysql.Open(driverName, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			testTracing(t, db)
+		})
+	}
+}
+
+func testTracing(t *testing.T, db *sql.DB) {
+	transport := &transportMock{}
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := sentry.NewHub(client, sentry.NewScope())
+	ctx := sentry.SetHubOnContext(context.Background(), hub)
+
+	// Queries without a span in their context are not traced.
+	if _, err := db.Exec("UPDATE users SET active = true"); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := sentry.StartTransaction(ctx, "queries")
+	ctx = tx.Context()
+	if _, err := db.ExecContext(ctx, "UPDATE users SET active = true"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE name = ?", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if _, err := db.ExecContext(ctx, "invalid"); !errors.Is(err, errQuery) {
+		t.Fatalf("got error %v, want %v", err, errQuery)
+	}
+	tx.Finish()
+
+	if len(transport.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(transport.events))
+	}
+	spans := transport.events[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	want := []struct {
+		description string
+		status      sentry.SpanStatus
+		data        map[string]interface{}
+	}{
+		{"UPDATE users SET active = true", sentry.SpanStatusOK, map[string]interface{}{"db.rows_affected": "3"}},
+		{"SELECT id FROM users WHERE name = ?", sentry.SpanStatusOK, nil},
+		{"invalid", sentry.SpanStatusInternalError, map[string]interface{}{"db.error": errQuery.Error()}},
+	}
+	for i, span := range spans {
+		if span.Op != "db.sql.query" {
+			t.Errorf("span %d: op = %q, want %q", i, span.Op, "db.sql.query")
+		}
+		if span.Description != want[i].description {
+			t.Errorf("span %d: description = %q, want %q", i, span.Description, want[i].description)
+		}
+		if span.Status != want[i].status {
+			t.Errorf("span %d: status = %v, want %v", i, span.Status, want[i].status)
+		}
+		if len(span.Data) != len(want[i].data) {
+			t.Errorf("span %d: data = %v, want %v", i, span.Data, want[i].data)
+		}
+		for k, v := range want[i].data {
+			if span.Data[k] != v {
+				t.Errorf("span %d: data = %v, want %v", i, span.Data, want[i].data)
+			}
+		}
+		if span.EndTime.Before(span.StartTime) {
+			t.Errorf("span %d: ends before it starts", i)
+		}
+	}
+}
@@ -41,6 +41,41 @@ func NewStacktrace() *Stacktrace {
 	return &stacktrace
 }
 
+// panicStacktrace creates a stacktrace like NewStacktrace, but when called while
+// a panic is being recovered, the stacktrace ends at the function that
+// panicked. The frames of the deferred function that recovered and of the
+// runtime's panic handling are left out, as they do not tell where the panic
+// came from.
+func panicStacktrace() *Stacktrace {
+	pcs := make([]uintptr, 100)
+	n := runtime.Callers(1, pcs)
+
+	if n == 0 {
+		return nil
+	}
+
+	runtimeFrames := extractFrames(pcs[:n])
+	// Frames are ordered from the outermost call, so the last call to gopanic
+	// is the panic being recovered if panics are nested.
+	for i := len(runtimeFrames) - 1; i >= 0; i-- {
+		if runtimeFrames[i].Function == "runtime.gopanic" {
+			runtimeFrames = runtimeFrames[:i]
+			break
+		}
+	}
+
+	return &Stacktrace{
+		Frames: createFrames(runtimeFrames),
+	}
+}
+
+// goroutineDump returns the stacks of all goroutines, formatted by
+// runtime.Stack, cut after max bytes.
+func goroutineDump(max int) []byte {
+	buf := make([]byte, max)
+	return buf[:runtime.Stack(buf, true)]
+}
+
 // TODO: Make it configurable so that anyone can provide their own implementation?
 // Use of reflection allows us to not have a hard dependency on any given
 // package, so we don't have to import it.
@@ -97,12 +132,26 @@ func extractReflectedStacktraceMethod(err error) reflect.Value {
 		}
 	}
 
+	// Errors keeping the program counters of their origin, following the
+	// convention of github.com/juju/errors and similar packages:
+	// Callers() []uintptr.
+	methodCallers := errValue.MethodByName("Callers")
+	if methodCallers.IsValid() {
+		return methodCallers
+	}
+
 	return reflect.Value{}
 }
 
 func extractPcs(method reflect.Value) []uintptr {
 	var pcs []uintptr
 
+	// Methods with arguments or several results do not follow any of the
+	// known conventions.
+	if method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil
+	}
+
 	stacktrace := method.Call(nil)[0]
 
 	if stacktrace.Kind() != reflect.Slice {
@@ -165,16 +214,22 @@ type Frame struct {
 	Symbol   string `json:"symbol,omitempty"`
 	// Module is, despite the name, the Sentry protocol equivalent of a Go
 	// package's import path.
-	Module      string                 `json:"module,omitempty"`
-	Filename    string                 `json:"filename,omitempty"`
-	AbsPath     string                 `json:"abs_path,omitempty"`
-	Lineno      int                    `json:"lineno,omitempty"`
-	Colno       int                    `json:"colno,omitempty"`
-	PreContext  []string               `json:"pre_context,omitempty"`
-	ContextLine string                 `json:"context_line,omitempty"`
-	PostContext []string               `json:"post_context,omitempty"`
-	InApp       bool                   `json:"in_app"`
-	Vars        map[string]interface{} `json:"vars,omitempty"`
+	Module      string   `json:"module,omitempty"`
+	Filename    string   `json:"filename,omitempty"`
+	AbsPath     string   `json:"abs_path,omitempty"`
+	Lineno      int      `json:"lineno,omitempty"`
+	Colno       int      `json:"colno,omitempty"`
+	PreContext  []string `json:"pre_context,omitempty"`
+	ContextLine string   `json:"context_line,omitempty"`
+	PostContext []string `json:"post_context,omitempty"`
+	InApp       bool     `json:"in_app"`
+	// Vars holds the values of the local variables of the frame. The SDK
+	// never sets it: Go has no runtime mechanism to read the local variables
+	// or arguments of the functions on a stack, short of parsing DWARF
+	// debugging information against register and stack memory, which is
+	// neither portable nor safe in a running program. Event processors can
+	// set it for values known to the application.
+	Vars map[string]interface{} `json:"vars,omitempty"`
 	// Package and the below are not used for Go stack trace frames.  In
 	// other platforms it refers to a container where the Module can be
 	// found.  For example, a Java JAR, a .NET Assembly, or a native
@@ -156,6 +156,19 @@ func TestExtractXErrorsPC(t *testing.T) {
 	}
 }
 
+// unrelatedStackTraceError has a StackTrace method that does not follow any
+// of the known conventions.
+type unrelatedStackTraceError struct{}
+
+func (unrelatedStackTraceError) Error() string                 { return "unrelated" }
+func (unrelatedStackTraceError) StackTrace(depth int) []string { return nil }
+
+func TestExtractStacktraceUnrelatedMethod(t *testing.T) {
+	if got := ExtractStacktrace(unrelatedStackTraceError{}); got != nil {
+		t.Errorf("got stacktrace %v for an unrelated StackTrace method, want nil", got)
+	}
+}
+
 func TestEventWithExceptionStacktraceMarshalJSON(t *testing.T) {
 	event := NewEvent()
 	event.Exception = []Exception{
@@ -0,0 +1,48 @@
+package sentry
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// A tokenBucket allows up to rate events per second on average, in bursts of
+// at most rate events, or a single event for rates below 1. It backs
+// ClientOptions.MaxTransactionsPerSecond.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+// newTokenBucket returns a full bucket refilled with rate tokens per second.
+// It holds at least one token, such that rates below 1 allow an event every
+// 1/rate seconds.
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := math.Max(rate, 1)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now(), now: time.Now}
+}
+
+// allow takes a token from the bucket, if any, and reports whether it did.
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -3,11 +3,12 @@ package sentry
 // Checks whether the transaction should be profiled (according to ProfilesSampleRate)
 // and starts a profiler if so.
 func (span *Span) sampleTransactionProfile() {
-	var sampleRate = span.clientOptions().ProfilesSampleRate
+	options := span.clientOptions()
+	var sampleRate = options.ProfilesSampleRate
 	switch {
 	case sampleRate < 0.0 || sampleRate > 1.0:
 		Logger.Printf("Skipping transaction profiling: ProfilesSampleRate out of range [0.0, 1.0]: %f", sampleRate)
-	case sampleRate == 0.0 || rng.Float64() >= sampleRate:
+	case sampleRate == 0.0 || !options.sample(sampleRate):
 		Logger.Printf("Skipping transaction profiling: ProfilesSampleRate is: %f", sampleRate)
 	default:
 		span.profiler = &_transactionProfiler{
@@ -1,13 +1,19 @@
 package sentry
 
+import "net/http"
+
 // A SamplingContext is passed to a TracesSampler to determine a sampling
 // decision.
-//
-// TODO(tracing): possibly expand SamplingContext to include custom /
-// user-provided data.
 type SamplingContext struct {
 	Span   *Span // The current span, always non-nil.
 	Parent *Span // The parent span, may be nil.
+	// Request is the HTTP request the span was started for, with the
+	// ContinueFromRequest option, as done by the HTTP integrations. It may
+	// be nil.
+	Request *http.Request
+	// Custom holds the data passed with the WithCustomSamplingContext option,
+	// for instance the route of a request. It may be nil.
+	Custom map[string]interface{}
 }
 
 // The TracesSample type is an adapter to allow the use of ordinary
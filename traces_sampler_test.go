@@ -2,6 +2,8 @@ package sentry
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 )
@@ -58,3 +60,26 @@ func repeatedSample(sampler TracesSampler, ctx SamplingContext, count int) (obse
 	}
 	return n / float64(count)
 }
+
+func TestSamplingContextRequestAndCustom(t *testing.T) {
+	var got SamplingContext
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing: true,
+		TracesSampler: func(ctx SamplingContext) float64 {
+			got = ctx
+			return 1
+		},
+	})
+	r := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+
+	StartTransaction(ctx, "GET /checkout",
+		ContinueFromRequest(r),
+		WithCustomSamplingContext(map[string]interface{}{"a": 1}),
+		WithCustomSamplingContext(map[string]interface{}{"b": 2}),
+	)
+
+	if got.Request != r {
+		t.Errorf("SamplingContext.Request = %v, want %v", got.Request, r)
+	}
+	assertEqual(t, got.Custom, map[string]interface{}{"a": 1, "b": 2})
+}
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -42,6 +44,10 @@ type Span struct { //nolint: maligned // prefer readability over optimal memory
 	mu sync.RWMutex
 	// sample rate the span was sampled with.
 	sampleRate float64
+	// sampleRand is the random value in [0.0, 1.0) the sampling decision of a
+	// transaction is based on, shared by all services of the trace through the
+	// sample_rand entry of the DynamicSamplingContext.
+	sampleRand float64
 	// ctx is the context where the span was started. Always non-nil.
 	ctx context.Context
 	// Dynamic Sampling context
@@ -58,8 +64,14 @@ type Span struct { //nolint: maligned // prefer readability over optimal memory
 	recorder *spanRecorder
 	// span context, can only be set on transactions
 	contexts map[string]Context
+	// measurements of the transaction, only set on transactions.
+	measurements map[string]Measurement
 	// profiler instance if attached, nil otherwise.
 	profiler transactionProfiler
+	// request and customSamplingContext are passed to the TracesSampler in
+	// the SamplingContext.
+	request               *http.Request
+	customSamplingContext map[string]interface{}
 }
 
 // TraceParentContext describes the context of a (remote) parent span.
@@ -181,10 +193,15 @@ func StartSpan(ctx context.Context, operation string, options ...SpanOption) *Sp
 	span.recorder.record(&span)
 
 	hub := hubFromContext(ctx)
+	if client := hub.Client(); client != nil && client.disabled() {
+		// Nothing is sent, skip updating the scope.
+		return &span
+	}
 
 	// Update scope so that all events include a trace context, allowing
 	// Sentry to correlate errors to transactions/spans.
 	hub.Scope().SetContext("trace", span.traceContext().Map())
+	hub.Scope().setSpan(&span)
 
 	// Start profiling only if it's a sampled root transaction.
 	if span.IsTransaction() && span.Sampled.Bool() {
@@ -210,6 +227,12 @@ func (s *Span) Finish() {
 		s.EndTime = monotonicTimeSince(s.StartTime)
 	}
 
+	hub := hubFromContext(s.ctx)
+	if s.IsTransaction() {
+		// Events captured after the transaction finished no longer belong to it.
+		hub.Scope().clearSpan(s)
+	}
+
 	if !s.Sampled.Bool() {
 		return
 	}
@@ -223,7 +246,6 @@ func (s *Span) Finish() {
 	// TODO(tracing): add breadcrumbs
 	// (see https://github.com/getsentry/sentry-python/blob/f6f3525f8812f609/sentry_sdk/tracing.py#L372)
 
-	hub := hubFromContext(s.ctx)
 	hub.CaptureEvent(event)
 }
 
@@ -277,6 +299,27 @@ func (s *Span) SetContext(key string, value Context) {
 	s.contexts[key] = value
 }
 
+// SetMeasurement sets a measurement of the transaction containing the span,
+// sent as part of the transaction, for example:
+//
+//	span.SetMeasurement("cache.hits", 42, sentry.UnitNone)
+//
+// Setting a measurement with the same name again overrides its value.
+func (s *Span) SetMeasurement(name string, value float64, unit MeasurementUnit) {
+	t := s.GetTransaction()
+	if t == nil {
+		// The span was created manually, keep the measurement on it.
+		t = s
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.measurements == nil {
+		t.measurements = make(map[string]Measurement)
+	}
+	t.measurements[name] = Measurement{Value: value, Unit: unit}
+}
+
 // IsTransaction checks if the given span is a transaction.
 func (s *Span) IsTransaction() bool {
 	return s.isTransaction
@@ -315,8 +358,6 @@ func (s *Span) GetTransaction() *Span {
 // Use this function to propagate the TraceParentContext to a downstream SDK,
 // either as the value of the "sentry-trace" HTTP header, or as an html "sentry-trace" meta tag.
 func (s *Span) ToSentryTrace() string {
-	// TODO(tracing): add instrumentation for outgoing HTTP requests using
-	// ToSentryTrace.
 	var b strings.Builder
 	fmt.Fprintf(&b, "%s-%s", s.TraceID.Hex(), s.SpanID.Hex())
 	switch s.Sampled {
@@ -333,22 +374,33 @@ func (s *Span) ToSentryTrace() string {
 // either as the value of the "baggage" HTTP header, or as an html "baggage" meta tag.
 func (s *Span) ToBaggage() string {
 	if containingTransaction := s.GetTransaction(); containingTransaction != nil {
-		// In case there is currently no frozen DynamicSamplingContext attached to the transaction,
-		// create one from the properties of the transaction.
-		if !s.dynamicSamplingContext.IsFrozen() {
-			// This will return a frozen DynamicSamplingContext.
-			s.dynamicSamplingContext = DynamicSamplingContextFromTransaction(containingTransaction)
-		}
-
-		return containingTransaction.dynamicSamplingContext.String()
+		return containingTransaction.frozenDynamicSamplingContext().String()
 	}
 	return ""
 }
 
+// frozenDynamicSamplingContext returns the dynamic sampling context of the
+// transaction s, freezing it if it was not yet.
+func (s *Span) frozenDynamicSamplingContext() DynamicSamplingContext {
+	// In case there is currently no frozen DynamicSamplingContext attached to the transaction,
+	// create one from the properties of the transaction.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dynamicSamplingContext.IsFrozen() {
+		// This will return a frozen DynamicSamplingContext.
+		s.dynamicSamplingContext = DynamicSamplingContextFromTransaction(s)
+	}
+	return s.dynamicSamplingContext
+}
+
 // SetDynamicSamplingContext sets the given dynamic sampling context on the
 // current transaction.
 func (s *Span) SetDynamicSamplingContext(dsc DynamicSamplingContext) {
 	if s.isTransaction {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
 		s.dynamicSamplingContext = dsc
 	}
 }
@@ -415,6 +467,27 @@ func (s *Span) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// serializedCopy returns a new span with the fields of s serialized to JSON,
+// to be modified before sending without affecting s.
+func (s *Span) serializedCopy() *Span {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &Span{
+		TraceID:      s.TraceID,
+		SpanID:       s.SpanID,
+		ParentSpanID: s.ParentSpanID,
+		Name:         s.Name,
+		Op:           s.Op,
+		Description:  s.Description,
+		Status:       s.Status,
+		Tags:         s.Tags,
+		StartTime:    s.StartTime,
+		EndTime:      s.EndTime,
+		Data:         s.Data,
+	}
+}
+
 func (s *Span) clientOptions() *ClientOptions {
 	client := hubFromContext(s.ctx).Client()
 	if client != nil {
@@ -425,6 +498,9 @@ func (s *Span) clientOptions() *ClientOptions {
 
 func (s *Span) sample() Sampled {
 	clientOptions := s.clientOptions()
+	if s.isTransaction {
+		s.sampleRand = s.traceSampleRand(clientOptions)
+	}
 	// https://develop.sentry.dev/sdk/performance/#sampling
 	// #1 tracing is not enabled.
 	if !clientOptions.EnableTracing {
@@ -456,8 +532,10 @@ func (s *Span) sample() Sampled {
 	// #3 use TracesSampler from ClientOptions.
 	sampler := clientOptions.TracesSampler
 	samplingContext := SamplingContext{
-		Span:   s,
-		Parent: s.parent,
+		Span:    s,
+		Parent:  s.parent,
+		Request: s.request,
+		Custom:  s.customSamplingContext,
 	}
 
 	if sampler != nil {
@@ -472,7 +550,7 @@ func (s *Span) sample() Sampled {
 			return SampledFalse
 		}
 
-		if rng.Float64() < tracesSamplerSampleRate {
+		if s.sampleRand < tracesSamplerSampleRate {
 			return SampledTrue
 		}
 		Logger.Printf("Dropping transaction: TracesSampler returned rate: %f", tracesSamplerSampleRate)
@@ -502,13 +580,27 @@ func (s *Span) sample() Sampled {
 		return SampledFalse
 	}
 
-	if rng.Float64() < sampleRate {
+	if s.sampleRand < sampleRate {
 		return SampledTrue
 	}
 
 	return SampledFalse
 }
 
+// traceSampleRand returns the sample_rand of the incoming DynamicSamplingContext
+// if the transaction continues a trace that has a valid one, or a new random
+// value otherwise, such that all transactions of a trace are sampled
+// consistently.
+func (s *Span) traceSampleRand(clientOptions *ClientOptions) float64 {
+	if value, ok := s.dynamicSamplingContext.Entries["sample_rand"]; ok {
+		sampleRand, err := strconv.ParseFloat(value, 64)
+		if err == nil && sampleRand >= 0.0 && sampleRand < 1.0 {
+			return sampleRand
+		}
+	}
+	return clientOptions.random()
+}
+
 func (s *Span) toEvent() *Event {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -539,6 +631,24 @@ func (s *Span) toEvent() *Event {
 	}
 	contexts["trace"] = s.traceContext().Map()
 
+	var measurements map[string]Measurement
+	if len(s.measurements) > 0 {
+		measurements = make(map[string]Measurement, len(s.measurements))
+		for k, v := range s.measurements {
+			measurements[k] = v
+		}
+	}
+
+	// Let users know that spans were dropped, see ClientOptions.MaxSpans.
+	tags := s.Tags
+	if dropped := s.recorder.droppedSpans(); dropped > 0 {
+		tags = make(map[string]string, len(s.Tags)+1)
+		for k, v := range s.Tags {
+			tags[k] = v
+		}
+		tags[droppedSpansTag] = strconv.Itoa(dropped)
+	}
+
 	// Make sure that the transaction source is valid
 	transactionSource := s.Source
 	if !transactionSource.isValid() {
@@ -549,7 +659,7 @@ func (s *Span) toEvent() *Event {
 		Type:        transactionType,
 		Transaction: s.Name,
 		Contexts:    contexts,
-		Tags:        s.Tags,
+		Tags:        tags,
 		Extra:       s.Data,
 		Timestamp:   s.EndTime,
 		StartTime:   s.StartTime,
@@ -557,6 +667,7 @@ func (s *Span) toEvent() *Event {
 		TransactionInfo: &TransactionInfo{
 			Source: transactionSource,
 		},
+		Measurements: measurements,
 		sdkMetaData: SDKMetaData{
 			dsc: s.dynamicSamplingContext,
 		},
@@ -862,6 +973,13 @@ func WithOpName(name string) SpanOption {
 	}
 }
 
+// WithDescription sets the description of a span.
+func WithDescription(description string) SpanOption {
+	return func(s *Span) {
+		s.Description = description
+	}
+}
+
 // TransctionSource sets the source of the transaction name.
 //
 // Deprecated: Use WithTransactionSource() instead.
@@ -894,23 +1012,59 @@ func WithSpanSampled(sampled Sampled) SpanOption {
 	}
 }
 
+// WithStartTime sets the start time of a span, which otherwise is the time it
+// is started at. Together with setting Span.EndTime before calling Finish, it
+// allows reporting spans and transactions that happened in the past:
+//
+//	tx := sentry.StartTransaction(ctx, "import", sentry.WithStartTime(job.Started))
+//	tx.EndTime = job.Finished
+//	tx.Finish()
+func WithStartTime(startTime time.Time) SpanOption {
+	return func(s *Span) {
+		s.StartTime = startTime
+	}
+}
+
 // ContinueFromRequest returns a span option that updates the span to continue
 // an existing trace. If it cannot detect an existing trace in the request, the
 // span will be left unchanged.
 //
-// ContinueFromRequest is an alias for:
+// The request is also passed to the TracesSampler, as SamplingContext.Request.
+// Besides that, ContinueFromRequest is an alias for:
 //
 // ContinueFromHeaders(r.Header.Get(SentryTraceHeader), r.Header.Get(SentryBaggageHeader)).
 func ContinueFromRequest(r *http.Request) SpanOption {
-	return ContinueFromHeaders(r.Header.Get(SentryTraceHeader), r.Header.Get(SentryBaggageHeader))
+	continueFromHeaders := ContinueFromHeaders(r.Header.Get(SentryTraceHeader), r.Header.Get(SentryBaggageHeader))
+	return func(s *Span) {
+		s.request = r
+		continueFromHeaders(s)
+	}
+}
+
+// WithCustomSamplingContext returns a span option that passes data to the
+// TracesSampler, as SamplingContext.Custom. Data passed by several options is
+// merged.
+func WithCustomSamplingContext(data map[string]interface{}) SpanOption {
+	return func(s *Span) {
+		if s.customSamplingContext == nil {
+			s.customSamplingContext = make(map[string]interface{}, len(data))
+		}
+		for k, v := range data {
+			s.customSamplingContext[k] = v
+		}
+	}
 }
 
 // ContinueFromHeaders returns a span option that updates the span to continue
 // an existing TraceID and propagates the Dynamic Sampling context.
+//
+// If the trace header is empty or malformed, the span is left unchanged and
+// starts a new trace. In that case, the baggage is ignored as well, since it
+// describes a trace that is not being continued.
 func ContinueFromHeaders(trace, baggage string) SpanOption {
 	return func(s *Span) {
-		if trace != "" {
-			s.updateFromSentryTrace([]byte(trace))
+		if trace == "" || !s.updateFromSentryTrace([]byte(trace)) {
+			return
 		}
 		if baggage != "" {
 			s.updateFromBaggage([]byte(baggage))
@@ -918,7 +1072,7 @@ func ContinueFromHeaders(trace, baggage string) SpanOption {
 
 		// In case a sentry-trace header is present but there are no sentry-related
 		// values in the baggage, create an empty, frozen DynamicSamplingContext.
-		if trace != "" && !s.dynamicSamplingContext.HasEntries() {
+		if !s.dynamicSamplingContext.HasEntries() {
 			s.dynamicSamplingContext = DynamicSamplingContext{
 				Frozen: true,
 			}
@@ -942,6 +1096,8 @@ type spanContextKey struct{}
 
 // TransactionFromContext returns the root span of the current transaction. It
 // returns nil if no transaction is tracked in the context.
+//
+// See SpanFromContext to retrieve the innermost span instead.
 func TransactionFromContext(ctx context.Context) *Span {
 	if span, ok := ctx.Value(spanContextKey{}).(*Span); ok {
 		return span.recorder.root()
@@ -949,33 +1105,73 @@ func TransactionFromContext(ctx context.Context) *Span {
 	return nil
 }
 
-// spanFromContext returns the last span stored in the context or a dummy
-// non-nil span.
-//
-// TODO(tracing): consider exporting this. Without this, users cannot retrieve a
-// span from a context since spanContextKey is not exported.
-//
-// This can be added retroactively, and in the meantime think better whether it
-// should return nil (like GetHubFromContext), always non-nil (like
-// HubFromContext), or both: two exported functions.
+// SpanFromContext returns the last span stored in the context, the span of the
+// innermost StartSpan or StartChild call, or nil if no span is tracked in the
+// context. Use it to modify spans created in code you do not control, for
+// example by middleware or SDK integrations, or to read their trace ID.
 //
 // Note the equivalence:
 //
 //	SpanFromContext(ctx).StartChild(...) === StartSpan(ctx, ...)
 //
-// So we don't aim spanFromContext at creating spans, but mutating existing
-// spans that you'd have no access otherwise (because it was created in code you
-// do not control, for example SDK auto-instrumentation).
-//
-// For now we provide TransactionFromContext, which solves the more common case
-// of setting tags, etc, on the current transaction.
-func spanFromContext(ctx context.Context) *Span {
+// See TransactionFromContext to retrieve the transaction of the span instead.
+func SpanFromContext(ctx context.Context) *Span {
 	if span, ok := ctx.Value(spanContextKey{}).(*Span); ok {
 		return span
 	}
 	return nil
 }
 
+// TraceHeaders returns the values of the "sentry-trace" and "baggage" HTTP
+// headers that propagate the trace of the span stored in ctx to a downstream
+// service. Both values are empty if there is no span in ctx.
+//
+// See ToSentryTrace and ToBaggage.
+func TraceHeaders(ctx context.Context) (sentryTrace, baggage string) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return "", ""
+	}
+	return span.ToSentryTrace(), span.ToBaggage()
+}
+
+// TraceToString serializes the trace of span, its "sentry-trace" and "baggage"
+// values, into a single string, for propagating the trace through a channel
+// other than HTTP, such as a header of a message sent to a queue:
+//
+//	msg.Header.Set("sentry-trace", sentry.TraceToString(sentry.SpanFromContext(ctx)))
+//
+// The consumer continues the trace with ContinueFromString. TraceToString
+// returns an empty string for a nil span.
+func TraceToString(span *Span) string {
+	if span == nil {
+		return ""
+	}
+	values := url.Values{SentryTraceHeader: {span.ToSentryTrace()}}
+	if baggage := span.ToBaggage(); baggage != "" {
+		values.Set(SentryBaggageHeader, baggage)
+	}
+	return values.Encode()
+}
+
+// ContinueFromString returns a span option that updates the span to continue
+// the trace serialized with TraceToString, like ContinueFromHeaders does for
+// HTTP headers:
+//
+//	transaction := sentry.StartTransaction(ctx, "process order",
+//		sentry.ContinueFromString(msg.Header.Get("sentry-trace")),
+//	)
+//
+// If s is empty or malformed, the span is left unchanged and starts a new
+// trace.
+func ContinueFromString(s string) SpanOption {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return func(*Span) {}
+	}
+	return ContinueFromHeaders(values.Get(SentryTraceHeader), values.Get(SentryBaggageHeader))
+}
+
 // StartTransaction will create a transaction (root span) if there's no existing
 // transaction in the context otherwise, it will return the existing transaction.
 func StartTransaction(ctx context.Context, name string, options ...SpanOption) *Span {
@@ -10,6 +10,7 @@ import (
 	"math"
 	"net/http"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -236,6 +237,43 @@ func TestStartChild(t *testing.T) {
 	}
 }
 
+func TestStartSpanWithDescriptionAndStatus(t *testing.T) {
+	transport := &TransportMock{}
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+	})
+	span := StartSpan(ctx, "top", WithTransactionName("Test Transaction"))
+	child := StartSpan(span.Context(), "db.query", WithDescription("SELECT 1"))
+	child.SetTag("db.system", "postgresql")
+	child.SetData("db.rows", "1")
+	child.Status = SpanStatusOK
+	child.Finish()
+	span.Finish()
+
+	if child.ParentSpanID != span.SpanID {
+		t.Errorf("child.ParentSpanID = %s, want %s", child.ParentSpanID, span.SpanID)
+	}
+	if child.EndTime.Before(child.StartTime) {
+		t.Errorf("child.EndTime = %s, want not before %s", child.EndTime, child.StartTime)
+	}
+
+	events := transport.Events()
+	if got := len(events); got != 1 {
+		t.Fatalf("sent %d events, want 1", got)
+	}
+	if got := len(events[0].Spans); got != 1 {
+		t.Fatalf("got %d spans, want 1", got)
+	}
+	got := events[0].Spans[0]
+	assertEqual(t, got.Op, "db.query")
+	assertEqual(t, got.Description, "SELECT 1")
+	assertEqual(t, got.Status, SpanStatusOK)
+	assertEqual(t, got.Tags, map[string]string{"db.system": "postgresql"})
+	assertEqual(t, got.Data, map[string]interface{}{"db.rows": "1"})
+}
+
 func TestStartTransaction(t *testing.T) {
 	transport := &TransportMock{}
 	ctx := NewTestContext(ClientOptions{
@@ -339,6 +377,35 @@ func TestSetData(t *testing.T) {
 	}
 }
 
+func TestSetMeasurement(t *testing.T) {
+	transport := &TransportMock{}
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+	})
+	transaction := StartTransaction(ctx, "Test Transaction")
+	child := transaction.StartChild("child")
+	transaction.SetMeasurement("cache.hits", 1, UnitNone)
+	// Measurements of child spans are set on the transaction.
+	child.SetMeasurement("cache.hits", 2, UnitNone)
+	child.SetMeasurement("payload.size", 512, UnitByte)
+	child.Finish()
+	transaction.Finish()
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("sent %d events, want 1", len(events))
+	}
+	want := map[string]Measurement{
+		"cache.hits":   {Value: 2, Unit: UnitNone},
+		"payload.size": {Value: 512, Unit: UnitByte},
+	}
+	if diff := cmp.Diff(want, events[0].Measurements); diff != "" {
+		t.Errorf("Measurements mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestIsTransaction(t *testing.T) {
 	ctx := NewTestContext(ClientOptions{
 		EnableTracing: true,
@@ -388,7 +455,7 @@ func (c SpanCheck) Check(t *testing.T, span *Span) {
 		t.Errorf("original context value lost")
 	}
 	// Invariant: SpanFromContext(span.Context) == span
-	if spanFromContext(gotCtx) != span {
+	if SpanFromContext(gotCtx) != span {
 		t.Errorf("span not in its context")
 	}
 
@@ -501,7 +568,33 @@ func TestContinueTransactionFromHeaders(t *testing.T) {
 				Sampled:       0,
 				dynamicSamplingContext: DynamicSamplingContext{
 					Frozen:  false,
-					Entries: map[string]string{},
+					Entries: nil,
+				},
+			},
+		},
+		{
+			// Sentry baggage but no sentry-trace => new trace, baggage ignored
+			traceStr:   "",
+			baggageStr: "sentry-trace_id=d49d9bf66f13450b81f65bc51cf49c03,sentry-public_key=public,sentry-sample_rate=1",
+			wantSpan: &Span{
+				isTransaction: true,
+				Sampled:       0,
+				dynamicSamplingContext: DynamicSamplingContext{
+					Frozen:  false,
+					Entries: nil,
+				},
+			},
+		},
+		{
+			// Malformed sentry-trace => new trace, baggage ignored
+			traceStr:   "not-a-valid-trace",
+			baggageStr: "sentry-trace_id=d49d9bf66f13450b81f65bc51cf49c03,sentry-public_key=public,sentry-sample_rate=1",
+			wantSpan: &Span{
+				isTransaction: true,
+				Sampled:       0,
+				dynamicSamplingContext: DynamicSamplingContext{
+					Frozen:  false,
+					Entries: nil,
 				},
 			},
 		},
@@ -577,30 +670,6 @@ func TestContinueSpanFromTrace(t *testing.T) {
 	}
 }
 
-func TestSpanFromContext(t *testing.T) {
-	// SpanFromContext always returns a non-nil value, such that you can use
-	// it without nil checks.
-	// When no span was in the context, the returned value is a no-op.
-	// Calling StartChild on the no-op creates a valid transaction.
-	// SpanFromContext(ctx).StartChild(...) === StartSpan(ctx, ...)
-
-	ctx := NewTestContext(ClientOptions{})
-	span := spanFromContext(ctx)
-
-	_ = span
-
-	// SpanCheck{
-	// 	ZeroTraceID: true,
-	// 	ZeroSpanID:  true,
-	// }.Check(t, span)
-
-	// // Should create a transaction
-	// child := span.StartChild("top")
-	// SpanCheck{
-	// 	RecorderLen: 1,
-	// }.Check(t, child)
-}
-
 func TestDoubleSampling(t *testing.T) {
 	transport := &TransportMock{}
 	ctx := NewTestContext(ClientOptions{
@@ -685,6 +754,28 @@ func TestSample(t *testing.T) {
 	if got := span.Sampled; got != SampledTrue {
 		t.Fatalf("got %s, want %s", got, SampledTrue)
 	}
+
+	// sampling decision inherited from an incoming sentry-trace header,
+	// overriding the traces sample rate
+	ctx = NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+	})
+	span = StartSpan(ctx, "op", WithTransactionName("name"),
+		ContinueFromHeaders("d49d9bf66f13450b81f65bc51cf49c03-1cc4b26ab9094ef0-0", ""))
+	if got := span.Sampled; got != SampledFalse {
+		t.Fatalf("got %s, want %s", got, SampledFalse)
+	}
+
+	ctx = NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 0.0,
+	})
+	span = StartSpan(ctx, "op", WithTransactionName("name"),
+		ContinueFromHeaders("d49d9bf66f13450b81f65bc51cf49c03-1cc4b26ab9094ef0-1", ""))
+	if got := span.Sampled; got != SampledTrue {
+		t.Fatalf("got %s, want %s", got, SampledTrue)
+	}
 }
 
 func TestDoesNotCrashWithEmptyContext(t *testing.T) {
@@ -818,6 +909,7 @@ func TestToBaggage(t *testing.T) {
 	ctx := NewTestContext(ClientOptions{
 		EnableTracing: true,
 		SampleRate:    1.0,
+		SampleRand:    func() float64 { return 0.25 },
 		Release:       "test-release",
 	})
 	transaction := StartTransaction(ctx, "transaction-name")
@@ -826,7 +918,7 @@ func TestToBaggage(t *testing.T) {
 	assertBaggageStringsEqual(
 		t,
 		transaction.ToBaggage(),
-		"sentry-trace_id=f1a4c5c9071eca1cdf04e4132527ed16,sentry-release=test-release,sentry-transaction=transaction-name",
+		"sentry-trace_id=f1a4c5c9071eca1cdf04e4132527ed16,sentry-release=test-release,sentry-transaction=transaction-name,sentry-sample_rand=0.250000,sentry-sampled=false",
 	)
 
 	// Calling ToBaggage() on a child span should return the same result
@@ -834,10 +926,226 @@ func TestToBaggage(t *testing.T) {
 	assertBaggageStringsEqual(
 		t,
 		child.ToBaggage(),
-		"sentry-trace_id=f1a4c5c9071eca1cdf04e4132527ed16,sentry-release=test-release,sentry-transaction=transaction-name",
+		"sentry-trace_id=f1a4c5c9071eca1cdf04e4132527ed16,sentry-release=test-release,sentry-transaction=transaction-name,sentry-sample_rand=0.250000,sentry-sampled=false",
 	)
 }
 
+func TestTraceHeaders(t *testing.T) {
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing: true,
+		SampleRate:    1.0,
+		SampleRand:    func() float64 { return 0.25 },
+		Release:       "test-release",
+	})
+
+	sentryTrace, baggage := TraceHeaders(ctx)
+	if sentryTrace != "" || baggage != "" {
+		t.Errorf("TraceHeaders() = %q, %q, want empty values without a span", sentryTrace, baggage)
+	}
+
+	transaction := StartTransaction(ctx, "transaction-name")
+	transaction.TraceID = TraceIDFromHex("f1a4c5c9071eca1cdf04e4132527ed16")
+	child := transaction.StartChild("op-name")
+
+	// Headers are derived from the innermost span, the baggage from its
+	// transaction, even when the baggage of the transaction was never
+	// computed before.
+	sentryTrace, baggage = TraceHeaders(child.Context())
+	assertEqual(t, sentryTrace, child.ToSentryTrace())
+	assertBaggageStringsEqual(
+		t,
+		baggage,
+		"sentry-trace_id=f1a4c5c9071eca1cdf04e4132527ed16,sentry-release=test-release,sentry-transaction=transaction-name,sentry-sample_rand=0.250000,sentry-sampled=false",
+	)
+}
+
+func TestErrorEventDynamicSamplingContext(t *testing.T) {
+	transport := &TransportMock{}
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Release:          "test-release",
+		Transport:        transport,
+		EnvelopeHeaders: func(event *Event) map[string]interface{} {
+			return map[string]interface{}{"route": "eu"}
+		},
+	})
+	hub := GetHubFromContext(ctx)
+
+	hub.CaptureMessage("outside of a transaction")
+	assertEqual(t, transport.lastEvent.sdkMetaData.dsc.HasEntries(), false)
+	assertEqual(t, transport.lastEvent.sdkMetaData.envelopeHeaders, map[string]interface{}{"route": "eu"})
+
+	transaction := StartTransaction(ctx, "checkout")
+	child := transaction.StartChild("db.query")
+	hub.CaptureMessage("inside of a transaction")
+	dsc := transport.lastEvent.sdkMetaData.dsc
+	assertEqual(t, dsc.Entries["trace_id"], transaction.TraceID.String())
+	assertEqual(t, dsc.Entries["transaction"], "checkout")
+	assertEqual(t, dsc.Entries["release"], "test-release")
+	child.Finish()
+	transaction.Finish()
+
+	hub.CaptureMessage("after the transaction")
+	assertEqual(t, transport.lastEvent.sdkMetaData.dsc.HasEntries(), false)
+}
+
+func TestToBaggageConcurrent(t *testing.T) {
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        &TransportMock{},
+	})
+	transaction := StartTransaction(ctx, "checkout")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = transaction.ToBaggage()
+		}()
+	}
+	wg.Wait()
+	transaction.Finish()
+}
+
+func TestErrorEventTraceContextFromContext(t *testing.T) {
+	transport := &TransportMock{}
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+	})
+	hub := GetHubFromContext(ctx)
+
+	transaction := StartTransaction(ctx, "checkout")
+	child := transaction.StartChild("db.query")
+	// The scope has the trace context of the last span started, the child.
+	CaptureExceptionWithContext(transaction.Context(), errors.New("failed"))
+	trace := transport.lastEvent.Contexts["trace"]
+	assertEqual(t, trace["trace_id"], transaction.TraceID)
+	assertEqual(t, trace["span_id"], transaction.SpanID)
+
+	CaptureMessageWithContext(child.Context(), "message")
+	assertEqual(t, transport.lastEvent.Contexts["trace"]["span_id"], child.SpanID)
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		defer RecoverWithContext(transaction.Context())
+		panic("oops")
+	}()
+	assertEqual(t, transport.lastEvent.Contexts["trace"]["span_id"], transaction.SpanID)
+
+	// The temporary scope is discarded after each capture.
+	assertEqual(t, hub.Scope().contexts["trace"]["span_id"], child.SpanID)
+	child.Finish()
+	transaction.Finish()
+}
+
+func TestTraceToString(t *testing.T) {
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Release:          "test-release",
+	})
+	assertEqual(t, TraceToString(nil), "")
+
+	producer := StartTransaction(ctx, "publish order")
+	child := producer.StartChild("queue.publish")
+	s := TraceToString(child)
+
+	consumer := StartTransaction(NewTestContext(ClientOptions{EnableTracing: true}), "process order",
+		ContinueFromString(s),
+	)
+	assertEqual(t, consumer.TraceID, producer.TraceID)
+	assertEqual(t, consumer.ParentSpanID, child.SpanID)
+	assertEqual(t, consumer.Sampled, SampledTrue)
+	assertEqual(t, consumer.dynamicSamplingContext.Entries["release"], "test-release")
+	assertEqual(t, consumer.dynamicSamplingContext.Frozen, true)
+
+	for _, s := range []string{"", "%zz", "sentry-trace=invalid"} {
+		span := StartTransaction(NewTestContext(ClientOptions{}), "process order", ContinueFromString(s))
+		if span.TraceID == producer.TraceID || span.ParentSpanID != (SpanID{}) {
+			t.Errorf("ContinueFromString(%q) continued a trace, want a new trace", s)
+		}
+	}
+}
+
+func TestWithStartTime(t *testing.T) {
+	transport := &TransportMock{}
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 1.0,
+		Transport:        transport,
+	})
+	startTime := time.Date(2023, 5, 4, 3, 2, 1, 0, time.UTC)
+	endTime := startTime.Add(time.Minute)
+
+	transaction := StartTransaction(ctx, "import", WithStartTime(startTime))
+	transaction.EndTime = endTime
+	transaction.Finish()
+
+	event := transport.lastEvent
+	if event == nil {
+		t.Fatal("transaction was not sent")
+	}
+	assertEqual(t, event.StartTime, startTime)
+	assertEqual(t, event.Timestamp, endTime)
+}
+
+func TestSampleRandPropagation(t *testing.T) {
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing:    true,
+		TracesSampleRate: 0.5,
+		SampleRand:       func() float64 { return 0.9 },
+	})
+
+	// A new trace is sampled with a new random value, propagated downstream.
+	root := StartTransaction(ctx, "root")
+	if root.Sampled != SampledFalse {
+		t.Errorf("root transaction with random value 0.9 has Sampled = %v, want %v", root.Sampled, SampledFalse)
+	}
+	dsc := DynamicSamplingContextFromTransaction(root)
+	assertEqual(t, dsc.Entries["sample_rand"], "0.900000")
+	assertEqual(t, dsc.Entries["sampled"], "false")
+
+	// A continued trace without a sampling decision reuses the incoming
+	// sample_rand, and forwards the incoming baggage unchanged.
+	baggage := "sentry-trace_id=d49d9bf66f13450b81f65bc51cf49c03,sentry-sample_rate=0.5,sentry-sample_rand=0.123456"
+	continued := StartTransaction(ctx, "continued",
+		ContinueFromHeaders("d49d9bf66f13450b81f65bc51cf49c03-b72fa28504b07285", baggage))
+	if continued.Sampled != SampledTrue {
+		t.Errorf("continued transaction with sample_rand 0.123456 has Sampled = %v, want %v", continued.Sampled, SampledTrue)
+	}
+	assertBaggageStringsEqual(t, continued.ToBaggage(), baggage)
+}
+
+func TestSpanFromContext(t *testing.T) {
+	ctx := NewTestContext(ClientOptions{
+		EnableTracing: true,
+	})
+	if span := SpanFromContext(ctx); span != nil {
+		t.Fatalf("SpanFromContext() = %v, want nil", span)
+	}
+
+	transaction := StartTransaction(ctx, "Test Transaction")
+	child := StartSpan(transaction.Context(), "child")
+	// A middleware further down the chain only gets the context.
+	ctx = child.Context()
+	if got := SpanFromContext(ctx); got != child {
+		t.Errorf("SpanFromContext() = %v, want the child span", got)
+	}
+	if got := TransactionFromContext(ctx); got != transaction {
+		t.Errorf("TransactionFromContext() = %v, want the transaction", got)
+	}
+	if got := SpanFromContext(transaction.Context()); got != transaction {
+		t.Errorf("SpanFromContext() = %v, want the transaction", got)
+	}
+}
+
 func TestSpanSetContext(t *testing.T) {
 	ctx := NewTestContext(ClientOptions{
 		EnableTracing: true,
@@ -2,6 +2,8 @@ package sentry
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -9,6 +11,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"runtime/debug"
 	"sync"
 	"time"
 
@@ -17,6 +20,7 @@ import (
 
 const defaultBufferSize = 30
 const defaultTimeout = time.Second * 30
+const defaultRetryBaseDelay = time.Second
 
 // maxDrainResponseBytes is the maximum number of bytes that transport
 // implementations will read from response bodies when draining them.
@@ -29,13 +33,68 @@ const defaultTimeout = time.Second * 30
 // server is misbehaving) and reusing TCP connections.
 const maxDrainResponseBytes = 16 << 10
 
+// maxAttachmentBytes is the maximum size of a single attachment, and
+// maxAttachmentsBytes the maximum combined size of all attachments sent with an
+// event. Attachments past the limits are dropped, such that the SDK does not
+// buffer arbitrarily large payloads that Sentry would reject anyway.
+const (
+	maxAttachmentBytes  = 20 << 20
+	maxAttachmentsBytes = 40 << 20
+)
+
 // Transport is used by the Client to deliver events to remote server.
+//
+// The SDK provides HTTPTransport, used by default, and HTTPSyncTransport.
+// Custom implementations can be set with ClientOptions.Transport, for example
+// to deliver events through a different protocol or to record them in tests.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
 type Transport interface {
+	// Flush waits until all events passed to SendEvent were delivered,
+	// blocking for at most the given timeout. It returns false if the timeout
+	// was reached before all events were delivered.
 	Flush(timeout time.Duration) bool
+	// Configure is called once by NewClient, before any other method, with
+	// the options the client was created with.
 	Configure(options ClientOptions)
+	// SendEvent delivers the event, or schedules its delivery. The event was
+	// already processed by the client, including BeforeSend and event
+	// processors.
 	SendEvent(event *Event)
 }
 
+// envelopeItem is a payload other than an event, such as a batch of logs, sent
+// to Sentry in an envelope of its own.
+type envelopeItem struct {
+	// eventID is the ID of the envelope.
+	eventID EventID
+	// itemType is the type of the item, which also determines its rate limit
+	// category.
+	itemType string
+	// count is the number of logs of a batch of logs.
+	count int
+	// payload is serialized as JSON to form the body of the item.
+	payload interface{}
+	// description names the item in debug logs.
+	description string
+}
+
+// itemTransport is implemented by the transports that can send envelope items
+// other than events. Custom implementations of Transport only get events.
+type itemTransport interface {
+	sendItem(item *envelopeItem)
+}
+
+// sendItem sends item with transport, if it implements itemTransport, and
+// drops it otherwise.
+func sendItem(transport Transport, item *envelopeItem) {
+	if t, ok := transport.(itemTransport); ok {
+		t.sendItem(item)
+		return
+	}
+	Logger.Printf("Dropping %s: %T only sends events.", item.description, transport)
+}
+
 func getProxyConfig(options ClientOptions) func(*http.Request) (*url.URL, error) {
 	if options.HTTPSProxy != "" {
 		return func(*http.Request) (*url.URL, error) {
@@ -64,7 +123,12 @@ func getTLSConfig(options ClientOptions) *tls.Config {
 	return nil
 }
 
+// getRequestBodyFromEvent returns event serialized as JSON, as serialized by
+// the client to enforce ClientOptions.MaxEventSize if it did.
 func getRequestBodyFromEvent(event *Event) []byte {
+	if body := event.sdkMetaData.body; body != nil {
+		return body
+	}
 	body, err := json.Marshal(event)
 	if err == nil {
 		return body
@@ -110,6 +174,91 @@ func encodeEnvelopeItem(enc *json.Encoder, itemType string, body json.RawMessage
 	return err
 }
 
+// encodeLogItem encodes an envelope item holding a batch of count logs.
+func encodeLogItem(enc *json.Encoder, count int, body json.RawMessage) error {
+	// Item header
+	err := enc.Encode(struct {
+		Type        string `json:"type"`
+		Length      int    `json:"length"`
+		ItemCount   int    `json:"item_count"`
+		ContentType string `json:"content_type"`
+	}{
+		Type:        logType,
+		Length:      len(body),
+		ItemCount:   count,
+		ContentType: logContentType,
+	})
+	if err == nil {
+		// payload
+		err = enc.Encode(body)
+	}
+	return err
+}
+
+func encodeAttachment(enc *json.Encoder, b *bytes.Buffer, attachment *Attachment) error {
+	// Item header
+	err := enc.Encode(struct {
+		Type        string `json:"type"`
+		Length      int    `json:"length"`
+		Filename    string `json:"filename"`
+		ContentType string `json:"content_type,omitempty"`
+	}{
+		Type:        attachmentType,
+		Length:      len(attachment.Payload),
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Payload, written as is and followed by a newline separating it from the
+	// next item.
+	if _, err = b.Write(attachment.Payload); err != nil {
+		return err
+	}
+	_, err = b.WriteString("\n")
+	return err
+}
+
+// dsnString returns dsn as a string, or the empty string if dsn is nil, for
+// envelopes sent without DSN to Spotlight.
+func dsnString(dsn *Dsn) string {
+	if dsn == nil {
+		return ""
+	}
+	return dsn.String()
+}
+
+// mergeEnvelopeHeaders returns the fields of header, a struct, and the custom
+// headers that header does not set, see ClientOptions.EnvelopeHeaders.
+func mergeEnvelopeHeaders(header interface{}, custom map[string]interface{}) interface{} {
+	b, err := json.Marshal(header)
+	if err != nil {
+		return header
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(b, &merged); err != nil {
+		return header
+	}
+	for k, v := range custom {
+		if k == "trace" || k == "dsn" {
+			// Omitted when empty, but reserved all the same.
+			continue
+		}
+		if _, ok := merged[k]; ok {
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			Logger.Printf("Envelope header %q dropped: %v", k, err)
+			continue
+		}
+		merged[k] = raw
+	}
+	return merged
+}
+
 func envelopeFromBody(event *Event, dsn *Dsn, sentAt time.Time, body json.RawMessage) (*bytes.Buffer, error) {
 	var b bytes.Buffer
 	enc := json.NewEncoder(&b)
@@ -123,29 +272,36 @@ func envelopeFromBody(event *Event, dsn *Dsn, sentAt time.Time, body json.RawMes
 	}
 
 	// Envelope header
-	err := enc.Encode(struct {
+	header := struct {
 		EventID EventID           `json:"event_id"`
 		SentAt  time.Time         `json:"sent_at"`
-		Dsn     string            `json:"dsn"`
+		Dsn     string            `json:"dsn,omitempty"`
 		Sdk     map[string]string `json:"sdk"`
 		Trace   map[string]string `json:"trace,omitempty"`
 	}{
 		EventID: event.EventID,
 		SentAt:  sentAt,
 		Trace:   trace,
-		Dsn:     dsn.String(),
+		Dsn:     dsnString(dsn),
 		Sdk: map[string]string{
 			"name":    event.Sdk.Name,
 			"version": event.Sdk.Version,
 		},
-	})
+	}
+	var err error
+	if custom := event.sdkMetaData.envelopeHeaders; len(custom) > 0 {
+		err = enc.Encode(mergeEnvelopeHeaders(header, custom))
+	} else {
+		err = enc.Encode(header)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if event.Type == transactionType {
+	switch event.Type {
+	case transactionType:
 		err = encodeEnvelopeItem(enc, transactionType, body)
-	} else {
+	default:
 		err = encodeEnvelopeItem(enc, eventType, body)
 	}
 	if err != nil {
@@ -164,15 +320,79 @@ func envelopeFromBody(event *Event, dsn *Dsn, sentAt time.Time, body json.RawMes
 		}
 	}
 
+	// Attachments
+	var attachmentsBytes int
+	for _, attachment := range event.Attachments {
+		size := len(attachment.Payload)
+		if size > maxAttachmentBytes || attachmentsBytes+size > maxAttachmentsBytes {
+			Logger.Printf("Attachment %q dropped: size of %d bytes exceeds the limit", attachment.Filename, size)
+			continue
+		}
+		attachmentsBytes += size
+		if err = encodeAttachment(enc, &b, attachment); err != nil {
+			return nil, err
+		}
+	}
+
 	return &b, nil
 }
 
-func getRequestFromEvent(event *Event, dsn *Dsn) (r *http.Request, err error) {
-	defer func() {
-		if r != nil {
-			r.Header.Set("User-Agent", userAgent)
-		}
-	}()
+// envelopeFromItem returns an envelope holding item alone.
+func envelopeFromItem(item *envelopeItem, dsn *Dsn, sentAt time.Time) (*bytes.Buffer, error) {
+	body, err := json.Marshal(item.payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+
+	// Envelope header
+	err = enc.Encode(struct {
+		EventID EventID           `json:"event_id"`
+		SentAt  time.Time         `json:"sent_at"`
+		Dsn     string            `json:"dsn,omitempty"`
+		Sdk     map[string]string `json:"sdk"`
+	}{
+		EventID: item.eventID,
+		SentAt:  sentAt,
+		Dsn:     dsnString(dsn),
+		Sdk: map[string]string{
+			"name":    SDKIdentifier,
+			"version": SDKVersion,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if item.itemType == logType {
+		err = encodeLogItem(enc, item.count, body)
+	} else {
+		err = encodeEnvelopeItem(enc, item.itemType, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// gzipBody returns the gzip-compressed contents of b.
+func gzipBody(b *bytes.Buffer) (*bytes.Buffer, error) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := b.WriteTo(zw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &compressed, nil
+}
+
+// getRequestFromEvent returns a request that sends event, and report if not
+// nil, to Sentry.
+func getRequestFromEvent(event *Event, dsn *Dsn, compress bool, report *clientReport) (*http.Request, error) {
 	body := getRequestBodyFromEvent(event)
 	if body == nil {
 		return nil, errors.New("event could not be marshaled")
@@ -181,6 +401,38 @@ func getRequestFromEvent(event *Event, dsn *Dsn) (r *http.Request, err error) {
 	if err != nil {
 		return nil, err
 	}
+	return newEnvelopeRequest(envelope, dsn, compress, report)
+}
+
+// getRequestFromItem returns a request that sends item, and report if not
+// nil, to Sentry.
+func getRequestFromItem(item *envelopeItem, dsn *Dsn, compress bool, report *clientReport) (*http.Request, error) {
+	envelope, err := envelopeFromItem(item, dsn, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return newEnvelopeRequest(envelope, dsn, compress, report)
+}
+
+// newEnvelopeRequest returns a request that sends envelope, with report
+// appended if not nil, to Sentry.
+func newEnvelopeRequest(envelope *bytes.Buffer, dsn *Dsn, compress bool, report *clientReport) (r *http.Request, err error) {
+	defer func() {
+		if r != nil {
+			r.Header.Set("User-Agent", userAgent)
+			if compress {
+				r.Header.Set("Content-Encoding", "gzip")
+			}
+		}
+	}()
+	if err = encodeClientReport(json.NewEncoder(envelope), report); err != nil {
+		return nil, err
+	}
+	if compress {
+		if envelope, err = gzipBody(envelope); err != nil {
+			return nil, err
+		}
+	}
 	return http.NewRequest(
 		http.MethodPost,
 		dsn.GetAPIURL().String(),
@@ -188,12 +440,133 @@ func getRequestFromEvent(event *Event, dsn *Dsn) (r *http.Request, err error) {
 	)
 }
 
+// getRequestFromClientReport returns a request that sends report on its own.
+func getRequestFromClientReport(report *clientReport, dsn *Dsn, compress bool) (*http.Request, error) {
+	envelope, err := envelopeFromClientReport(report, dsn, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if compress {
+		if envelope, err = gzipBody(envelope); err != nil {
+			return nil, err
+		}
+	}
+	return getRequestFromEnvelope(envelope.Bytes(), compress, dsn)
+}
+
+// getRequestFromEnvelope returns a request that sends an envelope serialized
+// before by getRequestFromEvent, for instance one restored by OfflineTransport.
+func getRequestFromEnvelope(envelope []byte, compressed bool, dsn *Dsn) (*http.Request, error) {
+	r, err := http.NewRequest(
+		http.MethodPost,
+		dsn.GetAPIURL().String(),
+		bytes.NewReader(envelope),
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("User-Agent", userAgent)
+	if compressed {
+		r.Header.Set("Content-Encoding", "gzip")
+	}
+	for headerKey, headerValue := range dsn.RequestHeaders() {
+		r.Header.Set(headerKey, headerValue)
+	}
+	return r, nil
+}
+
+// deliveryHooks are called by the HTTP transports after each attempt to send a
+// request to Sentry. They are used by OfflineTransport to store undelivered
+// envelopes and replay them once Sentry is reachable again.
+type deliveryHooks struct {
+	// failed is called when the request could not be delivered because of a
+	// network error or a server error response. It reports whether the
+	// request was stored to be sent again later, in which case the failure is
+	// not passed to ClientOptions.OnSendError.
+	failed func(request *http.Request) bool
+	// delivered is called when Sentry responded to the request.
+	delivered func()
+}
+
+// ErrRateLimited is passed to ClientOptions.OnSendError for events dropped
+// because of rate limits, either when Sentry responds with the 429 status or
+// when the transport drops events while backing off.
+var ErrRateLimited = errors.New("sentry: rate limited")
+
+// ErrBufferFull is passed to ClientOptions.OnSendError for events dropped by
+// HTTPTransport because its buffer is full, see HTTPTransport.BufferSize and
+// HTTPTransport.DropOldest.
+var ErrBufferFull = errors.New("sentry: transport buffer full")
+
+// SendError is passed to ClientOptions.OnSendError when Sentry responds to an
+// event with an error status. A SendError with the 429 status is
+// ErrRateLimited, as reported by errors.Is.
+type SendError struct {
+	StatusCode int
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("sentry: server responded with status %d", e.StatusCode)
+}
+
+// Is reports whether target is ErrRateLimited and e has the 429 status.
+func (e *SendError) Is(target error) bool {
+	return target == ErrRateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+// sendErrorFor returns the error passed to ClientOptions.OnSendError for the
+// outcome of sending a request, or nil if Sentry accepted it.
+func sendErrorFor(response *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return &SendError{StatusCode: response.StatusCode}
+	}
+	return nil
+}
+
+// reportSendError calls onSendError, if set, in a new goroutine, such that it
+// cannot block the transport. Requests without an event are not reported.
+func reportSendError(onSendError func(event *Event, err error), event *Event, err error) {
+	if onSendError == nil || event == nil || err == nil {
+		return
+	}
+	go onSendError(event, err)
+}
+
+// afterSend calls the hook that applies to the outcome of sending a request.
+// It reports whether the failed hook stored the request.
+func (h deliveryHooks) afterSend(request *http.Request, response *http.Response, err error) (stored bool) {
+	if err != nil || response.StatusCode >= http.StatusInternalServerError {
+		return h.failed != nil && h.failed(request)
+	}
+	if h.delivered != nil {
+		h.delivered()
+	}
+	return false
+}
+
+// describeEvent names event in debug logs.
+func describeEvent(event *Event) string {
+	switch event.Type {
+	case transactionType:
+		return "transaction"
+	default:
+		return fmt.Sprintf("%s event", event.Level)
+	}
+}
+
 func categoryFor(eventType string) ratelimit.Category {
 	switch eventType {
 	case "":
 		return ratelimit.CategoryError
 	case transactionType:
 		return ratelimit.CategoryTransaction
+	case logType:
+		return ratelimit.CategoryLog
+	case checkInType:
+		return ratelimit.CategoryMonitor
 	default:
 		return ratelimit.Category(eventType)
 	}
@@ -213,17 +586,31 @@ type batch struct {
 type batchItem struct {
 	request  *http.Request
 	category ratelimit.Category
+	// event is the event sent with the request, if any, passed to
+	// ClientOptions.OnSendError.
+	event *Event
+	// discarded are the counts of the client report sent with the request,
+	// recorded again if the request is dropped.
+	discarded map[discardedKey]uint64
 }
 
 // HTTPTransport is the default, non-blocking, implementation of Transport.
 //
 // Clients using this transport will enqueue requests in a buffer and return to
 // the caller before any network communication has happened. Requests are sent
-// to Sentry sequentially from a background goroutine.
+// to Sentry sequentially from a background goroutine, or concurrently from a
+// fixed number of goroutines, see Workers.
+//
+// Every event is sent in a request of its own: the Sentry envelope protocol
+// allows at most one error or transaction per envelope, so events cannot be
+// batched. Requests reuse keep-alive connections, and data that can be batched
+// is: client reports are sent along with events, and structured logs are sent
+// in batches, see NewLogger.
 type HTTPTransport struct {
 	dsn       *Dsn
 	client    *http.Client
 	transport http.RoundTripper
+	compress  bool
 
 	// buffer is a channel of batches. Calling Flush terminates work on the
 	// current in-flight items and starts a new batch for subsequent events.
@@ -231,13 +618,125 @@ type HTTPTransport struct {
 
 	start sync.Once
 
+	// done is closed by Close to stop the worker goroutine.
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// hooks are set by OfflineTransport before Configure.
+	hooks deliveryHooks
+
+	onSendError func(event *Event, err error)
+
 	// Size of the transport buffer. Defaults to 30.
 	BufferSize int
+	// DropOldest configures which event is dropped when an event is sent
+	// while the buffer is full. By default, the new event is dropped. When
+	// DropOldest is true, the oldest buffered event is dropped instead, making
+	// room for the new one. Either way, dropped events are counted in client
+	// reports.
+	DropOldest bool
 	// HTTP Client request timeout. Defaults to 30 seconds.
 	Timeout time.Duration
+	// MaxRetries is the number of times a request is sent again after a
+	// network error or a 5xx response, before it is given up on. Other
+	// responses are not retried, including 429 responses, whose rate limits
+	// apply to the next requests instead. Defaults to 0, no retries.
+	MaxRetries int
+	// RetryBaseDelay is the delay before the first retry, doubled for each
+	// subsequent retry. Defaults to 1 second. The worker sends no other
+	// request while waiting, so long delays can fill the buffer, unless there
+	// are more Workers. Close interrupts the wait and Flush does not wait past
+	// its deadline.
+	RetryBaseDelay time.Duration
+	// Workers is the number of goroutines sending requests to Sentry
+	// concurrently. Defaults to 1, a single worker sending requests one at
+	// a time, in the order events were sent. More workers deliver bursts of
+	// events faster, over as many connections, but not in order. The
+	// goroutines are started once, by Configure, and run until Close, however
+	// many events are sent.
+	Workers int
+
+	// jobs passes the items of the current batch from the worker to the
+	// senders, and inFlight counts the items passed but not sent yet. They
+	// are only used with more than one worker.
+	jobs     chan batchItem
+	inFlight sync.WaitGroup
 
 	mu     sync.RWMutex
 	limits ratelimit.Map
+
+	// discarded counts events dropped because of rate limits or a full
+	// buffer, and clientDiscarded those dropped by the client. They are sent
+	// to Sentry in client reports.
+	discarded       discardedEvents
+	clientDiscarded *discardedEvents
+
+	// stats are the counters returned by Stats.
+	stats transportStats
+}
+
+// TransportStats are counters describing the delivery of events by an
+// HTTPTransport. See HTTPTransport.Stats.
+type TransportStats struct {
+	// Sent is the number of requests Sentry accepted.
+	Sent uint64
+	// Failed is the number of requests that could not be delivered because
+	// of a network error, or that Sentry rejected, including because of
+	// rate limits.
+	Failed uint64
+	// Dropped is the number of events dropped before being sent, because the
+	// buffer was full or because of rate limits.
+	Dropped uint64
+	// Retried is the number of requests sent again after a failure, either
+	// by the HTTPTransport itself, see HTTPTransport.MaxRetries, or when an
+	// OfflineTransport replays stored envelopes.
+	Retried uint64
+	// QueueDepth is the number of requests waiting in the buffer.
+	QueueDepth int
+	// LastRateLimited is the last time Sentry responded with rate limits, or
+	// the zero time if it never did.
+	LastRateLimited time.Time
+}
+
+// transportStats holds the counters of TransportStats. They are guarded by a
+// mutex rather than updated atomically, as 64-bit atomic operations need an
+// alignment that fields of HTTPTransport do not have on 32-bit platforms.
+type transportStats struct {
+	mu              sync.Mutex
+	sent            uint64
+	failed          uint64
+	dropped         uint64
+	retried         uint64
+	lastRateLimited time.Time
+}
+
+// inc increments one of the counters of s.
+func (s *transportStats) inc(counter *uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	*counter++
+}
+
+func (s *transportStats) setLastRateLimited(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRateLimited = t
+}
+
+// snapshot returns the counters of s, without the queue depth.
+func (s *transportStats) snapshot() TransportStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return TransportStats{
+		Sent:            s.sent,
+		Failed:          s.failed,
+		Dropped:         s.dropped,
+		Retried:         s.retried,
+		LastRateLimited: s.lastRateLimited,
+	}
 }
 
 // NewHTTPTransport returns a new pre-configured instance of HTTPTransport.
@@ -252,12 +751,15 @@ func NewHTTPTransport() *HTTPTransport {
 
 // Configure is called by the Client itself, providing it it's own ClientOptions.
 func (t *HTTPTransport) Configure(options ClientOptions) {
-	dsn, err := NewDsn(options.Dsn)
+	dsn, err := dsnFromOptions(options)
 	if err != nil {
 		Logger.Printf("%v\n", err)
 		return
 	}
 	t.dsn = dsn
+	t.compress = !options.DisableCompression
+	t.onSendError = options.OnSendError
+	t.done = make(chan struct{})
 
 	// A buffered channel with capacity 1 works like a mutex, ensuring only one
 	// goroutine can access the current batch at a given time. Access is
@@ -288,24 +790,62 @@ func (t *HTTPTransport) Configure(options ClientOptions) {
 	}
 
 	t.start.Do(func() {
+		if t.Workers > 1 {
+			t.jobs = make(chan batchItem)
+			for i := 0; i < t.Workers; i++ {
+				go t.sender()
+			}
+		}
 		go t.worker()
 	})
 }
 
 // SendEvent assembles a new packet out of Event and sends it to remote server.
 func (t *HTTPTransport) SendEvent(event *Event) {
+	t.submit(event.EventID, describeEvent(event), categoryFor(event.Type), event,
+		func(report *clientReport) (*http.Request, error) {
+			return getRequestFromEvent(event, t.dsn, t.compress, report)
+		})
+}
+
+// sendItem implements itemTransport.
+func (t *HTTPTransport) sendItem(item *envelopeItem) {
+	t.submit(item.eventID, item.description, categoryFor(item.itemType), nil,
+		func(report *clientReport) (*http.Request, error) {
+			return getRequestFromItem(item, t.dsn, t.compress, report)
+		})
+}
+
+// submit enqueues the request returned by newRequest, sending the envelope
+// described by description along with the pending client report. event is the
+// event sent with the request, if any.
+func (t *HTTPTransport) submit(
+	id EventID,
+	description string,
+	category ratelimit.Category,
+	event *Event,
+	newRequest func(report *clientReport) (*http.Request, error),
+) {
 	if t.dsn == nil {
 		return
 	}
 
-	category := categoryFor(event.Type)
+	select {
+	case <-t.done:
+		Logger.Println("Event dropped due to transport being closed.")
+		return
+	default:
+	}
 
 	if t.disabled(category) {
+		reportSendError(t.onSendError, event, ErrRateLimited)
 		return
 	}
 
-	request, err := getRequestFromEvent(event, t.dsn)
+	counts := takeDiscardedEvents(&t.discarded, t.clientDiscarded)
+	request, err := newRequest(newClientReport(counts, time.Now()))
 	if err != nil {
+		t.discarded.merge(counts)
 		return
 	}
 
@@ -313,6 +853,58 @@ func (t *HTTPTransport) SendEvent(event *Event) {
 		request.Header.Set(headerKey, headerValue)
 	}
 
+	if !t.enqueue(batchItem{request: request, category: category, event: event, discarded: counts}) {
+		Logger.Println("Event dropped due to transport buffer being full.")
+		t.discarded.merge(counts)
+		t.discarded.record(discardReasonQueueOverflow, category)
+		t.stats.inc(&t.stats.dropped)
+		reportSendError(t.onSendError, event, ErrBufferFull)
+		return
+	}
+
+	Logger.Printf(
+		"Sending %s [%s] to %s project: %s",
+		description,
+		id,
+		t.dsn.host,
+		t.dsn.projectID,
+	)
+}
+
+// sendEnvelope enqueues a serialized envelope. It returns false if the
+// envelope could not be enqueued because the buffer is full or the transport
+// is closed.
+func (t *HTTPTransport) sendEnvelope(envelope []byte, compressed bool, category ratelimit.Category) bool {
+	if t.dsn == nil {
+		return false
+	}
+
+	select {
+	case <-t.done:
+		return false
+	default:
+	}
+
+	if t.disabled(category) {
+		// Dropped and counted, there is no point in retrying.
+		return true
+	}
+
+	request, err := getRequestFromEnvelope(envelope, compressed, t.dsn)
+	if err != nil {
+		return false
+	}
+	if !t.enqueue(batchItem{request: request, category: category}) {
+		return false
+	}
+	t.stats.inc(&t.stats.retried)
+	return true
+}
+
+// enqueue adds item to the current batch. It returns false if the item was
+// dropped because the buffer is full. With DropOldest, the oldest buffered
+// item is dropped instead, if any.
+func (t *HTTPTransport) enqueue(item batchItem) bool {
 	// <-t.buffer is equivalent to acquiring a lock to access the current batch.
 	// A few lines below, t.buffer <- b releases the lock.
 	//
@@ -322,33 +914,38 @@ func (t *HTTPTransport) SendEvent(event *Event) {
 	//
 	// Note that the select block takes a bounded amount of CPU time because of
 	// the default case that is executed if sending on b.items would block. That
-	// is, the event is dropped if it cannot be sent immediately to the b.items
+	// is, the request is dropped if it cannot be sent immediately to the b.items
 	// channel (used as a queue).
 	b := <-t.buffer
+	defer func() {
+		t.buffer <- b
+	}()
 
 	select {
-	case b.items <- batchItem{
-		request:  request,
-		category: category,
-	}:
-		var eventType string
-		if event.Type == transactionType {
-			eventType = "transaction"
-		} else {
-			eventType = fmt.Sprintf("%s event", event.Level)
-		}
-		Logger.Printf(
-			"Sending %s [%s] to %s project: %s",
-			eventType,
-			event.EventID,
-			t.dsn.host,
-			t.dsn.projectID,
-		)
+	case b.items <- item:
+		return true
 	default:
-		Logger.Println("Event dropped due to transport buffer being full.")
+	}
+	if !t.DropOldest {
+		return false
 	}
 
-	t.buffer <- b
+	select {
+	case oldest := <-b.items:
+		Logger.Println("Event dropped due to transport buffer being full, to make room for a newer one.")
+		t.discarded.merge(oldest.discarded)
+		t.discarded.record(discardReasonQueueOverflow, oldest.category)
+		t.stats.inc(&t.stats.dropped)
+		reportSendError(t.onSendError, oldest.event, ErrBufferFull)
+	default:
+		// The worker took the oldest item in the meantime.
+	}
+	select {
+	case b.items <- item:
+		return true
+	default:
+		return false
+	}
 }
 
 // Flush waits until any buffered events are sent to the Sentry server, blocking
@@ -356,13 +953,25 @@ func (t *HTTPTransport) SendEvent(event *Event) {
 // In that case, some events may not have been sent.
 //
 // Flush should be called before terminating the program to avoid
-// unintentionally dropping events.
+// unintentionally dropping events. It also sends the client report of events
+// discarded since the last event was sent, if any.
 //
 // Do not call Flush indiscriminately after every call to SendEvent. Instead, to
 // have the SDK send events over the network synchronously, configure it to use
 // the HTTPSyncTransport in the call to Init.
 func (t *HTTPTransport) Flush(timeout time.Duration) bool {
-	toolate := time.After(timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return t.FlushWithContext(ctx)
+}
+
+// FlushWithContext works like Flush, but waits until ctx is done instead of a
+// fixed timeout. It returns true only if all events buffered at the time of
+// the call were processed before ctx was done.
+func (t *HTTPTransport) FlushWithContext(ctx context.Context) bool {
+	toolate := ctx.Done()
+
+	t.sendClientReport()
 
 	// Wait until processing the current batch has started or the timeout.
 	//
@@ -380,6 +989,8 @@ func (t *HTTPTransport) Flush(timeout time.Duration) bool {
 			default:
 				t.buffer <- b
 			}
+		case <-t.done:
+			goto fail
 		case <-toolate:
 			goto fail
 		}
@@ -401,6 +1012,8 @@ started:
 	case <-b.done:
 		Logger.Println("Buffer flushed successfully.")
 		return true
+	case <-t.done:
+		goto fail
 	case <-toolate:
 		goto fail
 	}
@@ -410,8 +1023,28 @@ fail:
 	return false
 }
 
+// Close stops the worker goroutines started by Configure. Events sent after
+// Close are dropped, as are buffered events that were not sent yet. Call Flush
+// before Close to deliver them. It is safe to call Close multiple times.
+func (t *HTTPTransport) Close() {
+	if t.done == nil {
+		// Not configured, there is no worker to stop.
+		return
+	}
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+}
+
 func (t *HTTPTransport) worker() {
-	for b := range t.buffer {
+	for {
+		var b batch
+		select {
+		case <-t.done:
+			return
+		case b = <-t.buffer:
+		}
+
 		// Signal that processing of the current batch has started.
 		close(b.started)
 
@@ -420,40 +1053,198 @@ func (t *HTTPTransport) worker() {
 		t.buffer <- b
 
 		// Process all batch items.
-		for item := range b.items {
-			if t.disabled(item.category) {
-				continue
+		for {
+			var item batchItem
+			var open bool
+			select {
+			case <-t.done:
+				return
+			case item, open = <-b.items:
+			}
+			if !open {
+				break
 			}
 
-			response, err := t.client.Do(item.request)
-			if err != nil {
-				Logger.Printf("There was an issue with sending an event: %v", err)
+			if t.jobs == nil {
+				t.process(item)
 				continue
 			}
-			t.mu.Lock()
-			t.limits.Merge(ratelimit.FromResponse(response))
-			t.mu.Unlock()
-			// Drain body up to a limit and close it, allowing the
-			// transport to reuse TCP connections.
-			_, _ = io.CopyN(io.Discard, response.Body, maxDrainResponseBytes)
-			response.Body.Close()
+			t.inFlight.Add(1)
+			select {
+			case t.jobs <- item:
+			case <-t.done:
+				t.inFlight.Done()
+				return
+			}
 		}
 
-		// Signal that processing of the batch is done.
+		// Wait for the senders to be done with the items of the batch, if
+		// any, and signal that processing of the batch is done.
+		t.inFlight.Wait()
 		close(b.done)
 	}
 }
 
+// sender processes the items passed by the worker, when there is more than
+// one worker, see Workers.
+func (t *HTTPTransport) sender() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case item := <-t.jobs:
+			t.process(item)
+			t.inFlight.Done()
+		}
+	}
+}
+
+// process sends the request of item, unless its category is rate limited.
+func (t *HTTPTransport) process(item batchItem) {
+	if t.disabled(item.category) {
+		t.discarded.merge(item.discarded)
+		reportSendError(t.onSendError, item.event, ErrRateLimited)
+		return
+	}
+	t.send(item)
+}
+
+// send sends the request of item to Sentry and records the response. A panic
+// while sending, for instance in a custom http.RoundTripper, is logged and
+// counted as a failed request instead of crashing the program, and the worker
+// goes on with the next item.
+func (t *HTTPTransport) send(item batchItem) {
+	defer func() {
+		if err := recover(); err != nil {
+			Logger.Printf("Recovered from a panic while sending an event: %v\n%s", err, debug.Stack())
+			t.stats.inc(&t.stats.failed)
+		}
+	}()
+
+	response, err := t.do(item.request)
+	if !t.hooks.afterSend(item.request, response, err) {
+		reportSendError(t.onSendError, item.event, sendErrorFor(response, err))
+	}
+	if err != nil {
+		Logger.Printf("There was an issue with sending an event: %v", err)
+		t.stats.inc(&t.stats.failed)
+		return
+	}
+	defer drainAndClose(response)
+	if response.StatusCode >= http.StatusBadRequest {
+		t.stats.inc(&t.stats.failed)
+	} else {
+		t.stats.inc(&t.stats.sent)
+	}
+	limits := ratelimit.FromResponse(response)
+	if len(limits) > 0 {
+		t.stats.setLastRateLimited(time.Now())
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limits.Merge(limits)
+}
+
+// do sends request, retrying it up to MaxRetries times after network errors
+// and 5xx responses, with an exponential backoff. It gives up when the
+// transport is closed while waiting.
+func (t *HTTPTransport) do(request *http.Request) (*http.Response, error) {
+	delay := t.RetryBaseDelay
+	if delay <= 0 {
+		delay = defaultRetryBaseDelay
+	}
+	for attempt := 0; ; attempt++ {
+		response, err := t.client.Do(request)
+		retryable := err != nil || response.StatusCode >= http.StatusInternalServerError
+		if !retryable || attempt >= t.MaxRetries || request.GetBody == nil {
+			return response, err
+		}
+		if err == nil {
+			err = fmt.Errorf("server responded with status %d", response.StatusCode)
+			drainAndClose(response)
+		}
+		Logger.Printf("Retrying to send an event in %v: %v", delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-t.done:
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		delay *= 2
+
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		request = request.Clone(request.Context())
+		request.Body = body
+		t.stats.inc(&t.stats.retried)
+	}
+}
+
+// drainAndClose drains the body of response up to a limit and closes it,
+// allowing the transport to reuse TCP connections.
+func drainAndClose(response *http.Response) {
+	_, _ = io.CopyN(io.Discard, response.Body, maxDrainResponseBytes)
+	response.Body.Close()
+}
+
+// sendClientReport enqueues a client report of the events discarded since
+// the last report, if any. Reports are otherwise only sent along with events.
+func (t *HTTPTransport) sendClientReport() {
+	if t.dsn == nil {
+		return
+	}
+	counts := takeDiscardedEvents(&t.discarded, t.clientDiscarded)
+	report := newClientReport(counts, time.Now())
+	if report == nil {
+		return
+	}
+	request, err := getRequestFromClientReport(report, t.dsn, t.compress)
+	if err != nil || !t.enqueue(batchItem{request: request, category: categoryFor(clientReportType), discarded: counts}) {
+		t.discarded.merge(counts)
+	}
+}
+
+func (t *HTTPTransport) setClientDiscardedEvents(d *discardedEvents) {
+	t.clientDiscarded = d
+}
+
 func (t *HTTPTransport) disabled(c ratelimit.Category) bool {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	disabled := t.limits.IsRateLimited(c)
 	if disabled {
 		Logger.Printf("Too many requests for %q, backing off till: %v", c, t.limits.Deadline(c))
+		t.discarded.record(discardReasonRateLimitBackoff, c)
+		t.stats.inc(&t.stats.dropped)
 	}
 	return disabled
 }
 
+// Stats returns the counters describing the delivery of events by the
+// transport since it was created. It is cheap and safe for concurrent use,
+// for example to export the counters as metrics:
+//
+//	transport := sentry.NewHTTPTransport()
+//	sentry.Init(sentry.ClientOptions{Transport: transport})
+//	// ...
+//	stats := transport.Stats()
+//	sentFailures.Set(float64(stats.Failed))
+func (t *HTTPTransport) Stats() TransportStats {
+	stats := t.stats.snapshot()
+	if t.buffer != nil {
+		// Receiving the current batch is equivalent to acquiring a lock, see
+		// enqueue.
+		b := <-t.buffer
+		stats.QueueDepth = len(b.items)
+		t.buffer <- b
+	}
+	return stats
+}
+
 // ================================
 // HTTPSyncTransport
 // ================================
@@ -473,10 +1264,22 @@ type HTTPSyncTransport struct {
 	dsn       *Dsn
 	client    *http.Client
 	transport http.RoundTripper
+	compress  bool
 
 	mu     sync.Mutex
 	limits ratelimit.Map
 
+	// discarded counts events dropped because of rate limits, and
+	// clientDiscarded those dropped by the client. They are sent to Sentry in
+	// client reports.
+	discarded       discardedEvents
+	clientDiscarded *discardedEvents
+
+	// hooks are set by OfflineTransport before Configure.
+	hooks deliveryHooks
+
+	onSendError func(event *Event, err error)
+
 	// HTTP Client request timeout. Defaults to 30 seconds.
 	Timeout time.Duration
 }
@@ -493,12 +1296,14 @@ func NewHTTPSyncTransport() *HTTPSyncTransport {
 
 // Configure is called by the Client itself, providing it it's own ClientOptions.
 func (t *HTTPSyncTransport) Configure(options ClientOptions) {
-	dsn, err := NewDsn(options.Dsn)
+	dsn, err := dsnFromOptions(options)
 	if err != nil {
 		Logger.Printf("%v\n", err)
 		return
 	}
 	t.dsn = dsn
+	t.compress = !options.DisableCompression
+	t.onSendError = options.OnSendError
 
 	if options.HTTPTransport != nil {
 		t.transport = options.HTTPTransport
@@ -521,16 +1326,41 @@ func (t *HTTPSyncTransport) Configure(options ClientOptions) {
 
 // SendEvent assembles a new packet out of Event and sends it to remote server.
 func (t *HTTPSyncTransport) SendEvent(event *Event) {
+	t.submit(event.EventID, describeEvent(event), categoryFor(event.Type), event,
+		func(report *clientReport) (*http.Request, error) {
+			return getRequestFromEvent(event, t.dsn, t.compress, report)
+		})
+}
+
+// sendItem implements itemTransport.
+func (t *HTTPSyncTransport) sendItem(item *envelopeItem) {
+	t.submit(item.eventID, item.description, categoryFor(item.itemType), nil,
+		func(report *clientReport) (*http.Request, error) {
+			return getRequestFromItem(item, t.dsn, t.compress, report)
+		})
+}
+
+// submit sends the request returned by newRequest, like HTTPTransport.submit.
+func (t *HTTPSyncTransport) submit(
+	id EventID,
+	description string,
+	category ratelimit.Category,
+	event *Event,
+	newRequest func(report *clientReport) (*http.Request, error),
+) {
 	if t.dsn == nil {
 		return
 	}
 
-	if t.disabled(categoryFor(event.Type)) {
+	if t.disabled(category) {
+		reportSendError(t.onSendError, event, ErrRateLimited)
 		return
 	}
 
-	request, err := getRequestFromEvent(event, t.dsn)
+	counts := takeDiscardedEvents(&t.discarded, t.clientDiscarded)
+	request, err := newRequest(newClientReport(counts, time.Now()))
 	if err != nil {
+		t.discarded.merge(counts)
 		return
 	}
 
@@ -538,21 +1368,41 @@ func (t *HTTPSyncTransport) SendEvent(event *Event) {
 		request.Header.Set(headerKey, headerValue)
 	}
 
-	var eventType string
-	if event.Type == transactionType {
-		eventType = "transaction"
-	} else {
-		eventType = fmt.Sprintf("%s event", event.Level)
-	}
 	Logger.Printf(
 		"Sending %s [%s] to %s project: %s",
-		eventType,
-		event.EventID,
+		description,
+		id,
 		t.dsn.host,
 		t.dsn.projectID,
 	)
 
+	t.send(request, event)
+}
+
+// sendEnvelope sends a serialized envelope. It always returns true, because
+// the envelope was either delivered or reported to the failed delivery hook.
+func (t *HTTPSyncTransport) sendEnvelope(envelope []byte, compressed bool, category ratelimit.Category) bool {
+	if t.dsn == nil {
+		return false
+	}
+
+	if t.disabled(category) {
+		return true
+	}
+
+	request, err := getRequestFromEnvelope(envelope, compressed, t.dsn)
+	if err != nil {
+		return false
+	}
+	t.send(request, nil)
+	return true
+}
+
+func (t *HTTPSyncTransport) send(request *http.Request, event *Event) {
 	response, err := t.client.Do(request)
+	if !t.hooks.afterSend(request, response, err) {
+		reportSendError(t.onSendError, event, sendErrorFor(response, err))
+	}
 	if err != nil {
 		Logger.Printf("There was an issue with sending an event: %v", err)
 		return
@@ -572,12 +1422,26 @@ func (t *HTTPSyncTransport) Flush(_ time.Duration) bool {
 	return true
 }
 
+// FlushWithContext is a no-op for HTTPSyncTransport. It always returns true
+// immediately.
+func (t *HTTPSyncTransport) FlushWithContext(_ context.Context) bool {
+	return true
+}
+
+// Close is a no-op for HTTPSyncTransport, which does not start any goroutines.
+func (t *HTTPSyncTransport) Close() {}
+
+func (t *HTTPSyncTransport) setClientDiscardedEvents(d *discardedEvents) {
+	t.clientDiscarded = d
+}
+
 func (t *HTTPSyncTransport) disabled(c ratelimit.Category) bool {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	disabled := t.limits.IsRateLimited(c)
 	if disabled {
 		Logger.Printf("Too many requests for %q, backing off till: %v", c, t.limits.Deadline(c))
+		t.discarded.record(discardReasonRateLimitBackoff, c)
 	}
 	return disabled
 }
@@ -600,6 +1464,16 @@ func (noopTransport) SendEvent(*Event) {
 	Logger.Println("Event dropped due to noopTransport usage.")
 }
 
+func (noopTransport) sendItem(item *envelopeItem) {
+	Logger.Printf("Dropping %s due to noopTransport usage.", item.description)
+}
+
 func (noopTransport) Flush(time.Duration) bool {
 	return true
 }
+
+func (noopTransport) FlushWithContext(context.Context) bool {
+	return true
+}
+
+func (noopTransport) Close() {}
@@ -2,18 +2,23 @@ package sentry
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httptrace"
+	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/getsentry/sentry-go/internal/ratelimit"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -171,6 +176,26 @@ func TestEnvelopeFromErrorBody(t *testing.T) {
 	}
 }
 
+func TestEnvelopeCustomHeaders(t *testing.T) {
+	event := newTestEvent(eventType)
+	event.sdkMetaData.envelopeHeaders = map[string]interface{}{
+		"route":    "eu",
+		"event_id": "overridden",
+		"trace":    map[string]string{"trace_id": "overridden"},
+	}
+	sentAt := time.Unix(0, 0).UTC()
+
+	b, err := envelopeFromBody(event, newTestDSN(t), sentAt, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(b.String(), "\n")
+	want := `{"dsn":"http://public@example.com/sentry/1","event_id":"b81c5be4d31e48959103a1f878a1efcb","route":"eu","sdk":{"name":"sentry.go","version":"0.0.1"},"sent_at":"1970-01-01T00:00:00Z"}`
+	if diff := cmp.Diff(want, header); diff != "" {
+		t.Errorf("Envelope header mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestEnvelopeFromTransactionBody(t *testing.T) {
 	event := newTestEvent(transactionType)
 	sentAt := time.Unix(0, 0).UTC()
@@ -191,6 +216,29 @@ func TestEnvelopeFromTransactionBody(t *testing.T) {
 	}
 }
 
+func TestEnvelopeFromLogItem(t *testing.T) {
+	item := &envelopeItem{
+		eventID:  "b81c5be4d31e48959103a1f878a1efcb",
+		itemType: logType,
+		count:    2,
+		payload:  json.RawMessage(`{"items":"omitted"}`),
+	}
+	sentAt := time.Unix(0, 0).UTC()
+
+	b, err := envelopeFromItem(item, newTestDSN(t), sentAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	want := `{"event_id":"b81c5be4d31e48959103a1f878a1efcb","sent_at":"1970-01-01T00:00:00Z","dsn":"http://public@example.com/sentry/1","sdk":{"name":"sentry.go","version":"` + SDKVersion + `"}}
+{"type":"log","length":19,"item_count":2,"content_type":"application/vnd.sentry.items.log+json"}
+{"items":"omitted"}
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Envelope mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestEnvelopeFromTransactionWithProfile(t *testing.T) {
 	event := newTestEvent(transactionType)
 	event.sdkMetaData.transactionProfile = &profileInfo{
@@ -244,6 +292,64 @@ func TestEnvelopeFromTransactionWithProfile(t *testing.T) {
 	}
 }
 
+func TestEnvelopeFromErrorWithAttachments(t *testing.T) {
+	event := newTestEvent(eventType)
+	event.Attachments = []*Attachment{
+		{
+			Filename:    "config.json",
+			ContentType: "application/json",
+			Payload:     []byte(`{"key":"value"}`),
+		},
+		{
+			Filename: "empty.bin",
+		},
+		{
+			Filename: "too-large.bin",
+			Payload:  make([]byte, maxAttachmentBytes+1),
+		},
+	}
+	sentAt := time.Unix(0, 0).UTC()
+
+	body := json.RawMessage(`{"type":"event","fields":"omitted"}`)
+
+	b, err := envelopeFromBody(event, newTestDSN(t), sentAt, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	want := `{"event_id":"b81c5be4d31e48959103a1f878a1efcb","sent_at":"1970-01-01T00:00:00Z","dsn":"http://public@example.com/sentry/1","sdk":{"name":"sentry.go","version":"0.0.1"}}
+{"type":"event","length":35}
+{"type":"event","fields":"omitted"}
+{"type":"attachment","length":15,"filename":"config.json","content_type":"application/json"}
+{"key":"value"}
+{"type":"attachment","length":0,"filename":"empty.bin"}
+
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Envelope mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEnvelopeAttachmentsTotalLimit(t *testing.T) {
+	event := newTestEvent(eventType)
+	payload := make([]byte, maxAttachmentBytes)
+	for i := 0; i < 3; i++ {
+		event.Attachments = append(event.Attachments, &Attachment{
+			Filename: fmt.Sprintf("file%d.bin", i),
+			Payload:  payload,
+		})
+	}
+
+	b, err := envelopeFromBody(event, newTestDSN(t), time.Now(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Count(b.String(), `"type":"attachment"`)
+	if want := maxAttachmentsBytes / maxAttachmentBytes; got != want {
+		t.Errorf("got %d attachments, want %d", got, want)
+	}
+}
+
 func TestGetRequestFromEvent(t *testing.T) {
 	testCases := []struct {
 		testName string
@@ -277,7 +383,7 @@ func TestGetRequestFromEvent(t *testing.T) {
 		}
 
 		t.Run(test.testName, func(t *testing.T) {
-			req, err := getRequestFromEvent(test.event, dsn)
+			req, err := getRequestFromEvent(test.event, dsn, false, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -297,6 +403,15 @@ func TestGetRequestFromEvent(t *testing.T) {
 	}
 }
 
+// requestBody returns the body of r, decompressing it if the request was sent
+// with gzip compression.
+func requestBody(r *http.Request) (io.Reader, error) {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		return gzip.NewReader(r.Body)
+	}
+	return r.Body, nil
+}
+
 // A testHTTPServer counts events sent to it. It requires a call to Unblock
 // before incrementing its internal counter and sending a response to the HTTP
 // client. This allows for coordinating the execution flow when needed.
@@ -315,8 +430,12 @@ func newTestHTTPServer(t *testing.T) *testHTTPServer {
 		var event struct {
 			EventID string `json:"event_id"`
 		}
-		dec := json.NewDecoder(r.Body)
-		err := dec.Decode(&event)
+		body, err := requestBody(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := json.NewDecoder(body)
+		err = dec.Decode(&event)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -428,6 +547,25 @@ func TestHTTPTransport(t *testing.T) {
 		}()
 		wg.Wait()
 	})
+
+	t.Run("FlushWithContextCanceled", func(t *testing.T) {
+		// FlushWithContext must report failure when ctx is done before the
+		// buffered events were sent.
+
+		initialCount := server.EventCount()
+		id := transportSendTestEvent(t)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if transport.FlushWithContext(ctx) {
+			t.Fatalf("[CLIENT] {%.4s} FlushWithContext() = true, want false", id)
+		}
+		serverEventCountMustBe(t, initialCount)
+
+		server.Unblock()
+		transportMustFlush(t, id)
+		serverEventCountMustBe(t, initialCount+1)
+	})
 }
 
 // httptraceRoundTripper implements http.RoundTripper by wrapping
@@ -520,6 +658,287 @@ func testKeepAlive(t *testing.T, tr Transport) {
 	}
 }
 
+func TestHTTPTransportClose(t *testing.T) {
+	var count uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&count, 1)
+		fmt.Fprintln(w, `{"id":"ec71d87189164e79ab1e61030c183af0"}`)
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport()
+	tr.Configure(ClientOptions{
+		Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+	})
+
+	tr.SendEvent(&Event{})
+	if !tr.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+
+	tr.Close()
+	// Closing multiple times must not panic.
+	tr.Close()
+
+	tr.SendEvent(&Event{})
+	if tr.Flush(time.Second) {
+		t.Error("Flush after Close = true, want false")
+	}
+	if n := atomic.LoadUint64(&count); n != 1 {
+		t.Errorf("got %d requests, want %d", n, 1)
+	}
+}
+
+func TestHTTPTransportBufferFull(t *testing.T) {
+	tests := map[string]struct {
+		dropOldest bool
+		want       []string
+		dropped    string
+	}{
+		"DropNewest": {want: []string{"e1", "e2", "e3"}, dropped: "e4"},
+		"DropOldest": {dropOldest: true, want: []string{"e1", "e3", "e4"}, dropped: "e2"},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var received []string
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := requestBody(r)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				b, err := io.ReadAll(body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var event struct {
+					Message string `json:"message"`
+				}
+				lines := strings.Split(string(b), "\n")
+				if len(lines) < 3 || json.Unmarshal([]byte(lines[2]), &event) != nil {
+					return
+				}
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-release
+				mu.Lock()
+				received = append(received, event.Message)
+				mu.Unlock()
+			}))
+			defer srv.Close()
+
+			dropped := make(chan string, 1)
+			tr := NewHTTPTransport()
+			tr.BufferSize = 2
+			tr.DropOldest = tt.dropOldest
+			tr.Configure(ClientOptions{
+				Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+				OnSendError: func(event *Event, err error) {
+					if !errors.Is(err, ErrBufferFull) {
+						t.Errorf("got error %v for event %q, want ErrBufferFull", err, event.Message)
+					}
+					dropped <- event.Message
+				},
+			})
+
+			// The first event blocks the worker, the next two fill the buffer.
+			tr.SendEvent(&Event{Message: "e1"})
+			<-started
+			for _, message := range []string{"e2", "e3", "e4"} {
+				tr.SendEvent(&Event{Message: message})
+			}
+
+			want := map[discardedKey]uint64{
+				{reason: discardReasonQueueOverflow, category: ratelimit.CategoryError}: 1,
+			}
+			if diff := cmp.Diff(want, tr.discarded.take(), cmp.AllowUnexported(discardedKey{})); diff != "" {
+				t.Errorf("discarded events mismatch (-want +got):\n%s", diff)
+			}
+			select {
+			case message := <-dropped:
+				if message != tt.dropped {
+					t.Errorf("got OnSendError for %q, want %q", message, tt.dropped)
+				}
+			case <-time.After(time.Second):
+				t.Error("OnSendError not called for the dropped event")
+			}
+
+			close(release)
+			if !tr.Flush(time.Second) {
+				t.Fatal("Flush timed out")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if diff := cmp.Diff(tt.want, received); diff != "" {
+				t.Errorf("received events mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPTransportStats(t *testing.T) {
+	var count uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddUint64(&count, 1) {
+		case 2:
+			w.WriteHeader(http.StatusInternalServerError)
+		case 3:
+			w.Header().Set("X-Sentry-Rate-Limits", "60:error")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprintln(w, `{"id":"ec71d87189164e79ab1e61030c183af0"}`)
+		}
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport()
+	if got := tr.Stats(); got != (TransportStats{}) {
+		t.Errorf("got stats %+v before Configure, want zero", got)
+	}
+	tr.Configure(ClientOptions{
+		Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+	})
+	defer tr.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		tr.SendEvent(&Event{})
+		if !tr.Flush(time.Second) {
+			t.Fatal("Flush timed out")
+		}
+	}
+	// Dropped because of the rate limit.
+	tr.SendEvent(&Event{})
+
+	got := tr.Stats()
+	if got.LastRateLimited.Before(start) {
+		t.Errorf("got LastRateLimited %v, want after %v", got.LastRateLimited, start)
+	}
+	got.LastRateLimited = time.Time{}
+	want := TransportStats{Sent: 1, Failed: 2, Dropped: 1}
+	if got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+// BenchmarkHTTPTransportBurst measures how many events of a burst are dropped
+// by the HTTPTransport, depending on the buffer size, when Sentry responds in
+// 1ms.
+func BenchmarkHTTPTransportBurst(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		time.Sleep(time.Millisecond)
+	}))
+	defer srv.Close()
+
+	const burst = 500
+	for _, bufferSize := range []int{defaultBufferSize, 100, 1000} {
+		bufferSize := bufferSize
+		b.Run(fmt.Sprintf("BufferSize=%d", bufferSize), func(b *testing.B) {
+			var dropped uint64
+			for i := 0; i < b.N; i++ {
+				tr := NewHTTPTransport()
+				tr.BufferSize = bufferSize
+				tr.Configure(ClientOptions{
+					Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+				})
+				for j := 0; j < burst; j++ {
+					tr.SendEvent(&Event{Message: "burst"})
+				}
+				for _, n := range tr.discarded.take() {
+					dropped += n
+				}
+				tr.Flush(10 * time.Second)
+				tr.Close()
+			}
+			b.ReportMetric(float64(dropped)/float64(b.N*burst), "dropped/event")
+		})
+	}
+}
+
+func TestHTTPTransportWorkers(t *testing.T) {
+	var concurrent, maxConcurrent, requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&concurrent, 1)
+		defer atomic.AddInt32(&concurrent, -1)
+		for {
+			max := atomic.LoadInt32(&maxConcurrent)
+			if n <= max || atomic.CompareAndSwapInt32(&maxConcurrent, max, n) {
+				break
+			}
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport()
+	tr.Workers = 4
+	tr.Configure(ClientOptions{
+		Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+	})
+	defer tr.Close()
+
+	for i := 0; i < 12; i++ {
+		tr.SendEvent(NewEvent())
+	}
+	if !tr.Flush(5 * time.Second) {
+		t.Fatal("Flush timed out")
+	}
+	assertEqual(t, atomic.LoadInt32(&requests), int32(12))
+	assertEqual(t, tr.Stats().Sent, uint64(12))
+	if max := atomic.LoadInt32(&maxConcurrent); max < 2 || max > 4 {
+		t.Errorf("got %d concurrent requests at most, want between 2 and 4", max)
+	}
+}
+
+// BenchmarkHTTPTransportWorkers measures the time to deliver a burst of events
+// and the number of goroutines of the HTTPTransport, depending on the number
+// of workers, when Sentry responds in 1ms.
+func BenchmarkHTTPTransportWorkers(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		time.Sleep(time.Millisecond)
+	}))
+	defer srv.Close()
+
+	const burst = 200
+	for _, workers := range []int{1, 4, 16} {
+		workers := workers
+		b.Run(fmt.Sprintf("Workers=%d", workers), func(b *testing.B) {
+			var elapsed time.Duration
+			var goroutines int
+			for i := 0; i < b.N; i++ {
+				before := runtime.NumGoroutine()
+				tr := NewHTTPTransport()
+				tr.BufferSize = burst
+				tr.Workers = workers
+				tr.Configure(ClientOptions{
+					Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+				})
+				goroutines += runtime.NumGoroutine() - before
+				start := time.Now()
+				for j := 0; j < burst; j++ {
+					tr.SendEvent(&Event{Message: "burst"})
+				}
+				tr.Flush(10 * time.Second)
+				elapsed += time.Since(start)
+				tr.Close()
+			}
+			b.ReportMetric(float64(b.N*burst)/elapsed.Seconds(), "events/s")
+			b.ReportMetric(float64(goroutines)/float64(b.N), "goroutines")
+		})
+	}
+}
+
 func TestKeepAlive(t *testing.T) {
 	t.Run("AsyncTransport", func(t *testing.T) {
 		testKeepAlive(t, NewHTTPTransport())
@@ -529,6 +948,154 @@ func TestKeepAlive(t *testing.T) {
 	})
 }
 
+// countingRoundTripper implements http.RoundTripper by wrapping
+// http.DefaultTransport and counting the requests that go through it.
+type countingRoundTripper struct {
+	count uint64
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddUint64(&rt.count, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPClientOption(t *testing.T) {
+	t.Run("AsyncTransport", func(t *testing.T) {
+		testHTTPClientOption(t, NewHTTPTransport())
+	})
+	t.Run("SyncTransport", func(t *testing.T) {
+		testHTTPClientOption(t, NewHTTPSyncTransport())
+	})
+}
+
+func testHTTPClientOption(t *testing.T, tr Transport) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"id":"ec71d87189164e79ab1e61030c183af0"}`)
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1"
+
+	rt := &countingRoundTripper{}
+	tr.Configure(ClientOptions{
+		Dsn:        dsn,
+		HTTPClient: &http.Client{Transport: rt},
+		// HTTPTransport must not be used when HTTPClient is set.
+		HTTPTransport: &httptraceRoundTripper{},
+	})
+
+	for i := 0; i < 3; i++ {
+		tr.SendEvent(&Event{})
+	}
+	if !tr.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+	if n := atomic.LoadUint64(&rt.count); n != 3 {
+		t.Errorf("got %d requests through HTTPClient, want %d", n, 3)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	for _, disable := range []bool{false, true} {
+		disable := disable
+		t.Run(fmt.Sprintf("DisableCompression=%t", disable), func(t *testing.T) {
+			t.Run("AsyncTransport", func(t *testing.T) {
+				testCompression(t, NewHTTPTransport(), disable)
+			})
+			t.Run("SyncTransport", func(t *testing.T) {
+				testCompression(t, NewHTTPSyncTransport(), disable)
+			})
+		})
+	}
+}
+
+func testCompression(t *testing.T, tr Transport, disable bool) {
+	const eventID = "ec71d87189164e79ab1e61030c183af0"
+
+	envelopes := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Encoding") == "gzip"; got == disable {
+			t.Errorf("got Content-Encoding = %q, want compression %t", r.Header.Get("Content-Encoding"), !disable)
+		}
+		body, err := requestBody(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		envelopes <- b
+	}))
+	defer srv.Close()
+
+	tr.Configure(ClientOptions{
+		Dsn:                strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+		DisableCompression: disable,
+	})
+
+	tr.SendEvent(&Event{EventID: eventID, Message: "compressed"})
+	if !tr.Flush(time.Second) {
+		t.Fatal("Flush timed out")
+	}
+
+	b := <-envelopes
+	var header struct {
+		EventID string `json:"event_id"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&header); err != nil {
+		t.Fatal(err)
+	}
+	if header.EventID != eventID {
+		t.Errorf("got event_id = %q, want %q", header.EventID, eventID)
+	}
+	if !bytes.Contains(b, []byte(`"message":"compressed"`)) {
+		t.Errorf("envelope does not contain the event message:\n%s", b)
+	}
+}
+
+func BenchmarkCompression(b *testing.B) {
+	dsn, err := NewDsn("https://key@host/path/42")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// A representative error event with a stack trace and request data.
+	newEvent := func() *Event {
+		event := NewEvent()
+		event.Message = "representative event"
+		event.Exception = []Exception{{
+			Type:       "*errors.errorString",
+			Value:      "something went wrong",
+			Stacktrace: NewStacktrace(),
+		}}
+		event.Request = &Request{
+			URL:     "https://example.com/checkout",
+			Method:  http.MethodPost,
+			Data:    strings.Repeat(`{"item":"value"},`, 100),
+			Headers: map[string]string{"User-Agent": "Go-http-client/1.1"},
+		}
+		return event
+	}
+
+	for _, compress := range []bool{false, true} {
+		compress := compress
+		b.Run(fmt.Sprintf("compress=%t", compress), func(b *testing.B) {
+			var size int64
+			for i := 0; i < b.N; i++ {
+				req, err := getRequestFromEvent(newEvent(), dsn, compress, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = req.ContentLength
+			}
+			b.ReportMetric(float64(size), "bytes/event")
+		})
+	}
+}
+
 func TestRateLimiting(t *testing.T) {
 	t.Run("AsyncTransport", func(t *testing.T) {
 		testRateLimiting(t, NewHTTPTransport())
@@ -543,6 +1110,7 @@ func testRateLimiting(t *testing.T, tr Transport) {
 	transactionEvent := &Event{Type: transactionType}
 
 	var errorEventCount, transactionEventCount uint64
+	var reported discardedEvents
 
 	writeRateLimits := func(w http.ResponseWriter, s string) {
 		w.Header().Add("Retry-After", "50")
@@ -553,14 +1121,20 @@ func testRateLimiting(t *testing.T, tr Transport) {
 
 	// Test server that simulates responses with rate limits.
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		b, err := io.ReadAll(r.Body)
+		body, err := requestBody(r)
+		if err != nil {
+			panic(err)
+		}
+		b, err := io.ReadAll(body)
 		if err != nil {
 			panic(err)
 		}
-		if bytes.Contains(b, []byte("transaction")) {
+		reported.merge(clientReportsFromEnvelope(t, b))
+		switch {
+		case bytes.Contains(b, []byte(`"type":"transaction"`)):
 			atomic.AddUint64(&transactionEventCount, 1)
 			writeRateLimits(w, "20:transaction")
-		} else {
+		case bytes.Contains(b, []byte(`"type":"event"`)):
 			atomic.AddUint64(&errorEventCount, 1)
 			writeRateLimits(w, "50:error")
 		}
@@ -607,4 +1181,272 @@ func testRateLimiting(t *testing.T, tr Transport) {
 	if n := atomic.LoadUint64(&transactionEventCount); n != 1 {
 		t.Errorf("got transactionEvent = %d, want %d", n, 1)
 	}
+
+	// All discarded events should be counted, and either reported or still
+	// waiting to be reported with the next event.
+	var discarded *discardedEvents
+	switch tr := tr.(type) {
+	case *HTTPTransport:
+		discarded = &tr.discarded
+	case *HTTPSyncTransport:
+		discarded = &tr.discarded
+	default:
+		t.Fatalf("unexpected transport type %T", tr)
+	}
+	want := map[discardedKey]uint64{
+		{reason: discardReasonRateLimitBackoff, category: ratelimit.CategoryError}:       9,
+		{reason: discardReasonRateLimitBackoff, category: ratelimit.CategoryTransaction}: 9,
+	}
+	got := takeDiscardedEvents(discarded, &reported)
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(discardedKey{})); diff != "" {
+		t.Errorf("discarded events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// panickingRoundTripper implements http.RoundTripper by panicking on the
+// first request and wrapping http.DefaultTransport for the next ones.
+type panickingRoundTripper struct {
+	count uint64
+}
+
+func (rt *panickingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.AddUint64(&rt.count, 1) == 1 {
+		var m map[string]int
+		m["boom"]++
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPTransportRecoversFromPanic(t *testing.T) {
+	var count uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&count, 1)
+		fmt.Fprintln(w, `{"id":"ec71d87189164e79ab1e61030c183af0"}`)
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport()
+	tr.Configure(ClientOptions{
+		Dsn:        strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+		HTTPClient: &http.Client{Transport: &panickingRoundTripper{}},
+	})
+	defer tr.Close()
+
+	for i := 0; i < 2; i++ {
+		tr.SendEvent(&Event{})
+		if !tr.Flush(time.Second) {
+			t.Fatal("Flush timed out")
+		}
+	}
+	if n := atomic.LoadUint64(&count); n != 1 {
+		t.Errorf("got %d requests, want %d", n, 1)
+	}
+	if got, want := tr.Stats(), (TransportStats{Sent: 1, Failed: 1}); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestHTTPTransportSendItem(t *testing.T) {
+	for name, tr := range map[string]interface {
+		Transport
+		itemTransport
+		Close()
+	}{
+		"AsyncTransport": NewHTTPTransport(),
+		"SyncTransport":  NewHTTPSyncTransport(),
+	} {
+		tr := tr
+		t.Run(name, func(t *testing.T) {
+			bodies := make(chan string, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				bodies <- string(b)
+			}))
+			defer srv.Close()
+
+			tr.Configure(ClientOptions{
+				Dsn:                strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+				DisableCompression: true,
+			})
+			defer tr.Close()
+			tr.sendItem(&envelopeItem{
+				eventID:  "b81c5be4d31e48959103a1f878a1efcb",
+				itemType: logType,
+				count:    1,
+				payload:  json.RawMessage(`{"items":[]}`),
+			})
+			if !tr.Flush(time.Second) {
+				t.Fatal("Flush timed out")
+			}
+
+			select {
+			case body := <-bodies:
+				lines := strings.Split(body, "\n")
+				if len(lines) < 3 || lines[1] != `{"type":"log","length":12,"item_count":1,"content_type":"application/vnd.sentry.items.log+json"}` || lines[2] != `{"items":[]}` {
+					t.Errorf("got envelope %s, want a single log item", body)
+				}
+			default:
+				t.Fatal("got no request")
+			}
+		})
+	}
+}
+
+func TestHTTPTransportRetries(t *testing.T) {
+	tests := map[string]struct {
+		statuses     []int
+		wantRequests uint64
+		wantStats    TransportStats
+	}{
+		"ServerError": {
+			statuses:     []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			wantRequests: 3,
+			wantStats:    TransportStats{Sent: 1, Retried: 2},
+		},
+		"GiveUp": {
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			wantRequests: 3,
+			wantStats:    TransportStats{Failed: 1, Retried: 2},
+		},
+		"BadRequest": {
+			statuses:     []int{http.StatusBadRequest},
+			wantRequests: 1,
+			wantStats:    TransportStats{Failed: 1},
+		},
+		"TooManyRequests": {
+			statuses:     []int{http.StatusTooManyRequests},
+			wantRequests: 1,
+			wantStats:    TransportStats{Failed: 1},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var count uint64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddUint64(&count, 1)
+				body, err := io.ReadAll(r.Body)
+				if err != nil || len(body) == 0 {
+					t.Errorf("request %d has no body: %v", n, err)
+				}
+				if int(n) <= len(tt.statuses) {
+					w.WriteHeader(tt.statuses[n-1])
+				}
+			}))
+			defer srv.Close()
+
+			tr := NewHTTPTransport()
+			tr.MaxRetries = 2
+			tr.RetryBaseDelay = time.Millisecond
+			tr.Configure(ClientOptions{
+				Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+			})
+			defer tr.Close()
+
+			tr.SendEvent(&Event{})
+			if !tr.Flush(time.Second) {
+				t.Fatal("Flush timed out")
+			}
+			if n := atomic.LoadUint64(&count); n != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", n, tt.wantRequests)
+			}
+			got := tr.Stats()
+			got.LastRateLimited = time.Time{}
+			if got != tt.wantStats {
+				t.Errorf("got stats %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestHTTPTransportRetryInterruptedByClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport()
+	tr.MaxRetries = 1
+	tr.RetryBaseDelay = time.Hour
+	tr.Configure(ClientOptions{
+		Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+	})
+
+	tr.SendEvent(&Event{})
+	if tr.Flush(50 * time.Millisecond) {
+		t.Error("Flush = true while waiting to retry, want false")
+	}
+	tr.Close()
+	if got := tr.Stats(); got.Retried != 0 {
+		t.Errorf("got %d retries, want 0", got.Retried)
+	}
+}
+
+func TestOnSendError(t *testing.T) {
+	t.Run("AsyncTransport", func(t *testing.T) {
+		testOnSendError(t, NewHTTPTransport())
+	})
+	t.Run("SyncTransport", func(t *testing.T) {
+		testOnSendError(t, NewHTTPSyncTransport())
+	})
+}
+
+func testOnSendError(t *testing.T, tr Transport) {
+	var count uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddUint64(&count, 1) {
+		case 1:
+			fmt.Fprintln(w, `{"id":"ec71d87189164e79ab1e61030c183af0"}`)
+		case 2:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Header().Set("X-Sentry-Rate-Limits", "60:error")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	type sendError struct {
+		message string
+		err     error
+	}
+	errs := make(chan sendError, 4)
+	tr.Configure(ClientOptions{
+		Dsn: strings.Replace(srv.URL, "//", "//pubkey@", 1) + "/1",
+		OnSendError: func(event *Event, err error) {
+			errs <- sendError{event.Message, err}
+		},
+	})
+
+	for _, message := range []string{"sent", "rejected", "rate limited", "backoff"} {
+		tr.SendEvent(&Event{Message: message})
+		if !tr.Flush(time.Second) {
+			t.Fatal("Flush timed out")
+		}
+	}
+
+	got := make(map[string]error)
+	for i := 0; i < 3; i++ {
+		select {
+		case e := <-errs:
+			got[e.message] = e.err
+		case <-time.After(time.Second):
+			t.Fatalf("got %d send errors, want 3", len(got))
+		}
+	}
+	var rejected *SendError
+	if !errors.As(got["rejected"], &rejected) || rejected.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got error %v for the rejected event, want a SendError with status 500", got["rejected"])
+	}
+	if errors.Is(got["rejected"], ErrRateLimited) {
+		t.Errorf("rejected event error %v is ErrRateLimited", got["rejected"])
+	}
+	for _, message := range []string{"rate limited", "backoff"} {
+		if !errors.Is(got[message], ErrRateLimited) {
+			t.Errorf("got error %v for the %q event, want ErrRateLimited", got[message], message)
+		}
+	}
+	if _, ok := got["sent"]; ok {
+		t.Error("OnSendError called for a delivered event")
+	}
 }
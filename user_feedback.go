@@ -0,0 +1,55 @@
+package sentry
+
+import "errors"
+
+// userReportType is the type of a user feedback envelope item.
+const userReportType = "user_report"
+
+// UserFeedback is a message from a user about an event, for instance what they
+// were doing when they got an error page, see CaptureUserFeedback.
+type UserFeedback struct {
+	// EventID is the ID of the event the feedback is about, as returned by
+	// the Capture functions or LastEventID. It is required.
+	EventID EventID
+	// Name and Email identify the user. They are optional.
+	Name  string
+	Email string
+	// Comments is the message of the user. It is required.
+	Comments string
+}
+
+// validateUserFeedback returns an error if feedback cannot be sent.
+func validateUserFeedback(feedback *UserFeedback) error {
+	switch {
+	case feedback == nil:
+		return errors.New("no user feedback")
+	case feedback.EventID == "":
+		return errors.New("user feedback without event ID")
+	case feedback.Comments == "":
+		return errors.New("user feedback without comments")
+	}
+	return nil
+}
+
+// userReportPayload is the payload of a user report envelope item.
+type userReportPayload struct {
+	EventID  EventID `json:"event_id"`
+	Name     string  `json:"name"`
+	Email    string  `json:"email"`
+	Comments string  `json:"comments"`
+}
+
+// newUserReportItem returns the envelope item sending feedback.
+func newUserReportItem(feedback *UserFeedback) *envelopeItem {
+	return &envelopeItem{
+		eventID:  EventID(uuid()),
+		itemType: userReportType,
+		payload: userReportPayload{
+			EventID:  feedback.EventID,
+			Name:     feedback.Name,
+			Email:    feedback.Email,
+			Comments: feedback.Comments,
+		},
+		description: "user feedback",
+	}
+}
@@ -0,0 +1,61 @@
+package sentry
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureUserFeedback(t *testing.T) {
+	client, _, transport := setupClientTest()
+
+	client.CaptureUserFeedback(&UserFeedback{
+		EventID:  "b81c5be4d31e48959103a1f878a1efcb",
+		Name:     "Jane",
+		Email:    "jane@example.com",
+		Comments: "I was trying to check out.",
+	})
+
+	items := transport.Items()
+	if len(items) != 1 || items[0].itemType != userReportType {
+		t.Fatalf("got items %v, want a user report", items)
+	}
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("got %d events, want user feedback to be sent as an envelope item only", len(events))
+	}
+	b, err := json.Marshal(items[0].payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	want := `{"event_id":"b81c5be4d31e48959103a1f878a1efcb","name":"Jane","email":"jane@example.com","comments":"I was trying to check out."}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	envelope, err := envelopeFromItem(items[0], newTestDSN(t), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := `{"type":"user_report","length":` + strconv.Itoa(len(b)) + "}\n" + want + "\n"
+	if !strings.HasSuffix(envelope.String(), item) {
+		t.Errorf("envelope %s has no user report item", envelope)
+	}
+}
+
+func TestCaptureUserFeedbackInvalid(t *testing.T) {
+	client, _, transport := setupClientTest()
+
+	for _, feedback := range []*UserFeedback{
+		nil,
+		{Comments: "no event"},
+		{EventID: "b81c5be4d31e48959103a1f878a1efcb"},
+	} {
+		client.CaptureUserFeedback(feedback)
+	}
+	if items := transport.Items(); len(items) != 0 {
+		t.Errorf("got %d items, want 0", len(items))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -35,6 +36,12 @@ func monotonicTimeSince(start time.Time) (end time.Time) {
 	return start.Add(time.Since(start))
 }
 
+// logCallbackPanic logs err, recovered from a panic in the user callback
+// called name, with the stack trace of the panicking goroutine.
+func logCallbackPanic(name string, err interface{}) {
+	Logger.Printf("Recovered from a panic in %s: %v\n%s", name, err, debug.Stack())
+}
+
 // nolint: deadcode, unused
 func prettyPrint(data interface{}) {
 	dbg, _ := json.MarshalIndent(data, "", "  ")
@@ -44,7 +51,16 @@ func prettyPrint(data interface{}) {
 // defaultRelease attempts to guess a default release for the currently running
 // program.
 func defaultRelease() (release string) {
-	// Return first non-empty environment variable known to hold release info, if any.
+	// Use the VCS revision stamped into the binary by the go command, if any.
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if release = revisionFromBuildInfo(info); release != "" {
+			Logger.Printf("Using release from build info: %s", release)
+			return release
+		}
+	}
+
+	// Otherwise, return the first non-empty environment variable known to
+	// hold release info, if any.
 	envs := []string{
 		"SENTRY_RELEASE",
 		"HEROKU_SLUG_COMMIT",
@@ -89,3 +105,24 @@ func defaultRelease() (release string) {
 	Logger.Printf("Using release from Git: %s", release)
 	return release
 }
+
+// revisionFromBuildInfo returns the VCS revision the binary was built from,
+// with a "-dirty" suffix if the working tree had local modifications. It
+// returns the empty string if the go command did not stamp VCS information,
+// for example when building with -buildvcs=false or outside of a repository.
+func revisionFromBuildInfo(info *debug.BuildInfo) string {
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -1,6 +1,7 @@
 package sentry
 
 import (
+	"runtime/debug"
 	"testing"
 )
 
@@ -27,3 +28,50 @@ func TestFileExistsReturnsFalseForNonExistingFiles(t *testing.T) {
 	assertEqual(t, fileExists(("util_nope.go")), false)
 	assertEqual(t, fileExists(("util_nope_test.go")), false)
 }
+
+func TestRevisionFromBuildInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []debug.BuildSetting
+		want     string
+	}{
+		{
+			name: "NoVCS",
+			want: "",
+		},
+		{
+			name: "Clean",
+			settings: []debug.BuildSetting{
+				{Key: "vcs", Value: "git"},
+				{Key: "vcs.revision", Value: "9de4f72d7a"},
+				{Key: "vcs.modified", Value: "false"},
+			},
+			want: "9de4f72d7a",
+		},
+		{
+			name: "Dirty",
+			settings: []debug.BuildSetting{
+				{Key: "vcs", Value: "git"},
+				{Key: "vcs.revision", Value: "9de4f72d7a"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+			want: "9de4f72d7a-dirty",
+		},
+		{
+			name: "ModifiedWithoutRevision",
+			settings: []debug.BuildSetting{
+				{Key: "vcs.modified", Value: "true"},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := revisionFromBuildInfo(&debug.BuildInfo{Settings: tt.settings})
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
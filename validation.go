@@ -0,0 +1,70 @@
+package sentry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldError is the validation error of a single field of an input, such as
+// the body of an HTTP request.
+type FieldError struct {
+	// Field is the path of the field in the input, for instance
+	// "address.zip" or "items[2].quantity".
+	Field string
+	// Message describes why the field is invalid.
+	Message string
+	// Value is the invalid value. It is only reported with
+	// ClientOptions.SendDefaultPII set, as it is user input.
+	Value interface{}
+}
+
+// ValidationError is the error of an input with invalid fields, reported by
+// CaptureValidationError. It can be returned as is by code validating input.
+type ValidationError struct {
+	// Endpoint identifies what validated the input, for instance the route
+	// of an HTTP handler like "POST /orders".
+	Endpoint string
+	Fields   []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validation failed for %s: %s", e.Endpoint, strings.Join(e.fieldNames(), ", "))
+}
+
+// fieldNames returns the sorted, unique paths of the invalid fields.
+func (e *ValidationError) fieldNames() []string {
+	names := make([]string, 0, len(e.Fields))
+	seen := make(map[string]struct{}, len(e.Fields))
+	for _, field := range e.Fields {
+		if _, ok := seen[field.Field]; ok {
+			continue
+		}
+		seen[field.Field] = struct{}{}
+		names = append(names, field.Field)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// eventFromValidationError creates a warning event for err, grouped by its
+// endpoint and set of invalid fields regardless of their values.
+func (client *Client) eventFromValidationError(err *ValidationError) *Event {
+	event := NewEvent()
+	event.Level = LevelWarning
+	event.Message = err.Error()
+	event.Fingerprint = append([]string{"validation", err.Endpoint}, err.fieldNames()...)
+
+	fields := make(map[string]interface{}, len(err.Fields))
+	for _, field := range err.Fields {
+		details := map[string]interface{}{"message": field.Message}
+		if client.options.SendDefaultPII {
+			details["value"] = field.Value
+		}
+		fields[field.Field] = details
+	}
+	event.Extra["validation_errors"] = fields
+	event.Tags["validation.endpoint"] = err.Endpoint
+
+	return event
+}
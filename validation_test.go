@@ -0,0 +1,48 @@
+package sentry
+
+import (
+	"testing"
+)
+
+func TestCaptureValidationError(t *testing.T) {
+	for _, sendDefaultPII := range []bool{false, true} {
+		transport := &TransportMock{}
+		client, err := NewClient(ClientOptions{Transport: transport, SendDefaultPII: sendDefaultPII})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hub := NewHub(client, NewScope())
+
+		id := hub.CaptureValidationError(&ValidationError{
+			Endpoint: "POST /orders",
+			Fields: []FieldError{
+				{Field: "email", Message: "invalid address", Value: "bob@"},
+				{Field: "address.zip", Message: "required"},
+			},
+		})
+		if id == nil {
+			t.Fatal("CaptureValidationError() = nil, want an event ID")
+		}
+
+		event := transport.lastEvent
+		assertEqual(t, event.Level, LevelWarning)
+		assertEqual(t, event.Message, "validation failed for POST /orders: address.zip, email")
+		assertEqual(t, event.Fingerprint, []string{"validation", "POST /orders", "address.zip", "email"})
+		wantEmail := map[string]interface{}{"message": "invalid address"}
+		if sendDefaultPII {
+			wantEmail["value"] = "bob@"
+		}
+		fields := event.Extra["validation_errors"].(map[string]interface{})
+		assertEqual(t, fields["email"], wantEmail)
+	}
+}
+
+func TestCaptureValidationErrorNoFields(t *testing.T) {
+	hub, _, _ := setupHubTest()
+	if id := hub.CaptureValidationError(&ValidationError{Endpoint: "POST /orders"}); id != nil {
+		t.Errorf("CaptureValidationError() = %v, want nil without invalid fields", *id)
+	}
+	if id := hub.CaptureValidationError(nil); id != nil {
+		t.Errorf("CaptureValidationError(nil) = %v, want nil", *id)
+	}
+}